@@ -0,0 +1,166 @@
+// Package charts renders simple line charts as inline SVG so reports need no
+// JavaScript.
+package charts
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+// Chart dimensions in SVG user units; the chart scales to its container
+const (
+	width        = 640
+	height       = 240
+	marginLeft   = 48
+	marginRight  = 16
+	marginTop    = 32
+	marginBottom = 32
+	gridLines    = 4
+	maxXLabels   = 8
+)
+
+// palette colours successive series; a comparison series reuses its partner's colour
+var palette = []string{"#2c6fbb", "#d9822b", "#3a9a5b", "#b03a48", "#7a5bb5"}
+
+// Series is one line on a chart
+type Series struct {
+	Name   string
+	Values []float64
+	Dashed bool // Drawn dashed in the previous series' colour, for comparing periods
+}
+
+// Chart describes a line chart. Labels name the points along the x axis; each
+// series has one value per label.
+type Chart struct {
+	Title   string
+	Labels  []string
+	Series  []Series
+	Percent bool // Values are percentages; the y axis runs to 100 at most
+}
+
+// Line renders the chart as an SVG element
+func Line(c Chart) template.HTML {
+	plotWidth := float64(width - marginLeft - marginRight)
+	plotHeight := float64(height - marginTop - marginBottom)
+
+	// Scale the y axis to a round number above the largest value
+	maxValue := 0.0
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			maxValue = math.Max(maxValue, v)
+		}
+	}
+	yMax := niceCeiling(maxValue)
+	if c.Percent && yMax > 100 {
+		yMax = 100
+	}
+
+	x := func(i int) float64 {
+		if len(c.Labels) <= 1 {
+			return marginLeft + plotWidth/2
+		}
+		return marginLeft + plotWidth*float64(i)/float64(len(c.Labels)-1)
+	}
+	y := func(v float64) float64 {
+		return marginTop + plotHeight - plotHeight*v/yMax
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="%s" xmlns="http://www.w3.org/2000/svg">`,
+		width, height, template.HTMLEscapeString(c.Title))
+	fmt.Fprintf(&b, `<title>%s</title>`, template.HTMLEscapeString(c.Title))
+	fmt.Fprintf(&b, `<text x="%d" y="16" font-size="13" font-weight="bold">%s</text>`, marginLeft, template.HTMLEscapeString(c.Title))
+
+	// Horizontal grid lines with their values
+	for i := 0; i <= gridLines; i++ {
+		v := yMax * float64(i) / gridLines
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#e2e2e2"/>`, marginLeft, width-marginRight, y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" font-size="10" text-anchor="end" fill="#666">%s</text>`,
+			marginLeft-6, y(v)+3, formatValue(v, c.Percent))
+	}
+
+	// A label under every few points so they never overlap
+	step := (len(c.Labels) + maxXLabels - 1) / maxXLabels
+	if step < 1 {
+		step = 1
+	}
+	for i, label := range c.Labels {
+		if i%step != 0 && i != len(c.Labels)-1 {
+			continue
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="10" text-anchor="middle" fill="#666">%s</text>`,
+			x(i), height-marginBottom+16, template.HTMLEscapeString(label))
+	}
+
+	// Lines, with a legend entry each, right-aligned above the plot
+	colour := palette[0]
+	legendX := width - marginRight
+	for _, s := range c.Series {
+		legendX -= legendWidth(s.Name)
+	}
+	colourIndex := 0
+	for _, s := range c.Series {
+		dash := ""
+		if s.Dashed {
+			dash = ` stroke-dasharray="5 4" opacity="0.7"`
+		} else {
+			colour = palette[colourIndex%len(palette)]
+			colourIndex++
+		}
+
+		var points []string
+		for i, v := range s.Values {
+			if i >= len(c.Labels) {
+				break
+			}
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(i), y(math.Min(v, yMax))))
+		}
+		if len(points) == 1 {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`, x(0), y(math.Min(s.Values[0], yMax)), colour)
+		} else if len(points) > 1 {
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"%s/>`,
+				strings.Join(points, " "), colour, dash)
+		}
+
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="12" y2="12" stroke="%s" stroke-width="2"%s/>`, legendX, legendX+12, colour, dash)
+		fmt.Fprintf(&b, `<text x="%d" y="16" font-size="10" fill="#333">%s</text>`, legendX+16, template.HTMLEscapeString(s.Name))
+		legendX += legendWidth(s.Name)
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// legendWidth estimates the width of a legend entry: the line sample, the name and a gap
+func legendWidth(name string) int {
+	return 16 + 6*len(name) + 12
+}
+
+// niceCeiling rounds up to 1, 2 or 5 times a power of ten so grid lines fall on
+// round values
+func niceCeiling(v float64) float64 {
+	if v <= 0 {
+		return gridLines
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*magnitude {
+			// Whole numbers need at least one unit per grid line
+			return math.Max(m*magnitude, gridLines)
+		}
+	}
+	return 10 * magnitude
+}
+
+// formatValue labels a y axis value
+func formatValue(v float64, percent bool) string {
+	if percent {
+		return fmt.Sprintf("%.0f%%", v)
+	}
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
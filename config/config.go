@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -29,6 +30,66 @@ var AppConfig struct {
 		TemplatesDir         string
 		StaticDir            string
 	}
+	Privacy struct {
+		RetentionDays int
+	}
+	Circulation struct {
+		LoanPeriodDays  int
+		MaxActiveLoans  int
+		MaxOverdueItems int
+		MaxBalance      float64
+		FinePerDay      float64
+		FinePerHour     float64 // For short loans of course reserve items
+		ReplacementCost float64
+	}
+	Jobs struct {
+		Interval time.Duration
+	}
+	Metadata struct {
+		Providers      string // Comma-separated, tried in order: openlibrary, sru, marcdir
+		OpenLibraryURL string
+		SRUURL         string
+		MARCDir        string
+		Timeout        time.Duration
+	}
+	Storage struct {
+		Backend     string // "local" or "s3"
+		LocalDir    string
+		URLPrefix   string // Where local files are served
+		S3Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000 for MinIO
+		S3Region    string
+		S3Bucket    string
+		S3AccessKey string
+		S3SecretKey string
+		S3PublicURL string // Base URL objects are read from; defaults to endpoint/bucket
+	}
+	Covers struct {
+		MaxBytes int
+	}
+	Acquisitions struct {
+		FiscalYearStartMonth int // 1 for calendar years; a fiscal year is named after the year it ends in
+	}
+	Reviews struct {
+		RequireApproval bool // Hold new and edited reviews until a librarian approves them
+	}
+	Recommendations struct {
+		MinPatrons int // Fewest distinct patrons behind a co-borrowing pair before it is used
+		PerBook    int // Recommendations kept for each book
+	}
+	Mail struct {
+		Host     string // SMTP server; email is disabled when empty
+		Port     string
+		Username string
+		Password string
+		From     string
+		BaseURL  string // Address of the application, for links in emails
+	}
+	Reports struct {
+		DeliveryHour int // Hour of the day, 0 to 23, scheduled reports are sent
+	}
+	Notices struct {
+		RunHour int // Hour of the day, 0 to 23, overdue notices are generated
+	}
 }
 
 // LoadConfig loads the application configuration from environment variables
@@ -54,6 +115,67 @@ func LoadConfig() {
 	AppConfig.Template.CacheParsedTemplates = false // Set to true in production
 	AppConfig.Template.TemplatesDir = "templates"
 	AppConfig.Template.StaticDir = "static"
+
+	// Set privacy configuration (0 disables anonymization of returned borrows)
+	AppConfig.Privacy.RetentionDays = getEnvIntWithDefault("BORROW_RETENTION_DAYS", 365)
+
+	// Set circulation policy configuration
+	AppConfig.Circulation.LoanPeriodDays = getEnvIntWithDefault("LOAN_PERIOD_DAYS", 14)
+	AppConfig.Circulation.MaxActiveLoans = getEnvIntWithDefault("MAX_ACTIVE_LOANS", 5)
+	AppConfig.Circulation.MaxOverdueItems = getEnvIntWithDefault("MAX_OVERDUE_ITEMS", 0)
+	AppConfig.Circulation.MaxBalance = getEnvFloatWithDefault("MAX_OUTSTANDING_BALANCE", 10)
+	AppConfig.Circulation.FinePerDay = getEnvFloatWithDefault("FINE_PER_DAY", 0.5)
+	AppConfig.Circulation.FinePerHour = getEnvFloatWithDefault("SHORT_LOAN_FINE_PER_HOUR", 0.5)
+	AppConfig.Circulation.ReplacementCost = getEnvFloatWithDefault("DEFAULT_REPLACEMENT_COST", 25)
+
+	// Set background job configuration
+	AppConfig.Jobs.Interval = time.Hour
+
+	// Set bibliographic metadata provider configuration
+	AppConfig.Metadata.Providers = getEnvWithDefault("METADATA_PROVIDERS", "openlibrary")
+	AppConfig.Metadata.OpenLibraryURL = getEnvWithDefault("OPENLIBRARY_URL", "https://openlibrary.org")
+	AppConfig.Metadata.SRUURL = getEnvWithDefault("SRU_URL", "")
+	AppConfig.Metadata.MARCDir = getEnvWithDefault("MARC_DIR", "")
+	AppConfig.Metadata.Timeout = 10 * time.Second
+
+	// Set file storage configuration
+	AppConfig.Storage.Backend = getEnvWithDefault("STORAGE_BACKEND", "local")
+	AppConfig.Storage.LocalDir = getEnvWithDefault("STORAGE_DIR", "uploads")
+	AppConfig.Storage.URLPrefix = "/media/"
+	AppConfig.Storage.S3Endpoint = getEnvWithDefault("S3_ENDPOINT", "")
+	AppConfig.Storage.S3Region = getEnvWithDefault("S3_REGION", "us-east-1")
+	AppConfig.Storage.S3Bucket = getEnvWithDefault("S3_BUCKET", "")
+	AppConfig.Storage.S3AccessKey = getEnvWithDefault("S3_ACCESS_KEY", "")
+	AppConfig.Storage.S3SecretKey = getEnvWithDefault("S3_SECRET_KEY", "")
+	AppConfig.Storage.S3PublicURL = getEnvWithDefault("S3_PUBLIC_URL", "")
+
+	// Set cover image configuration
+	AppConfig.Covers.MaxBytes = getEnvIntWithDefault("MAX_COVER_BYTES", 5<<20)
+
+	// Set acquisitions configuration
+	AppConfig.Acquisitions.FiscalYearStartMonth = getEnvIntWithDefault("FISCAL_YEAR_START_MONTH", 1)
+
+	// Set review moderation configuration
+	AppConfig.Reviews.RequireApproval = getEnvBoolWithDefault("REVIEWS_REQUIRE_APPROVAL", false)
+
+	// Set recommendation configuration; pairs borrowed by fewer patrons are never shown,
+	// so a recommendation cannot reveal what one patron read
+	AppConfig.Recommendations.MinPatrons = getEnvIntWithDefault("RECOMMENDATION_MIN_PATRONS", 3)
+	AppConfig.Recommendations.PerBook = getEnvIntWithDefault("RECOMMENDATIONS_PER_BOOK", 10)
+
+	// Set outgoing email configuration
+	AppConfig.Mail.Host = getEnvWithDefault("SMTP_HOST", "")
+	AppConfig.Mail.Port = getEnvWithDefault("SMTP_PORT", "587")
+	AppConfig.Mail.Username = getEnvWithDefault("SMTP_USERNAME", "")
+	AppConfig.Mail.Password = getEnvWithDefault("SMTP_PASSWORD", "")
+	AppConfig.Mail.From = getEnvWithDefault("MAIL_FROM", "library@localhost")
+	AppConfig.Mail.BaseURL = getEnvWithDefault("APP_BASE_URL", "http://localhost:10000")
+
+	// Set scheduled report configuration
+	AppConfig.Reports.DeliveryHour = getEnvIntWithDefault("REPORT_DELIVERY_HOUR", 6)
+
+	// Set overdue notice configuration
+	AppConfig.Notices.RunHour = getEnvIntWithDefault("NOTICE_RUN_HOUR", 7)
 }
 
 // getEnvWithDefault gets an environment variable or returns a default value
@@ -64,3 +186,42 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvIntWithDefault gets an integer environment variable or returns a default value
+func getEnvIntWithDefault(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return intValue
+}
+
+// getEnvFloatWithDefault gets a decimal environment variable or returns a default value
+func getEnvFloatWithDefault(key string, defaultValue float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return floatValue
+}
+
+// getEnvBoolWithDefault gets a boolean environment variable or returns a default value
+func getEnvBoolWithDefault(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return boolValue
+}
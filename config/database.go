@@ -113,6 +113,717 @@ func initSchema() error {
 		return fmt.Errorf("failed to create borrows table: %v", err)
 	}
 
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS reservations (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id),
+			book_id INT NOT NULL REFERENCES books(id),
+			status VARCHAR(20) NOT NULL DEFAULT 'active',
+			reservation_date TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expiry_date TIMESTAMP NOT NULL,
+			fulfilled_date TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create reservations table: %v", err)
+	}
+
+	// Patron privacy: borrows can be detached from their patron once anonymized
+	_, err = db.Exec(`
+		ALTER TABLE borrows ALTER COLUMN user_id DROP NOT NULL;
+		ALTER TABLE borrows ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS keep_history BOOLEAN NOT NULL DEFAULT FALSE
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to apply privacy columns: %v", err)
+	}
+
+	// Borrowing eligibility: account expiry, manual blocks and patron charges
+	_, err = db.Exec(`
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+		CREATE TABLE IF NOT EXISTS patron_blocks (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			note TEXT NOT NULL,
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			expires_at TIMESTAMP,
+			lifted_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS charges (
+			id SERIAL PRIMARY KEY,
+			user_id INT REFERENCES users(id) ON DELETE SET NULL,
+			borrow_id INT REFERENCES borrows(id) ON DELETE SET NULL,
+			type VARCHAR(20) NOT NULL,
+			amount NUMERIC(10, 2) NOT NULL,
+			description TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'outstanding',
+			resolved_by INT REFERENCES users(id) ON DELETE SET NULL,
+			resolved_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create eligibility tables: %v", err)
+	}
+
+	// Lost and damaged items are charged at the book's replacement cost
+	_, err = db.Exec(`
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS replacement_cost NUMERIC(10, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE borrows ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP;
+		ALTER TABLE borrows ADD COLUMN IF NOT EXISTS resolution_note TEXT
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to apply loss columns: %v", err)
+	}
+
+	// Library calendar: weekly opening hours plus dated exceptions
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS opening_hours (
+			weekday SMALLINT PRIMARY KEY CHECK (weekday BETWEEN 0 AND 6),
+			closed BOOLEAN NOT NULL DEFAULT FALSE,
+			open_time VARCHAR(5) NOT NULL DEFAULT '09:00',
+			close_time VARCHAR(5) NOT NULL DEFAULT '18:00'
+		);
+
+		INSERT INTO opening_hours (weekday, closed)
+		SELECT d, d = 0 FROM generate_series(0, 6) AS d
+		ON CONFLICT (weekday) DO NOTHING;
+
+		CREATE TABLE IF NOT EXISTS calendar_exceptions (
+			id SERIAL PRIMARY KEY,
+			date DATE NOT NULL UNIQUE,
+			closed BOOLEAN NOT NULL DEFAULT TRUE,
+			open_time VARCHAR(5) NOT NULL DEFAULT '',
+			close_time VARCHAR(5) NOT NULL DEFAULT '',
+			note TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create calendar tables: %v", err)
+	}
+
+	// Author authority records, their variant names and links to books
+	_, err = db.Exec(`
+		ALTER TABLE books ALTER COLUMN author TYPE TEXT;
+
+		CREATE TABLE IF NOT EXISTS authors (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(200) NOT NULL,
+			match_key VARCHAR(200) NOT NULL,
+			dates VARCHAR(50) NOT NULL DEFAULT '',
+			biography TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_authors_match_key ON authors(match_key);
+
+		CREATE TABLE IF NOT EXISTS author_variants (
+			id SERIAL PRIMARY KEY,
+			author_id INT NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+			name VARCHAR(200) NOT NULL,
+			match_key VARCHAR(200) NOT NULL,
+			UNIQUE (author_id, match_key)
+		);
+		CREATE INDEX IF NOT EXISTS idx_author_variants_match_key ON author_variants(match_key);
+
+		CREATE TABLE IF NOT EXISTS author_see_also (
+			author_id INT NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+			related_id INT NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+			PRIMARY KEY (author_id, related_id),
+			CHECK (author_id <> related_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS book_authors (
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			author_id INT NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+			role VARCHAR(20) NOT NULL DEFAULT 'author',
+			position INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (book_id, author_id, role)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create author tables: %v", err)
+	}
+
+	// Subject headings and the classification scheme
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS subjects (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(200) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_subjects_name ON subjects(LOWER(name));
+
+		CREATE TABLE IF NOT EXISTS book_subjects (
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			subject_id INT NOT NULL REFERENCES subjects(id) ON DELETE CASCADE,
+			PRIMARY KEY (book_id, subject_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS classes (
+			id SERIAL PRIMARY KEY,
+			notation VARCHAR(30) NOT NULL UNIQUE,
+			caption VARCHAR(200) NOT NULL,
+			parent_id INT REFERENCES classes(id) ON DELETE RESTRICT
+		);
+
+		INSERT INTO classes (notation, caption) VALUES
+			('000', 'Computer science, information and general works'),
+			('100', 'Philosophy and psychology'),
+			('200', 'Religion'),
+			('300', 'Social sciences'),
+			('400', 'Language'),
+			('500', 'Science'),
+			('600', 'Technology'),
+			('700', 'Arts and recreation'),
+			('800', 'Literature'),
+			('900', 'History and geography')
+		ON CONFLICT (notation) DO NOTHING;
+
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS call_number VARCHAR(50) NOT NULL DEFAULT '';
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS call_number_sort VARCHAR(120) NOT NULL DEFAULT '';
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS class_id INT REFERENCES classes(id) ON DELETE SET NULL;
+
+		-- Existing categories become the first subject headings
+		INSERT INTO subjects (name)
+		SELECT DISTINCT ON (LOWER(TRIM(category))) TRIM(category) FROM books
+		WHERE TRIM(COALESCE(category, '')) <> ''
+		ON CONFLICT ((LOWER(name))) DO NOTHING;
+
+		INSERT INTO book_subjects (book_id, subject_id)
+		SELECT b.id, s.id FROM books b
+		JOIN subjects s ON LOWER(s.name) = LOWER(TRIM(b.category))
+		WHERE NOT EXISTS (SELECT 1 FROM book_subjects bs WHERE bs.book_id = b.id)
+		ON CONFLICT DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create subject tables: %v", err)
+	}
+
+	// Works group the editions of the same title; reservations may accept any edition
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS works (
+			id SERIAL PRIMARY KEY,
+			title VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS work_id INT REFERENCES works(id) ON DELETE SET NULL;
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS edition_statement VARCHAR(100) NOT NULL DEFAULT '';
+		CREATE INDEX IF NOT EXISTS idx_books_work_id ON books(work_id);
+
+		ALTER TABLE reservations ADD COLUMN IF NOT EXISTS any_edition BOOLEAN NOT NULL DEFAULT FALSE
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create work tables: %v", err)
+	}
+
+	// Series and multi-volume sets with ordered membership
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS series (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			is_set BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS series_books (
+			series_id INT NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			volume VARCHAR(20) NOT NULL DEFAULT '',
+			PRIMARY KEY (series_id, book_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_series_books_book_id ON series_books(book_id);
+
+		ALTER TABLE borrows ADD COLUMN IF NOT EXISTS set_request_id INT;
+		CREATE INDEX IF NOT EXISTS idx_borrows_set_request_id ON borrows(set_request_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create series tables: %v", err)
+	}
+
+	// Cover image location, filled in by hand or from a metadata provider
+	_, err = db.Exec(`
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_url TEXT NOT NULL DEFAULT ''
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add book cover column: %v", err)
+	}
+
+	// Uploaded cover image, stored as thumbnails under this key
+	_, err = db.Exec(`
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_key TEXT NOT NULL DEFAULT ''
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add book cover key column: %v", err)
+	}
+
+	// Serial subscriptions and their predicted and received issues
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS serials (
+			id SERIAL PRIMARY KEY,
+			title VARCHAR(255) NOT NULL,
+			issn VARCHAR(9) NOT NULL DEFAULT '',
+			publisher VARCHAR(100) NOT NULL DEFAULT '',
+			frequency VARCHAR(20) NOT NULL,
+			issues_per_volume INT NOT NULL DEFAULT 12,
+			start_date DATE NOT NULL,
+			start_volume INT NOT NULL DEFAULT 1,
+			start_number INT NOT NULL DEFAULT 1,
+			copies_per_issue INT NOT NULL DEFAULT 1,
+			claim_after_days INT NOT NULL DEFAULT 14,
+			call_number VARCHAR(50) NOT NULL DEFAULT '',
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS serial_issues (
+			id SERIAL PRIMARY KEY,
+			serial_id INT NOT NULL REFERENCES serials(id) ON DELETE CASCADE,
+			volume INT NOT NULL,
+			number INT NOT NULL,
+			expected_date DATE NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'expected',
+			received_at TIMESTAMP,
+			claimed_at TIMESTAMP,
+			claim_count INT NOT NULL DEFAULT 0,
+			book_id INT REFERENCES books(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (serial_id, volume, number)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_serial_issues_status ON serial_issues(status, expected_date);
+		CREATE INDEX IF NOT EXISTS idx_serial_issues_book_id ON serial_issues(book_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create serials tables: %v", err)
+	}
+
+	// Acquisitions: vendors, fund budgets, purchase orders and invoices
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS vendors (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			contact_name VARCHAR(100) NOT NULL DEFAULT '',
+			email VARCHAR(255) NOT NULL DEFAULT '',
+			phone VARCHAR(50) NOT NULL DEFAULT '',
+			address TEXT NOT NULL DEFAULT '',
+			account_number VARCHAR(50) NOT NULL DEFAULT '',
+			notes TEXT NOT NULL DEFAULT '',
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS funds (
+			id SERIAL PRIMARY KEY,
+			code VARCHAR(20) NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			fiscal_year INT NOT NULL,
+			allocated NUMERIC(12, 2) NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (code, fiscal_year)
+		);
+
+		CREATE TABLE IF NOT EXISTS purchase_orders (
+			id SERIAL PRIMARY KEY,
+			vendor_id INT NOT NULL REFERENCES vendors(id),
+			fund_id INT NOT NULL REFERENCES funds(id),
+			status VARCHAR(20) NOT NULL DEFAULT 'draft',
+			ordered_at TIMESTAMP,
+			notes TEXT NOT NULL DEFAULT '',
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS purchase_order_lines (
+			id SERIAL PRIMARY KEY,
+			order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+			book_id INT NOT NULL REFERENCES books(id),
+			quantity INT NOT NULL,
+			quantity_received INT NOT NULL DEFAULT 0,
+			unit_price NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (order_id, book_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS invoices (
+			id SERIAL PRIMARY KEY,
+			order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+			invoice_number VARCHAR(50) NOT NULL,
+			invoice_date DATE NOT NULL,
+			amount NUMERIC(12, 2) NOT NULL,
+			final BOOLEAN NOT NULL DEFAULT FALSE,
+			notes TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_purchase_orders_fund_id ON purchase_orders(fund_id);
+		CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_book_id ON purchase_order_lines(book_id);
+		CREATE INDEX IF NOT EXISTS idx_invoices_order_id ON invoices(order_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create acquisitions tables: %v", err)
+	}
+
+	// Create purchase suggestions; each patron backing a suggestion has a vote
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS suggestions (
+			id SERIAL PRIMARY KEY,
+			title VARCHAR(255) NOT NULL,
+			author VARCHAR(255) NOT NULL DEFAULT '',
+			isbn VARCHAR(13) NOT NULL DEFAULT '',
+			publisher VARCHAR(255) NOT NULL DEFAULT '',
+			publication_year INT NOT NULL DEFAULT 0,
+			notes TEXT NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL DEFAULT 'new',
+			librarian_note TEXT NOT NULL DEFAULT '',
+			book_id INT REFERENCES books(id) ON DELETE SET NULL,
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			reviewed_by INT REFERENCES users(id) ON DELETE SET NULL,
+			reviewed_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS suggestion_votes (
+			suggestion_id INT NOT NULL REFERENCES suggestions(id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			reserve BOOLEAN NOT NULL DEFAULT FALSE,
+			seen_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (suggestion_id, user_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_suggestions_status ON suggestions(status);
+		CREATE INDEX IF NOT EXISTS idx_suggestions_book_id ON suggestions(book_id);
+		CREATE INDEX IF NOT EXISTS idx_suggestion_votes_user_id ON suggestion_votes(user_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create suggestions tables: %v", err)
+	}
+
+	// Create course reserves; loans of reserve items are counted in hours
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS terms (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(100) UNIQUE NOT NULL,
+			starts_on DATE NOT NULL,
+			ends_on DATE NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS courses (
+			id SERIAL PRIMARY KEY,
+			code VARCHAR(20) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			term_id INT NOT NULL REFERENCES terms(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (code, term_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS course_instructors (
+			course_id INT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			PRIMARY KEY (course_id, user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS course_reserves (
+			id SERIAL PRIMARY KEY,
+			course_id INT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			loan_hours INT NOT NULL,
+			notes TEXT NOT NULL DEFAULT '',
+			added_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			released_at TIMESTAMP
+		);
+
+		ALTER TABLE borrows ADD COLUMN IF NOT EXISTS loan_hours INT NOT NULL DEFAULT 0;
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_course_reserves_active ON course_reserves(course_id, book_id) WHERE released_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_course_reserves_book_id ON course_reserves(book_id);
+		CREATE INDEX IF NOT EXISTS idx_course_instructors_user_id ON course_instructors(user_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create course reserves tables: %v", err)
+	}
+
+	// Create personal shelves and reading lists; kind marks the built-in shelves every
+	// patron has, and public lists can be shared by link
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS shelves (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			kind VARCHAR(20) NOT NULL DEFAULT 'custom',
+			public BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (user_id, name)
+		);
+
+		CREATE TABLE IF NOT EXISTS shelf_items (
+			shelf_id INT NOT NULL REFERENCES shelves(id) ON DELETE CASCADE,
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			note TEXT NOT NULL DEFAULT '',
+			added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (shelf_id, book_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_shelves_public ON shelves(public) WHERE public;
+		CREATE INDEX IF NOT EXISTS idx_shelf_items_book_id ON shelf_items(book_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create shelves tables: %v", err)
+	}
+
+	// Create book reviews; patrons report abusive reviews, which flags them for a librarian
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS reviews (
+			id SERIAL PRIMARY KEY,
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			body TEXT NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL DEFAULT 'published',
+			flagged BOOLEAN NOT NULL DEFAULT FALSE,
+			moderated_by INT REFERENCES users(id) ON DELETE SET NULL,
+			moderated_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (book_id, user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS review_reports (
+			review_id INT NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (review_id, user_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_reviews_book_status ON reviews(book_id, status);
+		CREATE INDEX IF NOT EXISTS idx_reviews_moderation ON reviews(status, flagged)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create reviews tables: %v", err)
+	}
+
+	// Book-to-book recommendations, rebuilt by a background job from borrow
+	// co-occurrence and shared subjects; no patron is recorded
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS book_recommendations (
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			recommended_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			patrons INT NOT NULL DEFAULT 0,
+			shared_subjects INT NOT NULL DEFAULT 0,
+			score REAL NOT NULL,
+			PRIMARY KEY (book_id, recommended_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_book_recommendations_score ON book_recommendations(book_id, score DESC)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create recommendations table: %v", err)
+	}
+
+	// Saved report definitions delivered by email on a schedule, with a history of
+	// every delivery attempt
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS scheduled_reports (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			report_type VARCHAR(20) NOT NULL,
+			params TEXT NOT NULL DEFAULT '',
+			format VARCHAR(10) NOT NULL,
+			frequency VARCHAR(10) NOT NULL,
+			delivery VARCHAR(20) NOT NULL DEFAULT 'attachment',
+			recipients TEXT NOT NULL,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			next_run_at TIMESTAMP NOT NULL,
+			created_by INT REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS scheduled_report_runs (
+			id SERIAL PRIMARY KEY,
+			scheduled_report_id INT NOT NULL REFERENCES scheduled_reports(id) ON DELETE CASCADE,
+			period_from DATE NOT NULL,
+			period_to DATE NOT NULL,
+			manual BOOLEAN NOT NULL DEFAULT FALSE,
+			succeeded BOOLEAN NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			started_at TIMESTAMP NOT NULL,
+			finished_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_scheduled_reports_due ON scheduled_reports(next_run_at) WHERE active;
+		CREATE INDEX IF NOT EXISTS idx_scheduled_report_runs_report ON scheduled_report_runs(scheduled_report_id, started_at DESC)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduled reports tables: %v", err)
+	}
+
+	// Stocktakes: shelves are scanned section by section and compared with the copies
+	// the catalogue expects on them, captured when each section is finished
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS stocktakes (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'open',
+			started_by INT REFERENCES users(id) ON DELETE SET NULL,
+			started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			completed_at TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS stocktake_sections (
+			id SERIAL PRIMARY KEY,
+			stocktake_id INT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			call_from VARCHAR(50) NOT NULL,
+			call_to VARCHAR(50) NOT NULL,
+			from_sort VARCHAR(120) NOT NULL,
+			to_sort VARCHAR(120) NOT NULL,
+			completed_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS stocktake_scans (
+			id SERIAL PRIMARY KEY,
+			stocktake_id INT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+			section_id INT NOT NULL REFERENCES stocktake_sections(id) ON DELETE CASCADE,
+			book_id INT REFERENCES books(id) ON DELETE SET NULL,
+			barcode VARCHAR(50) NOT NULL,
+			scanned_by INT REFERENCES users(id) ON DELETE SET NULL,
+			scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS stocktake_holdings (
+			stocktake_id INT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+			section_id INT NOT NULL REFERENCES stocktake_sections(id) ON DELETE CASCADE,
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			expected INT NOT NULL,
+			on_loan INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (stocktake_id, book_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS stocktake_resolutions (
+			stocktake_id INT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			action VARCHAR(20) NOT NULL,
+			copies INT NOT NULL DEFAULT 0,
+			resolved_by INT REFERENCES users(id) ON DELETE SET NULL,
+			resolved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (stocktake_id, book_id, action)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_stocktake_scans_section ON stocktake_scans(section_id, scanned_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_stocktake_scans_book ON stocktake_scans(stocktake_id, book_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create stocktake tables: %v", err)
+	}
+
+	// Weeding: candidates marked from the collection analysis are reviewed and either
+	// kept or withdrawn. Withdrawn books stay in the database for their history.
+	_, err = db.Exec(`
+		ALTER TABLE books ADD COLUMN IF NOT EXISTS withdrawn_at TIMESTAMP;
+
+		CREATE TABLE IF NOT EXISTS weeding_records (
+			id SERIAL PRIMARY KEY,
+			book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL DEFAULT 'candidate',
+			reason TEXT NOT NULL DEFAULT '',
+			note TEXT NOT NULL DEFAULT '',
+			copies INT NOT NULL DEFAULT 0,
+			marked_by INT REFERENCES users(id) ON DELETE SET NULL,
+			marked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			reviewed_by INT REFERENCES users(id) ON DELETE SET NULL,
+			reviewed_at TIMESTAMP
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_weeding_records_candidate ON weeding_records(book_id) WHERE status = 'candidate';
+		CREATE INDEX IF NOT EXISTS idx_weeding_records_status ON weeding_records(status, reviewed_at DESC)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create weeding tables: %v", err)
+	}
+
+	// Overdue notices: escalating stages, each sent a number of days from the due
+	// date, and the notices each loan has been sent. Letters wait in the print queue.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS notice_stages (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			days INT NOT NULL,
+			channel VARCHAR(20) NOT NULL DEFAULT 'email',
+			subject VARCHAR(255) NOT NULL,
+			body TEXT NOT NULL,
+			block_patron BOOLEAN NOT NULL DEFAULT FALSE,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS overdue_notices (
+			id SERIAL PRIMARY KEY,
+			borrow_id INT NOT NULL REFERENCES borrows(id) ON DELETE CASCADE,
+			stage_id INT REFERENCES notice_stages(id) ON DELETE SET NULL,
+			stage_name VARCHAR(100) NOT NULL,
+			stage_days INT NOT NULL,
+			user_id INT REFERENCES users(id) ON DELETE SET NULL,
+			channel VARCHAR(20) NOT NULL,
+			recipient VARCHAR(255) NOT NULL DEFAULT '',
+			subject VARCHAR(255) NOT NULL DEFAULT '',
+			body TEXT NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			blocked BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			printed_at TIMESTAMP,
+			UNIQUE (borrow_id, stage_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_overdue_notices_status ON overdue_notices(status, created_at);
+		CREATE INDEX IF NOT EXISTS idx_overdue_notices_created ON overdue_notices(created_at DESC);
+
+		CREATE TABLE IF NOT EXISTS notice_runs (
+			id SERIAL PRIMARY KEY,
+			run_date DATE NOT NULL,
+			manual BOOLEAN NOT NULL DEFAULT FALSE,
+			emailed INT NOT NULL DEFAULT 0,
+			letters INT NOT NULL DEFAULT 0,
+			blocked INT NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMP
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_notice_runs_daily ON notice_runs(run_date) WHERE NOT manual;
+
+		ALTER TABLE patron_blocks ADD COLUMN IF NOT EXISTS borrow_id INT REFERENCES borrows(id) ON DELETE SET NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create notice tables: %v", err)
+	}
+
 	var count int
 	err = db.QueryRow(`SELECT COUNT(*) FROM users WHERE role = 'librarian'`).Scan(&count)
 	if err != nil {
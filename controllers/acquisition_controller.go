@@ -0,0 +1,558 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	isbnpkg "library-management-system/isbn"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// requireAcquisitionsUser returns the signed-in librarian, or redirects and returns nil
+func requireAcquisitionsUser(w http.ResponseWriter, r *http.Request) *models.User {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil
+	}
+
+	// Only librarians can manage acquisitions
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to manage acquisitions")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}
+
+	return user
+}
+
+// fiscalYearParam reads the "year" query parameter, defaulting to the current fiscal year
+func fiscalYearParam(r *http.Request) int {
+	if year, err := strconv.Atoi(r.URL.Query().Get("year")); err == nil && year > 0 {
+		return year
+	}
+	return models.CurrentFiscalYear()
+}
+
+// parsePrice reads a money amount from a form field
+func parsePrice(value string) (float64, error) {
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "$"))
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// PurchaseOrderList displays purchase orders (GET) or starts a new draft order (POST)
+func PurchaseOrderList(w http.ResponseWriter, r *http.Request) {
+	user := requireAcquisitionsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		vendorID, _ := strconv.Atoi(r.FormValue("vendor_id"))
+		fundID, _ := strconv.Atoi(r.FormValue("fund_id"))
+		order := &models.PurchaseOrder{
+			VendorID:  vendorID,
+			FundID:    fundID,
+			Notes:     r.FormValue("notes"),
+			CreatedBy: sql.NullInt64{Int64: int64(user.ID), Valid: true},
+		}
+		if err := order.Create(); err != nil {
+			utils.SetError(w, r, "Error creating order: "+err.Error())
+			http.Redirect(w, r, "/acquisitions", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Draft order "+order.Number()+" created. Add the books to order.")
+		http.Redirect(w, r, "/acquisitions/orders/"+strconv.Itoa(order.ID), http.StatusSeeOther)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+
+	// Get orders
+	orders, err := models.GetPurchaseOrders(status)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching orders: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	vendors, _ := models.GetAllVendors(false)
+	funds, _ := models.GetFunds(models.CurrentFiscalYear())
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":  "Acquisitions",
+			"Orders": orders,
+			"Status": status,
+			"Statuses": []string{models.OrderStatusDraft, models.OrderStatusOrdered, models.OrderStatusPartial,
+				models.OrderStatusReceived, models.OrderStatusClosed, models.OrderStatusCancelled},
+			"Vendors": vendors,
+			"Funds":   funds,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "order_list.html", data)
+}
+
+// parseOrderPath splits /acquisitions/orders/{id}/... into the order ID and remaining segments
+func parseOrderPath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/acquisitions/orders/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// PurchaseOrderDetail displays an order with its lines, receiving and invoices
+func PurchaseOrderDetail(w http.ResponseWriter, r *http.Request) {
+	user := requireAcquisitionsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// Extract order ID from URL
+	id, _, err := parseOrderPath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get order
+	order, err := models.GetPurchaseOrderByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Purchase order not found")
+		http.Redirect(w, r, "/acquisitions", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title": "Purchase Order " + order.Number(),
+			"Order": order,
+			"Today": time.Now().Format("2006-01-02"),
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "order_detail.html", data)
+}
+
+// PurchaseOrderAction changes an order:
+//
+//	POST /acquisitions/orders/{id}/lines                  (isbn, title, author, quantity, unit_price)
+//	POST /acquisitions/orders/{id}/lines/{lineID}/delete
+//	POST /acquisitions/orders/{id}/place
+//	POST /acquisitions/orders/{id}/cancel
+//	POST /acquisitions/orders/{id}/receive                (received_{lineID} for each line)
+//	POST /acquisitions/orders/{id}/invoices               (invoice_number, invoice_date, amount, final, notes)
+func PurchaseOrderAction(w http.ResponseWriter, r *http.Request) {
+	user := requireAcquisitionsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract order ID and action from URL
+	id, parts, err := parseOrderPath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	orderURL := "/acquisitions/orders/" + strconv.Itoa(id)
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, orderURL, http.StatusSeeOther)
+		return
+	}
+
+	var message string
+	switch {
+	case len(parts) == 1 && parts[0] == "lines":
+		var book *models.Book
+		book, err = orderLineBook(r, user)
+		if err == nil {
+			quantity, _ := strconv.Atoi(r.FormValue("quantity"))
+			var price float64
+			price, err = parsePrice(r.FormValue("unit_price"))
+			if err == nil {
+				err = models.AddOrderLine(id, book.ID, quantity, price)
+			}
+		}
+		message = "Line saved"
+	case len(parts) == 3 && parts[0] == "lines" && parts[2] == "delete":
+		lineID, convErr := strconv.Atoi(parts[1])
+		if convErr != nil || lineID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		err = models.RemoveOrderLine(id, lineID)
+		message = "Line removed"
+	case len(parts) == 1 && parts[0] == "place":
+		err = models.PlaceOrder(id)
+		message = "Order placed; its total is now encumbered on the fund"
+	case len(parts) == 1 && parts[0] == "cancel":
+		err = models.CancelOrder(id)
+		message = "Order cancelled"
+	case len(parts) == 1 && parts[0] == "receive":
+		received := make(map[int]int)
+		for name, values := range r.PostForm {
+			lineID, convErr := strconv.Atoi(strings.TrimPrefix(name, "received_"))
+			if !strings.HasPrefix(name, "received_") || convErr != nil || len(values) == 0 {
+				continue
+			}
+			received[lineID], _ = strconv.Atoi(values[0])
+		}
+		var count int
+		count, err = models.ReceiveOrder(id, received)
+		message = "Received " + strconv.Itoa(count) + " copies; they are now on the shelf"
+	case len(parts) == 1 && parts[0] == "invoices":
+		invoice := &models.Invoice{
+			OrderID:       id,
+			InvoiceNumber: r.FormValue("invoice_number"),
+			Final:         r.FormValue("final") != "",
+			Notes:         strings.TrimSpace(r.FormValue("notes")),
+		}
+		invoice.InvoiceDate, _ = time.Parse("2006-01-02", r.FormValue("invoice_date"))
+		invoice.Amount, err = parsePrice(r.FormValue("amount"))
+		if err == nil {
+			err = models.AddInvoice(invoice)
+		}
+		message = "Invoice recorded"
+		if invoice.Final {
+			message = "Final invoice recorded; the order is closed"
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating order: "+err.Error())
+		http.Redirect(w, r, orderURL, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, message)
+	http.Redirect(w, r, orderURL, http.StatusSeeOther)
+}
+
+// errIsbnNotInCatalog asks for the details of a book being ordered for the first time
+var errIsbnNotInCatalog = errors.New("this ISBN is not in the catalog yet; enter its title and author to add it")
+
+// orderLineBook finds the book an order line is for by ISBN. A title not yet in the
+// catalog gets a brief record with no copies, which receiving fills in and which
+// patrons can already reserve.
+func orderLineBook(r *http.Request, user *models.User) (*models.Book, error) {
+	code, err := isbnpkg.Normalize(r.FormValue("isbn"))
+	if err != nil {
+		return nil, err
+	}
+	if book, err := models.GetBookByBarcode(code); err == nil {
+		return book, nil
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	author := strings.TrimSpace(r.FormValue("author"))
+	if title == "" || author == "" {
+		return nil, errIsbnNotInCatalog
+	}
+	contributors, err := parseContributors(author, "")
+	if err != nil {
+		return nil, err
+	}
+
+	book := &models.Book{
+		Title:   title,
+		Author:  author,
+		ISBN:    code,
+		AddedBy: sql.NullInt64{Int64: int64(user.ID), Valid: true},
+	}
+	if err := book.Create(); err != nil {
+		return nil, err
+	}
+	if err := models.SetBookContributors(book.ID, contributors); err != nil {
+		return nil, err
+	}
+
+	// Suggestions for the book are now on order
+	matchSuggestions(book)
+
+	return book, nil
+}
+
+// VendorList displays vendors (GET) or adds one (POST)
+func VendorList(w http.ResponseWriter, r *http.Request) {
+	user := requireAcquisitionsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		vendor := &models.Vendor{Active: true}
+		vendorFromForm(r, vendor)
+		if err := vendor.Save(); err != nil {
+			utils.SetError(w, r, "Error adding vendor: "+err.Error())
+			http.Redirect(w, r, "/acquisitions/vendors", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Vendor added")
+		http.Redirect(w, r, "/acquisitions/vendors", http.StatusSeeOther)
+		return
+	}
+
+	// Get vendors
+	vendors, err := models.GetAllVendors(true)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching vendors: "+err.Error())
+		http.Redirect(w, r, "/acquisitions", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":   "Vendors",
+			"Vendors": vendors,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "vendor_list.html", data)
+}
+
+// vendorFromForm copies the vendor form fields onto a vendor
+func vendorFromForm(r *http.Request, v *models.Vendor) {
+	v.Name = r.FormValue("name")
+	v.ContactName = strings.TrimSpace(r.FormValue("contact_name"))
+	v.Email = strings.TrimSpace(r.FormValue("email"))
+	v.Phone = strings.TrimSpace(r.FormValue("phone"))
+	v.Address = strings.TrimSpace(r.FormValue("address"))
+	v.AccountNumber = strings.TrimSpace(r.FormValue("account_number"))
+	v.Notes = strings.TrimSpace(r.FormValue("notes"))
+}
+
+// EditVendor displays the form for editing a vendor (GET) or saves it (POST)
+func EditVendor(w http.ResponseWriter, r *http.Request) {
+	user := requireAcquisitionsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// Extract vendor ID from URL
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/acquisitions/vendors/"), "/"))
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get vendor
+	vendor, err := models.GetVendorByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Vendor not found")
+		http.Redirect(w, r, "/acquisitions/vendors", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		vendorFromForm(r, vendor)
+		vendor.Active = r.FormValue("active") != ""
+		if err := vendor.Save(); err != nil {
+			utils.SetError(w, r, "Error updating vendor: "+err.Error())
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Vendor updated")
+		http.Redirect(w, r, "/acquisitions/vendors", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":  "Edit Vendor",
+			"Vendor": vendor,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "vendor_form.html", data)
+}
+
+// FundList displays a fiscal year's funds with their budgets (GET) or adds a fund (POST)
+func FundList(w http.ResponseWriter, r *http.Request) {
+	user := requireAcquisitionsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		fund := &models.Fund{}
+		err := fundFromForm(r, fund)
+		if err == nil {
+			err = fund.Save()
+		}
+		if err != nil {
+			utils.SetError(w, r, "Error adding fund: "+err.Error())
+			http.Redirect(w, r, "/acquisitions/funds", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Fund "+fund.Code+" added")
+		http.Redirect(w, r, "/acquisitions/funds?year="+strconv.Itoa(fund.FiscalYear), http.StatusSeeOther)
+		return
+	}
+
+	year := fiscalYearParam(r)
+
+	// Get funds
+	funds, err := models.GetFunds(year)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching funds: "+err.Error())
+		http.Redirect(w, r, "/acquisitions", http.StatusSeeOther)
+		return
+	}
+	years, _ := models.GetFiscalYears()
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":       "Funds",
+			"Funds":       funds,
+			"FiscalYear":  year,
+			"FiscalYears": years,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "fund_list.html", data)
+}
+
+// fundFromForm copies the fund form fields onto a fund
+func fundFromForm(r *http.Request, f *models.Fund) error {
+	f.Code = r.FormValue("code")
+	f.Name = r.FormValue("name")
+	f.FiscalYear, _ = strconv.Atoi(r.FormValue("fiscal_year"))
+
+	var err error
+	f.Allocated, err = parsePrice(r.FormValue("allocated"))
+	return err
+}
+
+// EditFund saves changes to a fund:
+//
+//	POST /acquisitions/funds/{id}
+func EditFund(w http.ResponseWriter, r *http.Request) {
+	user := requireAcquisitionsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract fund ID from URL
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/acquisitions/funds/"), "/"))
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get fund
+	fund, err := models.GetFundByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Fund not found")
+		http.Redirect(w, r, "/acquisitions/funds", http.StatusSeeOther)
+		return
+	}
+
+	err = fundFromForm(r, fund)
+	if err == nil {
+		err = fund.Save()
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating fund: "+err.Error())
+	} else {
+		utils.SetFlash(w, r, "Fund "+fund.Code+" updated")
+	}
+	http.Redirect(w, r, "/acquisitions/funds?year="+strconv.Itoa(fund.FiscalYear), http.StatusSeeOther)
+}
+
+// AcquisitionsReport shows encumbrance and expenditure by fund and by vendor for a
+// fiscal year
+func AcquisitionsReport(w http.ResponseWriter, r *http.Request) {
+	user := requireAcquisitionsUser(w, r)
+	if user == nil {
+		return
+	}
+
+	year := fiscalYearParam(r)
+
+	// Get funds
+	funds, err := models.GetFunds(year)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching funds: "+err.Error())
+		http.Redirect(w, r, "/acquisitions", http.StatusSeeOther)
+		return
+	}
+
+	// Get spending by vendor
+	vendors, err := models.GetVendorSpending(year)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching vendor spending: "+err.Error())
+		http.Redirect(w, r, "/acquisitions", http.StatusSeeOther)
+		return
+	}
+	years, _ := models.GetFiscalYears()
+
+	// Totals across all funds
+	total := &models.Fund{Code: "Total"}
+	for _, f := range funds {
+		total.Allocated += f.Allocated
+		total.Encumbered += f.Encumbered
+		total.Expended += f.Expended
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":       "Acquisitions Report",
+			"Funds":       funds,
+			"Total":       total,
+			"Vendors":     vendors,
+			"FiscalYear":  year,
+			"FiscalYears": years,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "acquisitions_report.html", data)
+}
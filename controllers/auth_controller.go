@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"library-management-system/config"
 	"library-management-system/middleware"
 	"library-management-system/models"
 	"library-management-system/utils"
@@ -348,6 +349,20 @@ func Profile(w http.ResponseWriter, r *http.Request) {
 		go models.CleanExpiredReservations()
 	}
 
+	// Get charges, blocks and borrowing eligibility for students
+	var charges []*models.Charge
+	var blocks []*models.PatronBlock
+	var balance float64
+	var eligibility *models.Eligibility
+	if profileUser.IsStudent {
+		charges, _ = models.GetUserCharges(profileUserID)
+		balance, _ = models.GetOutstandingBalance(profileUserID)
+		eligibility, _ = models.CheckBorrowEligibility(profileUserID, 0)
+		if user.IsLibrarian {
+			blocks, _ = models.GetPatronBlocks(profileUserID)
+		}
+	}
+
 	// Prepare data for template
 	data := &utils.TemplateData{
 		User: user,
@@ -358,6 +373,11 @@ func Profile(w http.ResponseWriter, r *http.Request) {
 			"pendingBorrows": pendingBorrows,
 			"pastBorrows":    pastBorrows,
 			"reservations":   reservations,
+			"retentionDays":  config.AppConfig.Privacy.RetentionDays,
+			"charges":        charges,
+			"balance":        balance,
+			"blocks":         blocks,
+			"eligibility":    eligibility,
 		},
 	}
 
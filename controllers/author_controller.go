@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// AuthorList displays the author index with optional search
+func AuthorList(w http.ResponseWriter, r *http.Request) {
+	// Get the current user if authenticated
+	user := middleware.GetUserFromContext(r)
+
+	search := r.URL.Query().Get("search")
+
+	// Get authors
+	authors, err := models.SearchAuthors(search)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching authors: "+err.Error())
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":   "Authors",
+			"Authors": authors,
+			"Search":  search,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "author_list.html", data)
+}
+
+// parseAuthorPath splits /authors/{id}/... into the author ID and remaining segments
+func parseAuthorPath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/authors/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// AuthorDetail displays an author's authority record and all of their works
+func AuthorDetail(w http.ResponseWriter, r *http.Request) {
+	// Get the current user if authenticated
+	user := middleware.GetUserFromContext(r)
+
+	// Extract author ID from URL
+	id, _, err := parseAuthorPath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get author
+	author, err := models.GetAuthorByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Author not found")
+		http.Redirect(w, r, "/authors", http.StatusSeeOther)
+		return
+	}
+	author.Works, _ = models.GetAuthorWorks(id)
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":  author.Name,
+			"Author": author,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "author_detail.html", data)
+}
+
+// EditAuthor displays the authority record form (GET) or saves it (POST)
+func EditAuthor(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can edit authority records
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to edit authors")
+		http.Redirect(w, r, "/authors", http.StatusSeeOther)
+		return
+	}
+
+	// Extract author ID from URL
+	id, _, err := parseAuthorPath(r.URL.Path)
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid author ID")
+		http.Redirect(w, r, "/authors", http.StatusSeeOther)
+		return
+	}
+
+	// Get author
+	author, err := models.GetAuthorByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Author not found")
+		http.Redirect(w, r, "/authors", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		// Parse form
+		if err := r.ParseForm(); err != nil {
+			utils.SetError(w, r, "Error processing form")
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			utils.SetError(w, r, "Please provide the authorised name")
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+
+		// The new heading must not belong to a different author
+		existing, err := models.FindAuthorByName(name)
+		if err == nil && existing != nil && existing.ID != id {
+			utils.SetError(w, r, "\""+name+"\" already identifies "+existing.Name+"; merge the authors instead")
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+
+		// Keep the previous heading as a variant so old searches still match
+		previousName := author.Name
+
+		author.Name = name
+		author.Dates = strings.TrimSpace(r.FormValue("dates"))
+		author.Biography = r.FormValue("biography")
+		if err := author.Update(); err != nil {
+			utils.SetError(w, r, "Error updating author: "+err.Error())
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+		if models.AuthorMatchKey(previousName) != models.AuthorMatchKey(name) {
+			_ = models.AddAuthorVariant(id, previousName)
+		}
+
+		utils.SetFlash(w, r, "Author updated successfully")
+		http.Redirect(w, r, "/authors/"+strconv.Itoa(id), http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":  "Edit Author",
+			"Author": author,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "author_form.html", data)
+}
+
+// AuthorAction manages variant names, see-also references and merges:
+//
+//	POST /authors/{id}/variants
+//	POST /authors/{id}/variants/{variantID}/delete
+//	POST /authors/{id}/see-also
+//	POST /authors/{id}/see-also/{relatedID}/delete
+//	POST /authors/{id}/merge
+func AuthorAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can edit authority records
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to edit authors")
+		http.Redirect(w, r, "/authors", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract author ID and action from URL
+	id, parts, err := parseAuthorPath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	editURL := "/authors/" + strconv.Itoa(id) + "/edit"
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, editURL, http.StatusSeeOther)
+		return
+	}
+
+	// Removal actions carry the target ID in the path
+	var targetID int
+	if len(parts) == 3 && parts[2] == "delete" {
+		targetID, err = strconv.Atoi(parts[1])
+		if err != nil || targetID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+	} else if len(parts) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var message string
+	switch {
+	case parts[0] == "variants" && targetID == 0:
+		err = models.AddAuthorVariant(id, r.FormValue("name"))
+		message = "Variant name added"
+	case parts[0] == "variants":
+		err = models.DeleteAuthorVariant(id, targetID)
+		message = "Variant name removed"
+	case parts[0] == "see-also" && targetID == 0:
+		var related *models.Author
+		related, err = models.FindAuthorByName(r.FormValue("name"))
+		if err == nil && related == nil {
+			utils.SetError(w, r, "No author found named \""+r.FormValue("name")+"\"")
+			http.Redirect(w, r, editURL, http.StatusSeeOther)
+			return
+		}
+		if err == nil {
+			err = models.AddSeeAlso(id, related.ID)
+		}
+		message = "See-also reference added"
+	case parts[0] == "see-also":
+		err = models.DeleteSeeAlso(id, targetID)
+		message = "See-also reference removed"
+	case parts[0] == "merge" && targetID == 0:
+		var target *models.Author
+		target, err = models.FindAuthorByName(r.FormValue("name"))
+		if err == nil && target == nil {
+			utils.SetError(w, r, "No author found named \""+r.FormValue("name")+"\"")
+			http.Redirect(w, r, editURL, http.StatusSeeOther)
+			return
+		}
+		if err == nil {
+			err = models.MergeAuthors(id, target.ID)
+		}
+		if err == nil {
+			utils.SetFlash(w, r, "Authors merged")
+			http.Redirect(w, r, "/authors/"+strconv.Itoa(target.ID), http.StatusSeeOther)
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating author: "+err.Error())
+		http.Redirect(w, r, editURL, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, message)
+	http.Redirect(w, r, editURL, http.StatusSeeOther)
+}
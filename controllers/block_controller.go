@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// BlockUser places a manual borrowing block on a patron
+func BlockUser(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can block patrons
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to block users")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract user ID from URL
+	idStr := strings.TrimPrefix(r.URL.Path, "/users/block/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid user ID")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/profile/"+idStr, http.StatusSeeOther)
+		return
+	}
+
+	// Parse optional expiry date
+	var expiresAt models.NullTime
+	if expiresStr := r.FormValue("expires_at"); expiresStr != "" {
+		expires, err := time.Parse("2006-01-02", expiresStr)
+		if err != nil {
+			utils.SetError(w, r, "Invalid expiry date format")
+			http.Redirect(w, r, "/profile/"+idStr, http.StatusSeeOther)
+			return
+		}
+		expiresAt = models.NullTime{Time: expires, Valid: true}
+	}
+
+	// Create block
+	err = models.CreatePatronBlock(id, r.FormValue("note"), user.ID, expiresAt)
+	if err != nil {
+		utils.SetError(w, r, "Error blocking user: "+err.Error())
+		http.Redirect(w, r, "/profile/"+idStr, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, "Borrowing block added")
+	http.Redirect(w, r, "/profile/"+idStr, http.StatusSeeOther)
+}
+
+// UnblockUser lifts a manual borrowing block
+func UnblockUser(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can lift blocks
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to unblock users")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract block ID from URL
+	idStr := strings.TrimPrefix(r.URL.Path, "/users/unblock/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid block ID")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// Lift block
+	userID, err := models.LiftPatronBlock(id)
+	if err != nil {
+		utils.SetError(w, r, "Error lifting block: "+err.Error())
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, "Borrowing block lifted")
+	http.Redirect(w, r, "/profile/"+strconv.Itoa(userID), http.StatusSeeOther)
+}
+
+// ResolveCharge marks a patron charge as paid or waived
+func ResolveCharge(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can settle charges
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to settle charges")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract charge ID and action from URL
+	path := strings.TrimPrefix(r.URL.Path, "/charges/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid charge ID")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	status := models.ChargeStatusPaid
+	if parts[1] == "waive" {
+		status = models.ChargeStatusWaived
+	}
+
+	// Resolve charge
+	userID, err := models.ResolveCharge(id, status, user.ID)
+	if err != nil {
+		utils.SetError(w, r, "Error updating charge: "+err.Error())
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, "Charge marked as "+status)
+	if userID == 0 {
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile/"+strconv.Itoa(userID), http.StatusSeeOther)
+}
@@ -2,12 +2,18 @@ package controllers
 
 import (
         "database/sql"
+        "errors"
         "net/http"
         "strconv"
         "strings"
 
+        "library-management-system/config"
+        "library-management-system/covers"
+        "library-management-system/export"
+        isbnpkg "library-management-system/isbn"
         "library-management-system/middleware"
         "library-management-system/models"
+        "library-management-system/reports"
         "library-management-system/utils"
 )
 
@@ -27,8 +33,18 @@ func BookList(w http.ResponseWriter, r *http.Request) {
                 page = 1
         }
         
+        // Get subject, classification and shelf-order filters
+        filter := models.BookFilter{Sort: query.Get("sort")}
+        filter.SubjectID, _ = strconv.Atoi(query.Get("subject"))
+        filter.ClassID, _ = strconv.Atoi(query.Get("class"))
+        
+        // Show one row per work unless every edition was asked for; shelf order lists
+        // each edition where it stands
+        showEditions := query.Get("editions") == "all"
+        filter.CollapseWorks = !showEditions && filter.Sort != "shelf"
+        
         // Get books based on search criteria
-        books, err := models.GetBooks(search, searchBy, page)
+        books, err := models.GetBooks(search, searchBy, filter, page)
         if err != nil {
                 utils.SetError(w, r, "Error fetching books: "+err.Error())
                 http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -36,7 +52,7 @@ func BookList(w http.ResponseWriter, r *http.Request) {
         }
         
         // Get total books for pagination
-        totalBooks, err := models.CountBooks(search, searchBy)
+        totalBooks, err := models.CountBooks(search, searchBy, filter)
         if err != nil {
                 utils.SetError(w, r, "Error counting books: "+err.Error())
                 http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -57,9 +73,19 @@ func BookList(w http.ResponseWriter, r *http.Request) {
                         "TotalPages": totalPages,
                         "Search":     search,
                         "SearchBy":   searchBy,
+                        "Sort":       filter.Sort,
+                        "AllEditions": showEditions,
                 },
         }
         
+        // Describe the active subject or class filter
+        if filter.SubjectID > 0 {
+                data.Data["Subject"], _ = models.GetSubjectByID(filter.SubjectID)
+        }
+        if filter.ClassID > 0 {
+                data.Data["Class"], _ = models.GetClassByID(filter.ClassID)
+        }
+        
         // Render template
         utils.RenderTemplate(w, r, "book_list.html", data)
 }
@@ -91,11 +117,42 @@ func BookDetail(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Get other editions of the same work
+        book.Editions, _ = models.GetWorkEditions(book)
+        
+        // Get the series the book belongs to, for previous and next links
+        series, _ := models.GetBookSeries(book.ID)
+        
+        // Get the serial issue the book was checked in as, if it is a periodical
+        issue, _ := models.GetBookSerialIssue(book.ID)
+        
+        // Get the courses the book is on short loan reserve for
+        reserves, _ := models.GetBookReserves(book.ID)
+        
+        // Get recommendations from what other patrons borrowed, or failing that by subject
+        alsoBorrowed, _ := models.GetAlsoBorrowed(book.ID, 5)
+        similarBooks, _ := models.GetSimilarBooks(book.ID, 5)
+        
+        // Get published reviews and the rating they add up to
+        reviews, _ := models.GetBookReviews(book.ID)
+        book.RatingAverage, book.RatingCount, _ = models.GetBookRating(book.ID)
+        
+        // Get the book's withdrawal, if it has been weeded from the collection
+        withdrawal, _ := models.GetBookWithdrawal(book.ID)
+        
         data := &utils.TemplateData{
                 User: user,
                 Data: map[string]interface{}{
-                        "Title": book.Title,
-                        "Book":  book,
+                        "Title":          book.Title,
+                        "Book":           book,
+                        "Series":         series,
+                        "SerialIssue":    issue,
+                        "CourseReserves": reserves,
+                        "Reviews":        reviews,
+                        "AlsoBorrowed":   alsoBorrowed,
+                        "SimilarBooks":   similarBooks,
+                        "Ratings":        []int{5, 4, 3, 2, 1},
+                        "Withdrawal":     withdrawal,
                 },
         }
         
@@ -116,6 +173,14 @@ func BookDetail(w http.ResponseWriter, r *http.Request) {
                         data.Data["IsCurrentlyBorrowing"] = false
                 }
                 
+                // Check borrowing eligibility so students see why they cannot borrow
+                if user.IsStudent {
+                        eligibility, err := models.CheckBorrowEligibility(user.ID, 0)
+                        if err == nil {
+                                data.Data["Eligibility"] = eligibility
+                        }
+                }
+                
                 // Check if user has an active reservation for this book
                 reservations, err := models.GetUserReservations(user.ID)
                 if err == nil {
@@ -127,6 +192,38 @@ func BookDetail(w http.ResponseWriter, r *http.Request) {
                                 }
                         }
                 }
+                
+                // Patrons who have returned the book can review it
+                myReview, err := models.GetUserBookReview(user.ID, id)
+                if err == nil {
+                        data.Data["MyReview"] = myReview
+                }
+                canReview, err := models.CanReview(user.ID, id)
+                if err == nil {
+                        data.Data["CanReview"] = canReview
+                }
+                data.Data["RetentionDays"] = config.AppConfig.Privacy.RetentionDays
+                
+                // Get the user's shelves, noting which already hold this book
+                shelves, err := models.GetUserShelves(user.ID)
+                if err == nil {
+                        data.Data["Shelves"] = shelves
+                        onShelf := make(map[int]bool)
+                        if holding, err := models.GetShelvesWithBook(user.ID, id); err == nil {
+                                for _, shelf := range holding {
+                                        onShelf[shelf.ID] = true
+                                }
+                        }
+                        data.Data["OnShelf"] = onShelf
+                }
+                
+                // Show librarians how the copies were bought
+                if user.IsLibrarian {
+                        orderLines, err := models.GetBookOrderLines(id)
+                        if err == nil {
+                                data.Data["OrderLines"] = orderLines
+                        }
+                }
         }
         
         // Render template
@@ -152,7 +249,12 @@ func AddBook(w http.ResponseWriter, r *http.Request) {
         // Process form submission
         if r.Method == http.MethodPost {
                 // Parse form
-                err := r.ParseForm()
+                err := parseBookForm(w, r)
+                if errors.Is(err, covers.ErrTooLarge) {
+                        utils.SetError(w, r, err.Error())
+                        http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+                        return
+                }
                 if err != nil {
                         utils.SetError(w, r, "Error processing form")
                         utils.RenderTemplate(w, r, "book_form.html", &utils.TemplateData{User: user})
@@ -165,9 +267,15 @@ func AddBook(w http.ResponseWriter, r *http.Request) {
                 isbn := r.FormValue("isbn")
                 publisher := r.FormValue("publisher")
                 pubYearStr := r.FormValue("publication_year")
-                category := r.FormValue("category")
                 description := r.FormValue("description")
                 quantityStr := r.FormValue("quantity")
+                replacementCostStr := r.FormValue("replacement_cost")
+                contributorsText := r.FormValue("contributors")
+                subjectNames := strings.Split(r.FormValue("subjects"), "\n")
+                callNumber := strings.TrimSpace(r.FormValue("call_number"))
+                editionStatement := strings.TrimSpace(r.FormValue("edition_statement"))
+                coverURL := strings.TrimSpace(r.FormValue("cover_url"))
+                classID, _ := strconv.Atoi(r.FormValue("class_id"))
                 
                 // Validate form
                 if title == "" || author == "" || isbn == "" || quantityStr == "" {
@@ -178,6 +286,7 @@ func AddBook(w http.ResponseWriter, r *http.Request) {
                 
                 // Convert numeric values
                 pubYear, _ := strconv.Atoi(pubYearStr)
+                replacementCost, _ := strconv.ParseFloat(replacementCostStr, 64)
                 quantity, err := strconv.Atoi(quantityStr)
                 if err != nil || quantity <= 0 {
                         utils.SetError(w, r, "Quantity must be a positive number")
@@ -185,6 +294,22 @@ func AddBook(w http.ResponseWriter, r *http.Request) {
                         return
                 }
                 
+                // Parse authors and other contributors
+                contributors, err := parseContributors(author, contributorsText)
+                if err != nil {
+                        utils.SetError(w, r, err.Error())
+                        utils.RenderTemplate(w, r, "book_form.html", &utils.TemplateData{User: user})
+                        return
+                }
+                
+                // Validate the ISBN and store it in its 13-digit form
+                isbn, err = isbnpkg.Normalize(isbn)
+                if err != nil {
+                        utils.SetError(w, r, err.Error())
+                        utils.RenderTemplate(w, r, "book_form.html", &utils.TemplateData{User: user})
+                        return
+                }
+                
                 // Check if ISBN already exists
                 exists, err := models.IsbnExists(isbn)
                 if err != nil {
@@ -205,10 +330,14 @@ func AddBook(w http.ResponseWriter, r *http.Request) {
                         ISBN:            isbn,
                         Publisher:       publisher,
                         PublicationYear: pubYear,
-                        Category:        category,
+                        CallNumber:      callNumber,
+                        EditionStatement: editionStatement,
+                        CoverURL:        coverURL,
+                        ClassID:         sql.NullInt64{Int64: int64(classID), Valid: classID > 0},
                         Description:     description,
                         Quantity:        quantity,
                         Available:       quantity,
+                        ReplacementCost: replacementCost,
                         AddedBy:         sql.NullInt64{Int64: int64(user.ID), Valid: true},
                 }
                 
@@ -220,6 +349,28 @@ func AddBook(w http.ResponseWriter, r *http.Request) {
                         return
                 }
                 
+                // Link authority records and subject headings
+                if err := models.SetBookContributors(book.ID, contributors); err != nil {
+                        utils.SetError(w, r, "Book added, but its authors could not be linked: "+err.Error())
+                        http.Redirect(w, r, "/books/"+strconv.Itoa(book.ID)+"/edit", http.StatusSeeOther)
+                        return
+                }
+                if err := models.SetBookSubjects(book.ID, subjectNames); err != nil {
+                        utils.SetError(w, r, "Book added, but its subjects could not be saved: "+err.Error())
+                        http.Redirect(w, r, "/books/"+strconv.Itoa(book.ID)+"/edit", http.StatusSeeOther)
+                        return
+                }
+                
+                // Save the uploaded cover
+                if err := updateBookCover(r, book.ID); err != nil {
+                        utils.SetError(w, r, "Book added, but its cover could not be saved: "+err.Error())
+                        http.Redirect(w, r, "/books/"+strconv.Itoa(book.ID)+"/edit", http.StatusSeeOther)
+                        return
+                }
+                
+                // Let patrons who suggested the book know it has arrived
+                matchSuggestions(book)
+                
                 // Set flash message and redirect
                 utils.SetFlash(w, r, "Book added successfully")
                 http.Redirect(w, r, "/books", http.StatusSeeOther)
@@ -230,7 +381,8 @@ func AddBook(w http.ResponseWriter, r *http.Request) {
         data := &utils.TemplateData{
                 User: user,
                 Data: map[string]interface{}{
-                        "Title": "Add Book",
+                        "Title":   "Add Book",
+                        "Classes": classOptions(),
                 },
         }
         
@@ -274,10 +426,19 @@ func EditBook(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Periodical issues are catalogued without an author
+        issue, _ := models.GetBookSerialIssue(id)
+        periodical := issue != nil
+        
         // Process form submission
         if r.Method == http.MethodPost {
                 // Parse form
-                err := r.ParseForm()
+                err := parseBookForm(w, r)
+                if errors.Is(err, covers.ErrTooLarge) {
+                        utils.SetError(w, r, err.Error())
+                        http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+                        return
+                }
                 if err != nil {
                         utils.SetError(w, r, "Error processing form")
                         data := &utils.TemplateData{
@@ -297,18 +458,25 @@ func EditBook(w http.ResponseWriter, r *http.Request) {
                 isbn := r.FormValue("isbn")
                 publisher := r.FormValue("publisher")
                 pubYearStr := r.FormValue("publication_year")
-                category := r.FormValue("category")
                 description := r.FormValue("description")
                 quantityStr := r.FormValue("quantity")
+                replacementCostStr := r.FormValue("replacement_cost")
+                contributorsText := r.FormValue("contributors")
+                subjectNames := strings.Split(r.FormValue("subjects"), "\n")
+                callNumber := strings.TrimSpace(r.FormValue("call_number"))
+                editionStatement := strings.TrimSpace(r.FormValue("edition_statement"))
+                coverURL := strings.TrimSpace(r.FormValue("cover_url"))
+                classID, _ := strconv.Atoi(r.FormValue("class_id"))
                 
                 // Validate form
-                if title == "" || author == "" || isbn == "" || quantityStr == "" {
+                if title == "" || (author == "" && !periodical) || isbn == "" || quantityStr == "" {
                         utils.SetError(w, r, "Please fill in all required fields")
                         data := &utils.TemplateData{
                                 User: user,
                                 Data: map[string]interface{}{
-                                        "Title": "Edit Book",
-                                        "Book":  book,
+                                        "Title":      "Edit Book",
+                                        "Book":       book,
+                                        "Periodical": periodical,
                                 },
                         }
                         utils.RenderTemplate(w, r, "book_form.html", data)
@@ -317,6 +485,7 @@ func EditBook(w http.ResponseWriter, r *http.Request) {
                 
                 // Convert numeric values
                 pubYear, _ := strconv.Atoi(pubYearStr)
+                replacementCost, _ := strconv.ParseFloat(replacementCostStr, 64)
                 quantity, err := strconv.Atoi(quantityStr)
                 if err != nil || quantity <= 0 {
                         utils.SetError(w, r, "Quantity must be a positive number")
@@ -331,6 +500,40 @@ func EditBook(w http.ResponseWriter, r *http.Request) {
                         return
                 }
                 
+                // Parse authors and other contributors
+                contributors, err := parseContributors(author, contributorsText)
+                if err != nil {
+                        utils.SetError(w, r, err.Error())
+                        data := &utils.TemplateData{
+                                User: user,
+                                Data: map[string]interface{}{
+                                        "Title": "Edit Book",
+                                        "Book":  book,
+                                },
+                        }
+                        utils.RenderTemplate(w, r, "book_form.html", data)
+                        return
+                }
+                
+                // Validate the ISBN and store it in its 13-digit form. An unchanged value
+                // is kept as it is, since periodical issues are catalogued under a barcode
+                // rather than an ISBN.
+                if isbn != book.ISBN {
+                        isbn, err = isbnpkg.Normalize(isbn)
+                }
+                if err != nil {
+                        utils.SetError(w, r, err.Error())
+                        data := &utils.TemplateData{
+                                User: user,
+                                Data: map[string]interface{}{
+                                        "Title": "Edit Book",
+                                        "Book":  book,
+                                },
+                        }
+                        utils.RenderTemplate(w, r, "book_form.html", data)
+                        return
+                }
+                
                 // Check if ISBN already exists and belongs to a different book
                 if isbn != book.ISBN {
                         exists, err := models.IsbnExistsExcept(isbn, id)
@@ -373,10 +576,14 @@ func EditBook(w http.ResponseWriter, r *http.Request) {
                 book.ISBN = isbn
                 book.Publisher = publisher
                 book.PublicationYear = pubYear
-                book.Category = category
+                book.CallNumber = callNumber
+                book.EditionStatement = editionStatement
+                book.CoverURL = coverURL
+                book.ClassID = sql.NullInt64{Int64: int64(classID), Valid: classID > 0}
                 book.Description = description
                 book.Quantity = quantity
                 book.Available = newAvailable
+                book.ReplacementCost = replacementCost
                 
                 // Save changes to database
                 err = book.Update()
@@ -393,6 +600,25 @@ func EditBook(w http.ResponseWriter, r *http.Request) {
                         return
                 }
                 
+                // Link authority records and subject headings
+                if err := models.SetBookContributors(book.ID, contributors); err != nil {
+                        utils.SetError(w, r, "Book updated, but its authors could not be linked: "+err.Error())
+                        http.Redirect(w, r, "/books/"+strconv.Itoa(id)+"/edit", http.StatusSeeOther)
+                        return
+                }
+                if err := models.SetBookSubjects(book.ID, subjectNames); err != nil {
+                        utils.SetError(w, r, "Book updated, but its subjects could not be saved: "+err.Error())
+                        http.Redirect(w, r, "/books/"+strconv.Itoa(id)+"/edit", http.StatusSeeOther)
+                        return
+                }
+                
+                // Replace or remove the cover
+                if err := updateBookCover(r, book.ID); err != nil {
+                        utils.SetError(w, r, "Book updated, but its cover could not be saved: "+err.Error())
+                        http.Redirect(w, r, "/books/"+strconv.Itoa(id)+"/edit", http.StatusSeeOther)
+                        return
+                }
+                
                 // Set flash message and redirect
                 utils.SetFlash(w, r, "Book updated successfully")
                 http.Redirect(w, r, "/books/"+strconv.Itoa(id), http.StatusSeeOther)
@@ -403,8 +629,10 @@ func EditBook(w http.ResponseWriter, r *http.Request) {
         data := &utils.TemplateData{
                 User: user,
                 Data: map[string]interface{}{
-                        "Title": "Edit Book",
-                        "Book":  book,
+                        "Title":      "Edit Book",
+                        "Book":       book,
+                        "Classes":    classOptions(),
+                        "Periodical": periodical,
                 },
         }
         
@@ -468,6 +696,7 @@ func DeleteBook(w http.ResponseWriter, r *http.Request) {
                 http.Redirect(w, r, "/books/"+strconv.Itoa(id), http.StatusSeeOther)
                 return
         }
+        deleteCover(r, book.CoverKey)
         
         // Set flash message and redirect
         utils.SetFlash(w, r, "Book deleted successfully")
@@ -536,6 +765,19 @@ func BorrowBook(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Check if user is allowed to borrow at all
+        eligibility, err := models.CheckBorrowEligibility(user.ID, 0)
+        if err != nil {
+                utils.SetError(w, r, "Error checking borrowing eligibility: "+err.Error())
+                http.Redirect(w, r, "/books/"+strconv.Itoa(id), http.StatusSeeOther)
+                return
+        }
+        if !eligibility.Eligible() {
+                utils.SetError(w, r, "You cannot borrow at the moment: "+eligibility.Summary())
+                http.Redirect(w, r, "/books/"+strconv.Itoa(id), http.StatusSeeOther)
+                return
+        }
+        
         // Create borrow request
         err = models.CreateBorrowRequest(user.ID, id)
         if err != nil {
@@ -565,6 +807,12 @@ func BookReport(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Download what is shown on screen
+        if format := r.URL.Query().Get("export"); export.Valid(format) {
+                exportReport(w, r, models.ReportTypeBook, format)
+                return
+        }
+        
         // Get report data
         totalBooks, err := models.CountAllBooks()
         if err != nil {
@@ -580,7 +828,16 @@ func BookReport(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
-        topBorrowedBooks, err := models.GetTopBorrowedBooks(10)
+        // Get the most borrowed books and categories over the report period
+        filter, compare := reports.ParseFilter(r.URL.Query())
+        topBooks, err := models.GetTopBooksInPeriod(filter, 10)
+        if err != nil {
+                utils.SetError(w, r, "Error generating report: "+err.Error())
+                http.Redirect(w, r, "/", http.StatusSeeOther)
+                return
+        }
+        
+        categoryLoans, err := models.GetLoansByCategory(filter)
         if err != nil {
                 utils.SetError(w, r, "Error generating report: "+err.Error())
                 http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -595,10 +852,50 @@ func BookReport(w http.ResponseWriter, r *http.Request) {
                         "TotalBooks":       totalBooks,
                         "AvailableBooks":   availableBooks,
                         "BorrowedBooks":    totalBooks - availableBooks,
-                        "TopBorrowedBooks": topBorrowedBooks,
+                        "TopBooks":         topBooks,
+                        "CategoryLoans":    categoryLoans,
+                        "Filter":           reportFilterData(filter, compare),
+                        "Exports":          export.Links(r),
+                        "ScheduleURL":      scheduleLink(r, models.ReportTypeBook),
                 },
         }
         
         // Render template
         utils.RenderTemplate(w, r, "book_report.html", data)
-}
\ No newline at end of file
+}
+
+// parseContributors reads the semicolon-separated author field and the "Role: Name"
+// lines of the contributors field from the book form
+func parseContributors(authorText, contributorsText string) ([]models.ContributorInput, error) {
+        var contributors []models.ContributorInput
+        
+        for _, name := range models.SplitAuthorNames(authorText) {
+                contributors = append(contributors, models.ContributorInput{Name: name, Role: models.RoleAuthor})
+        }
+        
+        for _, line := range strings.Split(contributorsText, "\n") {
+                line = strings.TrimSpace(line)
+                if line == "" {
+                        continue
+                }
+                
+                parts := strings.SplitN(line, ":", 2)
+                if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+                        return nil, errors.New("Contributors must be entered as \"Role: Name\", one per line")
+                }
+                
+                role := strings.ToLower(strings.TrimSpace(parts[0]))
+                if !models.IsValidContributorRole(role) {
+                        return nil, errors.New("Unknown contributor role \"" + parts[0] + "\". Use one of: " + strings.Join(models.ContributorRoles, ", "))
+                }
+                contributors = append(contributors, models.ContributorInput{Name: strings.TrimSpace(parts[1]), Role: role})
+        }
+        
+        return contributors, nil
+}
+
+// classOptions lists the classification scheme for the book form's class select
+func classOptions() []*models.Class {
+        tree, _ := models.GetClassTree()
+        return models.FlattenClassTree(tree)
+}
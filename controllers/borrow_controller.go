@@ -1,13 +1,16 @@
 package controllers
 
 import (
+        "log"
         "net/http"
         "strconv"
         "strings"
         "time"
 
+        "library-management-system/export"
         "library-management-system/middleware"
         "library-management-system/models"
+        "library-management-system/reports"
         "library-management-system/utils"
 )
 
@@ -27,10 +30,19 @@ func BorrowList(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
-        // Get query parameters
+        // Get query parameters; dashboard links name the status as type
         query := r.URL.Query()
         searchTerm := query.Get("search")
         status := query.Get("status")
+        if status == "" {
+                status = query.Get("type")
+        }
+        
+        // Download every matching record, not just the page on screen
+        if format := query.Get("export"); export.Valid(format) {
+                exportBorrowList(w, searchTerm, status, format)
+                return
+        }
         
         // Get page number from query string
         pageStr := query.Get("page")
@@ -54,6 +66,13 @@ func BorrowList(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Evaluate borrowing eligibility for pending requests
+        for _, borrow := range borrows {
+                if borrow.Status == models.BorrowStatusPending {
+                        borrow.Eligibility, _ = models.CheckBorrowEligibility(borrow.UserID, borrow.ID)
+                }
+        }
+        
         // Calculate total pages
         totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
         if totalPages < 1 {
@@ -71,6 +90,7 @@ func BorrowList(w http.ResponseWriter, r *http.Request) {
                         "page":       page,
                         "totalPages": totalPages,
                         "now":        time.Now(),
+                        "Exports":    export.Links(r),
                 },
         }
         
@@ -78,6 +98,36 @@ func BorrowList(w http.ResponseWriter, r *http.Request) {
         utils.RenderTemplate(w, r, "borrow_list.html", data)
 }
 
+// exportBorrowList downloads the borrow records matching the list's filters
+func exportBorrowList(w http.ResponseWriter, searchTerm, status, format string) {
+        borrows, _, err := models.GetBorrowsWithFilters(searchTerm, status, 0, 0)
+        if err != nil {
+                http.Error(w, "Error fetching borrow requests: "+err.Error(), http.StatusInternalServerError)
+                return
+        }
+        
+        title := "Borrow Requests"
+        if status != "" {
+                title += ", " + strings.ReplaceAll(status, "_", " ")
+        }
+        if searchTerm != "" {
+                title += `, matching "` + searchTerm + `"`
+        }
+        
+        out, err := export.Start(w, format, export.FileName("borrow-requests"), title)
+        if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+        }
+        err = reports.WriteBorrows(out, title, borrows)
+        if closeErr := out.Close(); err == nil {
+                err = closeErr
+        }
+        if err != nil {
+                log.Printf("Error exporting borrow requests: %v", err)
+        }
+}
+
 // BorrowAction handles actions on borrow requests (approve/reject)
 func BorrowAction(w http.ResponseWriter, r *http.Request) {
         // Get user from context
@@ -156,6 +206,29 @@ func BorrowAction(w http.ResponseWriter, r *http.Request) {
                         return
                 }
                 
+                // Move the due date off days the library is closed
+                requestedDueDate := dueDate
+                dueDate = models.AdjustDueDate(dueDate)
+                
+                // Re-check eligibility unless the librarian explicitly overrides it
+                borrow, err := models.GetBorrowByID(borrowID)
+                if err != nil {
+                        utils.SetError(w, r, "Borrow record not found")
+                        http.Redirect(w, r, "/borrows", http.StatusSeeOther)
+                        return
+                }
+                eligibility, err := models.CheckBorrowEligibility(borrow.UserID, borrow.ID)
+                if err != nil {
+                        utils.SetError(w, r, "Error checking borrowing eligibility: "+err.Error())
+                        http.Redirect(w, r, "/borrows", http.StatusSeeOther)
+                        return
+                }
+                if !eligibility.Eligible() && r.FormValue("override") != "on" {
+                        utils.SetError(w, r, "Patron is not eligible to borrow: "+eligibility.Summary())
+                        http.Redirect(w, r, "/borrows", http.StatusSeeOther)
+                        return
+                }
+                
                 // Approve borrow request
                 err = models.ApproveBorrow(borrowID, user.ID, dueDate)
                 if err != nil {
@@ -164,7 +237,13 @@ func BorrowAction(w http.ResponseWriter, r *http.Request) {
                         return
                 }
                 
-                utils.SetFlash(w, r, "Borrow request approved successfully")
+                if approved, err := models.GetBorrowByID(borrowID); err == nil && approved.ShortLoan() {
+                        utils.SetFlash(w, r, "Borrow request approved as a short loan for a course reserve, due "+approved.DueLabel())
+                } else if !dueDate.Equal(requestedDueDate) {
+                        utils.SetFlash(w, r, "Borrow request approved. The library is closed on "+requestedDueDate.Format("Jan 02, 2006")+", so the due date was moved to "+dueDate.Format("Jan 02, 2006"))
+                } else {
+                        utils.SetFlash(w, r, "Borrow request approved successfully")
+                }
         } else {
                 // Reject borrow request
                 err = models.RejectBorrow(borrowID, user.ID)
@@ -268,20 +347,60 @@ func BorrowHistory(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Get filters from query string
+        query := r.URL.Query()
+        filter := reports.ParseHistoryFilter(query)
+        
+        // Download every matching record, not just this page
+        if format := query.Get("export"); export.Valid(format) {
+                exportReport(w, r, models.ReportTypeHistory, format)
+                return
+        }
+        
+        // Get page number from query string
+        page, err := strconv.Atoi(query.Get("page"))
+        if err != nil || page < 1 {
+                page = 1
+        }
+        
+        // Items per page
+        const itemsPerPage = 50
+        
         // Get borrow history
-        borrows, err := models.GetBorrowHistory()
+        totalItems, err := models.CountBorrowHistory(filter)
+        if err != nil {
+                utils.SetError(w, r, "Error fetching borrow history: "+err.Error())
+                http.Redirect(w, r, "/", http.StatusSeeOther)
+                return
+        }
+        borrows, err := models.GetBorrowHistory(filter, page, itemsPerPage)
         if err != nil {
                 utils.SetError(w, r, "Error fetching borrow history: "+err.Error())
                 http.Redirect(w, r, "/", http.StatusSeeOther)
                 return
         }
         
+        // Calculate total pages
+        totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
+        if totalPages < 1 {
+                totalPages = 1
+        }
+        
         // Prepare data for template
         data := &utils.TemplateData{
                 User: user,
                 Data: map[string]interface{}{
-                        "Title":   "Borrow History",
-                        "Borrows": borrows,
+                        "Title":       "Borrow History",
+                        "Borrows":     borrows,
+                        "Search":      filter.Search,
+                        "Status":      filter.Status,
+                        "From":        query.Get("from"),
+                        "To":          query.Get("to"),
+                        "Page":        page,
+                        "TotalPages":  totalPages,
+                        "TotalItems":  totalItems,
+                        "Exports":     export.Links(r),
+                        "ScheduleURL": scheduleLink(r, models.ReportTypeHistory),
                 },
         }
         
@@ -305,6 +424,12 @@ func BorrowReport(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Download what is shown on screen
+        if format := r.URL.Query().Get("export"); export.Valid(format) {
+                exportReport(w, r, models.ReportTypeBorrow, format)
+                return
+        }
+        
         // Get active borrows
         activeBorrows, err := models.GetActiveBorrows()
         if err != nil {
@@ -321,6 +446,14 @@ func BorrowReport(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Get the latest notice each overdue borrow has been sent
+        lastNotices, err := models.GetLatestLoanNotices()
+        if err != nil {
+                utils.SetError(w, r, "Error fetching overdue notices: "+err.Error())
+                http.Redirect(w, r, "/", http.StatusSeeOther)
+                return
+        }
+        
         // Count pending requests
         pendingBorrows, err := models.GetAllPendingBorrows()
         if err != nil {
@@ -329,6 +462,36 @@ func BorrowReport(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Get circulation over the report period
+        filter, compare := reports.ParseFilter(r.URL.Query())
+        series, err := models.GetCirculationSeries(filter)
+        if err != nil {
+                utils.SetError(w, r, "Error generating report: "+err.Error())
+                http.Redirect(w, r, "/", http.StatusSeeOther)
+                return
+        }
+        totals, err := models.GetCirculationTotals(filter)
+        if err != nil {
+                utils.SetError(w, r, "Error generating report: "+err.Error())
+                http.Redirect(w, r, "/", http.StatusSeeOther)
+                return
+        }
+        
+        // Get circulation over the period it is compared with
+        var previous []*models.ReportPoint
+        var previousTotals *models.ReportPoint
+        if compare != "" {
+                previousFilter := filter.Shift(compare)
+                if previous, err = models.GetCirculationSeries(previousFilter); err == nil {
+                        previousTotals, err = models.GetCirculationTotals(previousFilter)
+                }
+                if err != nil {
+                        utils.SetError(w, r, "Error generating report: "+err.Error())
+                        http.Redirect(w, r, "/", http.StatusSeeOther)
+                        return
+                }
+        }
+        
         // Prepare data for template
         data := &utils.TemplateData{
                 User: user,
@@ -338,11 +501,20 @@ func BorrowReport(w http.ResponseWriter, r *http.Request) {
                         "ActiveCount":       len(activeBorrows),
                         "OverdueBorrows":    overdueBorrows,
                         "OverdueCount":      len(overdueBorrows),
+                        "LastNotices":       lastNotices,
                         "PendingBorrows":    pendingBorrows,
                         "PendingCount":      len(pendingBorrows),
                         "TotalActive":       len(activeBorrows),
                         "TotalOverdue":      len(overdueBorrows),
                         "OverduePercentage": calculatePercentage(len(overdueBorrows), len(activeBorrows)),
+                        "Filter":            reportFilterData(filter, compare),
+                        "Series":            series,
+                        "Totals":            totals,
+                        "PreviousTotals":    previousTotals,
+                        "CompareName":       reports.CompareName(compare),
+                        "Charts":            circulationCharts(filter, series, previous, reports.CompareName(compare)),
+                        "Exports":           export.Links(r),
+                        "ScheduleURL":       scheduleLink(r, models.ReportTypeBorrow),
                 },
         }
         
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// LibraryCalendar displays the opening hours and closures (GET) or saves the weekly hours (POST)
+func LibraryCalendar(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage the calendar
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		// Parse form
+		if err := r.ParseForm(); err != nil {
+			utils.SetError(w, r, "Error processing form")
+			http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+			return
+		}
+
+		// Save each day of the week
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			prefix := strconv.Itoa(int(day)) + "_"
+			hours := &models.OpeningHours{
+				Weekday:   day,
+				Closed:    r.FormValue(prefix+"closed") == "on",
+				OpenTime:  r.FormValue(prefix + "open"),
+				CloseTime: r.FormValue(prefix + "close"),
+			}
+			if err := models.UpdateOpeningHours(hours); err != nil {
+				utils.SetError(w, r, "Error saving hours for "+day.String()+": "+err.Error())
+				http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+				return
+			}
+		}
+
+		utils.SetFlash(w, r, "Opening hours updated")
+		http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+		return
+	}
+
+	// Get weekly hours
+	hours, err := models.GetOpeningHours()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching opening hours: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Get upcoming exceptions
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	exceptions, err := models.GetCalendarExceptions(today)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching closures: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":      "Library Calendar",
+			"Hours":      hours,
+			"Exceptions": exceptions,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "calendar.html", data)
+}
+
+// CalendarException adds a dated closure or special opening, or deletes one
+func CalendarException(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage the calendar
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to perform this action")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Delete an exception
+	path := strings.TrimPrefix(r.URL.Path, "/calendar/exceptions")
+	if strings.HasSuffix(path, "/delete") {
+		idStr := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/delete")
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			utils.SetError(w, r, "Invalid exception ID")
+			http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+			return
+		}
+		if err := models.DeleteCalendarException(id); err != nil {
+			utils.SetError(w, r, "Error removing date: "+err.Error())
+			http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Calendar date removed")
+		http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", r.FormValue("date"))
+	if err != nil {
+		utils.SetError(w, r, "Invalid date format")
+		http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+		return
+	}
+
+	exception := &models.CalendarException{
+		Date:      date,
+		Closed:    r.FormValue("type") != "open",
+		OpenTime:  r.FormValue("open"),
+		CloseTime: r.FormValue("close"),
+		Note:      r.FormValue("note"),
+	}
+	if err := models.CreateCalendarException(exception); err != nil {
+		utils.SetError(w, r, "Error saving date: "+err.Error())
+		http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, "Calendar updated for "+date.Format("Jan 02, 2006"))
+	http.Redirect(w, r, "/calendar", http.StatusSeeOther)
+}
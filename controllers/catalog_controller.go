@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// ClassificationBrowse displays the classification scheme as a browsable tree
+func ClassificationBrowse(w http.ResponseWriter, r *http.Request) {
+	// Get the current user if authenticated
+	user := middleware.GetUserFromContext(r)
+
+	// Get classification tree
+	tree, err := models.GetClassTree()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching classification: "+err.Error())
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":   "Browse by Classification",
+			"Tree":    tree,
+			"Classes": models.FlattenClassTree(tree),
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "classification.html", data)
+}
+
+// ClassAction adds a class to the scheme or deletes one:
+//
+//	POST /classification/add
+//	POST /classification/{id}/delete
+func ClassAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can change the classification scheme
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to perform this action")
+		http.Redirect(w, r, "/classification", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/classification", http.StatusSeeOther)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/classification/")
+
+	// Delete a class
+	if strings.HasSuffix(path, "/delete") {
+		id, err := strconv.Atoi(strings.TrimSuffix(path, "/delete"))
+		if err != nil || id <= 0 {
+			utils.SetError(w, r, "Invalid class ID")
+			http.Redirect(w, r, "/classification", http.StatusSeeOther)
+			return
+		}
+		if err := models.DeleteClass(id); err != nil {
+			utils.SetError(w, r, "Error deleting class: "+err.Error())
+			http.Redirect(w, r, "/classification", http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Class deleted")
+		http.Redirect(w, r, "/classification", http.StatusSeeOther)
+		return
+	}
+
+	if path != "add" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Add a class
+	parentID, _ := strconv.Atoi(r.FormValue("parent_id"))
+	class := &models.Class{
+		Notation: r.FormValue("notation"),
+		Caption:  r.FormValue("caption"),
+		ParentID: sql.NullInt64{Int64: int64(parentID), Valid: parentID > 0},
+	}
+	if err := class.Create(); err != nil {
+		utils.SetError(w, r, "Error adding class: "+err.Error())
+		http.Redirect(w, r, "/classification", http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, "Class "+class.Notation+" added")
+	http.Redirect(w, r, "/classification", http.StatusSeeOther)
+}
+
+// SubjectList displays the subject headings with the number of books under each
+func SubjectList(w http.ResponseWriter, r *http.Request) {
+	// Get the current user if authenticated
+	user := middleware.GetUserFromContext(r)
+
+	search := r.URL.Query().Get("search")
+
+	// Get subjects
+	subjects, err := models.GetSubjects(search)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching subjects: "+err.Error())
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":    "Subjects",
+			"Subjects": subjects,
+			"Search":   search,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "subject_list.html", data)
+}
+
+// SubjectAction renames, merges or deletes a subject heading across all books:
+//
+//	POST /subjects/{id}/rename  (renaming to an existing heading merges the two)
+//	POST /subjects/{id}/delete
+func SubjectAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can change subject headings
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to perform this action")
+		http.Redirect(w, r, "/subjects", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/subjects", http.StatusSeeOther)
+		return
+	}
+
+	// Extract subject ID and action from URL
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/subjects/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid subject ID")
+		http.Redirect(w, r, "/subjects", http.StatusSeeOther)
+		return
+	}
+
+	switch parts[1] {
+	case "rename":
+		err = models.RenameSubject(id, r.FormValue("name"))
+		if err == nil {
+			utils.SetFlash(w, r, "Subject renamed on all books")
+		}
+	case "delete":
+		err = models.DeleteSubject(id)
+		if err == nil {
+			utils.SetFlash(w, r, "Subject removed from all books")
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating subject: "+err.Error())
+	}
+
+	http.Redirect(w, r, "/subjects", http.StatusSeeOther)
+}
+
+// BookEditions groups a book with another edition of the same work, or removes it
+// from its work:
+//
+//	POST /books/{id}/editions         (isbn of the other edition)
+//	POST /books/{id}/editions/unlink
+func BookEditions(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can group editions
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to perform this action")
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL
+	path := strings.TrimPrefix(r.URL.Path, "/books/")
+	idStr := path[:strings.Index(path, "/")]
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid book ID")
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	// Remove the book from its work
+	if strings.HasSuffix(path, "/unlink") {
+		if err := models.DetachEdition(id); err != nil {
+			utils.SetError(w, r, "Error unlinking edition: "+err.Error())
+		} else {
+			utils.SetFlash(w, r, "Book is no longer grouped with other editions")
+		}
+		http.Redirect(w, r, "/books/"+idStr, http.StatusSeeOther)
+		return
+	}
+
+	// Find the other edition by ISBN
+	other, err := models.GetBookByBarcode(r.FormValue("isbn"))
+	if err != nil {
+		utils.SetError(w, r, err.Error())
+		http.Redirect(w, r, "/books/"+idStr, http.StatusSeeOther)
+		return
+	}
+
+	if err := models.AttachEdition(id, other.ID); err != nil {
+		utils.SetError(w, r, "Error linking edition: "+err.Error())
+	} else {
+		utils.SetFlash(w, r, "Linked "+other.Title+" as another edition of this work")
+	}
+	http.Redirect(w, r, "/books/"+idStr, http.StatusSeeOther)
+}
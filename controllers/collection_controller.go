@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management-system/export"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// collectionPeriods lists the usage periods, in months, the collection analysis offers
+var collectionPeriods = []int{6, 12, 24, 36, 60}
+
+// parseCollectionFilter reads the collection analysis filters from the query string.
+// By default it lists books not lent in the last two years.
+func parseCollectionFilter(query url.Values) models.CollectionFilter {
+	f := models.CollectionFilter{
+		Months:     24,
+		Category:   query.Get("category"),
+		IncludeNew: query.Get("include_new") == "1",
+		Sort:       query.Get("sort"),
+	}
+	if months, err := strconv.Atoi(query.Get("months")); err == nil && months > 0 && months <= 120 {
+		f.Months = months
+	}
+	if maxLoans, err := strconv.Atoi(query.Get("max_loans")); err == nil {
+		f.MaxLoans = maxLoans
+	}
+	if year, err := strconv.Atoi(query.Get("published_before")); err == nil && year > 0 {
+		f.PublishedBefore = year
+	}
+	switch f.Sort {
+	case models.CollectionSortAge, models.CollectionSortDemand, models.CollectionSortCopies:
+	default:
+		f.Sort = models.CollectionSortLoans
+	}
+	return f
+}
+
+// collectionTitle describes the collection analysis and its filters, for export headings
+func collectionTitle(f models.CollectionFilter) string {
+	title := fmt.Sprintf("Collection Analysis, %d months to %s", f.Months, utils.FormatDate(time.Now()))
+	if f.MaxLoans >= 0 {
+		title += fmt.Sprintf(", at most %d loans", f.MaxLoans)
+	}
+	if f.PublishedBefore > 0 {
+		title += fmt.Sprintf(", published %d or earlier", f.PublishedBefore)
+	}
+	if f.Category != "" {
+		title += ", " + f.Category
+	}
+	return title
+}
+
+// CollectionReport displays the collection analysis: the age of the collection and
+// the books lent least over the period, with their copies against the demand for
+// them. The listed books can be downloaded with ?export=csv, xlsx or pdf, or marked
+// for weeding.
+func CollectionReport(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can analyse the collection
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Get filters from query string
+	query := r.URL.Query()
+	filter := parseCollectionFilter(query)
+
+	// Download every listed book, not just this page
+	if format := query.Get("export"); export.Valid(format) {
+		exportCollection(w, filter, format)
+		return
+	}
+
+	// Get page number from query string
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Items per page
+	const itemsPerPage = 50
+
+	// Get the age profile and the listed books
+	bands, err := models.GetCollectionAgeProfile(filter)
+	if err != nil {
+		utils.SetError(w, r, "Error analysing collection: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	totalItems, err := models.CountCollectionItems(filter)
+	if err != nil {
+		utils.SetError(w, r, "Error analysing collection: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	items, err := models.GetCollectionItems(filter, page, itemsPerPage)
+	if err != nil {
+		utils.SetError(w, r, "Error analysing collection: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	categories, err := models.GetBookCategories()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching categories: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	candidates, err := models.CountWeedingRecords(models.WeedingStatusCandidate)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching weeding candidates: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Calculate total pages
+	totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	// Pagination keeps the filters; the page number is appended
+	pageQuery := r.URL.Query()
+	pageQuery.Del("page")
+	pageQuery.Del("export")
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":      "Collection Analysis",
+			"Filter":     filter,
+			"Since":      filter.Since(),
+			"Periods":    collectionPeriods,
+			"Categories": categories,
+			"Bands":      bands,
+			"Items":      items,
+			"Page":       page,
+			"TotalPages": totalPages,
+			"TotalItems": totalItems,
+			"PageURL":    "/collection?" + pageQuery.Encode(),
+			"Candidates": candidates,
+			"Exports":    export.Links(r),
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "collection_report.html", data)
+}
+
+// exportCollection downloads the collection analysis
+func exportCollection(w http.ResponseWriter, filter models.CollectionFilter, format string) {
+	out, err := export.Start(w, format, export.FileName("collection-analysis"), collectionTitle(filter))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = writeCollection(out, filter)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Error exporting collection analysis: %v", err)
+	}
+}
+
+// writeCollection adds the age profile and every listed book to an export
+func writeCollection(out export.Writer, filter models.CollectionFilter) error {
+	bands, err := models.GetCollectionAgeProfile(filter)
+	if err != nil {
+		return err
+	}
+	items, err := models.GetCollectionItems(filter, 1, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := out.Table("Age Profile", []string{"Published", "Titles", "Copies", "Loans", "Not Lent", "Not Lent %"}); err != nil {
+		return err
+	}
+	for _, b := range bands {
+		if err := out.Row(b.Label, b.Titles, b.Copies, b.Loans, b.Unused, float64(int(b.UnusedRate()*10+0.5))/10); err != nil {
+			return err
+		}
+	}
+
+	if err := out.Table("Books", []string{"Title", "Author", "Call Number", "Category", "Published", "Added", "Copies", "On Loan", "Loans", "Last Borrowed", "Reservations", "Loans per Copy", "Weeding"}); err != nil {
+		return err
+	}
+	for _, item := range items {
+		var published interface{}
+		if item.PublicationYear > 0 {
+			published = item.PublicationYear
+		}
+		weeding := ""
+		if item.Candidate {
+			weeding = "Candidate"
+		}
+		if err := out.Row(item.Title, item.Author, item.CallNumber, item.Category, published, item.AddedAt, item.Copies, item.OnLoan,
+			item.Loans, item.LastBorrowed, item.Holds, float64(int(item.LoansPerCopy()*100+0.5))/100, weeding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WeedingList displays the books marked for weeding and awaiting review, or the
+// history of books kept or withdrawn after review (?status=kept or withdrawn)
+func WeedingList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can weed the collection
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	query := r.URL.Query()
+	status := query.Get("status")
+	switch status {
+	case models.WeedingStatusKept, models.WeedingStatusWithdrawn:
+	default:
+		status = models.WeedingStatusCandidate
+	}
+	filter := parseCollectionFilter(query)
+
+	// Get page number from query string
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Items per page
+	const itemsPerPage = 50
+
+	// Get weeding records
+	totalItems, err := models.CountWeedingRecords(status)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching weeding records: "+err.Error())
+		http.Redirect(w, r, "/collection", http.StatusSeeOther)
+		return
+	}
+	records, err := models.GetWeedingRecords(status, filter.Months, page, itemsPerPage)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching weeding records: "+err.Error())
+		http.Redirect(w, r, "/collection", http.StatusSeeOther)
+		return
+	}
+
+	// Calculate total pages
+	totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":      "Weeding",
+			"Status":     status,
+			"Months":     filter.Months,
+			"Records":    records,
+			"Page":       page,
+			"TotalPages": totalPages,
+			"TotalItems": totalItems,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "weeding_list.html", data)
+}
+
+// formIDs returns the positive integers submitted in a form field
+func formIDs(r *http.Request, name string) []int {
+	var ids []int
+	for _, value := range r.Form[name] {
+		if id, err := strconv.Atoi(value); err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// WeedingAction marks books for weeding and records the review of candidates
+//
+//	POST /collection/weeding/mark    (book, reason)
+//	POST /collection/weeding/review  (record, action=withdraw|keep, note)
+func WeedingAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can weed the collection
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to weed the collection")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/collection/weeding", http.StatusSeeOther)
+		return
+	}
+
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/collection/weeding/"), "/") {
+	case "mark":
+		// Return to the analysis the books were chosen from
+		back := "/collection"
+		if ret := r.FormValue("return"); strings.HasPrefix(ret, "/collection?") {
+			back = ret
+		}
+
+		ids := formIDs(r, "book")
+		if len(ids) == 0 {
+			utils.SetError(w, r, "Select the books to mark for weeding")
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+		marked, err := models.MarkWeedingCandidates(ids, r.FormValue("reason"), user.ID)
+		if err != nil {
+			utils.SetError(w, r, "Error marking books for weeding: "+err.Error())
+		} else {
+			utils.SetFlash(w, r, fmt.Sprintf("%d of %d books marked for weeding", marked, len(ids)))
+		}
+		http.Redirect(w, r, back, http.StatusSeeOther)
+
+	case "review":
+		ids := formIDs(r, "record")
+		if len(ids) == 0 {
+			utils.SetError(w, r, "Select the candidates to review")
+			http.Redirect(w, r, "/collection/weeding", http.StatusSeeOther)
+			return
+		}
+
+		note := r.FormValue("note")
+		switch r.FormValue("action") {
+		case "withdraw":
+			withdrawn, skipped, err := models.WithdrawWeedingCandidates(ids, note, user.ID)
+			if err != nil {
+				utils.SetError(w, r, "Error withdrawing books: "+err.Error())
+				break
+			}
+			if len(skipped) > 0 {
+				utils.SetError(w, r, fmt.Sprintf("%d books withdrawn. Not withdrawn: %s", withdrawn, strings.Join(skipped, "; ")))
+			} else {
+				utils.SetFlash(w, r, fmt.Sprintf("%d books withdrawn from the collection", withdrawn))
+			}
+		case "keep":
+			kept, err := models.KeepWeedingCandidates(ids, note, user.ID)
+			if err != nil {
+				utils.SetError(w, r, "Error keeping books: "+err.Error())
+			} else {
+				utils.SetFlash(w, r, fmt.Sprintf("%d books kept in the collection", kept))
+			}
+		default:
+			utils.SetError(w, r, "Choose whether to withdraw or keep the selected books")
+		}
+		http.Redirect(w, r, "/collection/weeding", http.StatusSeeOther)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
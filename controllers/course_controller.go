@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// CourseList displays course reserves by course (GET) or creates a course (POST,
+// librarians only). Patrons search current courses by code, name or instructor.
+func CourseList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		if !user.IsLibrarian {
+			utils.SetError(w, r, "You do not have permission to perform this action")
+			http.Redirect(w, r, "/courses", http.StatusSeeOther)
+			return
+		}
+
+		course := &models.Course{Code: r.FormValue("code"), Name: r.FormValue("name")}
+		course.TermID, _ = strconv.Atoi(r.FormValue("term_id"))
+		if err := course.Save(); err != nil {
+			utils.SetError(w, r, "Error creating course: "+err.Error())
+			http.Redirect(w, r, "/courses", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Course "+course.Code+" created. Add its instructors and reading.")
+		http.Redirect(w, r, "/courses/"+strconv.Itoa(course.ID), http.StatusSeeOther)
+		return
+	}
+
+	search := r.URL.Query().Get("search")
+	past := user.IsLibrarian && r.URL.Query().Get("past") == "1"
+
+	// Get courses
+	courses, err := models.GetCourses(search, past)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching courses: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":   "Course Reserves",
+			"Courses": courses,
+			"Search":  search,
+			"Past":    past,
+		},
+	}
+	if user.IsLibrarian {
+		data.Data["Terms"], _ = models.GetTerms()
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "course_list.html", data)
+}
+
+// TermList displays academic terms (GET) or adds one (POST)
+func TermList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage terms
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to manage terms")
+		http.Redirect(w, r, "/courses", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		term := &models.Term{Name: r.FormValue("name")}
+		term.StartsOn, _ = time.Parse("2006-01-02", r.FormValue("starts_on"))
+		term.EndsOn, _ = time.Parse("2006-01-02", r.FormValue("ends_on"))
+		if err := models.CreateTerm(term); err != nil {
+			utils.SetError(w, r, "Error adding term: "+err.Error())
+		} else {
+			utils.SetFlash(w, r, "Term "+term.Name+" added")
+		}
+		http.Redirect(w, r, "/courses/terms", http.StatusSeeOther)
+		return
+	}
+
+	// Get terms
+	terms, err := models.GetTerms()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching terms: "+err.Error())
+		http.Redirect(w, r, "/courses", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title": "Terms",
+			"Terms": terms,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "term_list.html", data)
+}
+
+// parseCoursePath splits /courses/{id}/... into the course ID and remaining segments
+func parseCoursePath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/courses/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// CourseDetail displays a course's reading on reserve with its availability
+func CourseDetail(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Extract course ID from URL
+	id, _, err := parseCoursePath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get course
+	course, err := models.GetCourseByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Course not found")
+		http.Redirect(w, r, "/courses", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":       course.Label(),
+			"Course":      course,
+			"CanManage":   user.IsLibrarian || course.HasInstructor(user.ID),
+			"LoanPeriods": models.ReserveLoanPeriods,
+		},
+	}
+	if user.IsLibrarian {
+		data.Data["Terms"], _ = models.GetTerms()
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "course_detail.html", data)
+}
+
+// CourseAction manages a course and its reserves. Librarians manage courses and their
+// instructors; instructors manage the reserves of their own courses.
+//
+//	POST /courses/{id}/edit                           (code, name, term_id)
+//	POST /courses/{id}/delete
+//	POST /courses/{id}/instructors                    (email)
+//	POST /courses/{id}/instructors/{userID}/delete
+//	POST /courses/{id}/reserves                       (isbn, loan_hours, notes)
+//	POST /courses/{id}/reserves/{reserveID}/release
+func CourseAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract course ID and action from URL
+	id, parts, err := parseCoursePath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	courseURL := "/courses/" + strconv.Itoa(id)
+
+	// Get course
+	course, err := models.GetCourseByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Course not found")
+		http.Redirect(w, r, "/courses", http.StatusSeeOther)
+		return
+	}
+
+	// Reserves are managed by the course's instructors too; everything else needs a librarian
+	allowed := user.IsLibrarian || (parts[0] == "reserves" && course.HasInstructor(user.ID))
+	if !allowed {
+		utils.SetError(w, r, "You do not have permission to manage this course")
+		http.Redirect(w, r, courseURL, http.StatusSeeOther)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, courseURL, http.StatusSeeOther)
+		return
+	}
+
+	var message string
+	switch {
+	case len(parts) == 1 && parts[0] == "edit":
+		course.Code = r.FormValue("code")
+		course.Name = r.FormValue("name")
+		course.TermID, _ = strconv.Atoi(r.FormValue("term_id"))
+		err = course.Save()
+		message = "Course updated"
+	case len(parts) == 1 && parts[0] == "delete":
+		if err := models.DeleteCourse(id); err != nil {
+			utils.SetError(w, r, "Error deleting course: "+err.Error())
+			http.Redirect(w, r, courseURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Course deleted and its reserves released")
+		http.Redirect(w, r, "/courses", http.StatusSeeOther)
+		return
+	case len(parts) == 1 && parts[0] == "instructors":
+		err = models.AddCourseInstructor(id, r.FormValue("email"))
+		message = "Instructor added"
+	case len(parts) == 3 && parts[0] == "instructors" && parts[2] == "delete":
+		userID, convErr := strconv.Atoi(parts[1])
+		if convErr != nil || userID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		err = models.RemoveCourseInstructor(id, userID)
+		message = "Instructor removed"
+	case len(parts) == 1 && parts[0] == "reserves":
+		var book *models.Book
+		book, err = models.GetBookByBarcode(r.FormValue("isbn"))
+		if err == nil {
+			loanHours, _ := strconv.Atoi(r.FormValue("loan_hours"))
+			err = models.AddCourseReserve(id, book.ID, loanHours, r.FormValue("notes"), user.ID)
+		}
+		message = "Book placed on reserve"
+	case len(parts) == 3 && parts[0] == "reserves" && parts[2] == "release":
+		reserveID, convErr := strconv.Atoi(parts[1])
+		if convErr != nil || reserveID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		err = models.ReleaseCourseReserve(id, reserveID)
+		message = "Book released from reserve; new loans use the normal loan period"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating course: "+err.Error())
+		http.Redirect(w, r, courseURL, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, message)
+	http.Redirect(w, r, courseURL, http.StatusSeeOther)
+}
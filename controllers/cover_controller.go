@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"library-management-system/config"
+	"library-management-system/covers"
+	"library-management-system/models"
+)
+
+// formOverhead is the room left for the book form's text fields on top of the cover
+// size limit
+const formOverhead = 1 << 20
+
+// parseBookForm parses the book form, which is multipart when it carries a cover
+// upload. The request body is capped just above the cover size limit so an oversized
+// upload is refused before it is read into memory.
+func parseBookForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.AppConfig.Covers.MaxBytes+formOverhead))
+
+	err := r.ParseMultipartForm(formOverhead)
+	if errors.Is(err, http.ErrNotMultipart) {
+		return r.ParseForm()
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return covers.ErrTooLarge
+	}
+	return err
+}
+
+// updateBookCover applies the cover fields of a parsed book form: a new upload
+// replaces the current cover and the "remove_cover" checkbox clears it. The replaced
+// thumbnails are deleted from storage.
+func updateBookCover(r *http.Request, bookID int) error {
+	key := ""
+	file, _, err := r.FormFile("cover")
+	switch {
+	case err == nil:
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		key, err = covers.Save(r.Context(), data)
+		if err != nil {
+			return err
+		}
+	case errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart):
+		// No upload: only act on an explicit removal
+		if r.FormValue("remove_cover") == "" {
+			return nil
+		}
+	default:
+		return err
+	}
+
+	oldKey, err := models.SetBookCover(bookID, key)
+	if err != nil {
+		covers.Delete(r.Context(), key)
+		return err
+	}
+	deleteCover(r, oldKey)
+	return nil
+}
+
+// deleteCover removes a cover's thumbnails; a failure only leaves unused files behind
+func deleteCover(r *http.Request, key string) {
+	if err := covers.Delete(r.Context(), key); err != nil {
+		log.Printf("Error deleting cover %s: %v", key, err)
+	}
+}
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"library-management-system/config"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// DeskCheckout displays the circulation desk checkout screen (GET) or lends a scanned item (POST)
+func DeskCheckout(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can use the circulation desk
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		// Parse form
+		if err := r.ParseForm(); err != nil {
+			utils.SetError(w, r, "Error processing form")
+			http.Redirect(w, r, "/desk/checkout", http.StatusSeeOther)
+			return
+		}
+
+		card := r.FormValue("card")
+		barcode := r.FormValue("barcode")
+		redirectURL := "/desk/checkout?card=" + url.QueryEscape(card)
+
+		// Look up patron and item
+		patron, err := models.GetUserByCardNumber(card)
+		if err != nil {
+			utils.SetError(w, r, err.Error())
+			http.Redirect(w, r, "/desk/checkout", http.StatusSeeOther)
+			return
+		}
+		book, err := models.GetBookByBarcode(barcode)
+		if err != nil {
+			utils.SetError(w, r, err.Error())
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+
+		// Check eligibility, ignoring a pending request the checkout will fulfil
+		excludeID := 0
+		if pending, err := models.GetBorrowByUserAndBook(patron.ID, book.ID, models.BorrowStatusPending); err == nil && pending != nil {
+			excludeID = pending.ID
+		}
+		eligibility, err := models.CheckBorrowEligibility(patron.ID, excludeID)
+		if err != nil {
+			utils.SetError(w, r, "Error checking borrowing eligibility: "+err.Error())
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+		if !eligibility.Eligible() && r.FormValue("override") != "on" {
+			utils.SetError(w, r, "Patron is not eligible to borrow: "+eligibility.Summary())
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+
+		// Lend the book
+		dueDate := models.AdjustDueDate(time.Now().AddDate(0, 0, config.AppConfig.Circulation.LoanPeriodDays))
+		borrowID, err := models.CheckoutBook(patron.ID, book.ID, user.ID, dueDate)
+		if err != nil {
+			utils.SetError(w, r, "Error checking out "+book.Title+": "+err.Error())
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+
+		// Course reserve items go out on short loan, so report the due date recorded
+		dueLabel := dueDate.Format("Jan 02, 2006")
+		if borrow, err := models.GetBorrowByID(borrowID); err == nil {
+			dueLabel = borrow.DueLabel()
+		}
+		utils.SetFlash(w, r, fmt.Sprintf("Checked out \"%s\", due %s", book.Title, dueLabel))
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title": "Circulation Desk - Checkout",
+		},
+	}
+
+	// Load the patron if a card has been scanned
+	if card := r.URL.Query().Get("card"); card != "" {
+		data.Data["Card"] = card
+		patron, err := models.GetUserByCardNumber(card)
+		if err != nil {
+			utils.SetError(w, r, err.Error())
+			http.Redirect(w, r, "/desk/checkout", http.StatusSeeOther)
+			return
+		}
+		data.Data["Patron"] = patron
+		data.Data["Eligibility"], _ = models.CheckBorrowEligibility(patron.ID, 0)
+		data.Data["Balance"], _ = models.GetOutstandingBalance(patron.ID)
+		data.Data["ActiveBorrows"], _ = models.GetActiveUserBorrows(patron.ID)
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "desk_checkout.html", data)
+}
+
+// DeskCheckin displays the circulation desk check-in screen (GET) or returns a scanned item (POST)
+func DeskCheckin(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can use the circulation desk
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		// Parse form
+		if err := r.ParseForm(); err != nil {
+			utils.SetError(w, r, "Error processing form")
+			http.Redirect(w, r, "/desk/checkin", http.StatusSeeOther)
+			return
+		}
+
+		// Look up item
+		barcode := r.FormValue("barcode")
+		book, err := models.GetBookByBarcode(barcode)
+		if err != nil {
+			utils.SetError(w, r, err.Error())
+			http.Redirect(w, r, "/desk/checkin", http.StatusSeeOther)
+			return
+		}
+
+		// Return the loan, asking whose copy it is when several are out
+		borrowID, _ := strconv.Atoi(r.FormValue("borrow_id"))
+		result, err := models.CheckinBook(book.ID, borrowID)
+		if err == models.ErrSeveralLoans {
+			http.Redirect(w, r, "/desk/checkin?barcode="+url.QueryEscape(barcode), http.StatusSeeOther)
+			return
+		}
+		if err != nil && result == nil {
+			utils.SetError(w, r, "Error checking in "+book.Title+": "+err.Error())
+			http.Redirect(w, r, "/desk/checkin", http.StatusSeeOther)
+			return
+		}
+
+		message := fmt.Sprintf("Checked in \"%s\"", book.Title)
+		if result.Recovered {
+			message += ". It had been recorded as lost or claimed returned; the loan has been reversed"
+		}
+		if result.Fine > 0 {
+			message += fmt.Sprintf(". Overdue fine charged: %.2f", result.Fine)
+		}
+		if err != nil {
+			message += ". Reservation processing failed: " + err.Error()
+		}
+		utils.SetFlash(w, r, message)
+
+		// Redirect so a page refresh cannot check in another copy
+		redirectURL := "/desk/checkin?last=" + strconv.Itoa(result.Borrow.ID)
+		if result.HoldFor != nil {
+			redirectURL += "&hold=" + strconv.Itoa(result.HoldFor.ID)
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title": "Circulation Desk - Check-in",
+		},
+	}
+
+	// Show the item that was just checked in
+	query := r.URL.Query()
+	if lastID, err := strconv.Atoi(query.Get("last")); err == nil {
+		data.Data["LastBorrow"], _ = models.GetBorrowByID(lastID)
+	}
+	if holdID, err := strconv.Atoi(query.Get("hold")); err == nil {
+		data.Data["HoldFor"], _ = models.GetUserByID(holdID)
+	}
+
+	// List the open loans of a scanned book out to several patrons
+	if barcode := query.Get("barcode"); barcode != "" {
+		book, err := models.GetBookByBarcode(barcode)
+		if err != nil {
+			utils.SetError(w, r, err.Error())
+			http.Redirect(w, r, "/desk/checkin", http.StatusSeeOther)
+			return
+		}
+		data.Data["Barcode"] = barcode
+		data.Data["ChooseBook"] = book
+		data.Data["OpenLoans"], _ = models.GetOpenLoansForBook(book.ID)
+	}
+
+	// Show today's check-ins
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	data.Data["Checkins"], _ = models.GetCheckinsSince(today)
+
+	// Render template
+	utils.RenderTemplate(w, r, "desk_checkin.html", data)
+}
@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/isbn"
+	"library-management-system/metadata"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// enrichField is one row of the autofill comparison: the value on the form or in
+// the catalog next to the value from the metadata provider
+type enrichField struct {
+	Name      string
+	Label     string
+	Current   string
+	Fetched   string
+	Multiline bool
+}
+
+// Differs reports whether the provider offers a different, non-empty value
+func (f enrichField) Differs() bool {
+	return f.Fetched != "" && strings.TrimSpace(f.Fetched) != strings.TrimSpace(f.Current)
+}
+
+// Suggested reports whether the field should be accepted by default: the provider
+// fills a blank
+func (f enrichField) Suggested() bool {
+	return f.Differs() && strings.TrimSpace(f.Current) == ""
+}
+
+// enrichFieldNames lists the fields a provider can fill, in form order
+var enrichFieldNames = []struct {
+	Name      string
+	Label     string
+	Multiline bool
+}{
+	{"title", "Title", false},
+	{"author", "Author(s)", false},
+	{"publisher", "Publisher", false},
+	{"publication_year", "Publication Year", false},
+	{"subjects", "Subject Headings", true},
+	{"description", "Description", true},
+	{"cover_url", "Cover Image", false},
+}
+
+// bookFieldValues returns a book's values for the fields a provider can fill
+func bookFieldValues(book *models.Book) map[string]string {
+	values := map[string]string{
+		"title":       book.Title,
+		"author":      book.Author,
+		"publisher":   book.Publisher,
+		"subjects":    book.SubjectsText(),
+		"description": book.Description,
+		"cover_url":   book.CoverURL,
+	}
+	if book.PublicationYear > 0 {
+		values["publication_year"] = strconv.Itoa(book.PublicationYear)
+	}
+	return values
+}
+
+// recordFieldValues returns a provider record's values for the same fields
+func recordFieldValues(record *metadata.Record) map[string]string {
+	values := map[string]string{
+		"title":       record.Title,
+		"author":      strings.Join(record.Authors, "; "),
+		"publisher":   record.Publisher,
+		"subjects":    strings.Join(record.Subjects, "\n"),
+		"description": record.Description,
+		"cover_url":   record.CoverURL,
+	}
+	if record.Year > 0 {
+		values["publication_year"] = strconv.Itoa(record.Year)
+	}
+	return values
+}
+
+// EnrichBook looks a book up by ISBN in the configured metadata providers and shows
+// the result next to the current values (GET), then applies the fields the librarian
+// accepts (POST). For a new book the accepted values prefill the add form; for an
+// existing book they are saved straight away.
+func EnrichBook(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can catalog books
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to edit books")
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	// The book being edited, if any
+	var book *models.Book
+	backURL := "/books/add"
+	if id, _ := strconv.Atoi(r.FormValue("book")); id > 0 {
+		var err error
+		book, err = models.GetBookByID(id)
+		if err != nil {
+			utils.SetError(w, r, "Book not found")
+			http.Redirect(w, r, "/books", http.StatusSeeOther)
+			return
+		}
+		backURL = "/books/" + strconv.Itoa(id) + "/edit"
+	}
+
+	if r.Method == http.MethodPost {
+		applyEnrichment(w, r, user, book)
+		return
+	}
+
+	// Validate the ISBN before asking the providers
+	code, err := isbn.Normalize(r.FormValue("isbn"))
+	if err != nil {
+		utils.SetError(w, r, err.Error())
+		http.Redirect(w, r, backURL, http.StatusSeeOther)
+		return
+	}
+
+	record, err := metadata.Lookup(r.Context(), code)
+	if err != nil {
+		utils.SetError(w, r, "Could not autofill "+code+": "+err.Error())
+		http.Redirect(w, r, backURL, http.StatusSeeOther)
+		return
+	}
+
+	// Compare with the saved book, or with whatever was typed on the add form
+	var current map[string]string
+	if book != nil {
+		current = bookFieldValues(book)
+	} else {
+		current = make(map[string]string)
+		for _, f := range enrichFieldNames {
+			current[f.Name] = r.FormValue(f.Name)
+		}
+	}
+	fetched := recordFieldValues(record)
+
+	var fields []enrichField
+	for _, f := range enrichFieldNames {
+		fields = append(fields, enrichField{
+			Name:      f.Name,
+			Label:     f.Label,
+			Current:   current[f.Name],
+			Fetched:   fetched[f.Name],
+			Multiline: f.Multiline,
+		})
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":   "Autofill from ISBN",
+			"Book":    book,
+			"ISBN":    code,
+			"Source":  record.Source,
+			"Fields":  fields,
+			"BackURL": backURL,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "book_enrich.html", data)
+}
+
+// applyEnrichment applies the accepted fields from the comparison form
+func applyEnrichment(w http.ResponseWriter, r *http.Request, user *models.User, book *models.Book) {
+	accepted := make(map[string]bool)
+	for _, name := range r.Form["accept"] {
+		accepted[name] = true
+	}
+
+	// Start from the saved book, or from the values carried over from the add form
+	isNew := book == nil
+	if isNew {
+		book = &models.Book{Quantity: 1}
+		book.Title = r.FormValue("current_title")
+		book.Author = r.FormValue("current_author")
+		book.Publisher = r.FormValue("current_publisher")
+		book.PublicationYear, _ = strconv.Atoi(r.FormValue("current_publication_year"))
+		book.Description = r.FormValue("current_description")
+		book.CoverURL = r.FormValue("current_cover_url")
+		for _, name := range strings.Split(r.FormValue("current_subjects"), "\n") {
+			if name = strings.TrimSpace(name); name != "" {
+				book.Subjects = append(book.Subjects, &models.Subject{Name: name})
+			}
+		}
+		book.ISBN = r.FormValue("isbn")
+	}
+
+	value := func(name string) string {
+		return strings.TrimSpace(r.FormValue("fetched_" + name))
+	}
+	if accepted["title"] {
+		book.Title = value("title")
+	}
+	if accepted["author"] {
+		book.Author = value("author")
+	}
+	if accepted["publisher"] {
+		book.Publisher = value("publisher")
+	}
+	if accepted["publication_year"] {
+		book.PublicationYear, _ = strconv.Atoi(value("publication_year"))
+	}
+	if accepted["description"] {
+		book.Description = value("description")
+	}
+	if accepted["cover_url"] {
+		book.CoverURL = value("cover_url")
+	}
+	var subjectNames []string
+	if accepted["subjects"] {
+		subjectNames = strings.Split(value("subjects"), "\n")
+		book.Subjects = nil
+		for _, name := range subjectNames {
+			if name = strings.TrimSpace(name); name != "" {
+				book.Subjects = append(book.Subjects, &models.Subject{Name: name})
+			}
+		}
+	}
+
+	// A new book goes back to the add form, prefilled, for the librarian to finish
+	if isNew {
+		data := &utils.TemplateData{
+			User: user,
+			Data: map[string]interface{}{
+				"Title":   "Add Book",
+				"Book":    book,
+				"Classes": classOptions(),
+			},
+		}
+		utils.RenderTemplate(w, r, "book_form.html", data)
+		return
+	}
+
+	editURL := "/books/" + strconv.Itoa(book.ID) + "/edit"
+	if len(accepted) == 0 {
+		utils.SetFlash(w, r, "No changes were accepted")
+		http.Redirect(w, r, editURL, http.StatusSeeOther)
+		return
+	}
+
+	// Save the accepted fields
+	if err := book.Update(); err != nil {
+		utils.SetError(w, r, "Error updating book: "+err.Error())
+		http.Redirect(w, r, editURL, http.StatusSeeOther)
+		return
+	}
+	if accepted["author"] {
+		contributors, err := parseContributors(book.Author, book.OtherContributorsText())
+		if err == nil {
+			err = models.SetBookContributors(book.ID, contributors)
+		}
+		if err != nil {
+			utils.SetError(w, r, "Book updated, but its authors could not be linked: "+err.Error())
+			http.Redirect(w, r, editURL, http.StatusSeeOther)
+			return
+		}
+	}
+	if accepted["subjects"] {
+		if err := models.SetBookSubjects(book.ID, subjectNames); err != nil {
+			utils.SetError(w, r, "Book updated, but its subjects could not be saved: "+err.Error())
+			http.Redirect(w, r, editURL, http.StatusSeeOther)
+			return
+		}
+	}
+
+	utils.SetFlash(w, r, "Accepted "+strconv.Itoa(len(accepted))+" field(s) from "+r.FormValue("source"))
+	http.Redirect(w, r, editURL, http.StatusSeeOther)
+}
@@ -48,6 +48,21 @@ func Home(w http.ResponseWriter, r *http.Request) {
 				data.Data["OverdueBooks"] = overdueBooks
 				data.Data["OverdueCount"] = len(overdueBooks)
 			}
+
+			reviewCount, err := models.CountReviewsAwaitingModeration()
+			if err == nil {
+				data.Data["ReviewCount"] = reviewCount
+			}
+
+			failingReports, err := models.CountFailingScheduledReports()
+			if err == nil {
+				data.Data["FailingReports"] = failingReports
+			}
+
+			lettersToPrint, err := models.CountOverdueNotices(models.NoticeFilter{Status: models.NoticeStatusPrint})
+			if err == nil {
+				data.Data["LettersToPrint"] = lettersToPrint
+			}
 		} else {
 			// For students, get active and pending borrows
 			activeBorrows, err := models.GetActiveUserBorrows(user.ID)
@@ -61,6 +76,18 @@ func Home(w http.ResponseWriter, r *http.Request) {
 				data.Data["PendingBorrows"] = pendingBorrows
 				data.Data["PendingCount"] = len(pendingBorrows)
 			}
+
+			// Suggestions that have reached the catalog since the student last looked
+			addedSuggestions, err := models.GetUnseenAddedSuggestions(user.ID)
+			if err == nil {
+				data.Data["AddedSuggestions"] = addedSuggestions
+			}
+
+			// Books related to what the student has borrowed
+			recommended, err := models.GetRecommendedForUser(user.ID, 6)
+			if err == nil {
+				data.Data["RecommendedBooks"] = recommended
+			}
 		}
 	}
 
@@ -68,9 +95,7 @@ func Home(w http.ResponseWriter, r *http.Request) {
 	utils.RenderTemplate(w, r, "home.html", data)
 }
 
-// getRecentBooks returns a list of recent books
+// getRecentBooks returns the most recently added books
 func getRecentBooks(limit int) ([]*models.Book, error) {
-	// In a real application, we would implement this to fetch recent books
-	// For now, let's just return all books
-	return models.GetAllBooks()
+	return models.GetRecentBooks(limit)
 }
\ No newline at end of file
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// ResolveLoan records an active loan as lost, damaged or claimed returned, or reverses
+// a lost or claimed-returned loan when the item is found
+func ResolveLoan(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can resolve loans
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to perform this action")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/borrows", http.StatusSeeOther)
+		return
+	}
+
+	// Return to the page the action was taken from
+	redirectURL := "/borrows"
+	if r.FormValue("from") == "claims" {
+		redirectURL = "/borrows/claims"
+	}
+
+	// Extract borrow ID from URL
+	idStr := strings.TrimPrefix(r.URL.Path, "/borrows/")
+	idStr = strings.TrimSuffix(idStr, "/resolve")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid borrow ID")
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	note := r.FormValue("note")
+
+	// Perform action
+	var message string
+	switch r.FormValue("action") {
+	case "lost":
+		err = models.MarkBorrowLost(id, note)
+		message = "Loan marked as lost and replacement cost charged"
+	case "damaged":
+		amount := -1.0
+		if amountStr := r.FormValue("amount"); amountStr != "" {
+			amount, err = strconv.ParseFloat(amountStr, 64)
+			if err != nil || amount < 0 {
+				utils.SetError(w, r, "Damage charge must be a positive number")
+				http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+				return
+			}
+		}
+		err = models.MarkBorrowDamaged(id, note, amount)
+		message = "Loan marked as damaged"
+	case "claimed":
+		err = models.MarkClaimedReturned(id, note)
+		message = "Loan marked as claimed returned"
+	case "found":
+		err = models.RecoverBorrow(id)
+		message = "Item found and loan closed as returned"
+	default:
+		utils.SetError(w, r, "Invalid action")
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating loan: "+err.Error())
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, message)
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// ClaimsList displays loans under claims-returned investigation
+func ClaimsList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can view claims
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Get claims
+	claims, err := models.GetClaimedReturnedBorrows()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching claims: "+err.Error())
+		http.Redirect(w, r, "/borrows", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":  "Claims Returned",
+			"Claims": claims,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "borrow_claims.html", data)
+}
@@ -0,0 +1,399 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/mail"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/notices"
+	"library-management-system/utils"
+)
+
+// noticeRunHistory is how many past runs the notices page shows
+const noticeRunHistory = 10
+
+// NoticeList displays the notices sent about loans, newest first, and the recent
+// runs of the notice job. It can be limited to letters waiting to be printed
+// (?status=to_print), a loan (?borrow=) or a patron (?user=).
+func NoticeList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage notices
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Get filters from query string
+	query := r.URL.Query()
+	filter := models.NoticeFilter{Status: query.Get("status")}
+	filter.BorrowID, _ = strconv.Atoi(query.Get("borrow"))
+	filter.UserID, _ = strconv.Atoi(query.Get("user"))
+
+	// Get page number from query string
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Items per page
+	const itemsPerPage = 50
+
+	// Get notices and runs
+	totalItems, err := models.CountOverdueNotices(filter)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching notices: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	list, err := models.GetOverdueNotices(filter, page, itemsPerPage)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching notices: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	toPrint, err := models.CountOverdueNotices(models.NoticeFilter{Status: models.NoticeStatusPrint})
+	if err != nil {
+		utils.SetError(w, r, "Error fetching notices: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	runs, err := models.GetNoticeRuns(noticeRunHistory)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching notice runs: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Calculate total pages
+	totalPages := (totalItems + itemsPerPage - 1) / itemsPerPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":          "Overdue Notices",
+			"Notices":        list,
+			"Status":         filter.Status,
+			"BorrowID":       filter.BorrowID,
+			"UserID":         filter.UserID,
+			"Page":           page,
+			"TotalPages":     totalPages,
+			"TotalItems":     totalItems,
+			"ToPrint":        toPrint,
+			"Runs":           runs,
+			"MailConfigured": mail.Configured(),
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "notice_list.html", data)
+}
+
+// NoticeLetters displays letters for printing (GET) or records them as printed
+// (POST). It shows every letter waiting to be printed, or the notices picked
+// with ?id= to print again.
+func NoticeLetters(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage notices
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/notices", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		printed, err := models.MarkNoticesPrinted(formIDs(r, "notice"))
+		if err != nil {
+			utils.SetError(w, r, "Error recording printed letters: "+err.Error())
+			http.Redirect(w, r, "/notices/letters", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, fmt.Sprintf("%d letters marked as printed", printed))
+		http.Redirect(w, r, "/notices", http.StatusSeeOther)
+		return
+	}
+
+	// Get letters
+	var letters []*models.OverdueNotice
+	var err error
+	reprint := formIDs(r, "id")
+	if len(reprint) > 0 {
+		letters, err = models.GetOverdueNoticesByID(reprint)
+	} else {
+		letters, err = models.GetOverdueNotices(models.NoticeFilter{Status: models.NoticeStatusPrint}, 1, 0)
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error fetching letters: "+err.Error())
+		http.Redirect(w, r, "/notices", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":   "Notice Letters",
+			"Letters": letters,
+			"Reprint": len(reprint) > 0,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "notice_letters.html", data)
+}
+
+// RunNotices generates the notices due now, without waiting for the daily run
+func RunNotices(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage notices
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to send notices")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	run, err := notices.RunNow()
+	if err != nil {
+		utils.SetError(w, r, "Error sending notices: "+err.Error())
+	} else {
+		utils.SetFlash(w, r, fmt.Sprintf("Notices sent: %d emailed, %d letters to print, %d patrons blocked", run.Emailed, run.Letters, run.Blocked))
+	}
+	http.Redirect(w, r, "/notices", http.StatusSeeOther)
+}
+
+// noticeStageFromForm reads a notice stage's settings from the form. The form asks
+// for the days before or after the due date separately.
+func noticeStageFromForm(r *http.Request, s *models.NoticeStage) {
+	s.Name = r.FormValue("name")
+	s.Days, _ = strconv.Atoi(r.FormValue("days"))
+	if r.FormValue("when") == "before" {
+		s.Days = -s.Days
+	}
+	s.Channel = r.FormValue("channel")
+	s.Subject = r.FormValue("subject")
+	s.Body = r.FormValue("body")
+	s.BlockPatron = r.FormValue("block_patron") == "1"
+}
+
+// NoticeStages displays the notice stages (GET) or adds one (POST)
+func NoticeStages(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage notices
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		stage := &models.NoticeStage{}
+		noticeStageFromForm(r, stage)
+		if err := stage.Save(); err != nil {
+			utils.SetError(w, r, "Error adding notice stage: "+err.Error())
+			http.Redirect(w, r, "/notices/stages", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Notice stage "+stage.Name+" added")
+		http.Redirect(w, r, "/notices/stages", http.StatusSeeOther)
+		return
+	}
+
+	// Get notice stages
+	stages, err := models.GetNoticeStages()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching notice stages: "+err.Error())
+		http.Redirect(w, r, "/notices", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":    "Notice Stages",
+			"Stages":   stages,
+			"Channels": models.NoticeChannels,
+			"Stage":    &models.NoticeStage{Channel: models.NoticeChannelEmail, Days: 1},
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "notice_stages.html", data)
+}
+
+// parseNoticeStagePath extracts the notice stage ID and any action path after it
+func parseNoticeStagePath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/notices/stages/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// NoticeStageDetail displays the form editing a notice stage, with its notice as a
+// patron would receive it
+func NoticeStageDetail(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage notices
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Extract notice stage ID from URL
+	id, _, err := parseNoticeStagePath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get notice stage
+	stage, err := models.GetNoticeStageByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Notice stage not found")
+		http.Redirect(w, r, "/notices/stages", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":    stage.Name,
+			"Stage":    stage,
+			"Channels": models.NoticeChannels,
+		},
+	}
+	data.Data["PreviewSubject"], data.Data["PreviewBody"], _ = stage.Render(models.SampleNoticeData())
+
+	// Render template
+	utils.RenderTemplate(w, r, "notice_stage.html", data)
+}
+
+// NoticeStageAction manages a notice stage
+//
+//	POST /notices/stages/{id}/edit     (name, when, days, channel, subject, body, block_patron)
+//	POST /notices/stages/{id}/enable
+//	POST /notices/stages/{id}/disable
+//	POST /notices/stages/{id}/delete
+func NoticeStageAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage notices
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to manage notices")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract notice stage ID and action from URL
+	id, parts, err := parseNoticeStagePath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+	stageURL := "/notices/stages/" + strconv.Itoa(id)
+
+	// Get notice stage
+	stage, err := models.GetNoticeStageByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Notice stage not found")
+		http.Redirect(w, r, "/notices/stages", http.StatusSeeOther)
+		return
+	}
+
+	var failure, message string
+	switch parts[0] {
+	case "edit":
+		noticeStageFromForm(r, stage)
+		err = stage.Save()
+		failure, message = "Error updating notice stage: ", "Notice stage updated"
+	case "enable", "disable":
+		err = models.SetNoticeStageActive(id, parts[0] == "enable")
+		failure, message = "Error updating notice stage: ", "Notice stage "+parts[0]+"d"
+		stageURL = "/notices/stages"
+	case "delete":
+		if err := models.DeleteNoticeStage(id); err != nil {
+			utils.SetError(w, r, "Error deleting notice stage: "+err.Error())
+			http.Redirect(w, r, stageURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Notice stage "+stage.Name+" deleted")
+		http.Redirect(w, r, "/notices/stages", http.StatusSeeOther)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		utils.SetError(w, r, failure+err.Error())
+	} else {
+		utils.SetFlash(w, r, message)
+	}
+	http.Redirect(w, r, stageURL, http.StatusSeeOther)
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// ExportMyData lets a user download all personal data the library holds about them
+func ExportMyData(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	writeUserDataExport(w, r, user.ID, "/profile")
+}
+
+// ExportUserData lets a librarian download a patron's data on their behalf
+func ExportUserData(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can export other users' data
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to export user data")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Extract user ID from URL
+	idStr := strings.TrimPrefix(r.URL.Path, "/users/export/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid user ID")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	writeUserDataExport(w, r, id, "/users")
+}
+
+// writeUserDataExport streams a user's data as JSON or as a ZIP archive of JSON files
+func writeUserDataExport(w http.ResponseWriter, r *http.Request, userID int, errorRedirect string) {
+	export, err := models.GetUserDataExport(userID)
+	if err != nil {
+		utils.SetError(w, r, "Error exporting data: "+err.Error())
+		http.Redirect(w, r, errorRedirect, http.StatusSeeOther)
+		return
+	}
+
+	baseName := fmt.Sprintf("library-data-user-%d-%s", userID, export.ExportedAt.Format("20060102"))
+
+	if r.URL.Query().Get("format") == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+baseName+".zip\"")
+
+		archive := zip.NewWriter(w)
+		files := map[string]interface{}{
+			"profile.json":      export.Profile,
+			"borrows.json":      export.Borrows,
+			"reservations.json": export.Reservations,
+			"shelves.json":      export.Shelves,
+			"reviews.json":      export.Reviews,
+		}
+		for name, content := range files {
+			f, err := archive.Create(name)
+			if err != nil {
+				return
+			}
+			encoder := json.NewEncoder(f)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(content); err != nil {
+				return
+			}
+		}
+		archive.Close()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+baseName+".json\"")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(export)
+}
+
+// UpdatePrivacySettings stores the user's borrow history retention preference
+func UpdatePrivacySettings(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
+	// Save preference
+	keepHistory := r.FormValue("keep_history") == "on"
+	if err := user.SetKeepHistory(keepHistory); err != nil {
+		utils.SetError(w, r, "Error updating privacy settings: "+err.Error())
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
+	if keepHistory {
+		utils.SetFlash(w, r, "Your borrow history will be kept")
+	} else {
+		utils.SetFlash(w, r, "Your borrow history will be anonymized after the retention period")
+	}
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+// EraseUser displays the erase confirmation (GET) or erases a user's personal data (POST)
+func EraseUser(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can erase users
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to erase users")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Extract user ID from URL
+	idStr := strings.TrimPrefix(r.URL.Path, "/users/erase/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid user ID")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// Get user to erase
+	eraseUser, err := models.GetUserByID(id)
+	if err != nil {
+		utils.SetError(w, r, "User not found")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// Prevent erasing self
+	if eraseUser.ID == user.ID {
+		utils.SetError(w, r, "You cannot erase your own account")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		// Parse form
+		if err := r.ParseForm(); err != nil {
+			utils.SetError(w, r, "Error processing form")
+			http.Redirect(w, r, "/users/erase/"+idStr, http.StatusSeeOther)
+			return
+		}
+
+		// Require the email address to be typed as confirmation
+		if !strings.EqualFold(strings.TrimSpace(r.FormValue("confirm_email")), eraseUser.Email) {
+			utils.SetError(w, r, "Confirmation email does not match")
+			http.Redirect(w, r, "/users/erase/"+idStr, http.StatusSeeOther)
+			return
+		}
+
+		// Erase user
+		err = models.EraseUser(eraseUser.ID)
+		if err != nil {
+			utils.SetError(w, r, "Error erasing user: "+err.Error())
+			http.Redirect(w, r, "/users/erase/"+idStr, http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "User erased and borrow history anonymized")
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+
+	// Prepare data for template
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":     "Erase User",
+			"EraseUser": eraseUser,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "user_erase.html", data)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"library-management-system/charts"
+	"library-management-system/export"
+	"library-management-system/models"
+	"library-management-system/reports"
+)
+
+// reportFilterData returns the template data shared by the circulation reports' filter forms
+func reportFilterData(f models.ReportFilter, compare string) map[string]interface{} {
+	categories, _ := models.GetBookCategories()
+	return map[string]interface{}{
+		"From":        f.From.Format(reports.DateFormat),
+		"To":          f.To.Format(reports.DateFormat),
+		"Interval":    f.Interval,
+		"Category":    f.Category,
+		"PatronType":  f.PatronType,
+		"Compare":     compare,
+		"Categories":  categories,
+		"PatronTypes": reports.PatronTypes,
+		"Intervals":   []string{models.ReportIntervalDay, models.ReportIntervalWeek, models.ReportIntervalMonth},
+	}
+}
+
+// circulationCharts draws the trend charts for a report's series, with the series of
+// the period it is compared with, if any, dashed alongside. The comparison is matched
+// point by point, so a shorter comparison period leaves the end of its line off.
+func circulationCharts(f models.ReportFilter, points, previous []*models.ReportPoint, compareName string) []template.HTML {
+	labels := make([]string, len(points))
+	for i, p := range points {
+		labels[i] = p.Label(f.Interval)
+	}
+
+	figures := []struct {
+		title   string
+		percent bool
+		value   func(p *models.ReportPoint) float64
+	}{
+		{"Loans", false, func(p *models.ReportPoint) float64 { return float64(p.Loans) }},
+		{"Returns", false, func(p *models.ReportPoint) float64 { return float64(p.Returns) }},
+		{"Overdue Rate", true, (*models.ReportPoint).OverdueRate},
+		{"Unique Borrowers", false, func(p *models.ReportPoint) float64 { return float64(p.UniqueBorrowers) }},
+	}
+
+	var rendered []template.HTML
+	for _, fig := range figures {
+		chart := charts.Chart{Title: fig.title, Labels: labels, Percent: fig.percent}
+		chart.Series = append(chart.Series, charts.Series{Name: "This period", Values: reportValues(points, fig.value)})
+		if previous != nil {
+			chart.Series = append(chart.Series, charts.Series{Name: compareName, Values: reportValues(previous, fig.value), Dashed: true})
+		}
+		rendered = append(rendered, charts.Line(chart))
+	}
+	return rendered
+}
+
+// reportValues picks one figure from each point of a series
+func reportValues(points []*models.ReportPoint, value func(p *models.ReportPoint) float64) []float64 {
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = value(p)
+	}
+	return values
+}
+
+// exportReport downloads a report with the filters on screen. Once rows are being
+// streamed the response can no longer be replaced with an error page, so errors from
+// writing the report are only logged.
+func exportReport(w http.ResponseWriter, r *http.Request, reportType, format string) {
+	query := r.URL.Query()
+	out, err := export.Start(w, format, export.FileName(reports.BaseName(reportType)), reports.Title(reportType, query))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = reports.Write(out, reportType, query)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Error exporting %s: %v", reports.Name(reportType), err)
+	}
+}
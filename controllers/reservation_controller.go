@@ -43,8 +43,9 @@ func ReserveBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Reserve the book
-	err = models.ReserveBook(user.ID, id)
+	// Reserve the book, optionally accepting any edition of the same work
+	anyEdition := r.FormValue("any_edition") != ""
+	err = models.ReserveBook(user.ID, id, anyEdition)
 	if err != nil {
 		utils.SetError(w, r, "Error reserving book: "+err.Error())
 		http.Redirect(w, r, "/books/"+idStr, http.StatusSeeOther)
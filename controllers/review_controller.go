@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/config"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// ReviewBook saves the user's rating and review of a book they have returned
+func ReviewBook(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL
+	idStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/books/"), "/review")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		utils.SetError(w, r, "Invalid book ID")
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+	bookURL := "/books/" + idStr
+
+	rating, _ := strconv.Atoi(r.FormValue("rating"))
+	review, err := models.SaveReview(user.ID, id, rating, r.FormValue("body"))
+	if err != nil {
+		utils.SetError(w, r, "Error saving review: "+err.Error())
+		http.Redirect(w, r, bookURL, http.StatusSeeOther)
+		return
+	}
+
+	switch review.Status {
+	case models.ReviewStatusPending:
+		utils.SetFlash(w, r, "Thank you. Your review will appear once a librarian has approved it.")
+	case models.ReviewStatusHidden:
+		utils.SetFlash(w, r, "Your review has been saved but remains hidden by the library.")
+	default:
+		utils.SetFlash(w, r, "Thank you for your review")
+	}
+	http.Redirect(w, r, bookURL, http.StatusSeeOther)
+}
+
+// ReviewList displays the review moderation queue
+func ReviewList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can moderate reviews
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to moderate reviews")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	queue := r.URL.Query().Get("queue")
+
+	// Get reviews
+	reviews, err := models.GetReviewQueue(queue)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching reviews: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":           "Review Moderation",
+			"Reviews":         reviews,
+			"Queue":           queue,
+			"Queues":          []string{models.ReviewQueuePending, models.ReviewQueueFlagged, models.ReviewQueueHidden},
+			"RequireApproval": config.AppConfig.Reviews.RequireApproval,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "review_list.html", data)
+}
+
+// ReviewAction acts on a review. Anyone can report a review; its author can delete it;
+// librarians moderate it.
+//
+//	POST /reviews/{id}/report     (reason)
+//	POST /reviews/{id}/delete
+//	POST /reviews/{id}/approve
+//	POST /reviews/{id}/hide
+//	POST /reviews/{id}/flag
+func ReviewAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract review ID and action from URL
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/reviews/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 || len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get review
+	review, err := models.GetReviewByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Review not found")
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+	bookURL := "/books/" + strconv.Itoa(review.BookID)
+
+	switch parts[1] {
+	case "report":
+		if err := models.ReportReview(id, user.ID, r.FormValue("reason")); err != nil {
+			utils.SetError(w, r, "Error reporting review: "+err.Error())
+		} else {
+			utils.SetFlash(w, r, "Thank you. A librarian will look at this review.")
+		}
+		http.Redirect(w, r, bookURL, http.StatusSeeOther)
+	case "delete":
+		if review.UserID != user.ID && !user.IsLibrarian {
+			utils.SetError(w, r, "You can only delete your own reviews")
+			http.Redirect(w, r, bookURL, http.StatusSeeOther)
+			return
+		}
+		if err := models.DeleteReview(id); err != nil {
+			utils.SetError(w, r, "Error deleting review: "+err.Error())
+		} else {
+			utils.SetFlash(w, r, "Review deleted")
+		}
+		http.Redirect(w, r, bookURL, http.StatusSeeOther)
+	case "approve", "hide", "flag":
+		if !user.IsLibrarian {
+			utils.SetError(w, r, "You do not have permission to moderate reviews")
+			http.Redirect(w, r, bookURL, http.StatusSeeOther)
+			return
+		}
+		if err := models.ModerateReview(id, parts[1], user.ID); err != nil {
+			utils.SetError(w, r, "Error moderating review: "+err.Error())
+		} else {
+			utils.SetFlash(w, r, "Review by "+review.UserName+" updated")
+		}
+		http.Redirect(w, r, "/reviews?queue="+r.FormValue("queue"), http.StatusSeeOther)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"library-management-system/export"
+	"library-management-system/mail"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/reports"
+	"library-management-system/utils"
+)
+
+// scheduleRunHistory is how many past runs a scheduled report's page shows
+const scheduleRunHistory = 50
+
+// scheduleLink returns the address of the form scheduling the report on screen with
+// its current filters. The period is left out; each run reports on the period
+// before it.
+func scheduleLink(r *http.Request, reportType string) string {
+	params := r.URL.Query()
+	for _, key := range []string{"from", "to", "page", "export"} {
+		params.Del(key)
+	}
+	link := url.Values{"type": {reportType}, "params": {params.Encode()}}
+	return "/reports/schedules?" + link.Encode()
+}
+
+// scheduleFormData returns the choices shared by the schedule forms
+func scheduleFormData(data map[string]interface{}) {
+	data["ReportTypes"] = models.ReportTypes
+	data["Formats"] = export.Formats
+	data["Frequencies"] = models.ScheduleFrequencies
+	data["MailConfigured"] = mail.Configured()
+}
+
+// scheduleFromForm reads a scheduled report's settings from the form
+func scheduleFromForm(r *http.Request, s *models.ScheduledReport) {
+	s.Name = r.FormValue("name")
+	s.Format = r.FormValue("format")
+	s.Frequency = r.FormValue("frequency")
+	s.Delivery = r.FormValue("delivery")
+	s.Recipients = r.FormValue("recipients")
+}
+
+// ScheduleList displays the scheduled reports (GET) or schedules a report (POST).
+// The report pages link here with their filters to prefill the form.
+func ScheduleList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can schedule reports
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to schedule reports")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		s := &models.ScheduledReport{
+			ReportType: r.FormValue("report_type"),
+			Params:     r.FormValue("params"),
+			CreatedBy:  sql.NullInt64{Int64: int64(user.ID), Valid: true},
+		}
+		scheduleFromForm(r, s)
+		if err := s.Save(); err != nil {
+			utils.SetError(w, r, "Error scheduling report: "+err.Error())
+			http.Redirect(w, r, "/reports/schedules?"+url.Values{"type": {s.ReportType}, "params": {s.Params}}.Encode(), http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Report "+s.Name+" scheduled. It will first be sent on "+s.NextRunAt.Format("Jan 02, 2006 15:04"))
+		http.Redirect(w, r, "/reports/schedules/"+strconv.Itoa(s.ID), http.StatusSeeOther)
+		return
+	}
+
+	// Get scheduled reports
+	schedules, err := models.GetScheduledReports()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching scheduled reports: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// A new report starts from the filters it was scheduled with
+	query := r.URL.Query()
+	reportType := query.Get("type")
+	if reportType == "" {
+		reportType = models.ReportTypeBorrow
+	}
+	params := query.Get("params")
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":      "Scheduled Reports",
+			"Schedules":  schedules,
+			"ReportType": reportType,
+			"Params":     params,
+			"Filters":    reports.DescribeFilters(reportType, params),
+			"Preset":     query.Get("type") != "",
+		},
+	}
+	scheduleFormData(data.Data)
+
+	// Render template
+	utils.RenderTemplate(w, r, "schedule_list.html", data)
+}
+
+// parseSchedulePath extracts the scheduled report ID and any action path after it
+func parseSchedulePath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/reports/schedules/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// ScheduleDetail displays a scheduled report's settings and run history
+func ScheduleDetail(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can schedule reports
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to schedule reports")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Extract scheduled report ID from URL
+	id, _, err := parseSchedulePath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get scheduled report
+	schedule, err := models.GetScheduledReportByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Scheduled report not found")
+		http.Redirect(w, r, "/reports/schedules", http.StatusSeeOther)
+		return
+	}
+
+	// Get run history
+	runs, err := models.GetReportRuns(id, scheduleRunHistory)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching run history: "+err.Error())
+		http.Redirect(w, r, "/reports/schedules", http.StatusSeeOther)
+		return
+	}
+
+	// Link to the report on screen as the next run will see it
+	from, to := schedule.Period(schedule.NextRunAt)
+	query, _ := url.ParseQuery(schedule.Params)
+	query.Set("from", from.Format(reports.DateFormat))
+	query.Set("to", to.Format(reports.DateFormat))
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":      schedule.Name,
+			"Schedule":   schedule,
+			"Runs":       runs,
+			"ReportName": reports.Name(schedule.ReportType),
+			"ReportURL":  reports.Path(schedule.ReportType) + "?" + query.Encode(),
+			"Filters":    reports.DescribeFilters(schedule.ReportType, schedule.Params),
+		},
+	}
+	scheduleFormData(data.Data)
+
+	// Render template
+	utils.RenderTemplate(w, r, "schedule_detail.html", data)
+}
+
+// ScheduleAction manages a scheduled report
+//
+//	POST /reports/schedules/{id}/edit     (name, format, frequency, delivery, recipients)
+//	POST /reports/schedules/{id}/run
+//	POST /reports/schedules/{id}/pause
+//	POST /reports/schedules/{id}/resume
+//	POST /reports/schedules/{id}/delete
+func ScheduleAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can schedule reports
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to schedule reports")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract scheduled report ID and action from URL
+	id, parts, err := parseSchedulePath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+	scheduleURL := "/reports/schedules/" + strconv.Itoa(id)
+
+	// Get scheduled report
+	schedule, err := models.GetScheduledReportByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Scheduled report not found")
+		http.Redirect(w, r, "/reports/schedules", http.StatusSeeOther)
+		return
+	}
+
+	var failure, message string
+	switch parts[0] {
+	case "edit":
+		scheduleFromForm(r, schedule)
+		err = schedule.Save()
+		failure, message = "Error updating scheduled report: ", "Scheduled report updated"
+	case "run":
+		err = reports.Deliver(schedule, true)
+		failure, message = "Error sending report: ", "Report sent to "+schedule.Recipients
+	case "pause", "resume":
+		err = models.SetScheduledReportActive(id, parts[0] == "resume")
+		failure, message = "Error updating scheduled report: ", "Scheduled report "+parts[0]+"d"
+	case "delete":
+		if err := models.DeleteScheduledReport(id); err != nil {
+			utils.SetError(w, r, "Error deleting scheduled report: "+err.Error())
+			http.Redirect(w, r, scheduleURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Scheduled report "+schedule.Name+" deleted")
+		http.Redirect(w, r, "/reports/schedules", http.StatusSeeOther)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		utils.SetError(w, r, failure+err.Error())
+	} else {
+		utils.SetFlash(w, r, message)
+	}
+	http.Redirect(w, r, scheduleURL, http.StatusSeeOther)
+}
@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// serialFromForm copies the serial form fields onto a serial
+func serialFromForm(r *http.Request, s *models.Serial) {
+	s.Title = r.FormValue("title")
+	s.ISSN = r.FormValue("issn")
+	s.Publisher = strings.TrimSpace(r.FormValue("publisher"))
+	s.Frequency = r.FormValue("frequency")
+	s.IssuesPerVolume, _ = strconv.Atoi(r.FormValue("issues_per_volume"))
+	s.StartDate, _ = time.Parse("2006-01-02", r.FormValue("start_date"))
+	s.StartVolume, _ = strconv.Atoi(r.FormValue("start_volume"))
+	s.StartNumber, _ = strconv.Atoi(r.FormValue("start_number"))
+	s.CopiesPerIssue, _ = strconv.Atoi(r.FormValue("copies_per_issue"))
+	s.ClaimAfterDays, _ = strconv.Atoi(r.FormValue("claim_after_days"))
+	s.CallNumber = strings.TrimSpace(r.FormValue("call_number"))
+	s.Active = r.FormValue("active") != ""
+}
+
+// SerialList displays all serial subscriptions (GET) or creates a new one (POST,
+// librarians only)
+func SerialList(w http.ResponseWriter, r *http.Request) {
+	// Get the current user if authenticated
+	user := middleware.GetUserFromContext(r)
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		if user == nil || !user.IsLibrarian {
+			utils.SetError(w, r, "You do not have permission to perform this action")
+			http.Redirect(w, r, "/serials", http.StatusSeeOther)
+			return
+		}
+
+		serial := &models.Serial{}
+		serialFromForm(r, serial)
+		serial.Active = true
+		if err := serial.Create(); err != nil {
+			utils.SetError(w, r, "Error creating serial: "+err.Error())
+			http.Redirect(w, r, "/serials", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Serial created and upcoming issues predicted")
+		http.Redirect(w, r, "/serials/"+strconv.Itoa(serial.ID), http.StatusSeeOther)
+		return
+	}
+
+	search := r.URL.Query().Get("search")
+
+	// Get serials
+	serials, err := models.GetAllSerials(search)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching serials: "+err.Error())
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":       "Serials",
+			"Serials":     serials,
+			"Search":      search,
+			"Frequencies": models.Frequencies,
+			"Today":       time.Now().Format("2006-01-02"),
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "serial_list.html", data)
+}
+
+// parseSerialPath splits /serials/{id}/... into the serial ID and remaining segments
+func parseSerialPath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/serials/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// SerialDetail displays a serial with its issues. Received issues link to their
+// catalog records, where they are borrowed and reserved like any book.
+func SerialDetail(w http.ResponseWriter, r *http.Request) {
+	// Get the current user if authenticated
+	user := middleware.GetUserFromContext(r)
+
+	// Extract serial ID from URL
+	id, _, err := parseSerialPath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get serial
+	serial, err := models.GetSerialByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Serial not found")
+		http.Redirect(w, r, "/serials", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":       serial.Title,
+			"Serial":      serial,
+			"Frequencies": models.Frequencies,
+			"Today":       time.Now().Format("2006-01-02"),
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "serial_detail.html", data)
+}
+
+// SerialAction edits a serial and checks in its issues:
+//
+//	POST /serials/{id}/edit
+//	POST /serials/{id}/delete
+//	POST /serials/{id}/predict
+//	POST /serials/{id}/issues                      (volume, number, expected_date)
+//	POST /serials/{id}/issues/{issueID}/checkin    (copies)
+//	POST /serials/{id}/issues/{issueID}/missing
+func SerialAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can manage serials
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to manage serials")
+		http.Redirect(w, r, "/serials", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract serial ID and action from URL
+	id, parts, err := parseSerialPath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	serialURL := "/serials/" + strconv.Itoa(id)
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, serialURL, http.StatusSeeOther)
+		return
+	}
+
+	var message string
+	switch {
+	case len(parts) == 1 && parts[0] == "edit":
+		var serial *models.Serial
+		serial, err = models.GetSerialByID(id)
+		if err == nil {
+			serialFromForm(r, serial)
+			err = serial.Update()
+		}
+		message = "Serial updated"
+	case len(parts) == 1 && parts[0] == "delete":
+		if err := models.DeleteSerial(id); err != nil {
+			utils.SetError(w, r, "Error deleting serial: "+err.Error())
+			http.Redirect(w, r, serialURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Serial deleted")
+		http.Redirect(w, r, "/serials", http.StatusSeeOther)
+		return
+	case len(parts) == 1 && parts[0] == "predict":
+		var added int
+		added, err = models.PredictSerialIssues(id)
+		message = strconv.Itoa(added) + " issue(s) predicted"
+	case len(parts) == 1 && parts[0] == "issues":
+		volume, _ := strconv.Atoi(r.FormValue("volume"))
+		number, _ := strconv.Atoi(r.FormValue("number"))
+		expected, _ := time.Parse("2006-01-02", r.FormValue("expected_date"))
+		err = models.AddSerialIssue(id, volume, number, expected)
+		message = "Issue added"
+	case len(parts) == 3 && parts[0] == "issues":
+		issueID, convErr := strconv.Atoi(parts[1])
+		if convErr != nil || issueID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		switch parts[2] {
+		case "checkin":
+			copies, _ := strconv.Atoi(r.FormValue("copies"))
+			var bookID int
+			bookID, err = models.CheckInIssue(issueID, copies, user.ID)
+			if err == nil {
+				utils.SetFlash(w, r, "Issue checked in and added to the catalog for lending")
+				http.Redirect(w, r, "/books/"+strconv.Itoa(bookID), http.StatusSeeOther)
+				return
+			}
+		case "missing":
+			err = models.MarkIssueMissing(issueID)
+			message = "Issue marked as missing"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating serial: "+err.Error())
+		http.Redirect(w, r, serialURL, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, message)
+	http.Redirect(w, r, serialURL, http.StatusSeeOther)
+}
+
+// SerialClaims lists late issues due a claim (GET) and marks the selected issues as
+// claimed, showing the claim letters to send (POST)
+func SerialClaims(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can claim issues
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to claim issues")
+		http.Redirect(w, r, "/serials", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title": "Serial Claims",
+		},
+	}
+
+	if r.Method == http.MethodPost {
+		// Parse form
+		if err := r.ParseForm(); err != nil {
+			utils.SetError(w, r, "Error processing form")
+			http.Redirect(w, r, "/serials/claims", http.StatusSeeOther)
+			return
+		}
+
+		var ids []int
+		for _, value := range r.Form["issue"] {
+			if id, err := strconv.Atoi(value); err == nil && id > 0 {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			utils.SetError(w, r, "Select the issues to claim")
+			http.Redirect(w, r, "/serials/claims", http.StatusSeeOther)
+			return
+		}
+
+		claims, err := models.ClaimIssues(ids)
+		if err != nil {
+			utils.SetError(w, r, "Error claiming issues: "+err.Error())
+			http.Redirect(w, r, "/serials/claims", http.StatusSeeOther)
+			return
+		}
+
+		// Show the letters for the issues just claimed
+		data.Data["Letters"] = claims
+		utils.RenderTemplate(w, r, "serial_claims.html", data)
+		return
+	}
+
+	// Get late issues
+	claims, err := models.GetClaimableIssues()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching late issues: "+err.Error())
+		http.Redirect(w, r, "/serials", http.StatusSeeOther)
+		return
+	}
+	data.Data["Claims"] = claims
+
+	// Render template
+	utils.RenderTemplate(w, r, "serial_claims.html", data)
+}
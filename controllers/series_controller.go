@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// SeriesList displays all series (GET) or creates a new one (POST, librarians only)
+func SeriesList(w http.ResponseWriter, r *http.Request) {
+	// Get the current user if authenticated
+	user := middleware.GetUserFromContext(r)
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		if user == nil || !user.IsLibrarian {
+			utils.SetError(w, r, "You do not have permission to perform this action")
+			http.Redirect(w, r, "/series", http.StatusSeeOther)
+			return
+		}
+
+		series := &models.Series{
+			Name:        r.FormValue("name"),
+			Description: r.FormValue("description"),
+			IsSet:       r.FormValue("is_set") != "",
+		}
+		if err := series.Create(); err != nil {
+			utils.SetError(w, r, "Error creating series: "+err.Error())
+			http.Redirect(w, r, "/series", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Series created")
+		http.Redirect(w, r, "/series/"+strconv.Itoa(series.ID), http.StatusSeeOther)
+		return
+	}
+
+	search := r.URL.Query().Get("search")
+
+	// Get series
+	list, err := models.GetAllSeries(search)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching series: "+err.Error())
+		http.Redirect(w, r, "/books", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":  "Series",
+			"Series": list,
+			"Search": search,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "series_list.html", data)
+}
+
+// parseSeriesPath splits /series/{id}/... into the series ID and remaining segments
+func parseSeriesPath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/series/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// SeriesDetail displays a series with its volumes in reading order
+func SeriesDetail(w http.ResponseWriter, r *http.Request) {
+	// Get the current user if authenticated
+	user := middleware.GetUserFromContext(r)
+
+	// Extract series ID from URL
+	id, _, err := parseSeriesPath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get series
+	series, err := models.GetSeriesByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Series not found")
+		http.Redirect(w, r, "/series", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":  series.Name,
+			"Series": series,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "series_detail.html", data)
+}
+
+// SeriesAction edits a series and its volumes:
+//
+//	POST /series/{id}/edit
+//	POST /series/{id}/delete
+//	POST /series/{id}/volumes                  (isbn, position, volume)
+//	POST /series/{id}/volumes/{bookID}/delete
+func SeriesAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can edit series
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to edit series")
+		http.Redirect(w, r, "/series", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract series ID and action from URL
+	id, parts, err := parseSeriesPath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	seriesURL := "/series/" + strconv.Itoa(id)
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+		return
+	}
+
+	var message string
+	switch {
+	case len(parts) == 1 && parts[0] == "edit":
+		var series *models.Series
+		series, err = models.GetSeriesByID(id)
+		if err == nil {
+			series.Name = r.FormValue("name")
+			series.Description = r.FormValue("description")
+			series.IsSet = r.FormValue("is_set") != ""
+			err = series.Update()
+		}
+		message = "Series updated"
+	case len(parts) == 1 && parts[0] == "delete":
+		if err := models.DeleteSeries(id); err != nil {
+			utils.SetError(w, r, "Error deleting series: "+err.Error())
+			http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Series deleted")
+		http.Redirect(w, r, "/series", http.StatusSeeOther)
+		return
+	case len(parts) == 1 && parts[0] == "volumes":
+		var book *models.Book
+		book, err = models.GetBookByBarcode(r.FormValue("isbn"))
+		if err == nil {
+			position, _ := strconv.Atoi(r.FormValue("position"))
+			err = models.AddSeriesVolume(id, book.ID, position, r.FormValue("volume"))
+		}
+		message = "Volume saved"
+	case len(parts) == 3 && parts[0] == "volumes" && parts[2] == "delete":
+		bookID, convErr := strconv.Atoi(parts[1])
+		if convErr != nil || bookID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		err = models.RemoveSeriesVolume(id, bookID)
+		message = "Volume removed from the series"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating series: "+err.Error())
+		http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, message)
+	http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+}
+
+// SeriesCirculation borrows or reserves every volume of a multi-volume set at once:
+//
+//	POST /series/{id}/borrow
+//	POST /series/{id}/reserve
+func SeriesCirculation(w http.ResponseWriter, r *http.Request) {
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Extract series ID and action from URL
+	id, parts, err := parseSeriesPath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+	seriesURL := "/series/" + strconv.Itoa(id)
+
+	// Only students can borrow or reserve books
+	if !user.IsStudent {
+		utils.SetError(w, r, "Only students can borrow or reserve books")
+		http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+		return
+	}
+
+	switch parts[0] {
+	case "borrow":
+		// Check if user is allowed to borrow at all
+		eligibility, err := models.CheckBorrowEligibility(user.ID, 0)
+		if err != nil {
+			utils.SetError(w, r, "Error checking borrowing eligibility: "+err.Error())
+			http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+			return
+		}
+		if !eligibility.Eligible() {
+			utils.SetError(w, r, "You cannot borrow at the moment: "+eligibility.Summary())
+			http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+			return
+		}
+
+		count, err := models.BorrowSet(user.ID, id)
+		if err != nil {
+			utils.SetError(w, r, "Error borrowing set: "+err.Error())
+			http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Borrow requests submitted for all "+strconv.Itoa(count)+" volumes")
+	case "reserve":
+		count, err := models.ReserveSet(user.ID, id)
+		if err != nil {
+			utils.SetError(w, r, "Error reserving set: "+err.Error())
+			http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Reserved "+strconv.Itoa(count)+" volume(s) that are out. Any volumes on the shelf can be borrowed now.")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, seriesURL, http.StatusSeeOther)
+}
@@ -0,0 +1,356 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"library-management-system/export"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// ShelfList displays the user's shelves and other patrons' public reading lists (GET)
+// or creates a new list (POST)
+func ShelfList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		shelf := &models.Shelf{
+			UserID:      user.ID,
+			Name:        r.FormValue("name"),
+			Description: r.FormValue("description"),
+			Public:      r.FormValue("public") != "",
+		}
+		if err := shelf.Save(); err != nil {
+			utils.SetError(w, r, "Error creating list: "+err.Error())
+			http.Redirect(w, r, "/shelves", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "List created. Add books to it from their catalog pages.")
+		http.Redirect(w, r, "/shelves/"+strconv.Itoa(shelf.ID), http.StatusSeeOther)
+		return
+	}
+
+	// Get the user's own shelves
+	shelves, err := models.GetUserShelves(user.ID)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching shelves: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Get public lists shared by others
+	search := r.URL.Query().Get("search")
+	public, err := models.GetPublicShelves(search, user.ID)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching reading lists: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":         "My Shelves",
+			"Shelves":       shelves,
+			"PublicShelves": public,
+			"Search":        search,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "shelf_list.html", data)
+}
+
+// parseShelfPath splits /shelves/{id}/... into the shelf ID and remaining segments
+func parseShelfPath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/shelves/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// getVisibleShelf loads the shelf in the request path if the user may see it: its
+// owner always can, anyone else only once it is public
+func getVisibleShelf(r *http.Request, user *models.User) (*models.Shelf, []string, bool) {
+	id, parts, err := parseShelfPath(r.URL.Path)
+	if err != nil || id <= 0 {
+		return nil, nil, false
+	}
+
+	shelf, err := models.GetShelfByID(id)
+	if err != nil {
+		return nil, nil, false
+	}
+	if !shelf.Public && (user == nil || user.ID != shelf.UserID) {
+		return nil, nil, false
+	}
+	return shelf, parts, true
+}
+
+// ShelfDetail displays a shelf with its books and notes. Public lists can be viewed
+// without logging in.
+func ShelfDetail(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+
+	shelf, _, ok := getVisibleShelf(r, user)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":   shelf.Name,
+			"Shelf":   shelf,
+			"IsOwner": user != nil && user.ID == shelf.UserID,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "shelf_detail.html", data)
+}
+
+// ExportShelf downloads a shelf as CSV or, with format=bibtex, as a BibTeX file
+func ExportShelf(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+
+	shelf, _, ok := getVisibleShelf(r, user)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	baseName := fmt.Sprintf("reading-list-%d", shelf.ID)
+
+	if r.URL.Query().Get("format") == "bibtex" {
+		w.Header().Set("Content-Type", "application/x-bibtex; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+baseName+".bib\"")
+		writeShelfBibTeX(w, shelf)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+baseName+".csv\"")
+	out := csv.NewWriter(w)
+	out.Write([]string{"Title", "Author", "ISBN", "Publisher", "Year", "Call Number", "Note", "Added"})
+	for _, item := range shelf.Items {
+		year := ""
+		if item.Book.PublicationYear > 0 {
+			year = strconv.Itoa(item.Book.PublicationYear)
+		}
+		out.Write([]string{export.CSVSafe(item.Book.Title), export.CSVSafe(item.Book.Author), item.Book.ISBN,
+			export.CSVSafe(item.Book.Publisher), year, export.CSVSafe(item.Book.CallNumber),
+			export.CSVSafe(item.Note), item.AddedAt.Format("2006-01-02")})
+	}
+	out.Flush()
+}
+
+// writeShelfBibTeX writes one @book entry per book on the shelf, keyed by the first
+// author's surname, the year and the book ID so keys stay unique within the file
+func writeShelfBibTeX(w http.ResponseWriter, shelf *models.Shelf) {
+	fmt.Fprintf(w, "%% %s\n", shelf.Name)
+	for _, item := range shelf.Items {
+		b := item.Book
+		key := bibtexSurname(b.Author)
+		if b.PublicationYear > 0 {
+			key += strconv.Itoa(b.PublicationYear)
+		}
+		fmt.Fprintf(w, "\n@book{%s-%d,\n", key, b.ID)
+		fields := [][2]string{
+			{"title", b.Title},
+			{"author", b.Author},
+			{"publisher", b.Publisher},
+			{"isbn", b.ISBN},
+			{"note", item.Note},
+		}
+		if b.PublicationYear > 0 {
+			fields = append(fields, [2]string{"year", strconv.Itoa(b.PublicationYear)})
+		}
+		for _, f := range fields {
+			if f[1] != "" {
+				fmt.Fprintf(w, "  %s = {%s},\n", f[0], bibtexEscape(f[1]))
+			}
+		}
+		fmt.Fprint(w, "}\n")
+	}
+}
+
+// bibtexSurname returns the lowercase ASCII surname of the first author, accepting
+// both "First Last" and "Last, First"
+func bibtexSurname(author string) string {
+	first := author
+	for _, sep := range []string{" and ", ";", "&"} {
+		if i := strings.Index(first, sep); i >= 0 {
+			first = first[:i]
+		}
+	}
+	surname := strings.TrimSpace(first)
+	if i := strings.Index(surname, ","); i >= 0 {
+		surname = surname[:i]
+	} else if fields := strings.Fields(surname); len(fields) > 0 {
+		surname = fields[len(fields)-1]
+	}
+
+	var key strings.Builder
+	for _, c := range strings.ToLower(surname) {
+		if c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			key.WriteRune(c)
+		}
+	}
+	if key.Len() == 0 {
+		return "book"
+	}
+	return key.String()
+}
+
+// bibtexEscape escapes the characters LaTeX treats specially in a field value
+var bibtexEscape = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+).Replace
+
+// ShelfAction manages a shelf. Owners edit their shelves; anyone who can see a list
+// can reserve from it.
+//
+//	POST /shelves/{id}/edit                      (name, description, public)
+//	POST /shelves/{id}/delete
+//	POST /shelves/{id}/items                     (book_id, note)
+//	POST /shelves/{id}/items/{bookID}/note       (note)
+//	POST /shelves/{id}/items/{bookID}/remove
+//	POST /shelves/{id}/items/{bookID}/reserve
+//	POST /shelves/{id}/reserve
+func ShelfAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shelf, parts, ok := getVisibleShelf(r, user)
+	if !ok || len(parts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	shelfURL := "/shelves/" + strconv.Itoa(shelf.ID)
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, shelfURL, http.StatusSeeOther)
+		return
+	}
+
+	// Reserving works from any list the user can see; everything else is the owner's
+	action := parts[len(parts)-1]
+	if action == "reserve" {
+		if !user.IsStudent {
+			utils.SetError(w, r, "Only students can reserve books")
+			http.Redirect(w, r, shelfURL, http.StatusSeeOther)
+			return
+		}
+	} else if user.ID != shelf.UserID {
+		utils.SetError(w, r, "You can only change your own shelves")
+		http.Redirect(w, r, shelfURL, http.StatusSeeOther)
+		return
+	}
+
+	// Actions on a single entry name the book
+	var bookID int
+	if len(parts) == 3 && parts[0] == "items" {
+		var err error
+		if bookID, err = strconv.Atoi(parts[1]); err != nil || bookID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	var err error
+	var message string
+	failure := "Error updating list: "
+	switch {
+	case len(parts) == 1 && parts[0] == "edit":
+		shelf.Name = r.FormValue("name")
+		shelf.Description = r.FormValue("description")
+		shelf.Public = r.FormValue("public") != ""
+		err = shelf.Save()
+		message = "List updated"
+	case len(parts) == 1 && parts[0] == "delete":
+		if err := models.DeleteShelf(shelf.ID); err != nil {
+			utils.SetError(w, r, "Error deleting list: "+err.Error())
+			http.Redirect(w, r, shelfURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "List deleted")
+		http.Redirect(w, r, "/shelves", http.StatusSeeOther)
+		return
+	case len(parts) == 1 && parts[0] == "items":
+		// Books are added from their catalog pages, so go back there
+		bookID, _ = strconv.Atoi(r.FormValue("book_id"))
+		bookURL := "/books/" + strconv.Itoa(bookID)
+		if err := models.AddToShelf(shelf.ID, bookID, r.FormValue("note")); err != nil {
+			utils.SetError(w, r, "Error adding to "+shelf.Name+": "+err.Error())
+			http.Redirect(w, r, bookURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Added to "+shelf.Name)
+		http.Redirect(w, r, bookURL, http.StatusSeeOther)
+		return
+	case bookID > 0 && parts[2] == "note":
+		err = models.UpdateShelfItemNote(shelf.ID, bookID, r.FormValue("note"))
+		message = "Note saved"
+	case bookID > 0 && parts[2] == "remove":
+		err = models.RemoveFromShelf(shelf.ID, bookID)
+		message = "Removed from " + shelf.Name
+	case bookID > 0 && parts[2] == "reserve":
+		err = models.ReserveBook(user.ID, bookID, false)
+		failure = "Error reserving book: "
+		message = "Book reserved. You will be notified when it becomes available."
+	case len(parts) == 1 && parts[0] == "reserve":
+		var count int
+		count, err = models.ReserveShelf(user.ID, shelf.ID)
+		failure = "Error reserving books: "
+		message = "Reserved " + strconv.Itoa(count) + " book(s) that are out. Books on the shelf can be borrowed now."
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, failure+err.Error())
+		http.Redirect(w, r, shelfURL, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, message)
+	http.Redirect(w, r, shelfURL, http.StatusSeeOther)
+}
@@ -0,0 +1,436 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"library-management-system/export"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// stocktakeRecentScans is how many of a section's latest scans its page shows
+const stocktakeRecentScans = 20
+
+// StocktakeList displays the stocktakes (GET) or starts a new one (POST)
+func StocktakeList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can take stock
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		stocktake := &models.Stocktake{
+			Name:      r.FormValue("name"),
+			StartedBy: sql.NullInt64{Int64: int64(user.ID), Valid: true},
+		}
+		if err := stocktake.Create(); err != nil {
+			utils.SetError(w, r, "Error starting stocktake: "+err.Error())
+			http.Redirect(w, r, "/stocktakes", http.StatusSeeOther)
+			return
+		}
+
+		utils.SetFlash(w, r, "Stocktake started. Add the sections of shelving to scan.")
+		http.Redirect(w, r, "/stocktakes/"+strconv.Itoa(stocktake.ID), http.StatusSeeOther)
+		return
+	}
+
+	// Get stocktakes
+	stocktakes, err := models.GetStocktakes()
+	if err != nil {
+		utils.SetError(w, r, "Error fetching stocktakes: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":      "Stocktake",
+			"Stocktakes": stocktakes,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "stocktake_list.html", data)
+}
+
+// parseStocktakePath extracts the stocktake ID and any path after it
+func parseStocktakePath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/stocktakes/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// StocktakeDetail displays a stocktake's sections and its findings: the books
+// missing, unexpected or misplaced on the finished sections' shelves. The findings
+// can be downloaded with ?export=csv, xlsx or pdf.
+func StocktakeDetail(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can take stock
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Extract stocktake ID from URL
+	id, _, err := parseStocktakePath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get stocktake
+	stocktake, err := models.GetStocktakeByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Stocktake not found")
+		http.Redirect(w, r, "/stocktakes", http.StatusSeeOther)
+		return
+	}
+
+	// Compare the shelves with the catalogue
+	report, err := models.GetStocktakeReport(id)
+	if err != nil {
+		utils.SetError(w, r, "Error comparing stocktake: "+err.Error())
+		http.Redirect(w, r, "/stocktakes", http.StatusSeeOther)
+		return
+	}
+
+	if format := r.URL.Query().Get("export"); format != "" {
+		exportStocktake(w, stocktake, report, format)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":     stocktake.Name,
+			"Stocktake": stocktake,
+			"Report":    report,
+			"Exports":   export.Links(r),
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "stocktake_detail.html", data)
+}
+
+// exportStocktake downloads a stocktake's findings
+func exportStocktake(w http.ResponseWriter, stocktake *models.Stocktake, report *models.StocktakeReport, format string) {
+	out, err := export.Start(w, format, export.FileName("stocktake-"+strconv.Itoa(stocktake.ID)), stocktake.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = writeStocktakeReport(out, report)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Error exporting stocktake %d: %v", stocktake.ID, err)
+	}
+}
+
+// writeStocktakeReport adds a table to an export for each kind of finding
+func writeStocktakeReport(out export.Writer, report *models.StocktakeReport) error {
+	counted := []struct {
+		title string
+		items []*models.StocktakeItem
+	}{
+		{"Missing", report.Missing},
+		{"Unexpected", report.Unexpected},
+	}
+	for _, table := range counted {
+		if err := out.Table(table.title, []string{"Title", "ISBN", "Call Number", "Section", "Expected", "Found", "On Loan", "Copies", "Resolution"}); err != nil {
+			return err
+		}
+		for _, item := range table.items {
+			if err := out.Row(item.Title, item.ISBN, item.CallNumber, item.Section, item.Expected, item.Found, item.OnLoan, item.Copies, item.Resolution); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := out.Table("Misplaced", []string{"Title", "ISBN", "Call Number", "Belongs In", "Found In", "Copies", "Resolution"}); err != nil {
+		return err
+	}
+	for _, item := range report.Misplaced {
+		if err := out.Row(item.Title, item.ISBN, item.CallNumber, item.Section, item.FoundIn, item.Copies, item.Resolution); err != nil {
+			return err
+		}
+	}
+
+	if err := out.Table("Unknown Barcodes", []string{"Barcode", "Found In", "Copies"}); err != nil {
+		return err
+	}
+	for _, item := range report.Unknown {
+		if err := out.Row(item.ISBN, item.FoundIn, item.Copies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StocktakeSection displays the scanning screen for a section of shelving: the scan
+// form, the latest scans and the books the section should hold
+func StocktakeSection(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can take stock
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to view this page")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Extract stocktake and section IDs from URL
+	id, parts, err := parseStocktakePath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) != 2 || parts[0] != "sections" {
+		http.NotFound(w, r)
+		return
+	}
+	sectionID, err := strconv.Atoi(parts[1])
+	if err != nil || sectionID <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	stocktakeURL := "/stocktakes/" + strconv.Itoa(id)
+
+	// Get stocktake and section
+	stocktake, err := models.GetStocktakeByID(id)
+	if err != nil {
+		utils.SetError(w, r, "Stocktake not found")
+		http.Redirect(w, r, "/stocktakes", http.StatusSeeOther)
+		return
+	}
+	section, err := models.GetStocktakeSectionByID(sectionID)
+	if err != nil || section.StocktakeID != id {
+		utils.SetError(w, r, "Section not found")
+		http.Redirect(w, r, stocktakeURL, http.StatusSeeOther)
+		return
+	}
+
+	// Get latest scans and the section's shelf list
+	scans, err := models.GetStocktakeScans(sectionID, stocktakeRecentScans)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching scans: "+err.Error())
+		http.Redirect(w, r, stocktakeURL, http.StatusSeeOther)
+		return
+	}
+	shelf, err := models.GetStocktakeShelfList(section)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching shelf list: "+err.Error())
+		http.Redirect(w, r, stocktakeURL, http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":     section.Name + " - " + stocktake.Name,
+			"Stocktake": stocktake,
+			"Section":   section,
+			"Scans":     scans,
+			"Shelf":     shelf,
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "stocktake_section.html", data)
+}
+
+// StocktakeAction scans shelves and resolves a stocktake's findings
+//
+//	POST /stocktakes/{id}/complete
+//	POST /stocktakes/{id}/sections                              (name, call_from, call_to)
+//	POST /stocktakes/{id}/sections/{section}/scan               (barcode)
+//	POST /stocktakes/{id}/sections/{section}/scans/{scan}/delete
+//	POST /stocktakes/{id}/sections/{section}/finish
+//	POST /stocktakes/{id}/sections/{section}/reopen
+//	POST /stocktakes/{id}/sections/{section}/delete
+//	POST /stocktakes/{id}/books/{book}/missing
+//	POST /stocktakes/{id}/books/{book}/stock
+//	POST /stocktakes/{id}/books/{book}/reshelve
+//	POST /stocktakes/{id}/books/{book}/location                 (call_number)
+func StocktakeAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can take stock
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to take stock")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract stocktake ID and action from URL
+	id, parts, err := parseStocktakePath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	stocktakeURL := "/stocktakes/" + strconv.Itoa(id)
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, stocktakeURL, http.StatusSeeOther)
+		return
+	}
+
+	// The section or book the action applies to
+	var targetID int
+	if len(parts) >= 3 {
+		targetID, err = strconv.Atoi(parts[1])
+		if err != nil || targetID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	redirectURL := stocktakeURL
+
+	var failure, message string
+	switch {
+	case len(parts) == 1 && parts[0] == "complete":
+		err = models.CompleteStocktake(id)
+		failure, message = "Error completing stocktake: ", "Stocktake complete. Its findings can still be resolved."
+	case len(parts) == 1 && parts[0] == "sections":
+		section := &models.StocktakeSection{
+			StocktakeID: id,
+			Name:        r.FormValue("name"),
+			CallFrom:    r.FormValue("call_from"),
+			CallTo:      r.FormValue("call_to"),
+		}
+		err = section.Create()
+		failure, message = "Error adding section: ", "Section "+section.Name+" added"
+		if err == nil {
+			redirectURL = fmt.Sprintf("%s/sections/%d", stocktakeURL, section.ID)
+		}
+	case len(parts) >= 3 && parts[0] == "sections":
+		if !stocktakeOwnsSection(id, targetID) {
+			http.NotFound(w, r)
+			return
+		}
+		sectionURL := fmt.Sprintf("%s/sections/%d", stocktakeURL, targetID)
+		redirectURL = sectionURL
+
+		switch {
+		case len(parts) == 3 && parts[2] == "scan":
+			var scan *models.StocktakeScan
+			scan, err = models.AddStocktakeScan(targetID, r.FormValue("barcode"), user.ID)
+			failure = "Error recording scan: "
+			switch {
+			case err != nil:
+			case !scan.BookID.Valid:
+				message = "Barcode " + scan.Barcode + " matches no book in the catalogue; it will be reported as unexpected"
+			case scan.Misplaced:
+				message = "Scanned \"" + scan.Title + "\", which files elsewhere (" + scan.CallNumber + "); it will be reported as misplaced"
+			default:
+				message = "Scanned \"" + scan.Title + "\""
+			}
+		case len(parts) == 5 && parts[2] == "scans" && parts[4] == "delete":
+			scanID, convErr := strconv.Atoi(parts[3])
+			if convErr != nil || scanID <= 0 {
+				http.NotFound(w, r)
+				return
+			}
+			err = models.DeleteStocktakeScan(scanID, targetID)
+			failure, message = "Error removing scan: ", "Scan removed"
+		case len(parts) == 3 && parts[2] == "finish":
+			err = models.FinishStocktakeSection(targetID)
+			failure, message = "Error finishing section: ", "Section finished; its findings are in the stocktake report"
+			redirectURL = stocktakeURL
+		case len(parts) == 3 && parts[2] == "reopen":
+			err = models.ReopenStocktakeSection(targetID)
+			failure, message = "Error reopening section: ", "Section reopened for scanning"
+		case len(parts) == 3 && parts[2] == "delete":
+			err = models.DeleteStocktakeSection(targetID)
+			failure, message = "Error removing section: ", "Section removed"
+			redirectURL = stocktakeURL
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			redirectURL = sectionURL
+		}
+	case len(parts) == 3 && parts[0] == "books":
+		redirectURL = stocktakeURL + "#findings"
+		switch parts[2] {
+		case "missing":
+			var copies int
+			copies, err = models.WriteOffMissingCopies(id, targetID, user.ID)
+			failure, message = "Error writing off copies: ", fmt.Sprintf("%d missing copies written off", copies)
+		case "stock":
+			var copies int
+			copies, err = models.AddSurplusCopies(id, targetID, user.ID)
+			failure, message = "Error adding copies: ", fmt.Sprintf("%d copies added to stock", copies)
+		case "reshelve":
+			err = models.ReshelveMisplacedCopies(id, targetID, user.ID)
+			failure, message = "Error recording reshelving: ", "Copies recorded as reshelved"
+		case "location":
+			err = models.RelocateBook(id, targetID, r.FormValue("call_number"), user.ID)
+			failure, message = "Error updating call number: ", "Call number updated to "+strings.TrimSpace(r.FormValue("call_number"))
+		default:
+			http.NotFound(w, r)
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		utils.SetError(w, r, failure+err.Error())
+	} else {
+		utils.SetFlash(w, r, message)
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// stocktakeOwnsSection reports whether a section belongs to the stocktake in the URL
+func stocktakeOwnsSection(stocktakeID, sectionID int) bool {
+	section, err := models.GetStocktakeSectionByID(sectionID)
+	return err == nil && section.StocktakeID == stocktakeID
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	isbnpkg "library-management-system/isbn"
+	"library-management-system/middleware"
+	"library-management-system/models"
+	"library-management-system/utils"
+)
+
+// SuggestionList shows the triage queue to librarians and a patron's own suggestions
+// to everyone else (GET), or records a purchase suggestion (POST). The form can be
+// prefilled with the title, author and isbn query parameters.
+func SuggestionList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Process form submission
+	if r.Method == http.MethodPost {
+		suggestion := &models.Suggestion{
+			Title:     r.FormValue("title"),
+			Author:    r.FormValue("author"),
+			Publisher: strings.TrimSpace(r.FormValue("publisher")),
+			Notes:     r.FormValue("notes"),
+		}
+		suggestion.PublicationYear, _ = strconv.Atoi(r.FormValue("publication_year"))
+
+		// Validate the ISBN if one was given
+		if code := strings.TrimSpace(r.FormValue("isbn")); code != "" {
+			var err error
+			suggestion.ISBN, err = isbnpkg.Normalize(code)
+			if err != nil {
+				utils.SetError(w, r, err.Error())
+				http.Redirect(w, r, "/suggestions", http.StatusSeeOther)
+				return
+			}
+		}
+
+		merged, err := models.SuggestBook(suggestion, user.ID, r.FormValue("reserve") != "")
+		if err == models.ErrSuggestionInCatalog {
+			utils.SetError(w, r, "This book is already in the catalog")
+			http.Redirect(w, r, "/books?searchBy=isbn&search="+suggestion.ISBN, http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			utils.SetError(w, r, "Error saving suggestion: "+err.Error())
+			http.Redirect(w, r, "/suggestions", http.StatusSeeOther)
+			return
+		}
+
+		if merged {
+			utils.SetFlash(w, r, "This title has already been suggested; your vote has been added to it")
+		} else {
+			utils.SetFlash(w, r, "Thank you for your suggestion. You will be notified when the book is added.")
+		}
+		http.Redirect(w, r, "/suggestions", http.StatusSeeOther)
+		return
+	}
+
+	query := r.URL.Query()
+	status := query.Get("status")
+
+	// Librarians triage every suggestion; patrons follow their own
+	suggestions, err := models.GetSuggestions(status, user.ID, !user.IsLibrarian)
+	if err != nil {
+		utils.SetError(w, r, "Error fetching suggestions: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Seeing the list clears the patron's notifications
+	if !user.IsLibrarian {
+		_ = models.MarkSuggestionsSeen(user.ID)
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":       "Purchase Suggestions",
+			"Suggestions": suggestions,
+			"Status":      status,
+			"Statuses": []string{models.SuggestionStatusNew, models.SuggestionStatusAccepted,
+				models.SuggestionStatusRejected, models.SuggestionStatusOrdered, models.SuggestionStatusAdded},
+			"Prefill": map[string]string{
+				"Title":  query.Get("title"),
+				"Author": query.Get("author"),
+				"ISBN":   query.Get("isbn"),
+			},
+		},
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "suggestion_list.html", data)
+}
+
+// parseSuggestionPath splits /suggestions/{id}/... into the suggestion ID and remaining segments
+func parseSuggestionPath(path string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/suggestions/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		return 0, nil, err
+	}
+	return id, parts[1:], nil
+}
+
+// SuggestionDetail displays a suggestion with the patrons backing it and the triage
+// forms
+func SuggestionDetail(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can triage suggestions
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to manage suggestions")
+		http.Redirect(w, r, "/suggestions", http.StatusSeeOther)
+		return
+	}
+
+	// Extract suggestion ID from URL
+	id, _, err := parseSuggestionPath(r.URL.Path)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Get suggestion
+	suggestion, err := models.GetSuggestionByID(id, user.ID)
+	if err != nil {
+		utils.SetError(w, r, "Suggestion not found")
+		http.Redirect(w, r, "/suggestions", http.StatusSeeOther)
+		return
+	}
+
+	data := &utils.TemplateData{
+		User: user,
+		Data: map[string]interface{}{
+			"Title":      "Suggestion: " + suggestion.Title,
+			"Suggestion": suggestion,
+			"Statuses":   models.SuggestionStatuses,
+		},
+	}
+
+	// Linked books are shown with their availability
+	if suggestion.BookID.Valid {
+		data.Data["Book"], _ = models.GetBookByID(int(suggestion.BookID.Int64))
+	}
+
+	// Render template
+	utils.RenderTemplate(w, r, "suggestion_detail.html", data)
+}
+
+// SuggestionAction triages a suggestion:
+//
+//	POST /suggestions/{id}/review    (status, librarian_note)
+//	POST /suggestions/{id}/link      (isbn of the catalogued book)
+//	POST /suggestions/{id}/merge     (into: the suggestion to keep)
+func SuggestionAction(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Only librarians can triage suggestions
+	if !user.IsLibrarian {
+		utils.SetError(w, r, "You do not have permission to manage suggestions")
+		http.Redirect(w, r, "/suggestions", http.StatusSeeOther)
+		return
+	}
+
+	// Only POST method is allowed
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract suggestion ID and action from URL
+	id, parts, err := parseSuggestionPath(r.URL.Path)
+	if err != nil || id <= 0 || len(parts) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+	suggestionURL := "/suggestions/" + strconv.Itoa(id)
+
+	// Parse form
+	if err := r.ParseForm(); err != nil {
+		utils.SetError(w, r, "Error processing form")
+		http.Redirect(w, r, suggestionURL, http.StatusSeeOther)
+		return
+	}
+
+	var message string
+	switch parts[0] {
+	case "review":
+		err = models.ReviewSuggestion(id, r.FormValue("status"), r.FormValue("librarian_note"), user.ID)
+		message = "Suggestion updated"
+	case "link":
+		var book *models.Book
+		book, err = models.GetBookByBarcode(r.FormValue("isbn"))
+		if err == nil {
+			err = models.LinkSuggestion(id, book.ID)
+		}
+		message = "Suggestion linked to the catalog; its patrons have been notified"
+	case "merge":
+		intoID, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(r.FormValue("into")), "#"))
+		if err := models.MergeSuggestions(id, intoID); err != nil {
+			utils.SetError(w, r, "Error merging suggestions: "+err.Error())
+			http.Redirect(w, r, suggestionURL, http.StatusSeeOther)
+			return
+		}
+		utils.SetFlash(w, r, "Suggestions merged")
+		http.Redirect(w, r, "/suggestions/"+strconv.Itoa(intoID), http.StatusSeeOther)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		utils.SetError(w, r, "Error updating suggestion: "+err.Error())
+		http.Redirect(w, r, suggestionURL, http.StatusSeeOther)
+		return
+	}
+
+	utils.SetFlash(w, r, message)
+	http.Redirect(w, r, suggestionURL, http.StatusSeeOther)
+}
+
+// matchSuggestions links a newly catalogued book to the suggestions asking for it.
+// Failing to do so never stops the book being added, so errors are only logged.
+func matchSuggestions(book *models.Book) {
+	if err := models.MatchSuggestions(book); err != nil {
+		log.Printf("Error matching suggestions for book %d: %v", book.ID, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
         "net/http"
         "strconv"
         "strings"
+        "time"
 
         "library-management-system/middleware"
         "library-management-system/models"
@@ -108,6 +109,12 @@ func AddUser(w http.ResponseWriter, r *http.Request) {
                 role := r.FormValue("role")
                 studentID := r.FormValue("student_id")
                 phone := r.FormValue("phone")
+                expiresAt, err := parseMembershipExpiry(r.FormValue("expires_at"))
+                if err != nil {
+                        utils.SetError(w, r, "Invalid membership expiry date")
+                        utils.RenderTemplate(w, r, "user_form.html", &utils.TemplateData{User: user})
+                        return
+                }
                 
                 // Validate form
                 if name == "" || email == "" || password == "" || role == "" {
@@ -138,6 +145,7 @@ func AddUser(w http.ResponseWriter, r *http.Request) {
                         Role:      role,
                         StudentID: sql.NullString{String: studentID, Valid: studentID != ""},
                         Phone:     sql.NullString{String: phone, Valid: phone != ""},
+                        ExpiresAt: expiresAt,
                 }
                 
                 // Save user to database
@@ -228,6 +236,19 @@ func EditUser(w http.ResponseWriter, r *http.Request) {
                 role := r.FormValue("role")
                 studentID := r.FormValue("student_id")
                 phone := r.FormValue("phone")
+                expiresAt, err := parseMembershipExpiry(r.FormValue("expires_at"))
+                if err != nil {
+                        utils.SetError(w, r, "Invalid membership expiry date")
+                        data := &utils.TemplateData{
+                                User: user,
+                                Data: map[string]interface{}{
+                                        "Title":    "Edit User",
+                                        "EditUser": editUser,
+                                },
+                        }
+                        utils.RenderTemplate(w, r, "user_form.html", data)
+                        return
+                }
                 
                 // Validate form
                 if name == "" || email == "" || role == "" {
@@ -263,6 +284,7 @@ func EditUser(w http.ResponseWriter, r *http.Request) {
                 editUser.Role = role
                 editUser.StudentID = sql.NullString{String: studentID, Valid: studentID != ""}
                 editUser.Phone = sql.NullString{String: phone, Valid: phone != ""}
+                editUser.ExpiresAt = expiresAt
                 
                 // Save changes to database
                 err = editUser.Update()
@@ -382,4 +404,16 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
         // Set flash message and redirect
         utils.SetFlash(w, r, "User deleted successfully")
         http.Redirect(w, r, "/users", http.StatusSeeOther)
-}
\ No newline at end of file
+}
+
+// parseMembershipExpiry parses the optional membership expiry date from the user form
+func parseMembershipExpiry(value string) (models.NullTime, error) {
+        if value == "" {
+                return models.NullTime{}, nil
+        }
+        expires, err := time.Parse("2006-01-02", value)
+        if err != nil {
+                return models.NullTime{}, err
+        }
+        return models.NullTime{Time: expires, Valid: true}, nil
+}
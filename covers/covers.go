@@ -0,0 +1,182 @@
+// Package covers validates uploaded book cover images and stores them as JPEG
+// thumbnails in several sizes.
+package covers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// Register the decoders for the accepted upload formats
+	_ "image/gif"
+	_ "image/png"
+
+	"library-management-system/config"
+	"library-management-system/storage"
+)
+
+// Size is a thumbnail width in pixels; height follows the image's aspect ratio
+type Size struct {
+	Name  string
+	Width int
+}
+
+// Sizes lists the thumbnails generated for every cover
+var Sizes = []Size{
+	{Name: "small", Width: 80},   // Lists
+	{Name: "medium", Width: 200}, // Catalog cards
+	{Name: "large", Width: 480},  // Book detail page
+}
+
+// AllowedTypes lists the accepted upload formats, detected from the file contents
+var AllowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Upload errors shown to the librarian
+var (
+	ErrTooLarge = errors.New("cover image is too large")
+	ErrType     = errors.New("cover image must be a JPEG, PNG or GIF file")
+	ErrCorrupt  = errors.New("cover image could not be read")
+)
+
+// maxPixels guards against images that are small on disk but huge once decoded
+const maxPixels = 40_000_000
+
+// Save validates an uploaded image, generates the thumbnails and stores them. It
+// returns the key that identifies the cover.
+func Save(ctx context.Context, data []byte) (string, error) {
+	if max := config.AppConfig.Covers.MaxBytes; max > 0 && len(data) > max {
+		return "", fmt.Errorf("%w (limit %d KB)", ErrTooLarge, max/1024)
+	}
+	if !AllowedTypes[http.DetectContentType(data)] {
+		return "", ErrType
+	}
+
+	// Check the dimensions before decoding the whole image
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return "", fmt.Errorf("%w (%dx%d pixels)", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrCorrupt
+	}
+
+	// Flatten transparency onto white, since JPEG has no alpha channel
+	src := image.NewRGBA(img.Bounds())
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	key, err := newKey()
+	if err != nil {
+		return "", err
+	}
+
+	store := storage.Default()
+	for _, size := range Sizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resize(src, size.Width), &jpeg.Options{Quality: 85}); err != nil {
+			return "", err
+		}
+		if err := store.Put(ctx, path(key, size.Name), buf.Bytes(), "image/jpeg"); err != nil {
+			Delete(ctx, key)
+			return "", err
+		}
+	}
+
+	return key, nil
+}
+
+// Delete removes every thumbnail of a cover
+func Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	store := storage.Default()
+	var firstErr error
+	for _, size := range Sizes {
+		if err := store.Delete(ctx, path(key, size.Name)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// URL returns the address of a cover's thumbnail in the named size
+func URL(key, size string) string {
+	return storage.Default().URL(path(key, size))
+}
+
+func path(key, size string) string {
+	return "covers/" + key + "/" + size + ".jpg"
+}
+
+// newKey returns a random key, so replaced covers are never served from a stale cache
+func newKey() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// resize scales an image down to the given width by averaging the source pixels
+// under each target pixel. Images already narrower are returned unchanged.
+func resize(src *image.RGBA, width int) image.Image {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw <= width {
+		return src
+	}
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				i := sy*src.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					i += 4
+					n++
+				}
+			}
+
+			j := y*dst.Stride + x*4
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = 255
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// CSVSafe stops a text cell being run as a formula when the file is opened in a
+// spreadsheet, by prefixing a quote to text starting with =, +, -, @, a tab or a
+// carriage return
+func CSVSafe(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// csvWriter writes tables as CSV. Each table after the first is separated from the
+// one before by a blank line and headed by its title, so a report with several
+// tables still opens as one sheet.
+type csvWriter struct {
+	out    *csv.Writer
+	tables int
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{out: csv.NewWriter(w)}
+}
+
+// Table starts a new table
+func (c *csvWriter) Table(title string, columns []string) error {
+	if c.tables > 0 {
+		if err := c.out.Write([]string{}); err != nil {
+			return err
+		}
+	}
+	c.tables++
+	if title != "" {
+		if err := c.out.Write([]string{CSVSafe(title)}); err != nil {
+			return err
+		}
+	}
+	return c.out.Write(columns)
+}
+
+// Row adds a row to the current table
+func (c *csvWriter) Row(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = formatCell(cell)
+		if !isNumber(cell) {
+			record[i] = CSVSafe(record[i])
+		}
+	}
+	return c.out.Write(record)
+}
+
+// Close flushes the remaining rows
+func (c *csvWriter) Close() error {
+	c.out.Flush()
+	return c.out.Error()
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"equals", "=SUM(A1:A9)", "'=SUM(A1:A9)"},
+		{"plus", "+1+2", "'+1+2"},
+		{"minus", "-2+3", "'-2+3"},
+		{"at", "@SUM(A1)", "'@SUM(A1)"},
+		{"tab", "\t=1", "'\t=1"},
+		{"carriage return", "\r=1", "'\r=1"},
+		{"empty", "", ""},
+		{"plain text", "War and Peace", "War and Peace"},
+		{"formula character after the start", "A=B", "A=B"},
+		{"digits", "42", "42"},
+		{"decimal", "3.5", "3.5"},
+		{"date", "2026-03-10", "2026-03-10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CSVSafe(tt.in); got != tt.want {
+				t.Errorf("CSVSafe(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVRowNumbers(t *testing.T) {
+	var buf bytes.Buffer
+	out := newCSVWriter(&buf)
+	if err := out.Table("", []string{"Text", "Int", "Int64", "Float"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Row("-5", -5, int64(-7), -2.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Text,Int,Int64,Float\n'-5,-5,-7,-2.5\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV output = %q, want %q", got, want)
+	}
+}
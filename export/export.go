@@ -0,0 +1,147 @@
+// Package export writes tabular reports as CSV, XLSX or PDF. Rows are written to the
+// output as they are added, so large exports never have to be held in memory.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Export formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatPDF  = "pdf"
+)
+
+// Formats lists the export formats in the order they are offered
+var Formats = []string{FormatCSV, FormatXLSX, FormatPDF}
+
+// dateFormat is how dates are written in every format
+const dateFormat = "2006-01-02"
+
+// Writer writes one or more tables to an export file. A table must be started before
+// rows are added to it, and the writer must be closed to complete the file.
+//
+// Cells may be strings, integers, floats, times or time pointers; numbers are stored
+// as numbers in spreadsheets and nil times are left blank.
+type Writer interface {
+	Table(title string, columns []string) error
+	Row(cells ...interface{}) error
+	Close() error
+}
+
+// Valid reports whether format is a supported export format
+func Valid(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns a writer producing the format on w. The title heads PDF documents and
+// names the XLSX workbook's properties.
+func New(w io.Writer, format, title string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatXLSX:
+		return newXLSXWriter(w, title), nil
+	case FormatPDF:
+		return newPDFWriter(w, title), nil
+	}
+	return nil, errors.New("unknown export format")
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return "application/octet-stream"
+}
+
+// Start sets the response headers for downloading an export named after baseName
+// and returns a writer streaming it to the response
+func Start(w http.ResponseWriter, format, baseName, title string) (Writer, error) {
+	if !Valid(format) {
+		return nil, errors.New("unknown export format")
+	}
+
+	w.Header().Set("Content-Type", ContentType(format))
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+baseName+"."+format+"\"")
+	return New(w, format, title)
+}
+
+// FileName returns a base file name for an export, stamped with today's date
+func FileName(name string) string {
+	return name + "-" + time.Now().Format(dateFormat)
+}
+
+// Link is a download link for one export format
+type Link struct {
+	Format string
+	URL    string
+}
+
+// Links returns a download link for each format, keeping the request's query string
+// so the export covers exactly what is filtered on screen
+func Links(r *http.Request) []Link {
+	var links []Link
+	for _, format := range Formats {
+		query := r.URL.Query()
+		query.Del("page")
+		query.Set("export", format)
+		links = append(links, Link{Format: format, URL: r.URL.Path + "?" + query.Encode()})
+	}
+	return links
+}
+
+// formatCell renders a cell as text
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(dateFormat)
+	case *time.Time:
+		if v == nil || v.IsZero() {
+			return ""
+		}
+		return v.Format(dateFormat)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(cell)
+}
+
+// isNumber reports whether a cell holds a number
+func isNumber(cell interface{}) bool {
+	switch cell.(type) {
+	case int, int64, float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Page layout in points: A4 landscape, so wide tables fit
+const (
+	pageWidth    = 842
+	pageHeight   = 595
+	pageMargin   = 36
+	titleSize    = 14
+	headingSize  = 11
+	textSize     = 8
+	rowHeight    = 12
+	cellPadding  = 4
+	avgCharWidth = 0.52 // Average Helvetica glyph width as a fraction of the font size
+)
+
+// Fixed PDF objects; pages follow from firstPageObject, a content stream and a
+// page object each
+const (
+	catalogObject = iota + 1
+	pagesObject
+	fontObject
+	boldFontObject
+	infoObject
+	firstPageObject
+)
+
+// pdfWriter lays tables out on landscape pages in the standard Helvetica fonts.
+// Each page is written as soon as it is full; the page tree and cross-reference
+// table follow on Close.
+type pdfWriter struct {
+	out     *countingWriter
+	title   string
+	offsets map[int]int64
+	pages   []int // Page object numbers
+	nextObj int
+
+	page    *bytes.Buffer // Content of the page being laid out, or nil
+	y       float64
+	columns []string
+	err     error
+}
+
+func newPDFWriter(w io.Writer, title string) *pdfWriter {
+	p := &pdfWriter{
+		out:     &countingWriter{w: w},
+		title:   title,
+		offsets: map[int]int64{},
+		nextObj: firstPageObject,
+	}
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return p
+}
+
+// Table starts a new table, on a new page if there is no room for its heading,
+// header and first row
+func (p *pdfWriter) Table(title string, columns []string) error {
+	p.columns = columns
+	if p.page == nil || p.y-(headingSize+3*rowHeight) < pageMargin {
+		p.newPage()
+	}
+	if title != "" {
+		p.y -= headingSize + 4
+		p.text(pageMargin, p.y, headingSize, true, title)
+		p.y -= 6
+	}
+	p.header()
+	return p.err
+}
+
+// Row adds a row to the current table, starting a new page when this one is full
+func (p *pdfWriter) Row(cells ...interface{}) error {
+	if p.page == nil || p.y-rowHeight < pageMargin+rowHeight {
+		p.newPage()
+		p.header()
+	}
+	p.y -= rowHeight
+	p.cells(cells, false)
+	return p.err
+}
+
+// Close writes the last page and completes the document
+func (p *pdfWriter) Close() error {
+	if p.page == nil {
+		p.newPage()
+	}
+	p.endPage()
+
+	kids := make([]string, len(p.pages))
+	for i, n := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", n)
+	}
+	p.object(catalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject))
+	p.object(pagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	p.object(fontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	p.object(boldFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	p.object(infoObject, fmt.Sprintf("<< /Title (%s) /Producer (Library Management System) /CreationDate (D:%s) >>",
+		pdfString(p.title), time.Now().Format("20060102150405")))
+
+	// Cross-reference table
+	xref := p.out.n
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", p.nextObj)
+	for n := 1; n < p.nextObj; n++ {
+		p.printf("%010d 00000 n \n", p.offsets[n])
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		p.nextObj, catalogObject, infoObject, xref)
+	return p.err
+}
+
+// newPage writes the current page and starts another, with the document title at
+// the top of the first
+func (p *pdfWriter) newPage() {
+	p.endPage()
+	p.page = &bytes.Buffer{}
+	p.y = pageHeight - pageMargin
+	if len(p.pages) == 0 && p.title != "" {
+		p.y -= titleSize
+		p.text(pageMargin, p.y, titleSize, true, p.title)
+		p.y -= 8
+	}
+}
+
+// endPage numbers the page being laid out and writes it with its content stream
+func (p *pdfWriter) endPage() {
+	if p.page == nil {
+		return
+	}
+	number := len(p.pages) + 1
+	p.text(pageMargin, pageMargin/2, textSize, false, fmt.Sprintf("Page %d", number))
+
+	var content bytes.Buffer
+	zw := zlib.NewWriter(&content)
+	zw.Write(p.page.Bytes())
+	zw.Close()
+
+	contentObj, pageObj := p.nextObj, p.nextObj+1
+	p.nextObj += 2
+	p.offsets[contentObj] = p.out.n
+	p.printf("%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", contentObj, content.Len())
+	if p.err == nil {
+		_, p.err = p.out.Write(content.Bytes())
+	}
+	p.printf("\nendstream\nendobj\n")
+	p.object(pageObj, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R "+
+		"/Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> >>",
+		pagesObject, pageWidth, pageHeight, contentObj, fontObject, boldFontObject))
+	p.pages = append(p.pages, pageObj)
+	p.page = nil
+}
+
+// header draws the current table's column headings with a rule beneath
+func (p *pdfWriter) header() {
+	if len(p.columns) == 0 {
+		return
+	}
+	p.y -= rowHeight
+	cells := make([]interface{}, len(p.columns))
+	for i, c := range p.columns {
+		cells[i] = c
+	}
+	p.cells(cells, true)
+	fmt.Fprintf(p.page, "0.6 G 0.5 w %d %.1f m %d %.1f l S 0 G\n", pageMargin, p.y-3, pageWidth-pageMargin, p.y-3)
+}
+
+// cells draws a row of cells in equal-width columns, cutting text that would overflow
+func (p *pdfWriter) cells(cells []interface{}, bold bool) {
+	columns := len(p.columns)
+	if len(cells) > columns {
+		columns = len(cells)
+	}
+	if columns == 0 {
+		return
+	}
+	width := float64(pageWidth-2*pageMargin) / float64(columns)
+	maxChars := int((width - cellPadding) / (textSize * avgCharWidth))
+
+	for i, cell := range cells {
+		text := []rune(formatCell(cell))
+		if len(text) > maxChars && maxChars > 3 {
+			text = append(text[:maxChars-3], []rune("...")...)
+		}
+		p.text(pageMargin+float64(i)*width, p.y, textSize, bold, string(text))
+	}
+}
+
+// text draws a line of text on the current page
+func (p *pdfWriter) text(x, y float64, size int, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.page, "BT /%s %d Tf %.1f %.1f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// object writes an indirect object, recording its offset for the cross-reference table
+func (p *pdfWriter) object(n int, body string) {
+	p.offsets[n] = p.out.n
+	p.printf("%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+// printf writes to the document, remembering the first error
+func (p *pdfWriter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.out, format, args...)
+	}
+}
+
+// winAnsi maps the characters outside Latin-1 that WinAnsiEncoding can show
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// pdfString encodes text as a PDF string literal in WinAnsiEncoding, replacing
+// characters the standard fonts cannot show
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case winAnsi[r] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsi[r])
+		case r == '\t' || r == '\n' || r == '\r':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// countingWriter tracks how many bytes have been written, for object offsets
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
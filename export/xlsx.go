@@ -0,0 +1,214 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxSheetName is the longest worksheet name spreadsheet applications accept
+const maxSheetName = 31
+
+// xlsxWriter writes each table as a worksheet of an Office Open XML workbook. Sheets
+// are streamed into the zip archive one after another; the workbook parts that list
+// them are written on Close.
+type xlsxWriter struct {
+	zip    *zip.Writer
+	title  string
+	sheets []string
+	sheet  io.Writer // The worksheet being written, or nil before the first table
+	row    int
+	err    error
+}
+
+func newXLSXWriter(w io.Writer, title string) *xlsxWriter {
+	return &xlsxWriter{zip: zip.NewWriter(w), title: title}
+}
+
+// Table finishes the current worksheet and starts a new one
+func (x *xlsxWriter) Table(title string, columns []string) error {
+	if x.err != nil {
+		return x.err
+	}
+	x.endSheet()
+
+	x.sheets = append(x.sheets, x.sheetName(title))
+	x.sheet, x.err = x.zip.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(x.sheets)))
+	if x.err != nil {
+		return x.err
+	}
+	x.row = 0
+	x.write(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	x.write(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	x.write(`<sheetData>`)
+
+	// Header row in bold
+	cells := make([]interface{}, len(columns))
+	for i, c := range columns {
+		cells[i] = c
+	}
+	x.writeRow(cells, 1)
+	return x.err
+}
+
+// Row adds a row to the current worksheet
+func (x *xlsxWriter) Row(cells ...interface{}) error {
+	if x.sheet == nil {
+		return fmt.Errorf("xlsx: row added before a table was started")
+	}
+	x.writeRow(cells, 0)
+	return x.err
+}
+
+// Close writes the workbook parts and completes the archive
+func (x *xlsxWriter) Close() error {
+	if x.err != nil {
+		return x.err
+	}
+	if len(x.sheets) == 0 {
+		x.Table(x.title, nil)
+	}
+	x.endSheet()
+
+	var sheets, rels, overrides strings.Builder
+	for i, name := range x.sheets {
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	stylesID := len(x.sheets) + 1
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+			`</Relationships>`},
+		{"docProps/core.xml", `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">` +
+			`<dc:title>` + xmlEscape(x.title) + `</dc:title></cp:coreProperties>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() +
+			fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, stylesID) +
+			`</Relationships>`},
+		{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, part := range parts {
+		f, err := x.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xml.Header+part.body); err != nil {
+			return err
+		}
+	}
+
+	return x.zip.Close()
+}
+
+// endSheet closes the worksheet being written, if any
+func (x *xlsxWriter) endSheet() {
+	if x.sheet != nil {
+		x.write(`</sheetData></worksheet>`)
+		x.sheet = nil
+	}
+}
+
+// writeRow writes a row of cells with the given style
+func (x *xlsxWriter) writeRow(cells []interface{}, style int) {
+	x.row++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, x.row)
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(x.row)
+		styleAttr := ""
+		if style > 0 {
+			styleAttr = fmt.Sprintf(` s="%d"`, style)
+		}
+		if isNumber(cell) {
+			fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, styleAttr, formatCell(cell))
+		} else if text := formatCell(cell); text != "" {
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr, xmlEscape(text))
+		}
+	}
+	b.WriteString(`</row>`)
+	x.write(b.String())
+}
+
+// write appends to the current worksheet, remembering the first error
+func (x *xlsxWriter) write(s string) {
+	if x.err == nil {
+		_, x.err = io.WriteString(x.sheet, s)
+	}
+}
+
+// sheetName makes a table title into a unique, valid worksheet name
+func (x *xlsxWriter) sheetName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = "Sheet"
+	}
+
+	base := []rune(name)
+	for n := 1; ; n++ {
+		candidate := string(base)
+		if n > 1 {
+			suffix := fmt.Sprintf(" (%d)", n)
+			candidate = string(truncateRunes(base, maxSheetName-len(suffix))) + suffix
+		} else {
+			candidate = string(truncateRunes(base, maxSheetName))
+		}
+		taken := false
+		for _, s := range x.sheets {
+			taken = taken || strings.EqualFold(s, candidate)
+		}
+		if !taken {
+			return candidate
+		}
+	}
+}
+
+// columnName returns the spreadsheet column letters for a zero-based index
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// truncateRunes shortens a name to at most n runes
+func truncateRunes(r []rune, n int) []rune {
+	if len(r) > n {
+		return r[:n]
+	}
+	return r
+}
+
+// xmlEscape escapes text for XML, dropping characters XML cannot contain
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,187 @@
+// Package isbn validates and normalizes International Standard Book Numbers and
+// International Standard Serial Numbers.
+//
+// Books are stored under their 13-digit ISBN without hyphens, so the same book
+// entered as "0-06-112008-1", "978-0-06-112008-4" or "9780061120084" is recognised
+// as one record.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors returned when a number cannot be parsed
+var (
+	ErrEmpty    = errors.New("ISBN is required")
+	ErrLength   = errors.New("ISBN must have 10 or 13 digits")
+	ErrChars    = errors.New("ISBN may only contain digits, hyphens, spaces and a final X")
+	ErrChecksum = errors.New("ISBN check digit is wrong; please check for a typing mistake")
+	ErrPrefix   = errors.New("only ISBN-13s starting with 978 have an ISBN-10 form")
+
+	ErrISSNLength   = errors.New("ISSN must have 8 digits")
+	ErrISSNChars    = errors.New("ISSN may only contain digits, a hyphen and a final X")
+	ErrISSNChecksum = errors.New("ISSN check digit is wrong; please check for a typing mistake")
+)
+
+// Clean strips hyphens, spaces and an "ISBN" or "ISSN" label, and upper-cases a
+// final x. It does not validate the result.
+func Clean(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, label := range []string{"ISBN-13", "ISBN-10", "ISBN", "ISSN"} {
+		if strings.HasPrefix(s, label) {
+			s = strings.TrimLeft(strings.TrimPrefix(s, label), ": ")
+			break
+		}
+	}
+	return strings.NewReplacer("-", "", " ", "").Replace(s)
+}
+
+// Normalize validates an ISBN-10 or ISBN-13 and returns it as a 13-digit string
+// without hyphens, the form used for storage and comparison
+func Normalize(s string) (string, error) {
+	s = Clean(s)
+	switch len(s) {
+	case 0:
+		return "", ErrEmpty
+	case 10:
+		if err := check10(s); err != nil {
+			return "", err
+		}
+		return to13(s), nil
+	case 13:
+		if err := check13(s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	return "", ErrLength
+}
+
+// Valid reports whether s is a valid ISBN-10 or ISBN-13
+func Valid(s string) bool {
+	_, err := Normalize(s)
+	return err == nil
+}
+
+// To13 converts a valid ISBN-10 or ISBN-13 to its 13-digit form
+func To13(s string) (string, error) {
+	return Normalize(s)
+}
+
+// To10 converts a valid ISBN to its 10-digit form. ISBN-13s in the 979 range have
+// no 10-digit equivalent.
+func To10(s string) (string, error) {
+	s, err := Normalize(s)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(s, "978") {
+		return "", ErrPrefix
+	}
+	body := s[3:12]
+	return body + string(checkDigit10(body)), nil
+}
+
+// check10 verifies the characters and check digit of a cleaned ISBN-10
+func check10(s string) error {
+	for i := 0; i < 9; i++ {
+		if !isDigit(s[i]) {
+			return ErrChars
+		}
+	}
+	if !isDigit(s[9]) && s[9] != 'X' {
+		return ErrChars
+	}
+	if checkDigit10(s[:9]) != s[9] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// check13 verifies the characters and check digit of a cleaned ISBN-13
+func check13(s string) error {
+	for i := 0; i < 13; i++ {
+		if !isDigit(s[i]) {
+			return ErrChars
+		}
+	}
+	if checkDigit13(s[:12]) != s[12] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// checkDigit10 computes the ISBN-10 check digit for nine digits (weights 10 to 2, mod 11)
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// checkDigit13 computes the ISBN-13 check digit for twelve digits (weights 1 and 3, mod 10)
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(body[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// to13 converts a validated ISBN-10 to ISBN-13
+func to13(s string) string {
+	body := "978" + s[:9]
+	return body + string(checkDigit13(body))
+}
+
+// NormalizeISSN validates an ISSN and returns it in the standard "1234-567X" form
+func NormalizeISSN(s string) (string, error) {
+	s = Clean(s)
+	if len(s) != 8 {
+		return "", ErrISSNLength
+	}
+	for i := 0; i < 7; i++ {
+		if !isDigit(s[i]) {
+			return "", ErrISSNChars
+		}
+	}
+	if !isDigit(s[7]) && s[7] != 'X' {
+		return "", ErrISSNChars
+	}
+
+	// Weights 8 to 2, mod 11
+	sum := 0
+	for i := 0; i < 7; i++ {
+		sum += int(s[i]-'0') * (8 - i)
+	}
+	check := (11 - sum%11) % 11
+	want := byte('0' + check)
+	if check == 10 {
+		want = 'X'
+	}
+	if want != s[7] {
+		return "", ErrISSNChecksum
+	}
+
+	return s[:4] + "-" + s[4:], nil
+}
+
+// ValidISSN reports whether s is a valid ISSN
+func ValidISSN(s string) bool {
+	_, err := NormalizeISSN(s)
+	return err == nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,101 @@
+package isbn
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  error
+	}{
+		{"ISBN-10", "0061120081", "9780061120084", nil},
+		{"ISBN-10 with hyphens", "0-06-112008-1", "9780061120084", nil},
+		{"ISBN-10 with spaces", "0 06 112008 1", "9780061120084", nil},
+		{"ISBN-13", "9780061120084", "9780061120084", nil},
+		{"ISBN-13 with hyphens", "978-0-06-112008-4", "9780061120084", nil},
+		{"ISBN-13 with spaces", " 978 0 06 112008 4 ", "9780061120084", nil},
+		{"ISBN label", "ISBN-13: 978-0-06-112008-4", "9780061120084", nil},
+		{"X check digit", "080442957X", "9780804429573", nil},
+		{"lower-case x check digit", "0-8044-2957-x", "9780804429573", nil},
+		{"ISBN-10 bad checksum", "0061120082", "", ErrChecksum},
+		{"ISBN-10 X where a digit is due", "006112008X", "", ErrChecksum},
+		{"ISBN-13 bad checksum", "978-0-06-112008-5", "", ErrChecksum},
+		{"X inside ISBN-10", "00611X0081", "", ErrChars},
+		{"X ending ISBN-13", "978006112008X", "", ErrChars},
+		{"letters", "97800611200AB", "", ErrChars},
+		{"too short", "12345", "", ErrLength},
+		{"too long", "97800611200841", "", ErrLength},
+		{"empty", "  ", "", ErrEmpty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.in)
+			if err != tt.err {
+				t.Fatalf("Normalize(%q) error = %v, want %v", tt.in, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTo10(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  error
+	}{
+		{"978 prefix", "978-0-06-112008-4", "0061120081", nil},
+		{"978 prefix with X check digit", "9780804429573", "080442957X", nil},
+		{"already ISBN-10", "0-06-112008-1", "0061120081", nil},
+		{"979 prefix", "979-10-323-0569-0", "", ErrPrefix},
+		{"invalid", "9780061120085", "", ErrChecksum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := To10(tt.in)
+			if err != tt.err {
+				t.Fatalf("To10(%q) error = %v, want %v", tt.in, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("To10(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeISSN(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  error
+	}{
+		{"hyphenated", "0378-5955", "0378-5955", nil},
+		{"without hyphen", "03178471", "0317-8471", nil},
+		{"ISSN label", "ISSN 0378-5955", "0378-5955", nil},
+		{"X check digit", "1000-002x", "1000-002X", nil},
+		{"bad checksum", "0378-5956", "", ErrISSNChecksum},
+		{"X where a digit is due", "0378-595X", "", ErrISSNChecksum},
+		{"letters", "03A8-5955", "", ErrISSNChars},
+		{"too short", "0378-595", "", ErrISSNLength},
+		{"ISBN", "9780061120084", "", ErrISSNLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeISSN(tt.in)
+			if err != tt.err {
+				t.Fatalf("NormalizeISSN(%q) error = %v, want %v", tt.in, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeISSN(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"library-management-system/config"
+	"library-management-system/models"
+	"library-management-system/notices"
+	"library-management-system/reports"
+)
+
+// task is a periodic maintenance job
+type task struct {
+	Name string
+	Run  func() error
+}
+
+// tasks lists the jobs executed on every scheduler tick
+var tasks = []task{
+	{Name: "clean expired reservations", Run: models.CleanExpiredReservations},
+	{Name: "anonymize borrow history", Run: anonymizeHistory},
+	{Name: "link book authors", Run: models.LinkUnlinkedBookAuthors},
+	{Name: "normalize stored ISBNs", Run: models.NormalizeStoredISBNs},
+	{Name: "predict serial issues", Run: models.PredictAllSerialIssues},
+	{Name: "release ended term reserves", Run: models.ReleaseEndedTermReserves},
+	{Name: "refresh recommendations", Run: models.RefreshRecommendations},
+	{Name: "send scheduled reports", Run: reports.DeliverDue},
+	{Name: "send overdue notices", Run: notices.SendDue},
+}
+
+// Start launches the background scheduler
+func Start() {
+	go func() {
+		// Run once at startup, then on every tick
+		runAll()
+
+		ticker := time.NewTicker(config.AppConfig.Jobs.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runAll()
+		}
+	}()
+}
+
+// runAll executes every task, logging failures without stopping the others
+func runAll() {
+	for _, t := range tasks {
+		if err := t.Run(); err != nil {
+			log.Printf("Background job %q failed: %v", t.Name, err)
+		}
+	}
+}
+
+// anonymizeHistory applies the configured borrow history retention policy
+func anonymizeHistory() error {
+	count, err := models.AnonymizeExpiredHistory(config.AppConfig.Privacy.RetentionDays)
+	if count > 0 {
+		log.Printf("Anonymized %d borrow records past the retention period", count)
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+// Package mail sends email, with optional attachments, through the configured SMTP
+// server.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Attachment is a file sent with a message
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an email to one or more recipients
+type Message struct {
+	To          []string
+	Subject     string
+	Body        string // Plain text
+	Attachments []Attachment
+}
+
+// Configured reports whether an SMTP server has been set up
+func Configured() bool {
+	return config.AppConfig.Mail.Host != ""
+}
+
+// Send delivers the message. The connection is upgraded to TLS when the server
+// offers it, and authenticated when a username is configured.
+func Send(m Message) error {
+	if !Configured() {
+		return errors.New("email is not configured; set SMTP_HOST")
+	}
+	if len(m.To) == 0 {
+		return errors.New("message has no recipients")
+	}
+
+	body, err := m.build()
+	if err != nil {
+		return err
+	}
+
+	cfg := config.AppConfig.Mail
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(cfg.Host, cfg.Port), auth, cfg.From, m.To, body)
+}
+
+// build encodes the message as MIME: a plain text body, with the attachments in a
+// multipart/mixed envelope when there are any
+func (m Message) build() ([]byte, error) {
+	for _, to := range m.To {
+		if strings.ContainsAny(to, "\r\n") {
+			return nil, errors.New("invalid recipient address")
+		}
+	}
+
+	var b bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", config.AppConfig.Mail.From)
+	header("To", strings.Join(m.To, ", "))
+	subject := strings.Join(strings.Fields(m.Subject), " ")
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID())
+	header("MIME-Version", "1.0")
+
+	if len(m.Attachments) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err := writeQuotedPrintable(&b, m.Body); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&b)
+	header("Content-Type", "multipart/mixed; boundary="+parts.Boundary())
+	b.WriteString("\r\n")
+
+	text, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(text, m.Body); err != nil {
+		return nil, err
+	}
+
+	for _, a := range m.Attachments {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, a.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeQuotedPrintable writes text with CRLF line endings as quoted-printable
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data as base64 in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := 76
+		if len(encoded) < n {
+			n = len(encoded)
+		}
+		if _, err := w.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// messageID returns a unique Message-ID in the sender's domain
+func messageID() string {
+	domain := "localhost"
+	if at := strings.LastIndex(config.AppConfig.Mail.From, "@"); at >= 0 {
+		domain = strings.Trim(config.AppConfig.Mail.From[at+1:], "> ")
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%x@%s>", time.Now().UnixNano(), random, domain)
+}
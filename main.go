@@ -8,8 +8,10 @@ import (
 	"github.com/joho/godotenv"
 
 	"library-management-system/config"
+	"library-management-system/jobs"
 	"library-management-system/models"
 	"library-management-system/routes"
+	"library-management-system/storage"
 	"library-management-system/utils"
 )
 
@@ -40,10 +42,23 @@ func main() {
 		log.Printf("Warning: Failed to create default librarian: %v", err)
 	}
 
+	// Set up the standard overdue notice stages if none exist
+	if err := models.CreateDefaultNoticeStages(); err != nil {
+		log.Printf("Warning: Failed to create default notice stages: %v", err)
+	}
+
+	// Start background maintenance jobs
+	jobs.Start()
+
 	// Create file server for static files
 	fileServer := http.FileServer(http.Dir(config.AppConfig.Template.StaticDir))
 	http.Handle("/static/", http.StripPrefix("/static/", fileServer))
 
+	// Serve uploaded files when they are kept on the local filesystem
+	if local, ok := storage.Default().(*storage.Local); ok {
+		http.Handle(config.AppConfig.Storage.URLPrefix, local.Handler())
+	}
+
 	// Set up routes
 	routes.SetupRoutes()
 
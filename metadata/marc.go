@@ -0,0 +1,243 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"library-management-system/isbn"
+)
+
+// marcSubfield is a coded value within a MARC data field
+type marcSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// marcField is a MARC control or data field
+type marcField struct {
+	Tag       string         `xml:"tag,attr"`
+	Value     string         `xml:",chardata"` // Control fields only
+	Subfields []marcSubfield `xml:"subfield"`
+}
+
+// marcRecord is a MARC 21 bibliographic record, decoded from MARCXML or ISO 2709
+type marcRecord struct {
+	Controlfields []marcField `xml:"controlfield"`
+	Datafields    []marcField `xml:"datafield"`
+}
+
+const marcXMLNamespace = "http://www.loc.gov/MARC21/slim"
+
+// values returns the given subfields of every field with the tag
+func (m *marcRecord) values(tag string, codes string) [][]string {
+	var out [][]string
+	for _, f := range m.Datafields {
+		if f.Tag != tag {
+			continue
+		}
+		var parts []string
+		for _, sf := range f.Subfields {
+			if strings.Contains(codes, sf.Code) {
+				parts = append(parts, strings.TrimSpace(sf.Value))
+			}
+		}
+		if len(parts) > 0 {
+			out = append(out, parts)
+		}
+	}
+	return out
+}
+
+// first returns the first of the given subfields from the first tag that has one
+func (m *marcRecord) first(tags []string, code string) string {
+	for _, tag := range tags {
+		if v := m.values(tag, code); len(v) > 0 {
+			return v[0][0]
+		}
+	}
+	return ""
+}
+
+// control returns a control field's value
+func (m *marcRecord) control(tag string) string {
+	for _, f := range m.Controlfields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// isbns returns the normalized ISBNs in the 020 fields
+func (m *marcRecord) isbns() []string {
+	var out []string
+	for _, v := range m.values("020", "a") {
+		// 020$a may carry a qualifier, e.g. "0061120081 (pbk.)"
+		fields := strings.Fields(v[0])
+		if len(fields) == 0 {
+			continue
+		}
+		if normalized, err := isbn.Normalize(fields[0]); err == nil {
+			out = append(out, normalized)
+		}
+	}
+	return out
+}
+
+// hasISBN reports whether the record describes the given ISBN-13
+func (m *marcRecord) hasISBN(code string) bool {
+	for _, candidate := range m.isbns() {
+		if candidate == code {
+			return true
+		}
+	}
+	return false
+}
+
+// trimISBD removes the ISBD punctuation that ends MARC subfields, e.g. "Title /"
+func trimISBD(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,=."))
+}
+
+// toRecord maps the MARC fields used by the catalog onto a Record
+func (m *marcRecord) toRecord(code string) *Record {
+	record := &Record{ISBN: code}
+
+	if title := m.values("245", "ab"); len(title) > 0 {
+		parts := title[0]
+		for i := range parts {
+			parts[i] = trimISBD(parts[i])
+		}
+		record.Title = strings.Join(parts, ": ")
+	}
+
+	for _, tag := range []string{"100", "110", "700", "710"} {
+		for _, v := range m.values(tag, "a") {
+			record.Authors = append(record.Authors, trimISBD(v[0]))
+		}
+	}
+
+	record.Publisher = trimISBD(m.first([]string{"264", "260"}, "b"))
+
+	date := m.first([]string{"264", "260"}, "c")
+	if year := yearPattern.FindString(date); year != "" {
+		record.Year, _ = strconv.Atoi(year)
+	} else if fixed := m.control("008"); len(fixed) >= 11 {
+		record.Year, _ = strconv.Atoi(fixed[7:11])
+	}
+
+	for _, tag := range []string{"600", "610", "650", "651"} {
+		for _, v := range m.values(tag, "avxyz") {
+			for i := range v {
+				v[i] = trimISBD(v[i])
+			}
+			record.Subjects = append(record.Subjects, strings.Join(v, " -- "))
+		}
+	}
+
+	record.Description = m.first([]string{"520"}, "a")
+
+	return record
+}
+
+// parseMARCXML decodes every record in a MARCXML document, which may be a single
+// record, a collection, or records embedded in another response such as SRU
+func parseMARCXML(r io.Reader) ([]*marcRecord, error) {
+	decoder := xml.NewDecoder(r)
+	var records []*marcRecord
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		if start.Name.Space != marcXMLNamespace && start.Name.Space != "" {
+			continue
+		}
+		record := &marcRecord{}
+		if err := decoder.DecodeElement(record, &start); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// ISO 2709 delimiters
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+)
+
+// parseISO2709 decodes every record in a binary MARC file
+func parseISO2709(data []byte) ([]*marcRecord, error) {
+	var records []*marcRecord
+	for len(data) > 0 {
+		if len(data) < 24 {
+			break
+		}
+		length, err := strconv.Atoi(string(data[0:5]))
+		if err != nil || length < 24 || length > len(data) {
+			return nil, errors.New("invalid MARC record length")
+		}
+		record, err := parseISO2709Record(data[:length])
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+
+		// Skip the record and any line breaks between records
+		data = bytes.TrimLeft(data[length:], "\r\n")
+	}
+	return records, nil
+}
+
+// parseISO2709Record decodes one binary MARC record
+func parseISO2709Record(data []byte) (*marcRecord, error) {
+	base, err := strconv.Atoi(string(data[12:17]))
+	if err != nil || base <= 24 || base > len(data) {
+		return nil, errors.New("invalid MARC base address")
+	}
+
+	record := &marcRecord{}
+	directory := data[24 : base-1]
+	for len(directory) >= 12 {
+		tag := string(directory[0:3])
+		length, err1 := strconv.Atoi(string(directory[3:7]))
+		start, err2 := strconv.Atoi(string(directory[7:12]))
+		directory = directory[12:]
+		if err1 != nil || err2 != nil || base+start+length > len(data) {
+			return nil, errors.New("invalid MARC directory entry")
+		}
+		value := bytes.TrimRight(data[base+start:base+start+length], string([]byte{fieldTerminator, recordTerminator}))
+
+		// Control fields have no indicators or subfields
+		if tag < "010" {
+			record.Controlfields = append(record.Controlfields, marcField{Tag: tag, Value: string(value)})
+			continue
+		}
+
+		field := marcField{Tag: tag}
+		parts := bytes.Split(value, []byte{subfieldDelimiter})
+		for _, part := range parts[1:] { // parts[0] holds the indicators
+			if len(part) == 0 {
+				continue
+			}
+			field.Subfields = append(field.Subfields, marcSubfield{Code: string(part[0]), Value: string(part[1:])})
+		}
+		record.Datafields = append(record.Datafields, field)
+	}
+
+	return record, nil
+}
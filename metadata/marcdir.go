@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MARCDirectory searches a local directory of MARC files, such as vendor record
+// loads. Files ending in .xml are read as MARCXML and files ending in .mrc as
+// binary MARC; each may hold any number of records.
+type MARCDirectory struct {
+	Dir string
+}
+
+// Name identifies the provider
+func (d *MARCDirectory) Name() string {
+	return "Local MARC files"
+}
+
+// Lookup finds the first record in the directory that carries the ISBN
+func (d *MARCDirectory) Lookup(ctx context.Context, code string) (*Record, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if entry.IsDir() {
+			continue
+		}
+
+		var records []*marcRecord
+		path := filepath.Join(d.Dir, entry.Name())
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".xml":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			records, err = parseMARCXML(bytes.NewReader(data))
+			if err != nil {
+				continue // Not a MARCXML file
+			}
+		case ".mrc":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			records, err = parseISO2709(data)
+			if err != nil {
+				continue // Not a binary MARC file
+			}
+		default:
+			continue
+		}
+
+		for _, record := range records {
+			if record.hasISBN(code) {
+				return record.toRecord(code), nil
+			}
+		}
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OpenLibrary looks records up with the Open Library Books API
+type OpenLibrary struct {
+	BaseURL string // e.g. https://openlibrary.org
+	Client  *http.Client
+}
+
+// openLibraryBook is the part of a jscmd=data response that is used
+type openLibraryBook struct {
+	Title       string          `json:"title"`
+	Subtitle    string          `json:"subtitle"`
+	PublishDate string          `json:"publish_date"`
+	Notes       json.RawMessage `json:"notes"`
+	Authors     []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Publishers []struct {
+		Name string `json:"name"`
+	} `json:"publishers"`
+	Subjects []struct {
+		Name string `json:"name"`
+	} `json:"subjects"`
+	Cover struct {
+		Large  string `json:"large"`
+		Medium string `json:"medium"`
+	} `json:"cover"`
+}
+
+var yearPattern = regexp.MustCompile(`(1[5-9]|20)\d{2}`)
+
+// Name identifies the provider
+func (o *OpenLibrary) Name() string {
+	return "Open Library"
+}
+
+// Lookup fetches the record for an ISBN
+func (o *OpenLibrary) Lookup(ctx context.Context, isbn string) (*Record, error) {
+	key := "ISBN:" + isbn
+	endpoint := strings.TrimRight(o.BaseURL, "/") + "/api/books?" + url.Values{
+		"bibkeys": {key},
+		"format":  {"json"},
+		"jscmd":   {"data"},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("open library returned %s", resp.Status)
+	}
+
+	var result map[string]openLibraryBook
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("open library returned an unreadable response: %v", err)
+	}
+	book, ok := result[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	record := &Record{
+		ISBN:     isbn,
+		Title:    book.Title,
+		CoverURL: book.Cover.Large,
+		Source:   o.Name(),
+	}
+	if book.Subtitle != "" {
+		record.Title += ": " + book.Subtitle
+	}
+	if record.CoverURL == "" {
+		record.CoverURL = book.Cover.Medium
+	}
+	for _, a := range book.Authors {
+		record.Authors = append(record.Authors, a.Name)
+	}
+	if len(book.Publishers) > 0 {
+		record.Publisher = book.Publishers[0].Name
+	}
+	if year := yearPattern.FindString(book.PublishDate); year != "" {
+		record.Year, _ = strconv.Atoi(year)
+	}
+	for _, s := range book.Subjects {
+		record.Subjects = append(record.Subjects, s.Name)
+	}
+
+	// Notes are either a plain string or {"type": ..., "value": ...}
+	var notes string
+	if json.Unmarshal(book.Notes, &notes) != nil {
+		var typed struct {
+			Value string `json:"value"`
+		}
+		if json.Unmarshal(book.Notes, &typed) == nil {
+			notes = typed.Value
+		}
+	}
+	record.Description = notes
+
+	return record, nil
+}
+
+func (o *OpenLibrary) client() *http.Client {
+	if o.Client != nil {
+		return o.Client
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,141 @@
+// Package metadata looks up bibliographic records by ISBN so librarians do not have
+// to type every field of a new book by hand.
+//
+// Sources implement Provider. The providers in use are chosen by configuration and
+// tried in order, and each takes its base URL or directory from configuration so a
+// local fixture server or directory can stand in for the real service.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"library-management-system/config"
+)
+
+// ErrNotFound is returned when a provider has no record for the ISBN
+var ErrNotFound = errors.New("no record found for this ISBN")
+
+// Record is the bibliographic description returned by a provider
+type Record struct {
+	ISBN        string
+	Title       string
+	Authors     []string
+	Publisher   string
+	Year        int
+	Subjects    []string
+	Description string
+	CoverURL    string
+	Source      string // Name of the provider that supplied the record
+}
+
+// Provider is a source of bibliographic records
+type Provider interface {
+	// Name identifies the provider in the interface and logs
+	Name() string
+	// Lookup returns the record for a normalized ISBN-13, or ErrNotFound
+	Lookup(ctx context.Context, isbn string) (*Record, error)
+}
+
+// Chain tries each provider in turn and returns the first record found
+type Chain []Provider
+
+// Name lists the providers in the chain
+func (c Chain) Name() string {
+	var names []string
+	for _, p := range c {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// Lookup returns the first record found. If no provider has a record, the last
+// error other than ErrNotFound is returned so outages are not reported as misses.
+func (c Chain) Lookup(ctx context.Context, isbn string) (*Record, error) {
+	lastErr := ErrNotFound
+	for _, p := range c {
+		record, err := p.Lookup(ctx, isbn)
+		if err == nil {
+			if record.Source == "" {
+				record.Source = p.Name()
+			}
+			return record, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			log.Printf("Metadata provider %s failed for %s: %v", p.Name(), isbn, err)
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+var (
+	mu       sync.RWMutex
+	provider Provider
+)
+
+// SetProvider replaces the configured providers, e.g. with a fixture in tests
+func SetProvider(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	provider = p
+}
+
+// Default returns the providers named in the configuration, building them on first use
+func Default() Provider {
+	mu.RLock()
+	p := provider
+	mu.RUnlock()
+	if p != nil {
+		return p
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if provider == nil {
+		provider = fromConfig()
+	}
+	return provider
+}
+
+// Lookup finds a record for the ISBN using the configured providers
+func Lookup(ctx context.Context, isbn string) (*Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.AppConfig.Metadata.Timeout)
+	defer cancel()
+	return Default().Lookup(ctx, isbn)
+}
+
+// fromConfig builds the provider chain from configuration, skipping providers that
+// are named but not configured
+func fromConfig() Provider {
+	cfg := config.AppConfig.Metadata
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	var chain Chain
+	for _, name := range strings.Split(cfg.Providers, ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "openlibrary":
+			chain = append(chain, &OpenLibrary{BaseURL: cfg.OpenLibraryURL, Client: client})
+		case "sru":
+			if cfg.SRUURL == "" {
+				log.Println("Metadata provider sru is enabled but SRU_URL is not set")
+				continue
+			}
+			chain = append(chain, &SRU{BaseURL: cfg.SRUURL, Client: client})
+		case "marcdir":
+			if cfg.MARCDir == "" {
+				log.Println("Metadata provider marcdir is enabled but MARC_DIR is not set")
+				continue
+			}
+			chain = append(chain, &MARCDirectory{Dir: cfg.MARCDir})
+		case "":
+		default:
+			log.Printf("Unknown metadata provider %q", name)
+		}
+	}
+	return chain
+}
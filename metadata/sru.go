@@ -0,0 +1,74 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SRU queries a library catalogue over SRU (Search/Retrieve via URL), the HTTP
+// successor to Z39.50 offered by most national and union catalogues, asking for
+// MARCXML records
+type SRU struct {
+	BaseURL string // e.g. http://lx2.loc.gov:210/LCDB
+	Client  *http.Client
+}
+
+// Name identifies the provider
+func (s *SRU) Name() string {
+	return "SRU catalogue"
+}
+
+// Lookup fetches the record for an ISBN
+func (s *SRU) Lookup(ctx context.Context, code string) (*Record, error) {
+	endpoint := s.BaseURL
+	if strings.Contains(endpoint, "?") {
+		endpoint += "&"
+	} else {
+		endpoint += "?"
+	}
+	endpoint += url.Values{
+		"version":        {"1.1"},
+		"operation":      {"searchRetrieve"},
+		"query":          {"bath.isbn=" + code},
+		"maximumRecords": {"5"},
+		"recordSchema":   {"marcxml"},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SRU server returned %s", resp.Status)
+	}
+
+	records, err := parseMARCXML(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("SRU server returned an unreadable response: %v", err)
+	}
+
+	// Prefer the record that carries the ISBN; catalogues index 020$z and related
+	// editions too
+	for _, record := range records {
+		if record.hasISBN(code) {
+			return record.toRecord(code), nil
+		}
+	}
+	if len(records) > 0 {
+		return records[0].toRecord(code), nil
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,307 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Vendor is a supplier the library orders books from
+type Vendor struct {
+	ID            int
+	Name          string
+	ContactName   string
+	Email         string
+	Phone         string
+	Address       string
+	AccountNumber string // The library's customer number with the vendor
+	Notes         string
+	Active        bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Fund is a budget line for one fiscal year that orders are charged to
+type Fund struct {
+	ID         int
+	Code       string
+	Name       string
+	FiscalYear int
+	Allocated  float64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+
+	// Computed properties
+	Encumbered float64 // Committed on open orders and not yet invoiced
+	Expended   float64 // Invoiced
+}
+
+// Available returns what is left of the allocation after commitments and spending
+func (f *Fund) Available() float64 {
+	return f.Allocated - f.Encumbered - f.Expended
+}
+
+// PercentSpent returns the share of the allocation committed or spent, for budget bars
+func (f *Fund) PercentSpent() int {
+	if f.Allocated <= 0 {
+		return 0
+	}
+	return int((f.Encumbered + f.Expended) / f.Allocated * 100)
+}
+
+// Label returns the fund code and name
+func (f *Fund) Label() string {
+	return f.Code + " " + f.Name
+}
+
+// FiscalYearOf returns the fiscal year a date falls in. Fiscal years are named after
+// the calendar year they end in, so with a July start, July 2025 is in 2026.
+func FiscalYearOf(t time.Time) int {
+	start := config.AppConfig.Acquisitions.FiscalYearStartMonth
+	if start <= 1 || start > 12 {
+		return t.Year()
+	}
+	if int(t.Month()) >= start {
+		return t.Year() + 1
+	}
+	return t.Year()
+}
+
+// CurrentFiscalYear returns the fiscal year of today's date
+func CurrentFiscalYear() int {
+	return FiscalYearOf(time.Now())
+}
+
+// GetAllVendors retrieves vendors by name; inactive vendors are included on request
+func GetAllVendors(includeInactive bool) ([]*Vendor, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id, name, contact_name, email, phone, address, account_number, notes, active, created_at, updated_at
+                FROM vendors
+                WHERE active OR $1
+                ORDER BY LOWER(name)
+        `, includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var vendors []*Vendor
+	for rows.Next() {
+		v := &Vendor{}
+		err := rows.Scan(&v.ID, &v.Name, &v.ContactName, &v.Email, &v.Phone, &v.Address, &v.AccountNumber,
+			&v.Notes, &v.Active, &v.CreatedAt, &v.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		vendors = append(vendors, v)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return vendors, nil
+}
+
+// GetVendorByID retrieves a vendor
+func GetVendorByID(id int) (*Vendor, error) {
+	db := config.GetDB()
+
+	v := &Vendor{}
+	err := db.QueryRow(`
+                SELECT id, name, contact_name, email, phone, address, account_number, notes, active, created_at, updated_at
+                FROM vendors WHERE id = $1
+        `, id).Scan(&v.ID, &v.Name, &v.ContactName, &v.Email, &v.Phone, &v.Address, &v.AccountNumber,
+		&v.Notes, &v.Active, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("vendor not found")
+		}
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Save creates the vendor or saves changes to it
+func (v *Vendor) Save() error {
+	v.Name = strings.TrimSpace(v.Name)
+	if v.Name == "" {
+		return errors.New("vendor name is required")
+	}
+
+	db := config.GetDB()
+
+	if v.ID == 0 {
+		return db.QueryRow(`
+                        INSERT INTO vendors (name, contact_name, email, phone, address, account_number, notes, active)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+                        RETURNING id, created_at, updated_at
+                `, v.Name, v.ContactName, v.Email, v.Phone, v.Address, v.AccountNumber, v.Notes, v.Active,
+		).Scan(&v.ID, &v.CreatedAt, &v.UpdatedAt)
+	}
+
+	_, err := db.Exec(`
+                UPDATE vendors
+                SET name = $1, contact_name = $2, email = $3, phone = $4, address = $5, account_number = $6,
+                        notes = $7, active = $8, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $9
+        `, v.Name, v.ContactName, v.Email, v.Phone, v.Address, v.AccountNumber, v.Notes, v.Active, v.ID)
+
+	return err
+}
+
+// fundTotals computes each fund's encumbrance and expenditure. An order encumbers
+// its line total from the time it is placed until its final invoice; invoices count
+// as expenditure.
+const fundTotals = `
+                SELECT f.id, f.code, f.name, f.fiscal_year, f.allocated, f.created_at, f.updated_at,
+                        COALESCE((
+                                SELECT SUM(GREATEST(t.total - t.invoiced, 0)) FROM (
+                                        SELECT
+                                                (SELECT COALESCE(SUM(l.quantity * l.unit_price), 0) FROM purchase_order_lines l WHERE l.order_id = o.id) AS total,
+                                                (SELECT COALESCE(SUM(i.amount), 0) FROM invoices i WHERE i.order_id = o.id) AS invoiced
+                                        FROM purchase_orders o
+                                        WHERE o.fund_id = f.id AND o.status IN ('ordered', 'partial', 'received')
+                                ) t
+                        ), 0),
+                        COALESCE((
+                                SELECT SUM(i.amount) FROM invoices i
+                                JOIN purchase_orders o ON o.id = i.order_id
+                                WHERE o.fund_id = f.id
+                        ), 0)
+                FROM funds f`
+
+// scanFund reads a row selected with fundTotals
+func scanFund(row interface{ Scan(...interface{}) error }) (*Fund, error) {
+	f := &Fund{}
+	err := row.Scan(&f.ID, &f.Code, &f.Name, &f.FiscalYear, &f.Allocated, &f.CreatedAt, &f.UpdatedAt,
+		&f.Encumbered, &f.Expended)
+	return f, err
+}
+
+// GetFunds retrieves the funds of a fiscal year with their encumbrances and expenditure
+func GetFunds(fiscalYear int) ([]*Fund, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(fundTotals+`
+                WHERE f.fiscal_year = $1
+                ORDER BY f.code
+        `, fiscalYear)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var funds []*Fund
+	for rows.Next() {
+		f, err := scanFund(rows)
+		if err != nil {
+			return nil, err
+		}
+		funds = append(funds, f)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return funds, nil
+}
+
+// GetFundByID retrieves a fund with its encumbrance and expenditure
+func GetFundByID(id int) (*Fund, error) {
+	db := config.GetDB()
+
+	f, err := scanFund(db.QueryRow(fundTotals+" WHERE f.id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("fund not found")
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// GetFiscalYears lists the fiscal years that have funds, newest first, always
+// including the current one
+func GetFiscalYears() ([]int, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT DISTINCT fiscal_year FROM funds
+                UNION SELECT $1::int
+                ORDER BY 1 DESC
+        `, CurrentFiscalYear())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var years []int
+	for rows.Next() {
+		var year int
+		if err := rows.Scan(&year); err != nil {
+			return nil, err
+		}
+		years = append(years, year)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return years, nil
+}
+
+// Save creates the fund or saves changes to it
+func (f *Fund) Save() error {
+	f.Code = strings.ToUpper(strings.TrimSpace(f.Code))
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Code == "" || f.Name == "" {
+		return errors.New("fund code and name are required")
+	}
+	if f.FiscalYear < 1900 {
+		return errors.New("a valid fiscal year is required")
+	}
+	if f.Allocated < 0 {
+		return errors.New("the allocation cannot be negative")
+	}
+
+	db := config.GetDB()
+
+	var err error
+	if f.ID == 0 {
+		err = db.QueryRow(`
+                        INSERT INTO funds (code, name, fiscal_year, allocated)
+                        VALUES ($1, $2, $3, $4)
+                        RETURNING id, created_at, updated_at
+                `, f.Code, f.Name, f.FiscalYear, f.Allocated).Scan(&f.ID, &f.CreatedAt, &f.UpdatedAt)
+	} else {
+		_, err = db.Exec(`
+                        UPDATE funds
+                        SET code = $1, name = $2, fiscal_year = $3, allocated = $4, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $5
+                `, f.Code, f.Name, f.FiscalYear, f.Allocated, f.ID)
+	}
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return errors.New("fund " + f.Code + " already exists for this fiscal year")
+	}
+
+	return err
+}
@@ -0,0 +1,631 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Contributor role constants
+const (
+	RoleAuthor      = "author"
+	RoleEditor      = "editor"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+	RoleContributor = "contributor"
+)
+
+// ContributorRoles lists the roles in the order they are displayed
+var ContributorRoles = []string{RoleAuthor, RoleEditor, RoleTranslator, RoleIllustrator, RoleContributor}
+
+// Author represents an authority record for a person or organisation
+type Author struct {
+	ID        int
+	Name      string // Authorised form, e.g. "Orwell, George"
+	Dates     string
+	Biography string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// Computed properties
+	Variants []*AuthorVariant
+	SeeAlso  []*Author
+	Works    []*AuthorWork
+}
+
+// AuthorVariant is an alternative form of an author's name
+type AuthorVariant struct {
+	ID       int
+	AuthorID int
+	Name     string
+}
+
+// AuthorWork is a book linked to an author in a given role
+type AuthorWork struct {
+	Book *Book
+	Role string
+}
+
+// Contributor links an author to a book in a given role
+type Contributor struct {
+	Author *Author
+	Role   string
+}
+
+// ContributorInput is a name and role entered on the book form
+type ContributorInput struct {
+	Name string
+	Role string
+}
+
+// IsValidContributorRole checks a role against the known contributor roles
+func IsValidContributorRole(role string) bool {
+	for _, r := range ContributorRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorMatchKey normalises a name so that "Orwell, George" and "George Orwell"
+// compare equal. Anything after a second comma (usually dates) is ignored.
+func AuthorMatchKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.Replace(name, ".", " ", -1)
+
+	parts := strings.Split(name, ",")
+	if len(parts) >= 2 && strings.TrimSpace(parts[1]) != "" {
+		name = parts[1] + " " + parts[0]
+	}
+
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// GetAuthorByID retrieves an author with their variants and see-also references
+func GetAuthorByID(id int) (*Author, error) {
+	db := config.GetDB()
+
+	// Execute query
+	author := &Author{}
+	err := db.QueryRow(`
+                SELECT id, name, dates, COALESCE(biography, ''), created_at, updated_at
+                FROM authors
+                WHERE id = $1
+        `, id).Scan(&author.ID, &author.Name, &author.Dates, &author.Biography, &author.CreatedAt, &author.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("author not found")
+		}
+		return nil, err
+	}
+
+	// Get variant names
+	rows, err := db.Query("SELECT id, author_id, name FROM author_variants WHERE author_id = $1 ORDER BY name", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		variant := &AuthorVariant{}
+		if err := rows.Scan(&variant.ID, &variant.AuthorID, &variant.Name); err != nil {
+			return nil, err
+		}
+		author.Variants = append(author.Variants, variant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get see-also references
+	author.SeeAlso, err = queryAuthors(`
+                SELECT a.id, a.name, a.dates, COALESCE(a.biography, ''), a.created_at, a.updated_at
+                FROM authors a
+                JOIN author_see_also s ON s.related_id = a.id
+                WHERE s.author_id = $1
+                ORDER BY a.name
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return author, nil
+}
+
+// GetAuthorWorks retrieves the books an author is linked to, by title
+func GetAuthorWorks(authorID int) ([]*AuthorWork, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT book_id, role FROM book_authors
+                JOIN books ON books.id = book_authors.book_id
+                WHERE author_id = $1
+                ORDER BY books.title, role
+        `, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var links []struct {
+		bookID int
+		role   string
+	}
+	for rows.Next() {
+		var link struct {
+			bookID int
+			role   string
+		}
+		if err := rows.Scan(&link.bookID, &link.role); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get related books
+	var works []*AuthorWork
+	for _, link := range links {
+		book, err := GetBookByID(link.bookID)
+		if err != nil {
+			continue
+		}
+		works = append(works, &AuthorWork{Book: book, Role: link.role})
+	}
+
+	return works, nil
+}
+
+// SearchAuthors retrieves authors whose name or a variant matches the search
+func SearchAuthors(search string) ([]*Author, error) {
+	return queryAuthors(`
+                SELECT a.id, a.name, a.dates, COALESCE(a.biography, ''), a.created_at, a.updated_at
+                FROM authors a
+                WHERE $1 = '' OR a.name ILIKE '%' || $1 || '%'
+                        OR EXISTS (SELECT 1 FROM author_variants v WHERE v.author_id = a.id AND v.name ILIKE '%' || $1 || '%')
+                ORDER BY a.name
+                LIMIT 200
+        `, search)
+}
+
+// queryAuthors runs a query returning author rows
+func queryAuthors(query string, args ...interface{}) ([]*Author, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var authors []*Author
+	for rows.Next() {
+		author := &Author{}
+		err := rows.Scan(&author.ID, &author.Name, &author.Dates, &author.Biography, &author.CreatedAt, &author.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		authors = append(authors, author)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// FindAuthorByName looks up an author whose authorised or variant name matches
+func FindAuthorByName(name string) (*Author, error) {
+	db := config.GetDB()
+
+	key := AuthorMatchKey(name)
+	if key == "" {
+		return nil, errors.New("author name is required")
+	}
+
+	var id int
+	err := db.QueryRow(`
+                SELECT id FROM authors WHERE match_key = $1
+                UNION ALL
+                SELECT author_id FROM author_variants WHERE match_key = $1
+                LIMIT 1
+        `, key).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return GetAuthorByID(id)
+}
+
+// FindOrCreateAuthor returns the authority record matching a name, creating one if needed,
+// and keeping a different spelling of the name as a variant so it can still be searched
+func FindOrCreateAuthor(name string) (*Author, error) {
+	author, err := FindAuthorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if author != nil {
+		if !strings.EqualFold(strings.TrimSpace(name), author.Name) {
+			if err := AddAuthorVariant(author.ID, name); err != nil {
+				return nil, err
+			}
+		}
+		return author, nil
+	}
+
+	author = &Author{Name: strings.TrimSpace(name)}
+	if err := author.Create(); err != nil {
+		return nil, err
+	}
+
+	return author, nil
+}
+
+// Create saves a new author to the database
+func (a *Author) Create() error {
+	db := config.GetDB()
+
+	return db.QueryRow(`
+                INSERT INTO authors (name, match_key, dates, biography)
+                VALUES ($1, $2, $3, $4)
+                RETURNING id, created_at, updated_at
+        `, a.Name, AuthorMatchKey(a.Name), a.Dates, a.Biography).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// Update saves changes to an author and refreshes the author text of linked books
+func (a *Author) Update() error {
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                UPDATE authors
+                SET name = $1, match_key = $2, dates = $3, biography = $4, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $5
+        `, a.Name, AuthorMatchKey(a.Name), a.Dates, a.Biography, a.ID)
+	if err != nil {
+		return err
+	}
+
+	return refreshAuthorBooks(a.ID)
+}
+
+// AddAuthorVariant records an alternative name for an author
+func AddAuthorVariant(authorID int, name string) error {
+	key := AuthorMatchKey(name)
+	if key == "" {
+		return errors.New("variant name is required")
+	}
+
+	// A variant must not already identify a different author
+	existing, err := FindAuthorByName(name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != authorID {
+		return errors.New("this name already belongs to " + existing.Name + "; merge the authors instead")
+	}
+
+	db := config.GetDB()
+
+	_, err = db.Exec(`
+                INSERT INTO author_variants (author_id, name, match_key)
+                VALUES ($1, $2, $3)
+                ON CONFLICT (author_id, match_key) DO NOTHING
+        `, authorID, strings.TrimSpace(name), key)
+
+	return err
+}
+
+// DeleteAuthorVariant removes a variant name from an author
+func DeleteAuthorVariant(authorID, variantID int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM author_variants WHERE id = $1 AND author_id = $2", variantID, authorID)
+
+	return err
+}
+
+// AddSeeAlso links two authors in both directions, e.g. a writer and their pseudonym
+func AddSeeAlso(authorID, relatedID int) error {
+	if authorID == relatedID {
+		return errors.New("an author cannot refer to themselves")
+	}
+
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                INSERT INTO author_see_also (author_id, related_id)
+                VALUES ($1, $2), ($2, $1)
+                ON CONFLICT DO NOTHING
+        `, authorID, relatedID)
+
+	return err
+}
+
+// DeleteSeeAlso removes the link between two authors in both directions
+func DeleteSeeAlso(authorID, relatedID int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                DELETE FROM author_see_also
+                WHERE (author_id = $1 AND related_id = $2) OR (author_id = $2 AND related_id = $1)
+        `, authorID, relatedID)
+
+	return err
+}
+
+// MergeAuthors folds a duplicate author into another. The duplicate's books, variants
+// and references move to the target and its name is kept as a variant.
+func MergeAuthors(sourceID, targetID int) error {
+	if sourceID == targetID {
+		return errors.New("cannot merge an author into themselves")
+	}
+
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Get the duplicate's name
+	var name string
+	err = tx.QueryRow("SELECT name FROM authors WHERE id = $1", sourceID).Scan(&name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("author not found")
+		}
+		return err
+	}
+
+	statements := []string{
+		// Move book links, skipping any the target already has
+		`INSERT INTO book_authors (book_id, author_id, role, position)
+                 SELECT book_id, $2, role, position FROM book_authors WHERE author_id = $1
+                 ON CONFLICT DO NOTHING`,
+		// Move variants and keep the duplicate's name as one
+		`INSERT INTO author_variants (author_id, name, match_key)
+                 SELECT $2, name, match_key FROM (
+                         SELECT name, match_key FROM author_variants WHERE author_id = $1
+                         UNION ALL
+                         SELECT name, match_key FROM authors WHERE id = $1
+                 ) names
+                 WHERE match_key <> (SELECT match_key FROM authors WHERE id = $2)
+                 ON CONFLICT DO NOTHING`,
+		// Move see-also references
+		`INSERT INTO author_see_also (author_id, related_id)
+                 SELECT $2, related_id FROM author_see_also WHERE author_id = $1 AND related_id <> $2
+                 UNION ALL
+                 SELECT author_id, $2 FROM author_see_also WHERE related_id = $1 AND author_id <> $2
+                 ON CONFLICT DO NOTHING`,
+		`DELETE FROM authors WHERE id = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, sourceID, targetID); err != nil {
+			return err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return refreshAuthorBooks(targetID)
+}
+
+// GetBookContributors retrieves the authors linked to a book in display order
+func GetBookContributors(bookID int) ([]*Contributor, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT a.id, a.name, a.dates, COALESCE(a.biography, ''), a.created_at, a.updated_at, ba.role
+                FROM book_authors ba
+                JOIN authors a ON a.id = ba.author_id
+                WHERE ba.book_id = $1
+                ORDER BY ba.position, a.name
+        `, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var contributors []*Contributor
+	for rows.Next() {
+		author := &Author{}
+		contributor := &Contributor{Author: author}
+		err := rows.Scan(&author.ID, &author.Name, &author.Dates, &author.Biography, &author.CreatedAt, &author.UpdatedAt, &contributor.Role)
+		if err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, contributor)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contributors, nil
+}
+
+// SetBookContributors replaces a book's contributors, matching each name to an
+// authority record, and updates the book's author text to match
+func SetBookContributors(bookID int, inputs []ContributorInput) error {
+	db := config.GetDB()
+
+	// Resolve names before touching the links
+	var authorIDs []int
+	for _, input := range inputs {
+		if !IsValidContributorRole(input.Role) {
+			return errors.New("invalid contributor role: " + input.Role)
+		}
+		author, err := FindOrCreateAuthor(input.Name)
+		if err != nil {
+			return err
+		}
+		authorIDs = append(authorIDs, author.ID)
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM book_authors WHERE book_id = $1", bookID); err != nil {
+		return err
+	}
+	for i, input := range inputs {
+		_, err := tx.Exec(`
+                        INSERT INTO book_authors (book_id, author_id, role, position)
+                        VALUES ($1, $2, $3, $4)
+                        ON CONFLICT DO NOTHING
+                `, bookID, authorIDs[i], input.Role, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return refreshBookAuthorText(bookID)
+}
+
+// refreshBookAuthorText rewrites books.author from the linked authors so that lists,
+// reports and sorting keep working from the single column
+func refreshBookAuthorText(bookID int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                UPDATE books
+                SET author = sub.names, updated_at = CURRENT_TIMESTAMP
+                FROM (
+                        SELECT string_agg(a.name, '; ' ORDER BY ba.position) AS names
+                        FROM book_authors ba
+                        JOIN authors a ON a.id = ba.author_id
+                        WHERE ba.book_id = $1 AND ba.role = $2
+                ) sub
+                WHERE books.id = $1 AND sub.names IS NOT NULL
+        `, bookID, RoleAuthor)
+
+	return err
+}
+
+// refreshAuthorBooks rewrites the author text of every book linked to an author
+func refreshAuthorBooks(authorID int) error {
+	db := config.GetDB()
+
+	rows, err := db.Query("SELECT DISTINCT book_id FROM book_authors WHERE author_id = $1", authorID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var bookIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		bookIDs = append(bookIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range bookIDs {
+		if err := refreshBookAuthorText(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OtherContributorsText formats a book's non-author contributors one per line as
+// "Role: Name", the format accepted by the book form
+func (b *Book) OtherContributorsText() string {
+	var lines []string
+	for _, c := range b.Contributors {
+		if c.Role != RoleAuthor {
+			lines = append(lines, strings.ToUpper(c.Role[:1])+c.Role[1:]+": "+c.Author.Name)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SplitAuthorNames splits the free-text author field into individual names
+func SplitAuthorNames(text string) []string {
+	var names []string
+	for _, name := range strings.Split(text, ";") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// LinkUnlinkedBookAuthors creates author links for books catalogued before authority
+// records existed, using the books' author text
+func LinkUnlinkedBookAuthors() error {
+	db := config.GetDB()
+
+	rows, err := db.Query(`
+                SELECT id, author FROM books
+                WHERE NOT EXISTS (SELECT 1 FROM book_authors WHERE book_id = books.id)
+        `)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	books := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var author string
+		if err := rows.Scan(&id, &author); err != nil {
+			return err
+		}
+		books[id] = author
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, author := range books {
+		var inputs []ContributorInput
+		for _, name := range SplitAuthorNames(author) {
+			inputs = append(inputs, ContributorInput{Name: name, Role: RoleAuthor})
+		}
+		if len(inputs) == 0 {
+			continue
+		}
+		if err := SetBookContributors(id, inputs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
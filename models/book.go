@@ -4,9 +4,12 @@ import (
         "database/sql"
         "errors"
         "fmt"
+        "strings"
         "time"
 
         "library-management-system/config"
+        "library-management-system/covers"
+        "library-management-system/isbn"
 )
 
 // Book represents a book in the library system
@@ -25,11 +28,26 @@ type Book struct {
         AvailableCopy   int       // Alias for Available
         TotalCopies     int       // Alias for Quantity
         AddedBy         sql.NullInt64 // Using NullInt64 to handle NULL values in the database
+        ReplacementCost float64
+        CallNumber      string
+        ClassID         sql.NullInt64
+        WorkID          sql.NullInt64
+        EditionStatement string
+        CoverURL        string
+        CoverKey        string // Uploaded cover in the storage backend
         CreatedAt       time.Time
         UpdatedAt       time.Time
         
         // Computed properties
         AddedByUser     *User
+        Contributors    []*Contributor
+        Subjects        []*Subject
+        Class           *Class
+        EditionCount    int // Editions of the same work in a collapsed result
+        WorkAvailable   int // Available copies across those editions
+        RatingAverage   float64 // Average of the published review ratings
+        RatingCount     int
+        Editions        []*Book // Other editions of the same work
 }
 
 // SetAliasFields sets alias fields for template compatibility
@@ -47,7 +65,7 @@ func GetBookByID(id int) (*Book, error) {
         book := &Book{}
         err := db.QueryRow(`
                 SELECT id, title, author, isbn, publisher, publication_year, category, description, 
-                        quantity, available, added_by, created_at, updated_at
+                        quantity, available, added_by, replacement_cost, call_number, class_id, work_id, edition_statement, cover_url, cover_key, created_at, updated_at
                 FROM books
                 WHERE id = $1
         `, id).Scan(
@@ -62,6 +80,13 @@ func GetBookByID(id int) (*Book, error) {
                 &book.Quantity,
                 &book.Available,
                 &book.AddedBy,
+                &book.ReplacementCost,
+                &book.CallNumber,
+                &book.ClassID,
+                &book.WorkID,
+                &book.EditionStatement,
+                &book.CoverURL,
+                &book.CoverKey,
                 &book.CreatedAt,
                 &book.UpdatedAt,
         )
@@ -79,50 +104,228 @@ func GetBookByID(id int) (*Book, error) {
                 book.AddedByUser, _ = GetUserByID(addedByID)
         }
         
+        // Get linked authors and other contributors
+        book.Contributors, _ = GetBookContributors(book.ID)
+        
+        // Get subject headings and classification
+        book.Subjects, _ = GetBookSubjects(book.ID)
+        if book.ClassID.Valid {
+                book.Class, _ = GetClassByID(int(book.ClassID.Int64))
+        }
+        
         // Set alias fields for template compatibility
         book.SetAliasFields()
         
         return book, nil
 }
 
-// GetBooks retrieves books with optional search and pagination
-func GetBooks(search string, searchBy string, page int) ([]*Book, error) {
+// GetBookByBarcode retrieves a book by the ISBN printed on its barcode label
+func GetBookByBarcode(barcode string) (*Book, error) {
+        id, err := findBookByBarcode(barcode)
+        if err != nil {
+                return nil, err
+        }
+        if id == 0 {
+                return nil, errors.New("no book found for barcode " + barcode)
+        }
+        
+        return GetBookByID(id)
+}
+
+// findBookByBarcode returns the ID of the book with the barcode's ISBN, or 0 if
+// there is none
+func findBookByBarcode(barcode string) (int, error) {
         db := config.GetDB()
         
-        // Build query
-        query := `
-                SELECT id, title, author, isbn, publisher, publication_year, category, description, 
-                        quantity, available, added_by, created_at, updated_at
-                FROM books
-        `
+        // Barcode scanners may include hyphens or spaces depending on the label, and
+        // older labels carry the ISBN-10
+        code := isbn.Clean(barcode)
+        if normalized, err := isbn.Normalize(code); err == nil {
+                code = normalized
+        }
+        
+        var id int
+        err := db.QueryRow(`
+                SELECT id FROM books
+                WHERE `+isbnMatchCondition+` = $1
+                LIMIT 1
+        `, code).Scan(&id)
+        if err == sql.ErrNoRows {
+                return 0, nil
+        }
         
+        return id, err
+}
+
+// isbnMatchCondition is the stored ISBN with any hyphens or spaces removed, for rows
+// saved before ISBNs were normalized
+const isbnMatchCondition = "REPLACE(REPLACE(UPPER(isbn), '-', ''), ' ', '')"
+
+// ISBN10 returns the book's ISBN-10, or an empty string if it has none
+func (b *Book) ISBN10() string {
+        short, err := isbn.To10(b.ISBN)
+        if err != nil {
+                return ""
+        }
+        return short
+}
+
+// CoverImage returns the address of the book's cover in the given thumbnail size
+// ("small", "medium" or "large"): the uploaded cover if there is one, otherwise the
+// cover URL entered by hand or found by a metadata provider
+func (b *Book) CoverImage(size string) string {
+        if b.CoverKey != "" {
+                return covers.URL(b.CoverKey, size)
+        }
+        return b.CoverURL
+}
+
+// authorSearchCondition matches books linked to an author whose authorised or variant
+// name matches $1, or whose match key is $n, so searching "Orwell, George" also finds
+// books catalogued under "George Orwell"
+func authorSearchCondition(n int) string {
+        return fmt.Sprintf(`id IN (
+                SELECT ba.book_id FROM book_authors ba
+                JOIN authors a ON a.id = ba.author_id
+                WHERE a.name ILIKE $1
+                        OR a.id IN (SELECT author_id FROM author_variants WHERE name ILIKE $1)
+                        OR a.match_key = $%[1]d
+                        OR a.id IN (SELECT author_id FROM author_variants WHERE match_key = $%[1]d)
+        )`, n)
+}
+
+// subjectSearchCondition matches books with a subject heading matching $1
+const subjectSearchCondition = `id IN (
+                SELECT bs.book_id FROM book_subjects bs
+                JOIN subjects s ON s.id = bs.subject_id
+                WHERE s.name ILIKE $1
+        )`
+
+// BookFilter narrows the catalog to a subject or a classification branch and sets
+// the sort order
+type BookFilter struct {
+        SubjectID int
+        ClassID   int    // Includes books in narrower classes
+        Sort      string // "title" (default), "shelf" for call number order or "rating"
+        CollapseWorks bool // Show one row per work instead of one per edition
+}
+
+// bookColumns lists the columns scanned into a Book by GetBooks
+const bookColumns = `id, title, author, isbn, publisher, publication_year, category, description, 
+                quantity, available, added_by, replacement_cost, call_number, class_id, work_id, edition_statement, 
+                cover_url, cover_key, created_at, updated_at, call_number_sort`
+
+// bookRatingColumns selects the published review rating of each row in a query over books
+const bookRatingColumns = `(SELECT COALESCE(AVG(rv.rating), 0) FROM reviews rv WHERE rv.book_id = books.id AND rv.status = 'published') AS rating_average,
+                (SELECT COUNT(*) FROM reviews rv WHERE rv.book_id = books.id AND rv.status = 'published') AS rating_count`
+
+// workKey groups the editions of a work; books without a work form a group of one
+const workKey = "COALESCE(work_id, -id)"
+
+// buildBookConditions builds the WHERE clause shared by GetBooks and CountBooks
+func buildBookConditions(search string, searchBy string, filter BookFilter) (string, []interface{}) {
+        var conditions []string
         var args []interface{}
-        var whereClause string
         
         // Add search condition if provided
         if search != "" {
+                byAuthor := false
                 switch searchBy {
                 case "title":
-                        whereClause = "WHERE title ILIKE $1"
+                        conditions = append(conditions, "title ILIKE $1")
                 case "author":
-                        whereClause = "WHERE author ILIKE $1"
+                        conditions = append(conditions, "(author ILIKE $1 OR "+authorSearchCondition(2)+")")
+                        byAuthor = true
                 case "isbn":
-                        whereClause = "WHERE isbn ILIKE $1"
-                case "category":
-                        whereClause = "WHERE category ILIKE $1"
+                        conditions = append(conditions, isbnMatchCondition+" LIKE $1")
+                case "category", "genre", "subject":
+                        conditions = append(conditions, "(category ILIKE $1 OR "+subjectSearchCondition+")")
+                case "call_number":
+                        conditions = append(conditions, "call_number ILIKE $1")
                 default:
-                        whereClause = "WHERE title ILIKE $1 OR author ILIKE $1 OR isbn ILIKE $1"
+                        conditions = append(conditions, "(title ILIKE $1 OR author ILIKE $1 OR isbn ILIKE $1 OR "+authorSearchCondition(2)+")")
+                        byAuthor = true
+                }
+                if searchBy == "isbn" {
+                        // Match ISBNs however they were typed, and ISBN-10s against stored ISBN-13s
+                        code := isbn.Clean(search)
+                        if normalized, err := isbn.Normalize(code); err == nil {
+                                code = normalized
+                        }
+                        args = append(args, "%"+code+"%")
+                } else {
+                        args = append(args, "%"+search+"%")
+                }
+                
+                // Author names also match however the name was inverted or punctuated
+                if byAuthor {
+                        args = append(args, AuthorMatchKey(search))
+                }
+                
+                // A complete ISBN in a general search finds the book however it was typed
+                if normalized, err := isbn.Normalize(search); err == nil && searchBy != "isbn" {
+                        args = append(args, normalized)
+                        last := len(conditions) - 1
+                        conditions[last] = fmt.Sprintf("(%s OR %s = $%d)", conditions[last], isbnMatchCondition, len(args))
                 }
-                args = append(args, "%"+search+"%")
         }
         
-        // Add where clause if exists
-        if whereClause != "" {
-                query += " " + whereClause
+        // Withdrawn books are kept for their history but no longer listed
+        conditions = append(conditions, "withdrawn_at IS NULL")
+        
+        // Add subject filter
+        if filter.SubjectID > 0 {
+                args = append(args, filter.SubjectID)
+                conditions = append(conditions, fmt.Sprintf("id IN (SELECT book_id FROM book_subjects WHERE subject_id = $%d)", len(args)))
         }
         
-        // Add order by and pagination
-        query += " ORDER BY title ASC"
+        // Add classification filter, including narrower classes
+        if filter.ClassID > 0 {
+                args = append(args, filter.ClassID)
+                conditions = append(conditions, fmt.Sprintf(`class_id IN (
+                        WITH RECURSIVE branch AS (
+                                SELECT id FROM classes WHERE id = $%d
+                                UNION ALL
+                                SELECT c.id FROM classes c JOIN branch ON c.parent_id = branch.id
+                        )
+                        SELECT id FROM branch
+                )`, len(args)))
+        }
+        
+        return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetBooks retrieves books with optional search, filters and pagination
+func GetBooks(search string, searchBy string, filter BookFilter, page int) ([]*Book, error) {
+        db := config.GetDB()
+        
+        // Build query
+        whereClause, args := buildBookConditions(search, searchBy, filter)
+        query := "SELECT " + bookColumns + ", 1, available, " + bookRatingColumns + " FROM books " + whereClause
+        
+        // Collapse editions into one row per work, represented by an available edition
+        // if there is one, otherwise the most recent
+        if filter.CollapseWorks {
+                query = `
+                        SELECT ` + bookColumns + `, edition_count, work_available, ` + bookRatingColumns + ` FROM (
+                                SELECT DISTINCT ON (` + workKey + `) ` + bookColumns + `,
+                                        COUNT(*) OVER w AS edition_count,
+                                        SUM(available) OVER w AS work_available
+                                FROM books ` + whereClause + `
+                                WINDOW w AS (PARTITION BY ` + workKey + `)
+                                ORDER BY ` + workKey + `, available > 0 DESC, publication_year DESC, id
+                        ) books
+                `
+        }
+        
+        // Add order by; unclassified books go to the end of the shelf
+        if filter.Sort == "shelf" {
+                query += " ORDER BY call_number_sort = '', call_number_sort ASC, title ASC"
+        } else if filter.Sort == "rating" {
+                query += " ORDER BY rating_average DESC, rating_count DESC, title ASC"
+        } else {
+                query += " ORDER BY title ASC"
+        }
         
         // Add pagination
         pageSize := 10
@@ -153,8 +356,20 @@ func GetBooks(search string, searchBy string, page int) ([]*Book, error) {
                         &book.Quantity,
                         &book.Available,
                         &book.AddedBy,
+                        &book.ReplacementCost,
+                        &book.CallNumber,
+                        &book.ClassID,
+                        &book.WorkID,
+                        &book.EditionStatement,
+                        &book.CoverURL,
+                        &book.CoverKey,
                         &book.CreatedAt,
                         &book.UpdatedAt,
+                        new(string), // call_number_sort, selected only for ordering
+                        &book.EditionCount,
+                        &book.WorkAvailable,
+                        &book.RatingAverage,
+                        &book.RatingCount,
                 )
                 if err != nil {
                         return nil, err
@@ -174,48 +389,25 @@ func GetBooks(search string, searchBy string, page int) ([]*Book, error) {
         return books, nil
 }
 
-// CountBooks counts books with optional search
-func CountBooks(search string, searchBy string) (int, error) {
+// CountBooks counts books with optional search and filters
+func CountBooks(search string, searchBy string, filter BookFilter) (int, error) {
         db := config.GetDB()
         
         // Build query
         query := "SELECT COUNT(*) FROM books"
-        
-        var args []interface{}
-        var whereClause string
-        
-        // Add search condition if provided
-        if search != "" {
-                switch searchBy {
-                case "title":
-                        whereClause = "WHERE title ILIKE $1"
-                case "author":
-                        whereClause = "WHERE author ILIKE $1"
-                case "isbn":
-                        whereClause = "WHERE isbn ILIKE $1"
-                case "category":
-                        whereClause = "WHERE category ILIKE $1"
-                default:
-                        whereClause = "WHERE title ILIKE $1 OR author ILIKE $1 OR isbn ILIKE $1"
-                }
-                args = append(args, "%"+search+"%")
+        if filter.CollapseWorks {
+                query = "SELECT COUNT(DISTINCT " + workKey + ") FROM books"
         }
         
         // Add where clause if exists
+        whereClause, args := buildBookConditions(search, searchBy, filter)
         if whereClause != "" {
                 query += " " + whereClause
         }
         
         // Execute query
         var count int
-        var err error
-        
-        if len(args) > 0 {
-                err = db.QueryRow(query, args...).Scan(&count)
-        } else {
-                err = db.QueryRow(query).Scan(&count)
-        }
-        
+        err := db.QueryRow(query, args...).Scan(&count)
         if err != nil {
                 return 0, err
         }
@@ -230,7 +422,7 @@ func GetAllBooks() ([]*Book, error) {
         // Execute query
         rows, err := db.Query(`
                 SELECT id, title, author, isbn, publisher, publication_year, category, description, 
-                        quantity, available, added_by, created_at, updated_at
+                        quantity, available, added_by, replacement_cost, call_number, class_id, work_id, edition_statement, cover_url, cover_key, created_at, updated_at
                 FROM books
                 ORDER BY title ASC
         `)
@@ -255,6 +447,75 @@ func GetAllBooks() ([]*Book, error) {
                         &book.Quantity,
                         &book.Available,
                         &book.AddedBy,
+                        &book.ReplacementCost,
+                        &book.CallNumber,
+                        &book.ClassID,
+                        &book.WorkID,
+                        &book.EditionStatement,
+                        &book.CoverURL,
+                        &book.CoverKey,
+                        &book.CreatedAt,
+                        &book.UpdatedAt,
+                )
+                if err != nil {
+                        return nil, err
+                }
+                
+                // Set alias fields for template compatibility
+                book.SetAliasFields()
+                
+                books = append(books, book)
+        }
+        
+        // Check for errors
+        if err := rows.Err(); err != nil {
+                return nil, err
+        }
+        
+        return books, nil
+}
+
+// GetRecentBooks returns the most recently added books, newest first
+func GetRecentBooks(limit int) ([]*Book, error) {
+        db := config.GetDB()
+        
+        // Execute query
+        rows, err := db.Query(`
+                SELECT id, title, author, isbn, publisher, publication_year, category, description, 
+                        quantity, available, added_by, replacement_cost, call_number, class_id, work_id, edition_statement, cover_url, cover_key, created_at, updated_at
+                FROM books
+                WHERE withdrawn_at IS NULL
+                ORDER BY created_at DESC, id DESC
+                LIMIT $1
+        `, limit)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+        
+        // Parse rows
+        var books []*Book
+        for rows.Next() {
+                book := &Book{}
+                err := rows.Scan(
+                        &book.ID,
+                        &book.Title,
+                        &book.Author,
+                        &book.ISBN,
+                        &book.Publisher,
+                        &book.PublicationYear,
+                        &book.Category,
+                        &book.Description,
+                        &book.Quantity,
+                        &book.Available,
+                        &book.AddedBy,
+                        &book.ReplacementCost,
+                        &book.CallNumber,
+                        &book.ClassID,
+                        &book.WorkID,
+                        &book.EditionStatement,
+                        &book.CoverURL,
+                        &book.CoverKey,
                         &book.CreatedAt,
                         &book.UpdatedAt,
                 )
@@ -283,8 +544,9 @@ func (b *Book) Create() error {
         // Execute query
         err := db.QueryRow(`
                 INSERT INTO books (title, author, isbn, publisher, publication_year, category, description, 
-                        quantity, available, added_by)
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+                        quantity, available, added_by, replacement_cost, call_number, call_number_sort, class_id, 
+                        edition_statement, cover_url)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
                 RETURNING id, created_at, updated_at
         `,
                 b.Title,
@@ -297,6 +559,12 @@ func (b *Book) Create() error {
                 b.Quantity,
                 b.Available,
                 b.AddedBy, // sql.NullInt64 will be handled correctly by database/sql
+                b.ReplacementCost,
+                b.CallNumber,
+                CallNumberSortKey(b.CallNumber),
+                b.ClassID,
+                b.EditionStatement,
+                b.CoverURL,
         ).Scan(
                 &b.ID,
                 &b.CreatedAt,
@@ -315,8 +583,10 @@ func (b *Book) Update() error {
                 UPDATE books
                 SET title = $1, author = $2, isbn = $3, publisher = $4, publication_year = $5, 
                         category = $6, description = $7, quantity = $8, available = $9, 
-                        added_by = $10, updated_at = CURRENT_TIMESTAMP
-                WHERE id = $11
+                        added_by = $10, replacement_cost = $11, call_number = $12, call_number_sort = $13, 
+                        class_id = $14, edition_statement = $15, cover_url = $16, 
+                        updated_at = CURRENT_TIMESTAMP
+                WHERE id = $17
         `,
                 b.Title,
                 b.Author,
@@ -328,12 +598,38 @@ func (b *Book) Update() error {
                 b.Quantity,
                 b.Available,
                 b.AddedBy, // Include AddedBy in update
+                b.ReplacementCost,
+                b.CallNumber,
+                CallNumberSortKey(b.CallNumber),
+                b.ClassID,
+                b.EditionStatement,
+                b.CoverURL,
                 b.ID,
         )
         
         return err
 }
 
+// SetBookCover records the book's uploaded cover and returns the key of the cover
+// it replaces, so the caller can delete the old thumbnails
+func SetBookCover(bookID int, key string) (string, error) {
+        db := config.GetDB()
+        
+        // Execute query
+        var oldKey string
+        err := db.QueryRow(`
+                UPDATE books b SET cover_key = $1, updated_at = $2
+                FROM (SELECT id, cover_key FROM books WHERE id = $3 FOR UPDATE) old
+                WHERE b.id = old.id
+                RETURNING old.cover_key
+        `, key, time.Now(), bookID).Scan(&oldKey)
+        if err == sql.ErrNoRows {
+                return "", errors.New("book not found")
+        }
+        
+        return oldKey, err
+}
+
 // Delete removes a book from the database
 func (b *Book) Delete() error {
         db := config.GetDB()
@@ -345,11 +641,29 @@ func (b *Book) Delete() error {
 }
 
 // IsbnExists checks if a book with the given ISBN already exists
-func IsbnExists(isbn string) (bool, error) {
+func IsbnExists(code string) (bool, error) {
+        return IsbnExistsExcept(code, 0)
+}
+
+// IsbnExistsExcept checks if a book with the given ISBN exists, excluding a specific book ID.
+// ISBN-10 and ISBN-13 forms of the same number count as the same ISBN.
+func IsbnExistsExcept(code string, id int) (bool, error) {
         db := config.GetDB()
         
+        code = isbn.Clean(code)
+        if normalized, err := isbn.Normalize(code); err == nil {
+                code = normalized
+        }
+        short, err := isbn.To10(code)
+        if err != nil {
+                short = code
+        }
+        
         var count int
-        err := db.QueryRow("SELECT COUNT(*) FROM books WHERE isbn = $1", isbn).Scan(&count)
+        err = db.QueryRow(`
+                SELECT COUNT(*) FROM books
+                WHERE `+isbnMatchCondition+` IN ($1, $2) AND id != $3
+        `, code, short, id).Scan(&count)
         if err != nil {
                 return false, err
         }
@@ -357,25 +671,55 @@ func IsbnExists(isbn string) (bool, error) {
         return count > 0, nil
 }
 
-// IsbnExistsExcept checks if a book with the given ISBN exists, excluding a specific book ID
-func IsbnExistsExcept(isbn string, id int) (bool, error) {
+// NormalizeStoredISBNs rewrites valid ISBNs saved in other forms as ISBN-13s without
+// hyphens. Invalid ISBNs, and ones that would collide with another book, are left as they are.
+func NormalizeStoredISBNs() error {
         db := config.GetDB()
         
-        var count int
-        err := db.QueryRow("SELECT COUNT(*) FROM books WHERE isbn = $1 AND id != $2", isbn, id).Scan(&count)
+        // Execute query
+        rows, err := db.Query("SELECT id, isbn FROM books WHERE LENGTH(isbn) <> 13 OR isbn !~ '^[0-9]+$'")
         if err != nil {
-                return false, err
+                return err
         }
+        defer rows.Close()
         
-        return count > 0, nil
+        // Parse rows
+        updates := make(map[int]string)
+        for rows.Next() {
+                var id int
+                var code string
+                if err := rows.Scan(&id, &code); err != nil {
+                        return err
+                }
+                if normalized, err := isbn.Normalize(code); err == nil && normalized != code {
+                        updates[id] = normalized
+                }
+        }
+        
+        // Check for errors
+        if err := rows.Err(); err != nil {
+                return err
+        }
+        
+        for id, code := range updates {
+                _, err := db.Exec(`
+                        UPDATE books SET isbn = $1, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $2 AND NOT EXISTS (SELECT 1 FROM books WHERE isbn = $1)
+                `, code, id)
+                if err != nil {
+                        return err
+                }
+        }
+        
+        return nil
 }
 
-// CountAllBooks returns the total number of books
+// CountAllBooks returns the total number of books in the collection, leaving out withdrawn books
 func CountAllBooks() (int, error) {
         db := config.GetDB()
         
         var count int
-        err := db.QueryRow("SELECT COUNT(*) FROM books").Scan(&count)
+        err := db.QueryRow("SELECT COUNT(*) FROM books WHERE withdrawn_at IS NULL").Scan(&count)
         
         return count, err
 }
@@ -397,7 +741,7 @@ func GetTopBorrowedBooks(limit int) ([]*Book, error) {
         // Execute query
         rows, err := db.Query(`
                 SELECT b.id, b.title, b.author, b.isbn, b.publisher, b.publication_year, b.category, 
-                        b.description, b.quantity, b.available, b.added_by, b.created_at, b.updated_at, 
+                        b.description, b.quantity, b.available, b.added_by, b.replacement_cost, b.call_number, b.class_id, b.work_id, b.edition_statement, b.cover_url, b.cover_key, b.created_at, b.updated_at, 
                         COUNT(br.id) as borrow_count
                 FROM books b
                 JOIN borrows br ON b.id = br.book_id
@@ -427,6 +771,13 @@ func GetTopBorrowedBooks(limit int) ([]*Book, error) {
                         &book.Quantity,
                         &book.Available,
                         &book.AddedBy,
+                        &book.ReplacementCost,
+                        &book.CallNumber,
+                        &book.ClassID,
+                        &book.WorkID,
+                        &book.EditionStatement,
+                        &book.CoverURL,
+                        &book.CoverKey,
                         &book.CreatedAt,
                         &book.UpdatedAt,
                         &borrowCount,
@@ -15,27 +15,61 @@ const (
 	BorrowStatusApproved = "approved"
 	BorrowStatusRejected = "rejected"
 	BorrowStatusReturned = "returned"
+
+	// Loans that ended without a normal return
+	BorrowStatusLost            = "lost"
+	BorrowStatusDamaged         = "damaged"
+	BorrowStatusClaimedReturned = "claimed_returned"
 )
 
 // Borrow represents a book borrowing record
 type Borrow struct {
-	ID            int
-	UserID        int
-	BookID        int
-	Status        string
-	BorrowDate    *time.Time
-	DueDate       *time.Time
-	ReturnDate    *time.Time
-	ApprovedBy    *int
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	RejectionNote string
+	ID             int
+	UserID         int
+	BookID         int
+	Status         string
+	BorrowDate     *time.Time
+	DueDate        *time.Time
+	ReturnDate     *time.Time
+	ApprovedBy     *int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	RejectionNote  string
+	ResolutionNote string
+	LoanHours      int // Non-zero for short loans of course reserve items
 
 	// Computed properties
-	User      *User
-	Book      *Book
-	Approver  *User
-	IsOverdue bool
+	User        *User
+	Book        *Book
+	Approver    *User
+	IsOverdue   bool
+	DaysOverdue int // Open library days past the due date, filled in by overdue lists
+	Eligibility *Eligibility
+}
+
+// ShortLoan reports whether the loan is counted in hours rather than days
+func (b *Borrow) ShortLoan() bool {
+	return b.LoanHours > 0
+}
+
+// DueLabel formats the due date, with the time for short loans
+func (b *Borrow) DueLabel() string {
+	if b.DueDate == nil {
+		return ""
+	}
+	if b.ShortLoan() {
+		return b.DueDate.Format("Jan 02, 2006 15:04")
+	}
+	return b.DueDate.Format("Jan 02, 2006")
+}
+
+// getBorrowPatron loads the borrower, substituting a placeholder for anonymized records
+func getBorrowPatron(userID int) *User {
+	if userID == 0 {
+		return AnonymousPatron()
+	}
+	user, _ := GetUserByID(userID)
+	return user
 }
 
 // GetBorrowByID retrieves a borrow record by ID
@@ -45,8 +79,8 @@ func GetBorrowByID(id int) (*Borrow, error) {
 	// Execute query
 	borrow := &Borrow{}
 	err := db.QueryRow(`
-                SELECT id, user_id, book_id, status, borrow_date, due_date, return_date, 
-                        approved_by, created_at, updated_at
+                SELECT id, COALESCE(user_id, 0), book_id, status, borrow_date, due_date, return_date, 
+                        approved_by, created_at, updated_at, loan_hours
                 FROM borrows
                 WHERE id = $1
         `, id).Scan(
@@ -60,6 +94,7 @@ func GetBorrowByID(id int) (*Borrow, error) {
 		&borrow.ApprovedBy,
 		&borrow.CreatedAt,
 		&borrow.UpdatedAt,
+		&borrow.LoanHours,
 	)
 
 	if err != nil {
@@ -70,7 +105,7 @@ func GetBorrowByID(id int) (*Borrow, error) {
 	}
 
 	// Get related user
-	borrow.User, _ = GetUserByID(borrow.UserID)
+	borrow.User = getBorrowPatron(borrow.UserID)
 
 	// Get related book
 	borrow.Book, _ = GetBookByID(borrow.BookID)
@@ -96,8 +131,8 @@ func GetBorrowByUserAndBook(userID, bookID int, status string) (*Borrow, error)
 
 	// Build query
 	query := `
-                SELECT id, user_id, book_id, status, borrow_date, due_date, return_date, 
-                        approved_by, created_at, updated_at
+                SELECT id, COALESCE(user_id, 0), book_id, status, borrow_date, due_date, return_date, 
+                        approved_by, created_at, updated_at, loan_hours
                 FROM borrows
                 WHERE user_id = $1 AND book_id = $2
         `
@@ -124,6 +159,7 @@ func GetBorrowByUserAndBook(userID, bookID int, status string) (*Borrow, error)
 		&borrow.ApprovedBy,
 		&borrow.CreatedAt,
 		&borrow.UpdatedAt,
+		&borrow.LoanHours,
 	)
 
 	if err != nil {
@@ -134,7 +170,7 @@ func GetBorrowByUserAndBook(userID, bookID int, status string) (*Borrow, error)
 	}
 
 	// Get related user
-	borrow.User, _ = GetUserByID(borrow.UserID)
+	borrow.User = getBorrowPatron(borrow.UserID)
 
 	// Get related book
 	borrow.Book, _ = GetBookByID(borrow.BookID)
@@ -190,7 +226,9 @@ func CreateBorrowRequest(userID, bookID int) error {
 	return err
 }
 
-// ApproveBorrow approves a borrow request
+// ApproveBorrow approves a borrow request. Items on course reserve are lent for their
+// short loan period instead of until dueDate. A request for a volume of a
+// multi-volume set approves the whole set, or fails if any volume cannot be lent.
 func ApproveBorrow(id int, approverID int, dueDate time.Time) error {
 	db := config.GetDB()
 
@@ -204,7 +242,8 @@ func ApproveBorrow(id int, approverID int, dueDate time.Time) error {
 	// Get borrow request
 	var bookID int
 	var status string
-	err = tx.QueryRow("SELECT book_id, status FROM borrows WHERE id = $1", id).Scan(&bookID, &status)
+	var setRequestID sql.NullInt64
+	err = tx.QueryRow("SELECT book_id, status, set_request_id FROM borrows WHERE id = $1", id).Scan(&bookID, &status, &setRequestID)
 	if err != nil {
 		return err
 	}
@@ -214,24 +253,58 @@ func ApproveBorrow(id int, approverID int, dueDate time.Time) error {
 		return errors.New("borrow request is not in pending status")
 	}
 
-	// Check if book is available
-	var available int
-	err = tx.QueryRow("SELECT available FROM books WHERE id = $1", bookID).Scan(&available)
+	if setRequestID.Valid {
+		// Lend the set as a whole
+		err = approveSetRequest(tx, int(setRequestID.Int64), approverID, dueDate)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Check if book is available
+		var available int
+		err = tx.QueryRow("SELECT available FROM books WHERE id = $1", bookID).Scan(&available)
+		if err != nil {
+			return err
+		}
+
+		if available <= 0 {
+			return errors.New("no copies available for borrowing")
+		}
+
+		err = lendRequest(tx, id, bookID, approverID, dueDate)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit()
 	if err != nil {
 		return err
 	}
 
-	if available <= 0 {
-		return errors.New("no copies available for borrowing")
+	return nil
+}
+
+// lendRequest marks a pending request as lent and takes its copy off the shelf
+func lendRequest(tx *sql.Tx, id, bookID, approverID int, dueDate time.Time) error {
+	// Course reserve items go out on short loan
+	borrowDate := time.Now()
+	loanHours, err := shortLoanHours(tx, bookID)
+	if err != nil {
+		return err
+	}
+	if loanHours > 0 {
+		dueDate = borrowDate.Add(time.Duration(loanHours) * time.Hour)
 	}
 
 	// Update borrow request status
-	borrowDate := time.Now()
 	_, err = tx.Exec(`
                 UPDATE borrows
-                SET status = $1, borrow_date = $2, due_date = $3, approved_by = $4, updated_at = CURRENT_TIMESTAMP
-                WHERE id = $5
-        `, BorrowStatusApproved, borrowDate, dueDate, approverID, id)
+                SET status = $1, borrow_date = $2, due_date = $3, approved_by = $4, loan_hours = $5,
+                        updated_at = CURRENT_TIMESTAMP
+                WHERE id = $6
+        `, BorrowStatusApproved, borrowDate, dueDate, approverID, loanHours, id)
 	if err != nil {
 		return err
 	}
@@ -242,20 +315,11 @@ func ApproveBorrow(id int, approverID int, dueDate time.Time) error {
                 SET available = available - 1, updated_at = CURRENT_TIMESTAMP
                 WHERE id = $1
         `, bookID)
-	if err != nil {
-		return err
-	}
-
-	// Commit transaction
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return err
 }
 
-// RejectBorrow rejects a borrow request
+// RejectBorrow rejects a borrow request, along with the rest of its set if it is
+// for a volume of a multi-volume set
 func RejectBorrow(id int, approverID int) error {
 	db := config.GetDB()
 
@@ -263,7 +327,8 @@ func RejectBorrow(id int, approverID int) error {
 	_, err := db.Exec(`
                 UPDATE borrows
                 SET status = $1, approved_by = $2, updated_at = CURRENT_TIMESTAMP
-                WHERE id = $3 AND status = $4
+                WHERE (id = $3 OR set_request_id = (SELECT set_request_id FROM borrows WHERE id = $3))
+                        AND status = $4
         `, BorrowStatusRejected, approverID, id, BorrowStatusPending)
 
 	return err
@@ -271,26 +336,45 @@ func RejectBorrow(id int, approverID int) error {
 
 // ReturnBook marks a book as returned
 func ReturnBook(id int) error {
+	bookID, _, err := completeReturn(id)
+	if err != nil {
+		return err
+	}
+
+	// After the transaction completes successfully, check for pending reservations
+	go func() {
+		// We're using a goroutine to avoid blocking the return operation
+		// if there's an error with reservation processing
+		_ = ProcessReservationsForBook(bookID)
+	}()
+
+	return nil
+}
+
+// completeReturn closes a loan, charges any overdue fine and releases the copy.
+// It returns the book ID and the fine charged.
+func completeReturn(id int) (int, float64, error) {
 	db := config.GetDB()
 
 	// Begin transaction
 	tx, err := db.Begin()
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	defer tx.Rollback()
 
 	// Get borrow request
-	var bookID int
+	var bookID, userID, loanHours int
 	var status string
-	err = tx.QueryRow("SELECT book_id, status FROM borrows WHERE id = $1", id).Scan(&bookID, &status)
+	var dueDate *time.Time
+	err = tx.QueryRow("SELECT book_id, user_id, status, due_date, loan_hours FROM borrows WHERE id = $1", id).Scan(&bookID, &userID, &status, &dueDate, &loanHours)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
 	// Check if book is currently borrowed
 	if status != BorrowStatusApproved {
-		return errors.New("book is not currently borrowed")
+		return 0, 0, errors.New("book is not currently borrowed")
 	}
 
 	// Update borrow request status
@@ -301,7 +385,27 @@ func ReturnBook(id int) error {
                 WHERE id = $3
         `, BorrowStatusReturned, returnDate, id)
 	if err != nil {
-		return err
+		return 0, 0, err
+	}
+
+	// Charge an overdue fine for late returns
+	var fine float64
+	if dueDate != nil && loanHours > 0 {
+		if fine = CalculateShortLoanFine(*dueDate, returnDate); fine > 0 {
+			description := fmt.Sprintf("Late return of short loan, due %s", dueDate.Format("Jan 02, 2006 15:04"))
+			err = createCharge(tx, userID, id, ChargeTypeOverdue, fine, description)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+	} else if dueDate != nil {
+		if fine = CalculateOverdueFine(*dueDate, returnDate); fine > 0 {
+			description := fmt.Sprintf("Late return, due %s", dueDate.Format("Jan 02, 2006"))
+			err = createCharge(tx, userID, id, ChargeTypeOverdue, fine, description)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
 	}
 
 	// Update book available count
@@ -311,23 +415,16 @@ func ReturnBook(id int) error {
                 WHERE id = $1
         `, bookID)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
 	// Commit transaction
 	err = tx.Commit()
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
-	// After the transaction completes successfully, check for pending reservations
-	go func() {
-		// We're using a goroutine to avoid blocking the return operation
-		// if there's an error with reservation processing
-		_ = ProcessReservationsForBook(bookID)
-	}()
-
-	return nil
+	return bookID, fine, nil
 }
 
 // GetAllPendingBorrows retrieves all pending borrow requests
@@ -336,8 +433,8 @@ func GetAllPendingBorrows() ([]*Borrow, error) {
 
 	// Execute query
 	rows, err := db.Query(`
-                SELECT b.id, b.user_id, b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
-                        b.approved_by, b.created_at, b.updated_at
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
                 FROM borrows b
                 WHERE b.status = $1
                 ORDER BY b.created_at ASC
@@ -362,13 +459,14 @@ func GetAllPendingBorrows() ([]*Borrow, error) {
 			&borrow.ApprovedBy,
 			&borrow.CreatedAt,
 			&borrow.UpdatedAt,
+			&borrow.LoanHours,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		// Get related user
-		borrow.User, _ = GetUserByID(borrow.UserID)
+		borrow.User = getBorrowPatron(borrow.UserID)
 
 		// Get related book
 		borrow.Book, _ = GetBookByID(borrow.BookID)
@@ -390,8 +488,8 @@ func GetActiveBorrows() ([]*Borrow, error) {
 
 	// Execute query
 	rows, err := db.Query(`
-                SELECT b.id, b.user_id, b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
-                        b.approved_by, b.created_at, b.updated_at
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
                 FROM borrows b
                 WHERE b.status = $1
                 ORDER BY b.due_date ASC
@@ -416,13 +514,14 @@ func GetActiveBorrows() ([]*Borrow, error) {
 			&borrow.ApprovedBy,
 			&borrow.CreatedAt,
 			&borrow.UpdatedAt,
+			&borrow.LoanHours,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		// Get related user
-		borrow.User, _ = GetUserByID(borrow.UserID)
+		borrow.User = getBorrowPatron(borrow.UserID)
 
 		// Get related book
 		borrow.Book, _ = GetBookByID(borrow.BookID)
@@ -449,8 +548,8 @@ func GetOverdueBooks() ([]*Borrow, error) {
 
 	// Execute query
 	rows, err := db.Query(`
-                SELECT b.id, b.user_id, b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
-                        b.approved_by, b.created_at, b.updated_at
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
                 FROM borrows b
                 WHERE b.status = $1 AND b.due_date < CURRENT_TIMESTAMP
                 ORDER BY b.due_date ASC
@@ -475,13 +574,14 @@ func GetOverdueBooks() ([]*Borrow, error) {
 			&borrow.ApprovedBy,
 			&borrow.CreatedAt,
 			&borrow.UpdatedAt,
+			&borrow.LoanHours,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		// Get related user
-		borrow.User, _ = GetUserByID(borrow.UserID)
+		borrow.User = getBorrowPatron(borrow.UserID)
 
 		// Get related book
 		borrow.Book, _ = GetBookByID(borrow.BookID)
@@ -491,6 +591,7 @@ func GetOverdueBooks() ([]*Borrow, error) {
 			borrow.Approver, _ = GetUserByID(*borrow.ApprovedBy)
 		}
 
+		borrow.IsOverdue = true
 		borrows = append(borrows, borrow)
 	}
 
@@ -499,17 +600,46 @@ func GetOverdueBooks() ([]*Borrow, error) {
 		return nil, err
 	}
 
+	setDaysOverdue(borrows, time.Now())
+
 	return borrows, nil
 }
 
+// setDaysOverdue fills in the open days each loan is past its due date, loading the
+// calendar once for the whole list. Every calendar day is counted if the calendar
+// cannot be loaded.
+func setDaysOverdue(borrows []*Borrow, now time.Time) {
+	var earliest *time.Time
+	for _, b := range borrows {
+		if b.DueDate != nil && (earliest == nil || b.DueDate.Before(*earliest)) {
+			earliest = b.DueDate
+		}
+	}
+	if earliest == nil || !now.After(*earliest) {
+		return
+	}
+
+	calendar, err := LoadCalendar(*earliest, now)
+	for _, b := range borrows {
+		if b.DueDate == nil || !now.After(*b.DueDate) {
+			continue
+		}
+		if err != nil {
+			b.DaysOverdue = int(now.Sub(*b.DueDate).Hours() / 24)
+		} else {
+			b.DaysOverdue = calendar.OpenDaysBetween(*b.DueDate, now)
+		}
+	}
+}
+
 // GetActiveUserBorrows retrieves active borrows for a specific user
 func GetActiveUserBorrows(userID int) ([]*Borrow, error) {
 	db := config.GetDB()
 
 	// Execute query
 	rows, err := db.Query(`
-                SELECT b.id, b.user_id, b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
-                        b.approved_by, b.created_at, b.updated_at
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
                 FROM borrows b
                 WHERE b.user_id = $1 AND b.status = $2
                 ORDER BY b.due_date ASC
@@ -534,6 +664,7 @@ func GetActiveUserBorrows(userID int) ([]*Borrow, error) {
 			&borrow.ApprovedBy,
 			&borrow.CreatedAt,
 			&borrow.UpdatedAt,
+			&borrow.LoanHours,
 		)
 		if err != nil {
 			return nil, err
@@ -559,8 +690,8 @@ func GetPendingUserBorrows(userID int) ([]*Borrow, error) {
 
 	// Execute query
 	rows, err := db.Query(`
-                SELECT b.id, b.user_id, b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
-                        b.approved_by, b.created_at, b.updated_at
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
                 FROM borrows b
                 WHERE b.user_id = $1 AND b.status = $2
                 ORDER BY b.created_at DESC
@@ -585,6 +716,7 @@ func GetPendingUserBorrows(userID int) ([]*Borrow, error) {
 			&borrow.ApprovedBy,
 			&borrow.CreatedAt,
 			&borrow.UpdatedAt,
+			&borrow.LoanHours,
 		)
 		if err != nil {
 			return nil, err
@@ -610,8 +742,8 @@ func GetPastUserBorrows(userID int) ([]*Borrow, error) {
 
 	// Execute query
 	rows, err := db.Query(`
-                SELECT b.id, b.user_id, b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
-                        b.approved_by, b.created_at, b.updated_at
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
                 FROM borrows b
                 WHERE b.user_id = $1 AND b.status IN ($2, $3)
                 ORDER BY b.updated_at DESC
@@ -636,6 +768,7 @@ func GetPastUserBorrows(userID int) ([]*Borrow, error) {
 			&borrow.ApprovedBy,
 			&borrow.CreatedAt,
 			&borrow.UpdatedAt,
+			&borrow.LoanHours,
 		)
 		if err != nil {
 			return nil, err
@@ -670,8 +803,8 @@ func GetBorrowsWithFilters(searchTerm, status string, page, itemsPerPage int) ([
 
 	// Base query for fetching borrows with relations
 	query := `
-                SELECT b.id, b.user_id, b.book_id, b.status, b.borrow_date, b.due_date, b.return_date,
-                                b.approved_by, b.created_at, b.updated_at
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date,
+                                b.approved_by, b.created_at, b.updated_at, b.loan_hours
                 FROM borrows b
                 LEFT JOIN users u ON b.user_id = u.id
                 LEFT JOIN books bk ON b.book_id = bk.id
@@ -692,8 +825,14 @@ func GetBorrowsWithFilters(searchTerm, status string, page, itemsPerPage int) ([
 		paramCount++
 	}
 
-	// Add status filter if provided
-	if status != "" {
+	// Add status filter if provided; overdue loans are approved loans past their due date
+	if status == BorrowStatusOverdue {
+		statusFilter := fmt.Sprintf(" AND b.status = $%d AND b.due_date < CURRENT_TIMESTAMP", paramCount)
+		query += statusFilter
+		countQuery += statusFilter
+		params = append(params, BorrowStatusApproved)
+		paramCount++
+	} else if status != "" {
 		statusFilter := fmt.Sprintf(" AND b.status = $%d", paramCount)
 		query += statusFilter
 		countQuery += statusFilter
@@ -738,13 +877,14 @@ func GetBorrowsWithFilters(searchTerm, status string, page, itemsPerPage int) ([
 			&borrow.ApprovedBy,
 			&borrow.CreatedAt,
 			&borrow.UpdatedAt,
+			&borrow.LoanHours,
 		)
 		if err != nil {
 			return nil, 0, err
 		}
 
 		// Get related user
-		borrow.User, _ = GetUserByID(borrow.UserID)
+		borrow.User = getBorrowPatron(borrow.UserID)
 
 		// Get related book
 		borrow.Book, _ = GetBookByID(borrow.BookID)
@@ -772,22 +912,118 @@ func GetBorrowsWithFilters(searchTerm, status string, page, itemsPerPage int) ([
 	return borrows, totalItems, nil
 }
 
-// GetBorrowHistory retrieves all borrow history
-func GetBorrowHistory() ([]*Borrow, error) {
+// BorrowStatusOverdue filters the borrow history to approved loans past their due
+// date; it is never stored as a status
+const BorrowStatusOverdue = "overdue"
+
+// historyBatchSize is how many records EachBorrowHistory loads at a time
+const historyBatchSize = 500
+
+// BorrowHistoryFilter selects records from the borrow history
+type BorrowHistoryFilter struct {
+	Search string     // Book title or patron name, email or student ID
+	Status string     // A borrow status or BorrowStatusOverdue; empty for all
+	From   *time.Time // Requested on or after this day
+	To     *time.Time // Requested on or before this day
+}
+
+// where returns the filter's conditions and their parameters
+func (f BorrowHistoryFilter) where() (string, []interface{}) {
+	conditions := " WHERE 1=1"
+	params := []interface{}{}
+
+	if f.Search != "" {
+		params = append(params, "%"+f.Search+"%")
+		n := len(params)
+		conditions += fmt.Sprintf(" AND (bk.title ILIKE $%d OR u.name ILIKE $%d OR u.email ILIKE $%d OR u.student_id ILIKE $%d)", n, n, n, n)
+	}
+	if f.Status == BorrowStatusOverdue {
+		params = append(params, BorrowStatusApproved)
+		conditions += fmt.Sprintf(" AND b.status = $%d AND b.due_date < CURRENT_TIMESTAMP", len(params))
+	} else if f.Status != "" {
+		params = append(params, f.Status)
+		conditions += fmt.Sprintf(" AND b.status = $%d", len(params))
+	}
+	if f.From != nil {
+		params = append(params, *f.From)
+		conditions += fmt.Sprintf(" AND b.created_at >= $%d", len(params))
+	}
+	if f.To != nil {
+		params = append(params, *f.To)
+		conditions += fmt.Sprintf(" AND b.created_at < $%d::date + 1", len(params))
+	}
+	return conditions, params
+}
+
+// CountBorrowHistory counts the records matching the filter
+func CountBorrowHistory(f BorrowHistoryFilter) (int, error) {
+	db := config.GetDB()
+
+	conditions, params := f.where()
+	var count int
+	err := db.QueryRow(`
+                SELECT COUNT(*)
+                FROM borrows b
+                LEFT JOIN users u ON b.user_id = u.id
+                LEFT JOIN books bk ON b.book_id = bk.id
+        `+conditions, params...).Scan(&count)
+	return count, err
+}
+
+// GetBorrowHistory retrieves a page of the records matching the filter, most
+// recently updated first
+func GetBorrowHistory(f BorrowHistoryFilter, page, itemsPerPage int) ([]*Borrow, error) {
+	return queryBorrowHistory(f, nil, itemsPerPage, (page-1)*itemsPerPage)
+}
+
+// EachBorrowHistory calls fn for every record matching the filter, in the same order
+// as GetBorrowHistory. Records are loaded in batches, so the whole history is never
+// held in memory at once.
+func EachBorrowHistory(f BorrowHistoryFilter, fn func(*Borrow) error) error {
+	var after *Borrow
+	for {
+		batch, err := queryBorrowHistory(f, after, historyBatchSize, 0)
+		if err != nil {
+			return err
+		}
+		for _, borrow := range batch {
+			if err := fn(borrow); err != nil {
+				return err
+			}
+		}
+		if len(batch) < historyBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1]
+	}
+}
+
+// queryBorrowHistory retrieves records matching the filter, continuing after the
+// given record if there is one
+func queryBorrowHistory(f BorrowHistoryFilter, after *Borrow, limit, offset int) ([]*Borrow, error) {
 	db := config.GetDB()
 
+	conditions, params := f.where()
+	if after != nil {
+		params = append(params, after.UpdatedAt, after.ID)
+		conditions += fmt.Sprintf(" AND (b.updated_at, b.id) < ($%d, $%d)", len(params)-1, len(params))
+	}
+	params = append(params, limit, offset)
+
 	// Execute query
 	rows, err := db.Query(`
-                SELECT b.id, b.user_id, b.book_id, b.status, b.borrow_date, b.due_date, b.return_date, 
-                        b.approved_by, b.created_at, b.updated_at
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date,
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
                 FROM borrows b
-                ORDER BY b.updated_at DESC
-                LIMIT 100
-        `)
+                LEFT JOIN users u ON b.user_id = u.id
+                LEFT JOIN books bk ON b.book_id = bk.id
+        `+conditions+fmt.Sprintf(`
+                ORDER BY b.updated_at DESC, b.id DESC
+                LIMIT $%d OFFSET $%d
+        `, len(params)-1, len(params)), params...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	// Parse rows
 	var borrows []*Borrow
@@ -804,28 +1040,31 @@ func GetBorrowHistory() ([]*Borrow, error) {
 			&borrow.ApprovedBy,
 			&borrow.CreatedAt,
 			&borrow.UpdatedAt,
+			&borrow.LoanHours,
 		)
 		if err != nil {
+			rows.Close()
 			return nil, err
 		}
+		borrows = append(borrows, borrow)
+	}
 
-		// Get related user
-		borrow.User, _ = GetUserByID(borrow.UserID)
+	// Check for errors
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
 
-		// Get related book
+	// Load relations once the rows are closed, so a batch holds one connection at a time
+	now := time.Now()
+	for _, borrow := range borrows {
+		borrow.User = getBorrowPatron(borrow.UserID)
 		borrow.Book, _ = GetBookByID(borrow.BookID)
-
-		// Get approver if exists
 		if borrow.ApprovedBy != nil {
 			borrow.Approver, _ = GetUserByID(*borrow.ApprovedBy)
 		}
-
-		borrows = append(borrows, borrow)
-	}
-
-	// Check for errors
-	if err := rows.Err(); err != nil {
-		return nil, err
+		borrow.IsOverdue = borrow.Status == BorrowStatusApproved && borrow.DueDate != nil && borrow.DueDate.Before(now)
 	}
 
 	return borrows, nil
@@ -0,0 +1,277 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"library-management-system/config"
+)
+
+// calendarSearchLimit bounds how far ahead NextOpenDay looks for an open day
+const calendarSearchLimit = 366
+
+var openingTimePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// OpeningHours represents the regular opening hours for one day of the week
+type OpeningHours struct {
+	Weekday   time.Weekday
+	Closed    bool
+	OpenTime  string
+	CloseTime string
+}
+
+// CalendarException overrides the weekly hours on a specific date, e.g. a public
+// holiday closure or a special opening
+type CalendarException struct {
+	ID        int
+	Date      time.Time
+	Closed    bool
+	OpenTime  string
+	CloseTime string
+	Note      string
+	CreatedAt time.Time
+}
+
+// Calendar holds the weekly hours and exceptions used for date calculations
+type Calendar struct {
+	weekly     [7]OpeningHours
+	exceptions map[string]*CalendarException
+}
+
+// calendarDateKey normalises a time to the calendar day it falls on
+func calendarDateKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// LoadCalendar reads the weekly hours and the exceptions on the days from from's date
+// to to's date. The calendar only answers for days in that range.
+func LoadCalendar(from, to time.Time) (*Calendar, error) {
+	hours, err := GetOpeningHours()
+	if err != nil {
+		return nil, err
+	}
+	exceptions, err := queryCalendarExceptions(`
+                SELECT id, date, closed, open_time, close_time, COALESCE(note, ''), created_at
+                FROM calendar_exceptions
+                WHERE date BETWEEN $1::date AND $2::date
+                ORDER BY date
+        `, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &Calendar{exceptions: make(map[string]*CalendarException)}
+	for i := range calendar.weekly {
+		calendar.weekly[i] = OpeningHours{Weekday: time.Weekday(i)}
+	}
+	for _, h := range hours {
+		calendar.weekly[h.Weekday] = *h
+	}
+	for _, e := range exceptions {
+		calendar.exceptions[calendarDateKey(e.Date)] = e
+	}
+
+	return calendar, nil
+}
+
+// IsOpen reports whether the library is open on the day t falls on
+func (c *Calendar) IsOpen(t time.Time) bool {
+	if exception, ok := c.exceptions[calendarDateKey(t)]; ok {
+		return !exception.Closed
+	}
+	return !c.weekly[t.Weekday()].Closed
+}
+
+// NextOpenDay returns t moved forward by whole days to the first day the library is
+// open. t is returned unchanged if it is already an open day or no open day is found.
+func (c *Calendar) NextOpenDay(t time.Time) time.Time {
+	for i := 0; i < calendarSearchLimit; i++ {
+		day := t.AddDate(0, 0, i)
+		if c.IsOpen(day) {
+			return day
+		}
+	}
+	return t
+}
+
+// OpenDaysBetween counts the open days after from's date up to and including to's date
+func (c *Calendar) OpenDaysBetween(from, to time.Time) int {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, from.Location())
+
+	days := 0
+	for day := start.AddDate(0, 0, 1); !day.After(end); day = day.AddDate(0, 0, 1) {
+		if c.IsOpen(day) {
+			days++
+		}
+	}
+	return days
+}
+
+// AdjustDueDate moves a due date that falls on a closed day to the next open day.
+// The date is returned unchanged if the calendar cannot be loaded.
+func AdjustDueDate(dueDate time.Time) time.Time {
+	calendar, err := LoadCalendar(dueDate, dueDate.AddDate(0, 0, calendarSearchLimit))
+	if err != nil {
+		return dueDate
+	}
+	return calendar.NextOpenDay(dueDate)
+}
+
+// CountOpenDaysBetween counts the open days after from up to and including to. Every
+// calendar day is counted if the calendar cannot be loaded.
+func CountOpenDaysBetween(from, to time.Time) int {
+	if !to.After(from) {
+		return 0
+	}
+	calendar, err := LoadCalendar(from, to)
+	if err != nil {
+		return int(to.Sub(from).Hours() / 24)
+	}
+	return calendar.OpenDaysBetween(from, to)
+}
+
+// GetOpeningHours retrieves the weekly opening hours, Sunday first
+func GetOpeningHours() ([]*OpeningHours, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT weekday, closed, open_time, close_time
+                FROM opening_hours
+                ORDER BY weekday
+        `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var hours []*OpeningHours
+	for rows.Next() {
+		h := &OpeningHours{}
+		var weekday int
+		if err := rows.Scan(&weekday, &h.Closed, &h.OpenTime, &h.CloseTime); err != nil {
+			return nil, err
+		}
+		h.Weekday = time.Weekday(weekday)
+		hours = append(hours, h)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hours, nil
+}
+
+// validateOpeningTimes checks a pair of HH:MM opening and closing times
+func validateOpeningTimes(openTime, closeTime string) error {
+	if !openingTimePattern.MatchString(openTime) || !openingTimePattern.MatchString(closeTime) {
+		return errors.New("opening times must be in HH:MM format")
+	}
+	if openTime >= closeTime {
+		return errors.New("closing time must be after opening time")
+	}
+	return nil
+}
+
+// UpdateOpeningHours saves the regular hours for one day of the week
+func UpdateOpeningHours(h *OpeningHours) error {
+	if h.Weekday < time.Sunday || h.Weekday > time.Saturday {
+		return errors.New("invalid weekday")
+	}
+	if !h.Closed {
+		if err := validateOpeningTimes(h.OpenTime, h.CloseTime); err != nil {
+			return err
+		}
+	}
+
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                INSERT INTO opening_hours (weekday, closed, open_time, close_time)
+                VALUES ($1, $2, $3, $4)
+                ON CONFLICT (weekday) DO UPDATE
+                SET closed = EXCLUDED.closed, open_time = EXCLUDED.open_time, close_time = EXCLUDED.close_time
+        `, int(h.Weekday), h.Closed, h.OpenTime, h.CloseTime)
+
+	return err
+}
+
+// GetCalendarExceptions retrieves exceptions on or after the given date, earliest first
+func GetCalendarExceptions(from time.Time) ([]*CalendarException, error) {
+	return queryCalendarExceptions(`
+                SELECT id, date, closed, open_time, close_time, COALESCE(note, ''), created_at
+                FROM calendar_exceptions
+                WHERE date >= $1
+                ORDER BY date
+        `, from)
+}
+
+// queryCalendarExceptions runs a calendar exception query
+func queryCalendarExceptions(query string, args ...interface{}) ([]*CalendarException, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var exceptions []*CalendarException
+	for rows.Next() {
+		e := &CalendarException{}
+		err := rows.Scan(&e.ID, &e.Date, &e.Closed, &e.OpenTime, &e.CloseTime, &e.Note, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		exceptions = append(exceptions, e)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exceptions, nil
+}
+
+// CreateCalendarException adds or replaces the exception for a date
+func CreateCalendarException(e *CalendarException) error {
+	if e.Closed {
+		e.OpenTime, e.CloseTime = "", ""
+	} else if err := validateOpeningTimes(e.OpenTime, e.CloseTime); err != nil {
+		return err
+	}
+
+	db := config.GetDB()
+
+	return db.QueryRow(`
+                INSERT INTO calendar_exceptions (date, closed, open_time, close_time, note)
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (date) DO UPDATE
+                SET closed = EXCLUDED.closed, open_time = EXCLUDED.open_time,
+                    close_time = EXCLUDED.close_time, note = EXCLUDED.note
+                RETURNING id, created_at
+        `, e.Date, e.Closed, e.OpenTime, e.CloseTime, e.Note).Scan(&e.ID, &e.CreatedAt)
+}
+
+// DeleteCalendarException removes a calendar exception
+func DeleteCalendarException(id int) error {
+	db := config.GetDB()
+
+	result, err := db.Exec("DELETE FROM calendar_exceptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New("calendar exception not found")
+	}
+
+	return nil
+}
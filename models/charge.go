@@ -0,0 +1,157 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Charge type constants
+const (
+	ChargeTypeOverdue     = "overdue"
+	ChargeTypeReplacement = "replacement"
+	ChargeTypeDamage      = "damage"
+)
+
+// Charge status constants
+const (
+	ChargeStatusOutstanding = "outstanding"
+	ChargeStatusPaid        = "paid"
+	ChargeStatusWaived      = "waived"
+	ChargeStatusCancelled   = "cancelled"
+)
+
+// Charge represents a fee owed by a patron
+type Charge struct {
+	ID          int
+	UserID      sql.NullInt64 // Null once the patron is erased or the loan anonymized
+	BorrowID    sql.NullInt64
+	Type        string
+	Amount      float64
+	Description string
+	Status      string
+	ResolvedBy  sql.NullInt64
+	ResolvedAt  NullTime
+	CreatedAt   time.Time
+}
+
+// CalculateOverdueFine returns the fine for an item returned at returnDate. Only days
+// the library was open count towards the fine.
+func CalculateOverdueFine(dueDate, returnDate time.Time) float64 {
+	if !returnDate.After(dueDate) {
+		return 0
+	}
+	days := CountOpenDaysBetween(dueDate, returnDate)
+	if days <= 0 {
+		return 0
+	}
+	return float64(days) * config.AppConfig.Circulation.FinePerDay
+}
+
+// CalculateShortLoanFine returns the fine for a short loan returned at returnDate,
+// charged for every started hour late
+func CalculateShortLoanFine(dueDate, returnDate time.Time) float64 {
+	if !returnDate.After(dueDate) {
+		return 0
+	}
+	hours := math.Ceil(returnDate.Sub(dueDate).Hours())
+	return hours * config.AppConfig.Circulation.FinePerHour
+}
+
+// createCharge inserts a charge as part of an existing transaction
+func createCharge(tx *sql.Tx, userID int, borrowID int, chargeType string, amount float64, description string) error {
+	_, err := tx.Exec(`
+                INSERT INTO charges (user_id, borrow_id, type, amount, description)
+                VALUES ($1, $2, $3, $4, $5)
+        `, userID, borrowID, chargeType, amount, description)
+	return err
+}
+
+// GetOutstandingBalance returns the total of a user's unpaid charges
+func GetOutstandingBalance(userID int) (float64, error) {
+	db := config.GetDB()
+
+	var balance float64
+	err := db.QueryRow(`
+                SELECT COALESCE(SUM(amount), 0) FROM charges
+                WHERE user_id = $1 AND status = $2
+        `, userID, ChargeStatusOutstanding).Scan(&balance)
+
+	return balance, err
+}
+
+// GetUserCharges retrieves all charges for a user, newest first
+func GetUserCharges(userID int) ([]*Charge, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id, user_id, borrow_id, type, amount, COALESCE(description, ''), status,
+                        resolved_by, resolved_at, created_at
+                FROM charges
+                WHERE user_id = $1
+                ORDER BY created_at DESC
+        `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var charges []*Charge
+	for rows.Next() {
+		charge := &Charge{}
+		err := rows.Scan(
+			&charge.ID,
+			&charge.UserID,
+			&charge.BorrowID,
+			&charge.Type,
+			&charge.Amount,
+			&charge.Description,
+			&charge.Status,
+			&charge.ResolvedBy,
+			&charge.ResolvedAt,
+			&charge.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		charges = append(charges, charge)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return charges, nil
+}
+
+// ResolveCharge marks an outstanding charge as paid or waived and returns its owner,
+// or 0 if the patron has since been erased
+func ResolveCharge(id int, status string, resolverID int) (int, error) {
+	if status != ChargeStatusPaid && status != ChargeStatusWaived {
+		return 0, errors.New("invalid charge status")
+	}
+
+	db := config.GetDB()
+
+	var userID sql.NullInt64
+	err := db.QueryRow(`
+                UPDATE charges
+                SET status = $1, resolved_by = $2, resolved_at = CURRENT_TIMESTAMP
+                WHERE id = $3 AND status = $4
+                RETURNING user_id
+        `, status, resolverID, id, ChargeStatusOutstanding).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("charge not found or already resolved")
+		}
+		return 0, err
+	}
+
+	return int(userID.Int64), nil
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"library-management-system/config"
+)
+
+// Class represents a node in the classification scheme, e.g. Dewey 823
+type Class struct {
+	ID       int
+	Notation string
+	Caption  string
+	ParentID sql.NullInt64
+
+	// Computed properties
+	Children  []*Class
+	Depth     int
+	BookCount int // Books in this class and all narrower classes
+}
+
+var (
+	deweyPattern = regexp.MustCompile(`^(\d+)(\.\d+)?$`)
+	lcPattern    = regexp.MustCompile(`^([A-Z]{1,3})(\d+)(\.\d+)?$`)
+)
+
+// CallNumberSortKey converts a call number into a key that sorts in shelf order.
+// Class numbers are compared numerically, so "92" files before "823.912" and
+// Library of Congress numbers like "PR6029" file by letters then number. Any
+// remaining parts (cutters, dates) compare as text.
+func CallNumberSortKey(callNumber string) string {
+	tokens := strings.Fields(strings.ToUpper(callNumber))
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	if m := deweyPattern.FindStringSubmatch(tokens[0]); m != nil {
+		tokens[0] = fmt.Sprintf("0 %06s%s", m[1], m[2])
+	} else if m := lcPattern.FindStringSubmatch(tokens[0]); m != nil {
+		tokens[0] = fmt.Sprintf("1 %-3s%06s%s", m[1], m[2], m[3])
+	} else {
+		tokens[0] = "2 " + tokens[0]
+	}
+
+	key := strings.Join(tokens, " ")
+	if len(key) > 120 {
+		key = key[:120]
+	}
+	return key
+}
+
+// GetClassByID retrieves a class by ID
+func GetClassByID(id int) (*Class, error) {
+	db := config.GetDB()
+
+	class := &Class{}
+	err := db.QueryRow("SELECT id, notation, caption, parent_id FROM classes WHERE id = $1", id).
+		Scan(&class.ID, &class.Notation, &class.Caption, &class.ParentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("class not found")
+		}
+		return nil, err
+	}
+
+	return class, nil
+}
+
+// GetClassTree retrieves the classification scheme as a tree of top-level classes,
+// with book counts rolled up from narrower classes
+func GetClassTree() ([]*Class, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT c.id, c.notation, c.caption, c.parent_id, COUNT(b.id)
+                FROM classes c
+                LEFT JOIN books b ON b.class_id = c.id
+                GROUP BY c.id
+                ORDER BY c.notation
+        `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var classes []*Class
+	byID := make(map[int]*Class)
+	for rows.Next() {
+		class := &Class{}
+		if err := rows.Scan(&class.ID, &class.Notation, &class.Caption, &class.ParentID, &class.BookCount); err != nil {
+			return nil, err
+		}
+		classes = append(classes, class)
+		byID[class.ID] = class
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Link children to parents
+	var roots []*Class
+	for _, class := range classes {
+		if parent, ok := byID[int(class.ParentID.Int64)]; class.ParentID.Valid && ok {
+			parent.Children = append(parent.Children, class)
+		} else {
+			roots = append(roots, class)
+		}
+	}
+
+	// Roll up counts and record depths
+	var walk func(class *Class, depth int) int
+	walk = func(class *Class, depth int) int {
+		class.Depth = depth
+		for _, child := range class.Children {
+			class.BookCount += walk(child, depth+1)
+		}
+		return class.BookCount
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	return roots, nil
+}
+
+// FlattenClassTree lists a class tree depth-first, for indented select options
+func FlattenClassTree(roots []*Class) []*Class {
+	var flat []*Class
+	var walk func(classes []*Class)
+	walk = func(classes []*Class) {
+		for _, class := range classes {
+			flat = append(flat, class)
+			walk(class.Children)
+		}
+	}
+	walk(roots)
+	return flat
+}
+
+// InClass reports whether a book is filed under the given class
+func (b *Book) InClass(classID int) bool {
+	return b.ClassID.Valid && int(b.ClassID.Int64) == classID
+}
+
+// Indent returns a prefix showing the class's depth in a select list
+func (c *Class) Indent() string {
+	return strings.Repeat("\u00a0\u00a0", c.Depth)
+}
+
+// Create saves a new class to the scheme
+func (c *Class) Create() error {
+	c.Notation = strings.TrimSpace(c.Notation)
+	c.Caption = strings.TrimSpace(c.Caption)
+	if c.Notation == "" || c.Caption == "" {
+		return errors.New("notation and caption are required")
+	}
+
+	db := config.GetDB()
+
+	err := db.QueryRow(`
+                INSERT INTO classes (notation, caption, parent_id)
+                VALUES ($1, $2, $3)
+                RETURNING id
+        `, c.Notation, c.Caption, c.ParentID).Scan(&c.ID)
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return errors.New("a class with notation " + c.Notation + " already exists")
+	}
+
+	return err
+}
+
+// DeleteClass removes a class that has no narrower classes. Its books become unclassified.
+func DeleteClass(id int) error {
+	db := config.GetDB()
+
+	var children int
+	if err := db.QueryRow("SELECT COUNT(*) FROM classes WHERE parent_id = $1", id).Scan(&children); err != nil {
+		return err
+	}
+	if children > 0 {
+		return errors.New("remove the narrower classes first")
+	}
+
+	_, err := db.Exec("DELETE FROM classes WHERE id = $1", id)
+
+	return err
+}
@@ -0,0 +1,550 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Collection analysis sort orders
+const (
+	CollectionSortLoans  = "loans"  // Least used first
+	CollectionSortAge    = "age"    // Oldest publications first
+	CollectionSortDemand = "demand" // Most reservations per copy first
+	CollectionSortCopies = "copies" // Most copies per loan or reservation first
+)
+
+// Weeding record status constants
+const (
+	WeedingStatusCandidate = "candidate"
+	WeedingStatusKept      = "kept"
+	WeedingStatusWithdrawn = "withdrawn"
+)
+
+// CollectionFilter selects the books a collection analysis lists
+type CollectionFilter struct {
+	Months          int // Length of the usage period, ending today
+	MaxLoans        int // Most loans in the period a listed book may have; negative for any
+	PublishedBefore int // Latest publication year listed; 0 for any
+	Category        string
+	IncludeNew      bool // List books added during the period, which have had less time to circulate
+	Sort            string
+}
+
+// Since returns the start of the usage period
+func (f CollectionFilter) Since() time.Time {
+	return time.Now().AddDate(0, -f.Months, 0)
+}
+
+// CollectionItem is a book in the collection with its usage over the analysis period
+type CollectionItem struct {
+	BookID          int
+	Title           string
+	Author          string
+	CallNumber      string
+	Category        string
+	PublicationYear int
+	Copies          int
+	OnLoan          int
+	Loans           int // Loans in the period
+	LastBorrowed    *time.Time
+	Holds           int // Active reservations
+	AddedAt         time.Time
+	Candidate       bool // Marked for weeding and awaiting review
+}
+
+// Age returns the years since the book was published, or -1 if the year is unknown
+func (c *CollectionItem) Age() int {
+	if c.PublicationYear <= 0 {
+		return -1
+	}
+	return time.Now().Year() - c.PublicationYear
+}
+
+// Demand returns the loans in the period plus the reservations waiting
+func (c *CollectionItem) Demand() int {
+	return c.Loans + c.Holds
+}
+
+// LoansPerCopy returns the loans in the period for each copy held
+func (c *CollectionItem) LoansPerCopy() float64 {
+	if c.Copies == 0 {
+		return float64(c.Loans)
+	}
+	return float64(c.Loans) / float64(c.Copies)
+}
+
+// HoldsPerCopy returns the reservations waiting for each copy held; more than one
+// suggests buying copies rather than weeding
+func (c *CollectionItem) HoldsPerCopy() float64 {
+	if c.Copies == 0 {
+		return float64(c.Holds)
+	}
+	return float64(c.Holds) / float64(c.Copies)
+}
+
+// CollectionAgeBand summarizes the collection's titles published within a range of years
+type CollectionAgeBand struct {
+	Label  string
+	Titles int
+	Copies int
+	Loans  int // Loans in the period
+	Unused int // Titles not lent in the period
+}
+
+// UnusedRate returns the percentage of the band's titles not lent in the period
+func (b *CollectionAgeBand) UnusedRate() float64 {
+	if b.Titles == 0 {
+		return 0
+	}
+	return float64(b.Unused) / float64(b.Titles) * 100
+}
+
+// collectionAgeBands names the bands GetCollectionAgeProfile groups titles into, in
+// the order of the band numbers it computes
+var collectionAgeBands = []string{"Under 5 years", "5 to 9 years", "10 to 19 years", "20 to 39 years", "40 years or more", "Year unknown"}
+
+// collectionUsage joins books b to their loans since $1 (lent) and their active
+// reservations (held)
+const collectionUsage = `
+                LEFT JOIN (
+                        SELECT book_id, COUNT(*) FILTER (WHERE borrow_date >= $1) AS loans, MAX(borrow_date) AS last_borrowed
+                        FROM borrows
+                        WHERE borrow_date IS NOT NULL AND status NOT IN ('` + BorrowStatusPending + `', '` + BorrowStatusRejected + `')
+                        GROUP BY book_id
+                ) lent ON lent.book_id = b.id
+                LEFT JOIN (
+                        SELECT book_id, COUNT(*) AS holds
+                        FROM reservations
+                        WHERE status = '` + ReservationStatusActive + `'
+                        GROUP BY book_id
+                ) held ON held.book_id = b.id`
+
+// collectionItemColumns lists the columns scanned by scanCollectionItem from books b
+// joined with collectionUsage
+const collectionItemColumns = `b.id, b.title, b.author, b.call_number, COALESCE(b.category, ''), COALESCE(b.publication_year, 0),
+                b.quantity, b.quantity - b.available, COALESCE(lent.loans, 0), lent.last_borrowed, COALESCE(held.holds, 0), b.created_at,
+                EXISTS (SELECT 1 FROM weeding_records wc WHERE wc.book_id = b.id AND wc.status = '` + WeedingStatusCandidate + `')`
+
+// scanCollectionItem reads a row selected with collectionItemColumns, followed by any
+// extra destinations
+func scanCollectionItem(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*CollectionItem, error) {
+	c := &CollectionItem{}
+	dest := []interface{}{&c.BookID, &c.Title, &c.Author, &c.CallNumber, &c.Category, &c.PublicationYear,
+		&c.Copies, &c.OnLoan, &c.Loans, &c.LastBorrowed, &c.Holds, &c.AddedAt, &c.Candidate}
+	err := row.Scan(append(dest, extra...)...)
+	return c, err
+}
+
+// where returns the filter's conditions and their parameters; $1 is the start of the period
+func (f CollectionFilter) where() (string, []interface{}) {
+	conditions := " WHERE b.withdrawn_at IS NULL"
+	params := []interface{}{f.Since()}
+
+	if f.MaxLoans >= 0 {
+		params = append(params, f.MaxLoans)
+		conditions += fmt.Sprintf(" AND COALESCE(lent.loans, 0) <= $%d", len(params))
+	}
+	if f.PublishedBefore > 0 {
+		params = append(params, f.PublishedBefore)
+		conditions += fmt.Sprintf(" AND b.publication_year > 0 AND b.publication_year <= $%d", len(params))
+	}
+	if f.Category != "" {
+		params = append(params, f.Category)
+		conditions += fmt.Sprintf(" AND COALESCE(b.category, '') = $%d", len(params))
+	}
+	if !f.IncludeNew {
+		conditions += " AND b.created_at < $1"
+	}
+	return conditions, params
+}
+
+// orderBy returns the ORDER BY clause for the filter's sort
+func (f CollectionFilter) orderBy() string {
+	switch f.Sort {
+	case CollectionSortAge:
+		return " ORDER BY NULLIF(b.publication_year, 0) ASC NULLS LAST, COALESCE(lent.loans, 0), b.title"
+	case CollectionSortDemand:
+		return " ORDER BY COALESCE(held.holds, 0)::real / GREATEST(b.quantity, 1) DESC, COALESCE(lent.loans, 0) DESC, b.title"
+	case CollectionSortCopies:
+		return " ORDER BY b.quantity::real / (COALESCE(lent.loans, 0) + COALESCE(held.holds, 0) + 1) DESC, b.title"
+	default:
+		return " ORDER BY COALESCE(lent.loans, 0), lent.last_borrowed ASC NULLS FIRST, b.title"
+	}
+}
+
+// CountCollectionItems counts the books matching the filter
+func CountCollectionItems(f CollectionFilter) (int, error) {
+	db := config.GetDB()
+
+	conditions, params := f.where()
+	var count int
+	err := db.QueryRow(`
+                SELECT COUNT(*)
+                FROM books b`+collectionUsage+conditions, params...).Scan(&count)
+	return count, err
+}
+
+// GetCollectionItems retrieves a page of the books matching the filter, or all of
+// them when itemsPerPage is 0
+func GetCollectionItems(f CollectionFilter, page, itemsPerPage int) ([]*CollectionItem, error) {
+	db := config.GetDB()
+
+	conditions, params := f.where()
+	limit := ""
+	if itemsPerPage > 0 {
+		params = append(params, itemsPerPage, (page-1)*itemsPerPage)
+		limit = fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(params)-1, len(params))
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT `+collectionItemColumns+`
+                FROM books b`+collectionUsage+conditions+f.orderBy()+limit, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var items []*CollectionItem
+	for rows.Next() {
+		item, err := scanCollectionItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// GetCollectionAgeProfile groups every title in the collection, or in the filter's
+// category, by years since publication, with its loans over the filter's period.
+// The last band is the total.
+func GetCollectionAgeProfile(f CollectionFilter) ([]*CollectionAgeBand, error) {
+	db := config.GetDB()
+
+	bands := make([]*CollectionAgeBand, len(collectionAgeBands))
+	for i, label := range collectionAgeBands {
+		bands[i] = &CollectionAgeBand{Label: label}
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT CASE
+                                WHEN COALESCE(b.publication_year, 0) <= 0 THEN 5
+                                WHEN $2 - b.publication_year < 5 THEN 0
+                                WHEN $2 - b.publication_year < 10 THEN 1
+                                WHEN $2 - b.publication_year < 20 THEN 2
+                                WHEN $2 - b.publication_year < 40 THEN 3
+                                ELSE 4
+                        END AS band,
+                        COUNT(*), COALESCE(SUM(b.quantity), 0), COALESCE(SUM(lent.loans), 0),
+                        COUNT(*) FILTER (WHERE COALESCE(lent.loans, 0) = 0)
+                FROM books b`+collectionUsage+`
+                WHERE b.withdrawn_at IS NULL AND ($3 = '' OR COALESCE(b.category, '') = $3)
+                GROUP BY band
+        `, f.Since(), time.Now().Year(), f.Category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	total := &CollectionAgeBand{Label: "All titles"}
+	for rows.Next() {
+		var band int
+		b := &CollectionAgeBand{}
+		if err := rows.Scan(&band, &b.Titles, &b.Copies, &b.Loans, &b.Unused); err != nil {
+			return nil, err
+		}
+		if band < 0 || band >= len(bands) {
+			continue
+		}
+		b.Label = bands[band].Label
+		bands[band] = b
+
+		total.Titles += b.Titles
+		total.Copies += b.Copies
+		total.Loans += b.Loans
+		total.Unused += b.Unused
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return append(bands, total), nil
+}
+
+// WeedingRecord is a decision on withdrawing a book: a candidate awaiting review, or
+// the outcome of a review, kept as the book's weeding history
+type WeedingRecord struct {
+	ID         int
+	BookID     int
+	Status     string
+	Reason     string // Why the book was marked
+	Note       string // The reviewer's note
+	Copies     int    // Copies withdrawn
+	MarkedBy   sql.NullInt64
+	MarkedAt   time.Time
+	ReviewedBy sql.NullInt64
+	ReviewedAt *time.Time
+
+	// Computed properties
+	Item         *CollectionItem
+	MarkerName   string
+	ReviewerName string
+}
+
+// weedingRecordColumns lists the columns scanned by scanWeedingRecord from
+// weeding_records w left joined to users m (marker) and r (reviewer)
+const weedingRecordColumns = `w.id, w.book_id, w.status, w.reason, w.note, w.copies, w.marked_by, w.marked_at,
+                w.reviewed_by, w.reviewed_at, COALESCE(m.name, ''), COALESCE(r.name, '')`
+
+// scanWeedingRecord reads a row selected with collectionItemColumns followed by
+// weedingRecordColumns
+func scanWeedingRecord(row interface{ Scan(...interface{}) error }) (*WeedingRecord, error) {
+	w := &WeedingRecord{}
+	item, err := scanCollectionItem(row, &w.ID, &w.BookID, &w.Status, &w.Reason, &w.Note, &w.Copies, &w.MarkedBy,
+		&w.MarkedAt, &w.ReviewedBy, &w.ReviewedAt, &w.MarkerName, &w.ReviewerName)
+	w.Item = item
+	return w, err
+}
+
+// CountWeedingRecords counts the weeding records with the given status
+func CountWeedingRecords(status string) (int, error) {
+	db := config.GetDB()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM weeding_records WHERE status = $1", status).Scan(&count)
+	return count, err
+}
+
+// GetWeedingRecords retrieves a page of the weeding records with the given status and
+// their books' usage over the period: candidates in the order they were marked,
+// decisions most recent first
+func GetWeedingRecords(status string, months, page, itemsPerPage int) ([]*WeedingRecord, error) {
+	db := config.GetDB()
+
+	order := "w.reviewed_at DESC, w.id DESC"
+	if status == WeedingStatusCandidate {
+		order = "w.marked_at, w.id"
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT `+collectionItemColumns+`, `+weedingRecordColumns+`
+                FROM weeding_records w
+                JOIN books b ON b.id = w.book_id`+collectionUsage+`
+                LEFT JOIN users m ON m.id = w.marked_by
+                LEFT JOIN users r ON r.id = w.reviewed_by
+                WHERE w.status = $2
+                ORDER BY `+order+`
+                LIMIT $3 OFFSET $4
+        `, CollectionFilter{Months: months}.Since(), status, itemsPerPage, (page-1)*itemsPerPage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var records []*WeedingRecord
+	for rows.Next() {
+		record, err := scanWeedingRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// GetBookWithdrawal retrieves the record of a book's withdrawal, or nil if the book
+// is still in the collection
+func GetBookWithdrawal(bookID int) (*WeedingRecord, error) {
+	db := config.GetDB()
+
+	w := &WeedingRecord{}
+	err := db.QueryRow(`
+                SELECT w.id, w.book_id, w.status, w.reason, w.note, w.copies, w.marked_by, w.marked_at,
+                        w.reviewed_by, w.reviewed_at, COALESCE(r.name, '')
+                FROM weeding_records w
+                LEFT JOIN users r ON r.id = w.reviewed_by
+                WHERE w.book_id = $1 AND w.status = $2
+                ORDER BY w.reviewed_at DESC
+                LIMIT 1
+        `, bookID, WeedingStatusWithdrawn).Scan(&w.ID, &w.BookID, &w.Status, &w.Reason, &w.Note, &w.Copies,
+		&w.MarkedBy, &w.MarkedAt, &w.ReviewedBy, &w.ReviewedAt, &w.ReviewerName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// MarkWeedingCandidates marks books for review, skipping any already awaiting review
+// or withdrawn, and returns how many were marked
+func MarkWeedingCandidates(bookIDs []int, reason string, userID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	marked := 0
+	for _, id := range bookIDs {
+		result, err := tx.Exec(`
+                        INSERT INTO weeding_records (book_id, status, reason, marked_by)
+                        SELECT id, $2::text, $3::text, $4::int FROM books
+                        WHERE id = $1 AND withdrawn_at IS NULL
+                        ON CONFLICT (book_id) WHERE status = '`+WeedingStatusCandidate+`' DO NOTHING
+                `, id, WeedingStatusCandidate, strings.TrimSpace(reason), userID)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		marked += int(n)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return marked, nil
+}
+
+// KeepWeedingCandidates records that the reviewed candidates stay in the collection
+// and returns how many were kept
+func KeepWeedingCandidates(recordIDs []int, note string, userID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	kept := 0
+	for _, id := range recordIDs {
+		result, err := tx.Exec(`
+                        UPDATE weeding_records
+                        SET status = $1, note = $2, reviewed_by = $3, reviewed_at = CURRENT_TIMESTAMP
+                        WHERE id = $4 AND status = $5
+                `, WeedingStatusKept, strings.TrimSpace(note), userID, id, WeedingStatusCandidate)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		kept += int(n)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return kept, nil
+}
+
+// WithdrawWeedingCandidates withdraws the reviewed candidates from the collection.
+// A withdrawn book is taken out of stock and no longer listed, but its record and
+// loan history are kept. Books with copies out, requested or reserved are skipped
+// and returned with the reason.
+func WithdrawWeedingCandidates(recordIDs []int, note string, userID int) (int, []string, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	withdrawn := 0
+	var skipped []string
+	for _, id := range recordIDs {
+		// Lock the book and check nothing is waiting on it
+		var bookID, copies, loans, holds int
+		var title string
+		err := tx.QueryRow(`
+                        SELECT b.id, b.title, b.quantity,
+                                (SELECT COUNT(*) FROM borrows br WHERE br.book_id = b.id AND br.status IN ($2, $3, $4)),
+                                (SELECT COUNT(*) FROM reservations rs WHERE rs.book_id = b.id AND rs.status = $5)
+                        FROM weeding_records w
+                        JOIN books b ON b.id = w.book_id
+                        WHERE w.id = $1 AND w.status = $6
+                        FOR UPDATE OF b
+                `, id, BorrowStatusApproved, BorrowStatusPending, BorrowStatusClaimedReturned, ReservationStatusActive,
+			WeedingStatusCandidate).Scan(&bookID, &title, &copies, &loans, &holds)
+		if err == sql.ErrNoRows {
+			// Reviewed since the list was shown
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if loans > 0 {
+			skipped = append(skipped, title+" (copies are on loan or requested)")
+			continue
+		}
+		if holds > 0 {
+			skipped = append(skipped, title+" (patrons are waiting for it)")
+			continue
+		}
+
+		_, err = tx.Exec(`
+                        UPDATE books
+                        SET withdrawn_at = CURRENT_TIMESTAMP, quantity = 0, available = 0, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $1
+                `, bookID)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		_, err = tx.Exec(`
+                        UPDATE weeding_records
+                        SET status = $1, note = $2, copies = $3, reviewed_by = $4, reviewed_at = CURRENT_TIMESTAMP
+                        WHERE id = $5
+                `, WeedingStatusWithdrawn, strings.TrimSpace(note), copies, userID, id)
+		if err != nil {
+			return 0, nil, err
+		}
+		withdrawn++
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+
+	if withdrawn == 0 && len(skipped) == 0 {
+		return 0, nil, errors.New("none of the selected books are awaiting review")
+	}
+	return withdrawn, skipped, nil
+}
@@ -0,0 +1,461 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// ReserveLoanPeriods lists the short loan periods, in hours, a reserve item can have
+var ReserveLoanPeriods = []int{2, 4, 24}
+
+// Term is an academic term; course reserves are released when it ends
+type Term struct {
+	ID        int
+	Name      string
+	StartsOn  time.Time
+	EndsOn    time.Time
+	CreatedAt time.Time
+}
+
+// Current reports whether today falls within the term
+func (t *Term) Current() bool {
+	today := time.Now().Format("2006-01-02")
+	return t.StartsOn.Format("2006-01-02") <= today && today <= t.EndsOn.Format("2006-01-02")
+}
+
+// Course is a class taught in a term, with the reading its instructors put on reserve
+type Course struct {
+	ID        int
+	Code      string
+	Name      string
+	TermID    int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// Computed properties
+	Term         *Term
+	Instructors  []*User
+	Reserves     []*CourseReserve
+	ReserveCount int
+}
+
+// Label returns the course code and name
+func (c *Course) Label() string {
+	return c.Code + " " + c.Name
+}
+
+// HasInstructor reports whether the user teaches the course
+func (c *Course) HasInstructor(userID int) bool {
+	for _, u := range c.Instructors {
+		if u.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CourseReserve is a book placed on reserve for a course. While the reserve is
+// active, the book is lent for LoanHours at a time.
+type CourseReserve struct {
+	ID         int
+	CourseID   int
+	BookID     int
+	LoanHours  int
+	Notes      string
+	AddedBy    sql.NullInt64
+	CreatedAt  time.Time
+	ReleasedAt NullTime
+
+	// Computed properties
+	Book   *Book
+	Course *Course
+}
+
+// GetTerms retrieves all terms, latest first
+func GetTerms() ([]*Term, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query("SELECT id, name, starts_on, ends_on, created_at FROM terms ORDER BY starts_on DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var terms []*Term
+	for rows.Next() {
+		t := &Term{}
+		if err := rows.Scan(&t.ID, &t.Name, &t.StartsOn, &t.EndsOn, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return terms, nil
+}
+
+// CreateTerm adds a term
+func CreateTerm(t *Term) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return errors.New("term name is required")
+	}
+	if t.StartsOn.IsZero() || t.EndsOn.IsZero() {
+		return errors.New("term start and end dates are required")
+	}
+	if t.EndsOn.Before(t.StartsOn) {
+		return errors.New("a term cannot end before it starts")
+	}
+
+	db := config.GetDB()
+
+	err := db.QueryRow(`
+                INSERT INTO terms (name, starts_on, ends_on)
+                VALUES ($1, $2, $3)
+                RETURNING id, created_at
+        `, t.Name, t.StartsOn, t.EndsOn).Scan(&t.ID, &t.CreatedAt)
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return errors.New("a term named " + t.Name + " already exists")
+	}
+
+	return err
+}
+
+// GetCourses retrieves the courses of terms that have not ended, matching the search
+// against course code, name and instructor names. With all set, past terms are
+// included too.
+func GetCourses(search string, all bool) ([]*Course, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT c.id, c.code, c.name, c.term_id, c.created_at, c.updated_at,
+                        t.id, t.name, t.starts_on, t.ends_on, t.created_at,
+                        (SELECT COUNT(*) FROM course_reserves r WHERE r.course_id = c.id AND r.released_at IS NULL)
+                FROM courses c
+                JOIN terms t ON t.id = c.term_id
+                WHERE ($2 OR t.ends_on >= CURRENT_DATE)
+                        AND ($1 = '' OR c.code ILIKE '%' || $1 || '%' OR c.name ILIKE '%' || $1 || '%'
+                                OR c.id IN (
+                                        SELECT ci.course_id FROM course_instructors ci
+                                        JOIN users u ON u.id = ci.user_id
+                                        WHERE u.name ILIKE '%' || $1 || '%'
+                                ))
+                ORDER BY t.starts_on DESC, c.code
+        `, strings.TrimSpace(search), all)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var courses []*Course
+	for rows.Next() {
+		c := &Course{Term: &Term{}}
+		err := rows.Scan(&c.ID, &c.Code, &c.Name, &c.TermID, &c.CreatedAt, &c.UpdatedAt,
+			&c.Term.ID, &c.Term.Name, &c.Term.StartsOn, &c.Term.EndsOn, &c.Term.CreatedAt, &c.ReserveCount)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, c)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range courses {
+		if c.Instructors, err = getCourseInstructors(c.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return courses, nil
+}
+
+// GetCourseByID retrieves a course with its term, instructors and active reserves
+func GetCourseByID(id int) (*Course, error) {
+	db := config.GetDB()
+
+	c := &Course{Term: &Term{}}
+	err := db.QueryRow(`
+                SELECT c.id, c.code, c.name, c.term_id, c.created_at, c.updated_at,
+                        t.id, t.name, t.starts_on, t.ends_on, t.created_at
+                FROM courses c
+                JOIN terms t ON t.id = c.term_id
+                WHERE c.id = $1
+        `, id).Scan(&c.ID, &c.Code, &c.Name, &c.TermID, &c.CreatedAt, &c.UpdatedAt,
+		&c.Term.ID, &c.Term.Name, &c.Term.StartsOn, &c.Term.EndsOn, &c.Term.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("course not found")
+		}
+		return nil, err
+	}
+
+	if c.Instructors, err = getCourseInstructors(id); err != nil {
+		return nil, err
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id, course_id, book_id, loan_hours, notes, added_by, created_at, released_at
+                FROM course_reserves
+                WHERE course_id = $1 AND released_at IS NULL
+                ORDER BY created_at
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	for rows.Next() {
+		r := &CourseReserve{Course: c}
+		err := rows.Scan(&r.ID, &r.CourseID, &r.BookID, &r.LoanHours, &r.Notes, &r.AddedBy, &r.CreatedAt, &r.ReleasedAt)
+		if err != nil {
+			return nil, err
+		}
+		c.Reserves = append(c.Reserves, r)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range c.Reserves {
+		if r.Book, err = GetBookByID(r.BookID); err != nil {
+			return nil, err
+		}
+	}
+	c.ReserveCount = len(c.Reserves)
+
+	return c, nil
+}
+
+// getCourseInstructors retrieves the users teaching a course
+func getCourseInstructors(courseID int) ([]*User, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT u.id, u.name, u.email
+                FROM course_instructors ci
+                JOIN users u ON u.id = ci.user_id
+                WHERE ci.course_id = $1
+                ORDER BY u.name
+        `, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var instructors []*User
+	for rows.Next() {
+		u := &User{}
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+			return nil, err
+		}
+		instructors = append(instructors, u)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return instructors, nil
+}
+
+// Save creates the course or saves changes to it
+func (c *Course) Save() error {
+	c.Code = strings.ToUpper(strings.TrimSpace(c.Code))
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Code == "" || c.Name == "" {
+		return errors.New("course code and name are required")
+	}
+	if c.TermID <= 0 {
+		return errors.New("a term is required")
+	}
+
+	db := config.GetDB()
+
+	var err error
+	if c.ID == 0 {
+		err = db.QueryRow(`
+                        INSERT INTO courses (code, name, term_id)
+                        VALUES ($1, $2, $3)
+                        RETURNING id, created_at, updated_at
+                `, c.Code, c.Name, c.TermID).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	} else {
+		_, err = db.Exec(`
+                        UPDATE courses
+                        SET code = $1, name = $2, term_id = $3, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $4
+                `, c.Code, c.Name, c.TermID, c.ID)
+	}
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return errors.New("course " + c.Code + " already exists in this term")
+	}
+
+	return err
+}
+
+// DeleteCourse deletes a course, releasing its reserves
+func DeleteCourse(id int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM courses WHERE id = $1", id)
+	return err
+}
+
+// AddCourseInstructor lets a user place books on reserve for a course
+func AddCourseInstructor(courseID int, email string) error {
+	db := config.GetDB()
+
+	var userID int
+	err := db.QueryRow("SELECT id FROM users WHERE LOWER(email) = LOWER($1)", strings.TrimSpace(email)).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("no user has that email address")
+		}
+		return err
+	}
+
+	_, err = db.Exec(`
+                INSERT INTO course_instructors (course_id, user_id)
+                VALUES ($1, $2)
+                ON CONFLICT DO NOTHING
+        `, courseID, userID)
+
+	return err
+}
+
+// RemoveCourseInstructor removes an instructor from a course
+func RemoveCourseInstructor(courseID, userID int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM course_instructors WHERE course_id = $1 AND user_id = $2", courseID, userID)
+	return err
+}
+
+// AddCourseReserve places a book on reserve for a course with a short loan period
+func AddCourseReserve(courseID, bookID, loanHours int, notes string, addedBy int) error {
+	valid := false
+	for _, hours := range ReserveLoanPeriods {
+		valid = valid || hours == loanHours
+	}
+	if !valid {
+		return errors.New("invalid loan period")
+	}
+
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                INSERT INTO course_reserves (course_id, book_id, loan_hours, notes, added_by)
+                VALUES ($1, $2, $3, $4, $5)
+        `, courseID, bookID, loanHours, strings.TrimSpace(notes), addedBy)
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return errors.New("this book is already on reserve for the course")
+	}
+
+	return err
+}
+
+// ReleaseCourseReserve takes a book off reserve; loans already made keep their due dates
+func ReleaseCourseReserve(courseID, reserveID int) error {
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                UPDATE course_reserves SET released_at = CURRENT_TIMESTAMP
+                WHERE id = $1 AND course_id = $2 AND released_at IS NULL
+        `, reserveID, courseID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("reserve not found")
+	}
+
+	return nil
+}
+
+// ReleaseEndedTermReserves releases the reserves of courses whose term has ended,
+// returning the books to normal loan periods. It runs as a background job.
+func ReleaseEndedTermReserves() error {
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                UPDATE course_reserves SET released_at = CURRENT_TIMESTAMP
+                WHERE released_at IS NULL AND course_id IN (
+                        SELECT c.id FROM courses c JOIN terms t ON t.id = c.term_id WHERE t.ends_on < CURRENT_DATE
+                )
+        `)
+	return err
+}
+
+// GetBookReserves retrieves the active course reserves for a book
+func GetBookReserves(bookID int) ([]*CourseReserve, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT r.id, r.course_id, r.book_id, r.loan_hours, r.notes, r.added_by, r.created_at, r.released_at,
+                        c.code, c.name
+                FROM course_reserves r
+                JOIN courses c ON c.id = r.course_id
+                WHERE r.book_id = $1 AND r.released_at IS NULL
+                ORDER BY r.loan_hours, c.code
+        `, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var reserves []*CourseReserve
+	for rows.Next() {
+		r := &CourseReserve{Course: &Course{}}
+		err := rows.Scan(&r.ID, &r.CourseID, &r.BookID, &r.LoanHours, &r.Notes, &r.AddedBy, &r.CreatedAt, &r.ReleasedAt,
+			&r.Course.Code, &r.Course.Name)
+		if err != nil {
+			return nil, err
+		}
+		r.Course.ID = r.CourseID
+		reserves = append(reserves, r)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reserves, nil
+}
+
+// shortLoanHours returns the loan period in hours for a book on course reserve, the
+// shortest if it is on reserve for several courses, or 0 for a normal loan. It runs
+// on the database or inside the caller's transaction.
+func shortLoanHours(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, bookID int) (int, error) {
+	var hours int
+	err := q.QueryRow(`
+                SELECT COALESCE(MIN(loan_hours), 0) FROM course_reserves
+                WHERE book_id = $1 AND released_at IS NULL
+        `, bookID).Scan(&hours)
+	return hours, err
+}
@@ -0,0 +1,341 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"library-management-system/config"
+)
+
+// CheckinResult describes the outcome of returning an item at the circulation desk
+type CheckinResult struct {
+	Borrow     *Borrow
+	Fine       float64
+	WasOverdue bool
+	Recovered  bool
+	HoldFor    *User
+}
+
+// CheckoutBook lends a book to a patron immediately, bypassing the request workflow.
+// A pending request or active reservation by the same patron for the book is fulfilled.
+// Items on course reserve are lent for their short loan period instead of until dueDate.
+func CheckoutBook(userID, bookID, librarianID int, dueDate time.Time) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Check if book is available
+	var available int
+	err = tx.QueryRow("SELECT available FROM books WHERE id = $1 FOR UPDATE", bookID).Scan(&available)
+	if err != nil {
+		return 0, err
+	}
+	if available <= 0 {
+		return 0, errors.New("no copies available for borrowing")
+	}
+
+	// Check if the patron already has this book
+	var count int
+	err = tx.QueryRow(`
+                SELECT COUNT(*) FROM borrows
+                WHERE user_id = $1 AND book_id = $2 AND status = $3
+        `, userID, bookID, BorrowStatusApproved).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, errors.New("patron is already borrowing this book")
+	}
+
+	// Course reserve items go out on short loan
+	borrowDate := time.Now()
+	loanHours, err := shortLoanHours(tx, bookID)
+	if err != nil {
+		return 0, err
+	}
+	if loanHours > 0 {
+		dueDate = borrowDate.Add(time.Duration(loanHours) * time.Hour)
+	}
+
+	// Approve an existing pending request, or create the loan directly. A request
+	// for a volume of a set is left to be approved with the rest of the set.
+	var borrowID int
+	err = tx.QueryRow(`
+                SELECT id FROM borrows
+                WHERE user_id = $1 AND book_id = $2 AND status = $3 AND set_request_id IS NULL
+                ORDER BY created_at ASC
+                LIMIT 1
+        `, userID, bookID, BorrowStatusPending).Scan(&borrowID)
+	switch {
+	case err == sql.ErrNoRows:
+		err = tx.QueryRow(`
+                        INSERT INTO borrows (user_id, book_id, status, borrow_date, due_date, approved_by, loan_hours)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7)
+                        RETURNING id
+                `, userID, bookID, BorrowStatusApproved, borrowDate, dueDate, librarianID, loanHours).Scan(&borrowID)
+		if err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	default:
+		_, err = tx.Exec(`
+                        UPDATE borrows
+                        SET status = $1, borrow_date = $2, due_date = $3, approved_by = $4, loan_hours = $5,
+                                updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $6
+                `, BorrowStatusApproved, borrowDate, dueDate, librarianID, loanHours, borrowID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Fulfil the patron's reservation for this book, if any
+	_, err = tx.Exec(`
+                UPDATE reservations
+                SET status = $1, fulfilled_date = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE user_id = $2 AND `+holdsForBook("$3")+` AND status = $4
+        `, ReservationStatusFulfilled, userID, bookID, ReservationStatusActive)
+	if err != nil {
+		return 0, err
+	}
+
+	// Update book available count
+	_, err = tx.Exec(`
+                UPDATE books
+                SET available = available - 1, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $1
+        `, bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return borrowID, nil
+}
+
+// ErrSeveralLoans is returned when a scanned book is out to more than one patron, so
+// the desk must say whose copy has been handed back
+var ErrSeveralLoans = errors.New("more than one copy of this book is on loan; choose whose copy is being returned")
+
+// CheckinBook returns a loan of a book and reports any fine charged and whether the
+// copy must go to the hold shelf. Copies of a book share its barcode, so borrowID
+// names the loan being returned when several are open; with borrowID 0 the only open
+// loan is returned, or ErrSeveralLoans if there is more than one. When no copy is on
+// loan, a lost or claimed-returned loan for the book is reversed instead.
+func CheckinBook(bookID, borrowID int) (*CheckinResult, error) {
+	db := config.GetDB()
+
+	if borrowID > 0 {
+		// The chosen loan must be an open loan of the scanned book
+		var count int
+		err := db.QueryRow(`
+                        SELECT COUNT(*) FROM borrows
+                        WHERE id = $1 AND book_id = $2 AND status = $3
+                `, borrowID, bookID, BorrowStatusApproved).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, errors.New("the chosen loan is not an open loan of this book")
+		}
+	} else {
+		// Find the open loans of this book
+		rows, err := db.Query(`
+                        SELECT id FROM borrows
+                        WHERE book_id = $1 AND status = $2
+                        LIMIT 2
+                `, bookID, BorrowStatusApproved)
+		if err != nil {
+			return nil, err
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(ids) > 1 {
+			return nil, ErrSeveralLoans
+		}
+		if len(ids) == 1 {
+			borrowID = ids[0]
+		}
+	}
+
+	result := &CheckinResult{}
+	if borrowID > 0 {
+		// Remember whether the loan was overdue before closing it
+		before, err := GetBorrowByID(borrowID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Close the loan
+		_, fine, err := completeReturn(borrowID)
+		if err != nil {
+			return nil, err
+		}
+		result.Fine = fine
+		result.WasOverdue = before.IsOverdue
+	} else {
+		// An item recorded as lost or claimed returned has turned up
+		var err error
+		borrowID, err = findMissingLoan(bookID)
+		if err != nil {
+			return nil, err
+		}
+		if borrowID == 0 {
+			return nil, errors.New("this book is not currently on loan")
+		}
+		if _, err := recoverBorrow(borrowID); err != nil {
+			return nil, err
+		}
+		result.Recovered = true
+	}
+	result.Borrow, _ = GetBorrowByID(borrowID)
+
+	// Find who is waiting for this book, then hand the copy to them
+	var holderID int
+	err := db.QueryRow(`
+                SELECT user_id FROM reservations
+                WHERE `+holdsForBook("$1")+` AND status = $2
+                ORDER BY reservation_date ASC
+                LIMIT 1
+        `, bookID, ReservationStatusActive).Scan(&holderID)
+	if err != nil && err != sql.ErrNoRows {
+		return result, err
+	}
+	if holderID > 0 {
+		if err := ProcessReservationsForBook(bookID); err != nil {
+			return result, err
+		}
+		result.HoldFor, _ = GetUserByID(holderID)
+	}
+
+	return result, nil
+}
+
+// GetOpenLoansForBook retrieves the open loans of a book, earliest due first
+func GetOpenLoansForBook(bookID int) ([]*Borrow, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date,
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
+                FROM borrows b
+                WHERE b.book_id = $1 AND b.status = $2
+                ORDER BY b.due_date ASC
+        `, bookID, BorrowStatusApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var borrows []*Borrow
+	for rows.Next() {
+		borrow := &Borrow{}
+		err := rows.Scan(
+			&borrow.ID,
+			&borrow.UserID,
+			&borrow.BookID,
+			&borrow.Status,
+			&borrow.BorrowDate,
+			&borrow.DueDate,
+			&borrow.ReturnDate,
+			&borrow.ApprovedBy,
+			&borrow.CreatedAt,
+			&borrow.UpdatedAt,
+			&borrow.LoanHours,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get related user
+		borrow.User = getBorrowPatron(borrow.UserID)
+
+		borrows = append(borrows, borrow)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return borrows, nil
+}
+
+// GetCheckinsSince retrieves loans returned since the given time, newest first
+func GetCheckinsSince(since time.Time) ([]*Borrow, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date,
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours
+                FROM borrows b
+                WHERE b.status = $1 AND b.return_date >= $2
+                ORDER BY b.return_date DESC
+        `, BorrowStatusReturned, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var borrows []*Borrow
+	for rows.Next() {
+		borrow := &Borrow{}
+		err := rows.Scan(
+			&borrow.ID,
+			&borrow.UserID,
+			&borrow.BookID,
+			&borrow.Status,
+			&borrow.BorrowDate,
+			&borrow.DueDate,
+			&borrow.ReturnDate,
+			&borrow.ApprovedBy,
+			&borrow.CreatedAt,
+			&borrow.UpdatedAt,
+			&borrow.LoanHours,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get related user
+		borrow.User = getBorrowPatron(borrow.UserID)
+
+		// Get related book
+		borrow.Book, _ = GetBookByID(borrow.BookID)
+
+		borrows = append(borrows, borrow)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return borrows, nil
+}
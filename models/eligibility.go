@@ -0,0 +1,215 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// PatronBlock is a manual borrowing block placed on a patron by a librarian
+type PatronBlock struct {
+	ID        int
+	UserID    int
+	Note      string
+	CreatedBy sql.NullInt64
+	ExpiresAt NullTime
+	LiftedAt  NullTime
+	CreatedAt time.Time
+
+	// Computed properties
+	Creator  *User
+	IsActive bool
+}
+
+// Eligibility is the outcome of checking whether a patron may borrow
+type Eligibility struct {
+	Reasons []string
+}
+
+// Eligible reports whether no rule prevents the patron from borrowing
+func (e *Eligibility) Eligible() bool {
+	return len(e.Reasons) == 0
+}
+
+// Summary joins the reasons into a single message
+func (e *Eligibility) Summary() string {
+	return strings.Join(e.Reasons, "; ")
+}
+
+// CheckBorrowEligibility evaluates the circulation rules for a patron. excludeBorrowID
+// lets the approval step ignore the pending request currently being approved.
+func CheckBorrowEligibility(userID int, excludeBorrowID int) (*Eligibility, error) {
+	db := config.GetDB()
+	policy := config.AppConfig.Circulation
+	result := &Eligibility{}
+
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expired membership
+	if user.IsExpired() {
+		result.Reasons = append(result.Reasons, "Library membership expired on "+user.ExpiresAt.Time.Format("Jan 02, 2006"))
+	}
+
+	// Manual blocks
+	blocks, err := GetActivePatronBlocks(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		reason := "Blocked by library staff: " + block.Note
+		if block.ExpiresAt.Valid {
+			reason += " (until " + block.ExpiresAt.Time.Format("Jan 02, 2006") + ")"
+		}
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	// Active loans, counting pending requests so they cannot be queued past the limit
+	var activeCount int
+	err = db.QueryRow(`
+                SELECT COUNT(*) FROM borrows
+                WHERE user_id = $1 AND status IN ($2, $3) AND id <> $4
+        `, userID, BorrowStatusPending, BorrowStatusApproved, excludeBorrowID).Scan(&activeCount)
+	if err != nil {
+		return nil, err
+	}
+	if policy.MaxActiveLoans > 0 && activeCount >= policy.MaxActiveLoans {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("Loan limit reached (%d of %d)", activeCount, policy.MaxActiveLoans))
+	}
+
+	// Overdue items
+	var overdueCount int
+	err = db.QueryRow(`
+                SELECT COUNT(*) FROM borrows
+                WHERE user_id = $1 AND status = $2 AND due_date < CURRENT_TIMESTAMP
+        `, userID, BorrowStatusApproved).Scan(&overdueCount)
+	if err != nil {
+		return nil, err
+	}
+	if overdueCount > policy.MaxOverdueItems {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("%d overdue item(s) must be returned first", overdueCount))
+	}
+
+	// Outstanding balance
+	balance, err := GetOutstandingBalance(userID)
+	if err != nil {
+		return nil, err
+	}
+	if balance > policy.MaxBalance {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("Outstanding balance of %.2f exceeds the %.2f limit", balance, policy.MaxBalance))
+	}
+
+	return result, nil
+}
+
+// GetActivePatronBlocks retrieves blocks that are neither lifted nor expired
+func GetActivePatronBlocks(userID int) ([]*PatronBlock, error) {
+	return queryPatronBlocks(`
+                SELECT id, user_id, note, created_by, expires_at, lifted_at, created_at
+                FROM patron_blocks
+                WHERE user_id = $1 AND lifted_at IS NULL
+                        AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+                ORDER BY created_at DESC
+        `, userID)
+}
+
+// GetPatronBlocks retrieves the full block history for a user
+func GetPatronBlocks(userID int) ([]*PatronBlock, error) {
+	return queryPatronBlocks(`
+                SELECT id, user_id, note, created_by, expires_at, lifted_at, created_at
+                FROM patron_blocks
+                WHERE user_id = $1
+                ORDER BY created_at DESC
+        `, userID)
+}
+
+// queryPatronBlocks runs a block query and loads related data
+func queryPatronBlocks(query string, args ...interface{}) ([]*PatronBlock, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var blocks []*PatronBlock
+	for rows.Next() {
+		block := &PatronBlock{}
+		err := rows.Scan(
+			&block.ID,
+			&block.UserID,
+			&block.Note,
+			&block.CreatedBy,
+			&block.ExpiresAt,
+			&block.LiftedAt,
+			&block.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		block.IsActive = !block.LiftedAt.Valid && (!block.ExpiresAt.Valid || block.ExpiresAt.Time.After(time.Now()))
+		blocks = append(blocks, block)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get creators
+	for _, block := range blocks {
+		if block.CreatedBy.Valid {
+			block.Creator, _ = GetUserByID(int(block.CreatedBy.Int64))
+		}
+	}
+
+	return blocks, nil
+}
+
+// CreatePatronBlock places a manual block on a patron
+func CreatePatronBlock(userID int, note string, createdBy int, expiresAt NullTime) error {
+	if strings.TrimSpace(note) == "" {
+		return errors.New("a note is required when blocking a patron")
+	}
+
+	db := config.GetDB()
+
+	// Execute query
+	_, err := db.Exec(`
+                INSERT INTO patron_blocks (user_id, note, created_by, expires_at)
+                VALUES ($1, $2, $3, $4)
+        `, userID, note, createdBy, expiresAt)
+
+	return err
+}
+
+// LiftPatronBlock ends a block early and returns the blocked user's ID
+func LiftPatronBlock(id int) (int, error) {
+	db := config.GetDB()
+
+	var userID int
+	err := db.QueryRow(`
+                UPDATE patron_blocks
+                SET lifted_at = CURRENT_TIMESTAMP
+                WHERE id = $1 AND lifted_at IS NULL
+                RETURNING user_id
+        `, id).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("block not found or already lifted")
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
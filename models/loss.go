@@ -0,0 +1,270 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"library-management-system/config"
+)
+
+// MarkBorrowLost records an active or claimed-returned loan as lost, removes the copy
+// from stock and charges the patron the book's replacement cost.
+func MarkBorrowLost(id int, note string) error {
+	return closeMissingLoan(id, BorrowStatusLost, note, ChargeTypeReplacement, -1)
+}
+
+// MarkBorrowDamaged records a loan returned damaged beyond use, removes the copy from
+// stock and charges the given amount (the replacement cost when amount is negative).
+func MarkBorrowDamaged(id int, note string, amount float64) error {
+	return closeMissingLoan(id, BorrowStatusDamaged, note, ChargeTypeDamage, amount)
+}
+
+// MarkClaimedReturned records that the patron says they returned an item the library
+// cannot find. No fine accrues while the claim is investigated.
+func MarkClaimedReturned(id int, note string) error {
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                UPDATE borrows
+                SET status = $1, resolution_note = $2, resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $3 AND status = $4
+        `, BorrowStatusClaimedReturned, note, id, BorrowStatusApproved)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New("only active loans can be marked as claimed returned")
+	}
+
+	return nil
+}
+
+// closeMissingLoan ends a loan whose copy will not come back to the shelf
+func closeMissingLoan(id int, status, note, chargeType string, amount float64) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Get borrow record and book details
+	var bookID, userID int
+	var current, title string
+	var replacementCost float64
+	err = tx.QueryRow(`
+                SELECT b.book_id, b.user_id, b.status, bk.title, bk.replacement_cost
+                FROM borrows b
+                JOIN books bk ON b.book_id = bk.id
+                WHERE b.id = $1
+        `, id).Scan(&bookID, &userID, &current, &title, &replacementCost)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("borrow record not found")
+		}
+		return err
+	}
+
+	// Lost can follow a claim that was not upheld; damaged is found on check-in of an active loan
+	if current != BorrowStatusApproved && !(status == BorrowStatusLost && current == BorrowStatusClaimedReturned) {
+		return errors.New("this loan cannot be marked as " + status)
+	}
+
+	// Update borrow status
+	_, err = tx.Exec(`
+                UPDATE borrows
+                SET status = $1, resolution_note = $2, resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $3
+        `, status, note, id)
+	if err != nil {
+		return err
+	}
+
+	// Remove the copy from stock; it was already counted as unavailable while on loan
+	_, err = tx.Exec(`
+                UPDATE books
+                SET quantity = GREATEST(quantity - 1, 0), updated_at = CURRENT_TIMESTAMP
+                WHERE id = $1
+        `, bookID)
+	if err != nil {
+		return err
+	}
+
+	// Charge the patron
+	if amount < 0 {
+		amount = replacementCost
+		if amount <= 0 {
+			amount = config.AppConfig.Circulation.ReplacementCost
+		}
+	}
+	if amount > 0 {
+		description := fmt.Sprintf("%s: %s", status, title)
+		if err := createCharge(tx, userID, id, chargeType, amount, description); err != nil {
+			return err
+		}
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// RecoverBorrow reverses a lost or claimed-returned loan when the item turns up: the
+// loan is closed as returned, the copy goes back into stock and any replacement
+// charge is cancelled.
+func RecoverBorrow(id int) error {
+	bookID, err := recoverBorrow(id)
+	if err != nil {
+		return err
+	}
+
+	// The copy is back on the shelf, so waiting reservations can be served
+	go func() {
+		_ = ProcessReservationsForBook(bookID)
+	}()
+
+	return nil
+}
+
+// recoverBorrow closes a lost or claimed-returned loan and returns the book ID
+func recoverBorrow(id int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Get borrow record
+	var bookID int
+	var status string
+	err = tx.QueryRow("SELECT book_id, status FROM borrows WHERE id = $1", id).Scan(&bookID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("borrow record not found")
+		}
+		return 0, err
+	}
+	if status != BorrowStatusLost && status != BorrowStatusClaimedReturned {
+		return 0, errors.New("only lost or claimed-returned loans can be recovered")
+	}
+
+	// Close the loan as a normal return
+	_, err = tx.Exec(`
+                UPDATE borrows
+                SET status = $1, return_date = CURRENT_TIMESTAMP, resolved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2
+        `, BorrowStatusReturned, id)
+	if err != nil {
+		return 0, err
+	}
+
+	// A lost copy was removed from stock, so it is added back as well as made available
+	quantityChange := 0
+	if status == BorrowStatusLost {
+		quantityChange = 1
+	}
+	_, err = tx.Exec(`
+                UPDATE books
+                SET quantity = quantity + $1, available = available + 1, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2
+        `, quantityChange, bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Cancel the replacement charge if it has not been settled
+	_, err = tx.Exec(`
+                UPDATE charges
+                SET status = $1, resolved_at = CURRENT_TIMESTAMP
+                WHERE borrow_id = $2 AND type = $3 AND status = $4
+        `, ChargeStatusCancelled, id, ChargeTypeReplacement, ChargeStatusOutstanding)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return bookID, nil
+}
+
+// findMissingLoan returns the oldest lost or claimed-returned loan for a book, or 0
+func findMissingLoan(bookID int) (int, error) {
+	db := config.GetDB()
+
+	var id int
+	err := db.QueryRow(`
+                SELECT id FROM borrows
+                WHERE book_id = $1 AND status IN ($2, $3)
+                ORDER BY resolved_at ASC
+                LIMIT 1
+        `, bookID, BorrowStatusClaimedReturned, BorrowStatusLost).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return id, err
+}
+
+// GetClaimedReturnedBorrows retrieves loans under claims-returned investigation
+func GetClaimedReturnedBorrows() ([]*Borrow, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT b.id, COALESCE(b.user_id, 0), b.book_id, b.status, b.borrow_date, b.due_date, b.return_date,
+                        b.approved_by, b.created_at, b.updated_at, b.loan_hours, COALESCE(b.resolution_note, '')
+                FROM borrows b
+                WHERE b.status = $1
+                ORDER BY b.resolved_at ASC
+        `, BorrowStatusClaimedReturned)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var borrows []*Borrow
+	for rows.Next() {
+		borrow := &Borrow{}
+		err := rows.Scan(
+			&borrow.ID,
+			&borrow.UserID,
+			&borrow.BookID,
+			&borrow.Status,
+			&borrow.BorrowDate,
+			&borrow.DueDate,
+			&borrow.ReturnDate,
+			&borrow.ApprovedBy,
+			&borrow.CreatedAt,
+			&borrow.UpdatedAt,
+			&borrow.LoanHours,
+			&borrow.ResolutionNote,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get related user
+		borrow.User = getBorrowPatron(borrow.UserID)
+
+		// Get related book
+		borrow.Book, _ = GetBookByID(borrow.BookID)
+
+		borrows = append(borrows, borrow)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return borrows, nil
+}
@@ -0,0 +1,781 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Notice channels
+const (
+	NoticeChannelEmail  = "email"  // Emailed, or printed as a letter if the patron cannot be emailed
+	NoticeChannelLetter = "letter" // Always printed as a letter
+)
+
+// NoticeChannels lists the channels a notice stage can be sent by
+var NoticeChannels = []string{NoticeChannelEmail, NoticeChannelLetter}
+
+// Overdue notice status constants
+const (
+	NoticeStatusSent    = "sent"     // Emailed to the patron
+	NoticeStatusPrint   = "to_print" // A letter waiting to be printed
+	NoticeStatusPrinted = "printed"
+)
+
+// NoticeStage is a step in the escalation of notices about a loan: a courtesy
+// reminder before it is due, then overdue notices at growing delays after. Each loan
+// is sent each stage once; the final stage can block the patron from borrowing.
+type NoticeStage struct {
+	ID          int
+	Name        string
+	Days        int // Days after the due date the stage is sent; negative for before it
+	Channel     string
+	Subject     string // Template filled with NoticeData
+	Body        string // Template filled with NoticeData
+	BlockPatron bool
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// NoticeData is the loan a notice is about, as filled into its stage's templates
+type NoticeData struct {
+	Patron          string
+	StudentID       string
+	Title           string
+	Author          string
+	CallNumber      string
+	BorrowDate      string
+	DueDate         string
+	DaysUntilDue    int     // Whole days left before the due date; 0 once due
+	DaysOverdue     int     // Whole days since the due date; 0 before it
+	Fine            float64 // Fine accrued if the book were returned now
+	ReplacementCost float64
+	Library         string // Address of the library system, to sign in and renew
+}
+
+// SampleNoticeData returns a made-up loan to check and preview stage templates with
+func SampleNoticeData() *NoticeData {
+	return &NoticeData{
+		Patron:          "Jane Doe",
+		StudentID:       "S12345",
+		Title:           "Sample Book",
+		Author:          "A. Author",
+		CallNumber:      "500 SAM",
+		BorrowDate:      "Jan 02, 2006",
+		DueDate:         "Jan 16, 2006",
+		DaysOverdue:     7,
+		Fine:            3.5,
+		ReplacementCost: 25,
+		Library:         "http://localhost",
+	}
+}
+
+// NewNoticeData describes a loan for a notice sent at the given time
+func NewNoticeData(borrow *Borrow, at time.Time) *NoticeData {
+	data := &NoticeData{Library: strings.TrimRight(config.AppConfig.Mail.BaseURL, "/")}
+	if borrow.User != nil {
+		data.Patron, data.StudentID = borrow.User.Name, borrow.User.StudentID.String
+	}
+	if borrow.Book != nil {
+		data.Title, data.Author, data.CallNumber = borrow.Book.Title, borrow.Book.Author, borrow.Book.CallNumber
+		data.ReplacementCost = borrow.Book.ReplacementCost
+	}
+	if data.ReplacementCost <= 0 {
+		data.ReplacementCost = config.AppConfig.Circulation.ReplacementCost
+	}
+	if borrow.BorrowDate != nil {
+		data.BorrowDate = borrow.BorrowDate.Format("Jan 02, 2006")
+	}
+	if borrow.DueDate != nil {
+		data.DueDate = borrow.DueLabel()
+		if at.Before(*borrow.DueDate) {
+			data.DaysUntilDue = int(borrow.DueDate.Sub(at).Hours() / 24)
+		} else {
+			data.DaysOverdue = int(at.Sub(*borrow.DueDate).Hours() / 24)
+			if borrow.ShortLoan() {
+				data.Fine = CalculateShortLoanFine(*borrow.DueDate, at)
+			} else {
+				data.Fine = CalculateOverdueFine(*borrow.DueDate, at)
+			}
+		}
+	}
+	return data
+}
+
+// Timing describes when the stage is sent
+func (s *NoticeStage) Timing() string {
+	switch {
+	case s.Days < -1:
+		return fmt.Sprintf("%d days before due", -s.Days)
+	case s.Days == -1:
+		return "1 day before due"
+	case s.Days == 0:
+		return "On the due date"
+	case s.Days == 1:
+		return "1 day overdue"
+	default:
+		return fmt.Sprintf("%d days overdue", s.Days)
+	}
+}
+
+// Render fills the stage's subject and body with a loan's details
+func (s *NoticeStage) Render(data *NoticeData) (string, string, error) {
+	subject, err := renderNoticeTemplate("subject", s.Subject, data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := renderNoticeTemplate("body", s.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.Join(strings.Fields(subject), " "), body, nil
+}
+
+// renderNoticeTemplate parses and executes a notice template
+func renderNoticeTemplate(name, text string, data *NoticeData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %v", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("invalid %s template: %v", name, err)
+	}
+	return out.String(), nil
+}
+
+// validate checks the stage, including that its templates render
+func (s *NoticeStage) validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return errors.New("stage name is required")
+	}
+	if s.Days < -60 || s.Days > 365 {
+		return errors.New("a stage is sent between 60 days before and 365 days after the due date")
+	}
+	if !containsString(NoticeChannels, s.Channel) {
+		return errors.New("unknown notice channel")
+	}
+	if strings.TrimSpace(s.Subject) == "" || strings.TrimSpace(s.Body) == "" {
+		return errors.New("subject and message are required")
+	}
+	if s.BlockPatron && s.Days <= 0 {
+		return errors.New("only a stage sent after the due date can block the patron")
+	}
+	_, _, err := s.Render(SampleNoticeData())
+	return err
+}
+
+// Save creates or updates the notice stage
+func (s *NoticeStage) Save() error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	db := config.GetDB()
+
+	if s.ID == 0 {
+		s.Active = true
+		return db.QueryRow(`
+                        INSERT INTO notice_stages (name, days, channel, subject, body, block_patron)
+                        VALUES ($1, $2, $3, $4, $5, $6)
+                        RETURNING id, created_at, updated_at
+                `, s.Name, s.Days, s.Channel, s.Subject, s.Body, s.BlockPatron).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	}
+
+	result, err := db.Exec(`
+                UPDATE notice_stages
+                SET name = $2, days = $3, channel = $4, subject = $5, body = $6, block_patron = $7, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $1
+        `, s.ID, s.Name, s.Days, s.Channel, s.Subject, s.Body, s.BlockPatron)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("notice stage not found")
+	}
+	return nil
+}
+
+// noticeStageColumns lists the columns scanned by scanNoticeStage
+const noticeStageColumns = `id, name, days, channel, subject, body, block_patron, active, created_at, updated_at`
+
+// scanNoticeStage reads a row selected with noticeStageColumns
+func scanNoticeStage(row interface{ Scan(...interface{}) error }) (*NoticeStage, error) {
+	s := &NoticeStage{}
+	err := row.Scan(&s.ID, &s.Name, &s.Days, &s.Channel, &s.Subject, &s.Body, &s.BlockPatron, &s.Active, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+// GetNoticeStages retrieves every notice stage in the order they are sent
+func GetNoticeStages() ([]*NoticeStage, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query("SELECT " + noticeStageColumns + " FROM notice_stages ORDER BY days, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var stages []*NoticeStage
+	for rows.Next() {
+		s, err := scanNoticeStage(rows)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stages, nil
+}
+
+// GetNoticeStageByID retrieves a notice stage by ID
+func GetNoticeStageByID(id int) (*NoticeStage, error) {
+	db := config.GetDB()
+
+	s, err := scanNoticeStage(db.QueryRow("SELECT "+noticeStageColumns+" FROM notice_stages WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("notice stage not found")
+	}
+	return s, err
+}
+
+// SetNoticeStageActive turns a notice stage on or off. Loans that passed a stage
+// while it was off are not sent it when it is turned back on.
+func SetNoticeStageActive(id int, active bool) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("UPDATE notice_stages SET active = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1", id, active)
+	return err
+}
+
+// DeleteNoticeStage removes a notice stage. The notices already sent at the stage
+// are kept with its name.
+func DeleteNoticeStage(id int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM notice_stages WHERE id = $1", id)
+	return err
+}
+
+// defaultNoticeStages are set up when the library has no notice stages
+var defaultNoticeStages = []*NoticeStage{
+	{
+		Name:    "Courtesy reminder",
+		Days:    -2,
+		Channel: NoticeChannelEmail,
+		Subject: "Reminder: {{.Title}} is due {{.DueDate}}",
+		Body: "Dear {{.Patron}},\n\n" +
+			"This is a friendly reminder that \"{{.Title}}\" by {{.Author}} is due back on {{.DueDate}}.\n\n" +
+			"Please return it by then, or sign in to see your loans:\n{{.Library}}/profile\n\n" +
+			"Thank you,\nThe Library",
+	},
+	{
+		Name:    "First overdue notice",
+		Days:    1,
+		Channel: NoticeChannelEmail,
+		Subject: "Overdue: {{.Title}}",
+		Body: "Dear {{.Patron}},\n\n" +
+			"\"{{.Title}}\" by {{.Author}} was due back on {{.DueDate}} and is now overdue.\n\n" +
+			"Please return it as soon as possible. Fines are charged for each day the library is open " +
+			"until it is returned; so far they come to {{printf \"%.2f\" .Fine}}.\n\n" +
+			"Thank you,\nThe Library",
+	},
+	{
+		Name:    "Second overdue notice",
+		Days:    7,
+		Channel: NoticeChannelEmail,
+		Subject: "Second notice: {{.Title}} is {{.DaysOverdue}} days overdue",
+		Body: "Dear {{.Patron}},\n\n" +
+			"We have not yet received \"{{.Title}}\" by {{.Author}}, which was due back on {{.DueDate}}.\n\n" +
+			"It is now {{.DaysOverdue}} days overdue and fines of {{printf \"%.2f\" .Fine}} have accrued. " +
+			"Please return it promptly to avoid further action.\n\n" +
+			"The Library",
+	},
+	{
+		Name:        "Final notice",
+		Days:        21,
+		Channel:     NoticeChannelLetter,
+		BlockPatron: true,
+		Subject:     "Final notice and bill: {{.Title}}",
+		Body: "Dear {{.Patron}},{{if .StudentID}} ({{.StudentID}}){{end}}\n\n" +
+			"FINAL NOTICE\n\n" +
+			"\"{{.Title}}\" by {{.Author}} (call number {{.CallNumber}}), borrowed on {{.BorrowDate}}, " +
+			"was due back on {{.DueDate}} and is now {{.DaysOverdue}} days overdue.\n\n" +
+			"Your borrowing has been suspended until it is returned. If it is not returned, you will be " +
+			"billed for its replacement:\n\n" +
+			"    Replacement cost    {{printf \"%.2f\" .ReplacementCost}}\n" +
+			"    Fines to date       {{printf \"%.2f\" .Fine}}\n\n" +
+			"Please return the book or contact the library to settle this account.\n\n" +
+			"The Library",
+	},
+}
+
+// CreateDefaultNoticeStages sets up the standard escalation when the library has
+// no notice stages
+func CreateDefaultNoticeStages() error {
+	db := config.GetDB()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM notice_stages").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for _, stage := range defaultNoticeStages {
+		s := *stage
+		if err := s.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OverdueNotice records a notice sent about a loan
+type OverdueNotice struct {
+	ID        int
+	BorrowID  int
+	StageID   sql.NullInt64
+	StageName string
+	StageDays int
+	UserID    sql.NullInt64
+	Channel   string // How it was sent: email or letter
+	Recipient string // Email address, for emailed notices
+	Subject   string
+	Body      string
+	Status    string
+	Error     string // Why an email notice was printed as a letter instead
+	Blocked   bool   // The patron was blocked from borrowing
+	CreatedAt time.Time
+	PrintedAt NullTime
+
+	// Computed properties
+	PatronName string
+	StudentID  string
+	BookID     int
+	BookTitle  string
+	DueDate    *time.Time
+}
+
+// NoticeFilter selects overdue notices
+type NoticeFilter struct {
+	Status   string
+	BorrowID int
+	UserID   int
+}
+
+// where returns the filter's conditions and their parameters
+func (f NoticeFilter) where() (string, []interface{}) {
+	conditions := " WHERE 1=1"
+	var params []interface{}
+
+	if f.Status != "" {
+		params = append(params, f.Status)
+		conditions += fmt.Sprintf(" AND n.status = $%d", len(params))
+	}
+	if f.BorrowID > 0 {
+		params = append(params, f.BorrowID)
+		conditions += fmt.Sprintf(" AND n.borrow_id = $%d", len(params))
+	}
+	if f.UserID > 0 {
+		params = append(params, f.UserID)
+		conditions += fmt.Sprintf(" AND n.user_id = $%d", len(params))
+	}
+	return conditions, params
+}
+
+// overdueNoticeColumns lists the columns scanned by scanOverdueNotice from
+// overdue_notices n joined to borrows br and books bk, and left joined to users u
+const overdueNoticeColumns = `n.id, n.borrow_id, n.stage_id, n.stage_name, n.stage_days, n.user_id, n.channel, n.recipient,
+                n.subject, n.body, n.status, n.error, n.blocked, n.created_at, n.printed_at,
+                COALESCE(u.name, ''), COALESCE(u.student_id, ''), bk.id, bk.title, br.due_date`
+
+// overdueNoticeJoins joins overdue_notices n to the tables overdueNoticeColumns reads
+const overdueNoticeJoins = `
+                FROM overdue_notices n
+                JOIN borrows br ON br.id = n.borrow_id
+                JOIN books bk ON bk.id = br.book_id
+                LEFT JOIN users u ON u.id = n.user_id`
+
+// scanOverdueNotice reads a row selected with overdueNoticeColumns
+func scanOverdueNotice(row interface{ Scan(...interface{}) error }) (*OverdueNotice, error) {
+	n := &OverdueNotice{}
+	err := row.Scan(&n.ID, &n.BorrowID, &n.StageID, &n.StageName, &n.StageDays, &n.UserID, &n.Channel, &n.Recipient,
+		&n.Subject, &n.Body, &n.Status, &n.Error, &n.Blocked, &n.CreatedAt, &n.PrintedAt,
+		&n.PatronName, &n.StudentID, &n.BookID, &n.BookTitle, &n.DueDate)
+	return n, err
+}
+
+// queryOverdueNotices runs a notice query and parses the rows
+func queryOverdueNotices(query string, args ...interface{}) ([]*OverdueNotice, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var notices []*OverdueNotice
+	for rows.Next() {
+		n, err := scanOverdueNotice(rows)
+		if err != nil {
+			return nil, err
+		}
+		notices = append(notices, n)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notices, nil
+}
+
+// CountOverdueNotices counts the notices matching the filter
+func CountOverdueNotices(f NoticeFilter) (int, error) {
+	db := config.GetDB()
+
+	conditions, params := f.where()
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM overdue_notices n"+conditions, params...).Scan(&count)
+	return count, err
+}
+
+// GetOverdueNotices retrieves a page of the notices matching the filter, newest
+// first. Letters waiting to be printed come oldest first, and all of them when
+// itemsPerPage is 0.
+func GetOverdueNotices(f NoticeFilter, page, itemsPerPage int) ([]*OverdueNotice, error) {
+	conditions, params := f.where()
+
+	order := " ORDER BY n.created_at DESC, n.id DESC"
+	if f.Status == NoticeStatusPrint {
+		order = " ORDER BY n.created_at, n.id"
+	}
+	limit := ""
+	if itemsPerPage > 0 {
+		params = append(params, itemsPerPage, (page-1)*itemsPerPage)
+		limit = fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(params)-1, len(params))
+	}
+
+	return queryOverdueNotices("SELECT "+overdueNoticeColumns+overdueNoticeJoins+conditions+order+limit, params...)
+}
+
+// GetOverdueNoticesByID retrieves the given notices in the order they were created
+func GetOverdueNoticesByID(ids []int) ([]*OverdueNotice, error) {
+	var notices []*OverdueNotice
+	for _, id := range ids {
+		found, err := queryOverdueNotices("SELECT "+overdueNoticeColumns+overdueNoticeJoins+" WHERE n.id = $1", id)
+		if err != nil {
+			return nil, err
+		}
+		notices = append(notices, found...)
+	}
+	return notices, nil
+}
+
+// GetLatestLoanNotices retrieves the latest stage each current loan has been sent,
+// keyed by borrow ID
+func GetLatestLoanNotices() (map[int]*OverdueNotice, error) {
+	notices, err := queryOverdueNotices(`
+                SELECT DISTINCT ON (n.borrow_id) `+overdueNoticeColumns+overdueNoticeJoins+`
+                WHERE br.status = $1
+                ORDER BY n.borrow_id, n.stage_days DESC, n.id DESC
+        `, BorrowStatusApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[int]*OverdueNotice, len(notices))
+	for _, n := range notices {
+		latest[n.BorrowID] = n
+	}
+	return latest, nil
+}
+
+// DueNotice is a stage a loan is due to be sent
+type DueNotice struct {
+	BorrowID int
+	Stage    *NoticeStage
+}
+
+// GetDueNotices finds the loans due a notice at the given time and the stage each
+// should be sent, as chosen by dueStage
+func GetDueNotices(at time.Time) ([]*DueNotice, error) {
+	db := config.GetDB()
+
+	stages, err := GetNoticeStages()
+	if err != nil {
+		return nil, err
+	}
+	if len(stages) == 0 {
+		return nil, nil
+	}
+	earliest := stages[0].Days // Stages come in the order they are sent
+
+	// Execute query, skipping loans too far from their due date to reach any stage
+	rows, err := db.Query(`
+                SELECT b.id, b.due_date, b.loan_hours,
+                        (SELECT MAX(n.stage_days) FROM overdue_notices n WHERE n.borrow_id = b.id)
+                FROM borrows b
+                WHERE b.status = $1 AND b.user_id IS NOT NULL AND b.due_date IS NOT NULL
+                        AND b.due_date <= $2
+                ORDER BY b.id
+        `, BorrowStatusApproved, at.AddDate(0, 0, -earliest))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var due []*DueNotice
+	for rows.Next() {
+		var borrowID, loanHours int
+		var dueDate time.Time
+		var sent sql.NullInt64
+		if err := rows.Scan(&borrowID, &dueDate, &loanHours, &sent); err != nil {
+			return nil, err
+		}
+		if stage := dueStage(stages, dueDate, loanHours > 0, sent, at); stage != nil {
+			due = append(due, &DueNotice{BorrowID: borrowID, Stage: stage})
+		}
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return due, nil
+}
+
+// dueStage returns the stage a loan due at dueDate should be sent at the given
+// time, or nil if none. sent holds the days of the latest stage already sent to the
+// loan. A loan is only sent the latest active stage it has reached and never a stage
+// at or before one already sent, so a loan that passes several stages at once, while
+// notices were not running, gets a single notice and no stage is sent twice.
+// Reminders before the due date are not sent for short loans or once the loan is due.
+func dueStage(stages []*NoticeStage, dueDate time.Time, shortLoan bool, sent sql.NullInt64, at time.Time) *NoticeStage {
+	var best *NoticeStage
+	for _, s := range stages {
+		if !s.Active || dueDate.AddDate(0, 0, s.Days).After(at) {
+			continue
+		}
+		if s.Days < 0 && (shortLoan || !dueDate.After(at)) {
+			continue
+		}
+		if sent.Valid && int64(s.Days) <= sent.Int64 {
+			continue
+		}
+		if best == nil || s.Days > best.Days || (s.Days == best.Days && s.ID < best.ID) {
+			best = s
+		}
+	}
+	return best
+}
+
+// ClaimOverdueNotice records a notice before it is sent, so a loan is sent each
+// stage once even if several schedulers run. It reports false if the stage was
+// already sent.
+func ClaimOverdueNotice(n *OverdueNotice) (bool, error) {
+	db := config.GetDB()
+
+	err := db.QueryRow(`
+                INSERT INTO overdue_notices (borrow_id, stage_id, stage_name, stage_days, user_id, channel, recipient, subject, body, status)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+                ON CONFLICT (borrow_id, stage_id) DO NOTHING
+                RETURNING id, created_at
+        `, n.BorrowID, n.StageID, n.StageName, n.StageDays, n.UserID, n.Channel, n.Recipient, n.Subject, n.Body, n.Status).Scan(&n.ID, &n.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateOverdueNotice saves how a claimed notice was sent
+func UpdateOverdueNotice(n *OverdueNotice) error {
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                UPDATE overdue_notices
+                SET channel = $2, recipient = $3, status = $4, error = $5, blocked = $6
+                WHERE id = $1
+        `, n.ID, n.Channel, n.Recipient, n.Status, n.Error, n.Blocked)
+	return err
+}
+
+// MarkNoticesPrinted records that letters have been printed and returns how many
+// were waiting
+func MarkNoticesPrinted(ids []int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	printed := 0
+	for _, id := range ids {
+		result, err := tx.Exec(`
+                        UPDATE overdue_notices
+                        SET status = $1, printed_at = CURRENT_TIMESTAMP
+                        WHERE id = $2 AND status = $3
+                `, NoticeStatusPrinted, id, NoticeStatusPrint)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		printed += int(n)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return printed, nil
+}
+
+// BlockPatronForLoan blocks a patron from borrowing until an overdue loan is
+// returned, unless the loan already holds a block. It reports whether a block
+// was placed.
+func BlockPatronForLoan(userID, borrowID int, note string) (bool, error) {
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                INSERT INTO patron_blocks (user_id, note, borrow_id)
+                SELECT $1, $2, $3
+                WHERE NOT EXISTS (SELECT 1 FROM patron_blocks WHERE borrow_id = $3 AND lifted_at IS NULL)
+        `, userID, note, borrowID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// LiftSettledLoanBlocks lifts the blocks placed by final notices whose loans are no
+// longer out, and returns how many were lifted. A loan closed as lost or damaged is
+// charged instead, and the charge blocks the patron while it is outstanding.
+func LiftSettledLoanBlocks() (int, error) {
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                UPDATE patron_blocks pb
+                SET lifted_at = CURRENT_TIMESTAMP
+                FROM borrows b
+                WHERE pb.borrow_id = b.id AND pb.lifted_at IS NULL AND b.status <> $1
+        `, BorrowStatusApproved)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// NoticeRun records one generation of overdue notices
+type NoticeRun struct {
+	ID         int
+	RunDate    time.Time
+	Manual     bool // Run by a librarian rather than the daily job
+	Emailed    int
+	Letters    int
+	Blocked    int
+	Error      string
+	StartedAt  time.Time
+	FinishedAt NullTime
+}
+
+// ClaimNoticeRun starts a run of the notices. The daily run is claimed once per
+// day, so it returns nil if the day's run has already started; manual runs can be
+// started at any time.
+func ClaimNoticeRun(day time.Time, manual bool) (*NoticeRun, error) {
+	db := config.GetDB()
+
+	run := &NoticeRun{Manual: manual}
+	err := db.QueryRow(`
+                INSERT INTO notice_runs (run_date, manual)
+                VALUES ($1, $2)
+                ON CONFLICT (run_date) WHERE NOT manual DO NOTHING
+                RETURNING id, run_date, started_at
+        `, day.Format("2006-01-02"), manual).Scan(&run.ID, &run.RunDate, &run.StartedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// FinishNoticeRun saves the outcome of a run
+func FinishNoticeRun(run *NoticeRun) error {
+	db := config.GetDB()
+
+	return db.QueryRow(`
+                UPDATE notice_runs
+                SET emailed = $2, letters = $3, blocked = $4, error = $5, finished_at = CURRENT_TIMESTAMP
+                WHERE id = $1
+                RETURNING finished_at
+        `, run.ID, run.Emailed, run.Letters, run.Blocked, run.Error).Scan(&run.FinishedAt)
+}
+
+// GetNoticeRuns retrieves the most recent runs, newest first
+func GetNoticeRuns(limit int) ([]*NoticeRun, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id, run_date, manual, emailed, letters, blocked, error, started_at, finished_at
+                FROM notice_runs
+                ORDER BY started_at DESC
+                LIMIT $1
+        `, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var runs []*NoticeRun
+	for rows.Next() {
+		run := &NoticeRun{}
+		err := rows.Scan(&run.ID, &run.RunDate, &run.Manual, &run.Emailed, &run.Letters, &run.Blocked, &run.Error,
+			&run.StartedAt, &run.FinishedAt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestDueStage(t *testing.T) {
+	stages := []*NoticeStage{
+		{ID: 1, Name: "Courtesy reminder", Days: -2, Active: true},
+		{ID: 2, Name: "First notice", Days: 1, Active: true},
+		{ID: 3, Name: "Second notice", Days: 7, Active: true},
+		{ID: 4, Name: "Disabled notice", Days: 10, Active: false},
+		{ID: 5, Name: "Final notice", Days: 14, Active: true},
+		{ID: 6, Name: "Final letter", Days: 14, Active: true},
+	}
+
+	due := time.Date(2026, 3, 10, 17, 0, 0, 0, time.UTC)
+	none := sql.NullInt64{}
+	sent := func(days int64) sql.NullInt64 { return sql.NullInt64{Int64: days, Valid: true} }
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		shortLoan bool
+		sent      sql.NullInt64
+		want      int // Stage ID, 0 for none
+	}{
+		{"too early for a reminder", due.AddDate(0, 0, -3), false, none, 0},
+		{"reminder before due", due.AddDate(0, 0, -2), false, none, 1},
+		{"no reminder for short loans", due.AddDate(0, 0, -1), true, none, 0},
+		{"no reminder once due", due, false, none, 0},
+		{"first notice", due.AddDate(0, 0, 1), false, none, 2},
+		{"between stages", due.AddDate(0, 0, 5), false, sent(1), 0},
+		{"second notice", due.AddDate(0, 0, 7), false, sent(1), 3},
+		{"skipped stages send only the latest reached", due.AddDate(0, 0, 9), false, none, 3},
+		{"inactive stage is not sent", due.AddDate(0, 0, 11), false, sent(7), 0},
+		{"stages sharing a day send the first", due.AddDate(0, 0, 20), false, sent(7), 5},
+		{"short loans are sent overdue notices", due.AddDate(0, 0, 1), true, none, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 0
+			if s := dueStage(stages, due, tt.shortLoan, tt.sent, tt.at); s != nil {
+				got = s.ID
+			}
+			if got != tt.want {
+				t.Errorf("dueStage at %s = stage %d, want %d", tt.at.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDueStageNotSentTwice(t *testing.T) {
+	stages := []*NoticeStage{
+		{ID: 1, Name: "Courtesy reminder", Days: -2, Active: true},
+		{ID: 2, Name: "First notice", Days: 1, Active: true},
+		{ID: 3, Name: "Final notice", Days: 14, Active: true},
+	}
+	due := time.Date(2026, 3, 10, 17, 0, 0, 0, time.UTC)
+
+	// Run the daily job for a month, recording each stage sent as the job does
+	var sent sql.NullInt64
+	counts := make(map[int]int)
+	for day := -5; day <= 30; day++ {
+		s := dueStage(stages, due, false, sent, due.AddDate(0, 0, day))
+		if s == nil {
+			continue
+		}
+		counts[s.ID]++
+		sent = sql.NullInt64{Int64: int64(s.Days), Valid: true}
+	}
+
+	for _, s := range stages {
+		if counts[s.ID] != 1 {
+			t.Errorf("%s sent %d times, want once", s.Name, counts[s.ID])
+		}
+	}
+
+	// A run repeated on the same day after the final notice sends nothing
+	if s := dueStage(stages, due, false, sent, due.AddDate(0, 0, 30)); s != nil {
+		t.Errorf("repeated run sent %s again", s.Name)
+	}
+}
@@ -0,0 +1,554 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"library-management-system/config"
+)
+
+// UserDataExport holds everything the library stores about a single patron
+type UserDataExport struct {
+	ExportedAt   time.Time             `json:"exported_at"`
+	Profile      ExportedProfile       `json:"profile"`
+	Borrows      []ExportedBorrow      `json:"borrows"`
+	Reservations []ExportedReservation `json:"reservations"`
+	Shelves      []ExportedShelf       `json:"shelves"`
+	Reviews      []ExportedReview      `json:"reviews"`
+	Charges      []ExportedCharge      `json:"charges"`
+	Blocks       []ExportedBlock       `json:"blocks"`
+	Suggestions  []ExportedSuggestion  `json:"suggestions"`
+	Courses      []ExportedCourse      `json:"courses_taught"`
+	Notices      []ExportedNotice      `json:"notices"`
+}
+
+// ExportedProfile is the account portion of a data export
+type ExportedProfile struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	StudentID   string    `json:"student_id,omitempty"`
+	Phone       string    `json:"phone,omitempty"`
+	KeepHistory bool      `json:"keep_history"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExportedBorrow is a borrow record as included in a data export
+type ExportedBorrow struct {
+	ID         int        `json:"id"`
+	BookID     int        `json:"book_id"`
+	BookTitle  string     `json:"book_title"`
+	BookISBN   string     `json:"book_isbn"`
+	Status     string     `json:"status"`
+	BorrowDate *time.Time `json:"borrow_date,omitempty"`
+	DueDate    *time.Time `json:"due_date,omitempty"`
+	ReturnDate *time.Time `json:"return_date,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ExportedReservation is a reservation record as included in a data export
+type ExportedReservation struct {
+	ID              int        `json:"id"`
+	BookID          int        `json:"book_id"`
+	BookTitle       string     `json:"book_title"`
+	Status          string     `json:"status"`
+	ReservationDate time.Time  `json:"reservation_date"`
+	ExpiryDate      time.Time  `json:"expiry_date"`
+	FulfilledDate   *time.Time `json:"fulfilled_date,omitempty"`
+}
+
+// ExportedShelf is a shelf or reading list as included in a data export
+type ExportedShelf struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Public      bool                `json:"public"`
+	Books       []ExportedShelfItem `json:"books"`
+}
+
+// ExportedShelfItem is a book on a shelf as included in a data export
+type ExportedShelfItem struct {
+	BookID    int       `json:"book_id"`
+	BookTitle string    `json:"book_title"`
+	Note      string    `json:"note,omitempty"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// ExportedReview is a book review as included in a data export
+type ExportedReview struct {
+	BookID    int       `json:"book_id"`
+	BookTitle string    `json:"book_title"`
+	Rating    int       `json:"rating"`
+	Body      string    `json:"body,omitempty"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportedCharge is a fee charged to the patron as included in a data export
+type ExportedCharge struct {
+	ID          int        `json:"id"`
+	BorrowID    *int64     `json:"borrow_id,omitempty"`
+	Type        string     `json:"type"`
+	Amount      float64    `json:"amount"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ExportedBlock is a borrowing block on the patron as included in a data export
+type ExportedBlock struct {
+	Note      string     `json:"note"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LiftedAt  *time.Time `json:"lifted_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ExportedSuggestion is a purchase suggestion the patron made or voted for as included
+// in a data export
+type ExportedSuggestion struct {
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	Author    string     `json:"author,omitempty"`
+	ISBN      string     `json:"isbn,omitempty"`
+	Notes     string     `json:"notes,omitempty"` // Only for suggestions the patron made
+	Status    string     `json:"status"`
+	Suggested bool       `json:"suggested"`
+	Voted     bool       `json:"voted"`
+	Reserve   bool       `json:"reserve"`
+	VotedAt   *time.Time `json:"voted_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ExportedCourse is a course the patron is listed as teaching as included in a data export
+type ExportedCourse struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Term string `json:"term"`
+}
+
+// ExportedNotice is a reminder or overdue notice sent to the patron as included in a
+// data export
+type ExportedNotice struct {
+	BorrowID  int        `json:"borrow_id"`
+	BookTitle string     `json:"book_title"`
+	Stage     string     `json:"stage"`
+	Channel   string     `json:"channel"`
+	Recipient string     `json:"recipient,omitempty"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	PrintedAt *time.Time `json:"printed_at,omitempty"`
+}
+
+// exportedTime returns a nullable time as exported, omitted when NULL
+func exportedTime(nt NullTime) *time.Time {
+	if !nt.Valid {
+		return nil
+	}
+	return &nt.Time
+}
+
+// AnonymousPatron returns the placeholder shown for borrows detached from their patron
+func AnonymousPatron() *User {
+	return &User{
+		Name:      "Anonymized patron",
+		Role:      "student",
+		IsStudent: true,
+	}
+}
+
+// GetUserDataExport collects all personal data stored for a user
+func GetUserDataExport(userID int) (*UserDataExport, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &UserDataExport{
+		ExportedAt: time.Now(),
+		Profile: ExportedProfile{
+			ID:          user.ID,
+			Name:        user.Name,
+			Email:       user.Email,
+			Role:        user.Role,
+			StudentID:   user.StudentID.String,
+			Phone:       user.Phone.String,
+			KeepHistory: user.KeepHistory,
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+		},
+		Borrows:      []ExportedBorrow{},
+		Reservations: []ExportedReservation{},
+		Shelves:      []ExportedShelf{},
+		Reviews:      []ExportedReview{},
+		Charges:      []ExportedCharge{},
+		Blocks:       []ExportedBlock{},
+		Suggestions:  []ExportedSuggestion{},
+		Courses:      []ExportedCourse{},
+		Notices:      []ExportedNotice{},
+	}
+
+	db := config.GetDB()
+
+	// Get every borrow record, whatever its status
+	rows, err := db.Query(`
+                SELECT b.id, b.book_id, bk.title, bk.isbn, b.status, b.borrow_date, b.due_date,
+                        b.return_date, b.created_at
+                FROM borrows b
+                JOIN books bk ON b.book_id = bk.id
+                WHERE b.user_id = $1
+                ORDER BY b.created_at ASC
+        `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var borrow ExportedBorrow
+		err := rows.Scan(
+			&borrow.ID,
+			&borrow.BookID,
+			&borrow.BookTitle,
+			&borrow.BookISBN,
+			&borrow.Status,
+			&borrow.BorrowDate,
+			&borrow.DueDate,
+			&borrow.ReturnDate,
+			&borrow.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		export.Borrows = append(export.Borrows, borrow)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get reservations
+	reservations, err := GetUserReservations(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, reservation := range reservations {
+		exported := ExportedReservation{
+			ID:              reservation.ID,
+			BookID:          reservation.BookID,
+			Status:          reservation.Status,
+			ReservationDate: reservation.ReservationDate,
+			ExpiryDate:      reservation.ExpiryDate,
+		}
+		if reservation.Book != nil {
+			exported.BookTitle = reservation.Book.Title
+		}
+		if reservation.HasFulfilledDate {
+			fulfilled := reservation.FulfilledDate
+			exported.FulfilledDate = &fulfilled
+		}
+		export.Reservations = append(export.Reservations, exported)
+	}
+
+	// Get shelves and reading lists
+	shelves, err := GetUserShelves(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range shelves {
+		if s, err = GetShelfByID(s.ID); err != nil {
+			return nil, err
+		}
+		exported := ExportedShelf{Name: s.Name, Description: s.Description, Public: s.Public, Books: []ExportedShelfItem{}}
+		for _, item := range s.Items {
+			exported.Books = append(exported.Books, ExportedShelfItem{
+				BookID:    item.BookID,
+				BookTitle: item.Book.Title,
+				Note:      item.Note,
+				AddedAt:   item.AddedAt,
+			})
+		}
+		export.Shelves = append(export.Shelves, exported)
+	}
+
+	// Get reviews
+	reviews, err := GetUserReviews(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reviews {
+		export.Reviews = append(export.Reviews, ExportedReview{
+			BookID:    r.BookID,
+			BookTitle: r.BookTitle,
+			Rating:    r.Rating,
+			Body:      r.Body,
+			Status:    r.Status,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: r.UpdatedAt,
+		})
+	}
+
+	// Get charges
+	charges, err := GetUserCharges(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range charges {
+		exported := ExportedCharge{
+			ID:          c.ID,
+			Type:        c.Type,
+			Amount:      c.Amount,
+			Description: c.Description,
+			Status:      c.Status,
+			ResolvedAt:  exportedTime(c.ResolvedAt),
+			CreatedAt:   c.CreatedAt,
+		}
+		if c.BorrowID.Valid {
+			exported.BorrowID = &c.BorrowID.Int64
+		}
+		export.Charges = append(export.Charges, exported)
+	}
+
+	// Get borrowing blocks
+	blocks, err := GetPatronBlocks(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		export.Blocks = append(export.Blocks, ExportedBlock{
+			Note:      b.Note,
+			ExpiresAt: exportedTime(b.ExpiresAt),
+			LiftedAt:  exportedTime(b.LiftedAt),
+			CreatedAt: b.CreatedAt,
+		})
+	}
+
+	// Get purchase suggestions made or voted for
+	rows, err = db.Query(`
+                SELECT s.id, s.title, s.author, s.isbn, CASE WHEN s.created_by = $1 THEN s.notes ELSE '' END,
+                        s.status, COALESCE(s.created_by = $1, FALSE), v.user_id IS NOT NULL,
+                        COALESCE(v.reserve, FALSE), v.created_at, s.created_at
+                FROM suggestions s
+                LEFT JOIN suggestion_votes v ON v.suggestion_id = s.id AND v.user_id = $1
+                WHERE s.created_by = $1 OR v.user_id IS NOT NULL
+                ORDER BY s.created_at ASC
+        `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var suggestion ExportedSuggestion
+		var votedAt NullTime
+		err := rows.Scan(
+			&suggestion.ID,
+			&suggestion.Title,
+			&suggestion.Author,
+			&suggestion.ISBN,
+			&suggestion.Notes,
+			&suggestion.Status,
+			&suggestion.Suggested,
+			&suggestion.Voted,
+			&suggestion.Reserve,
+			&votedAt,
+			&suggestion.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suggestion.VotedAt = exportedTime(votedAt)
+		export.Suggestions = append(export.Suggestions, suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get the courses the patron is listed as an instructor of
+	rows, err = db.Query(`
+                SELECT c.code, c.name, t.name
+                FROM course_instructors ci
+                JOIN courses c ON c.id = ci.course_id
+                JOIN terms t ON t.id = c.term_id
+                WHERE ci.user_id = $1
+                ORDER BY t.starts_on, c.code
+        `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var course ExportedCourse
+		if err := rows.Scan(&course.Code, &course.Name, &course.Term); err != nil {
+			return nil, err
+		}
+		export.Courses = append(export.Courses, course)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get reminders and overdue notices
+	notices, err := GetOverdueNotices(NoticeFilter{UserID: userID}, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notices {
+		export.Notices = append(export.Notices, ExportedNotice{
+			BorrowID:  n.BorrowID,
+			BookTitle: n.BookTitle,
+			Stage:     n.StageName,
+			Channel:   n.Channel,
+			Recipient: n.Recipient,
+			Subject:   n.Subject,
+			Body:      n.Body,
+			Status:    n.Status,
+			CreatedAt: n.CreatedAt,
+			PrintedAt: exportedTime(n.PrintedAt),
+		})
+	}
+
+	return export, nil
+}
+
+// AnonymizeExpiredHistory detaches finished borrows older than the retention period from
+// their patrons, unless the patron opted in to keeping their history. The borrow rows stay
+// in place so aggregate statistics such as GetTopBorrowedBooks are unaffected.
+func AnonymizeExpiredHistory(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	db := config.GetDB()
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	// Anonymize closed borrows: returned, rejected, lost and damaged
+	result, err := db.Exec(`
+                UPDATE borrows
+                SET user_id = NULL, anonymized_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE user_id IS NOT NULL
+                        AND status IN ($1, $2, $3, $4)
+                        AND COALESCE(return_date, resolved_at, updated_at) < $5
+                        AND user_id NOT IN (SELECT id FROM users WHERE keep_history)
+        `, BorrowStatusReturned, BorrowStatusRejected, BorrowStatusLost, BorrowStatusDamaged, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	anonymized, _ := result.RowsAffected()
+
+	// Settled charges on anonymized borrows would still tie the patron to the book
+	_, err = db.Exec(`
+                UPDATE charges
+                SET user_id = NULL
+                WHERE user_id IS NOT NULL
+                        AND status <> $1
+                        AND borrow_id IN (SELECT id FROM borrows WHERE anonymized_at IS NOT NULL)
+        `, ChargeStatusOutstanding)
+	if err != nil {
+		return anonymized, err
+	}
+
+	// Notices name the patron and the book, so those about anonymized borrows are removed
+	_, err = db.Exec(`
+                DELETE FROM overdue_notices
+                WHERE borrow_id IN (SELECT id FROM borrows WHERE anonymized_at IS NOT NULL)
+        `)
+	if err != nil {
+		return anonymized, err
+	}
+
+	// Finished reservations carry no statistical value, so they are removed
+	_, err = db.Exec(`
+                DELETE FROM reservations
+                WHERE status <> $1
+                        AND updated_at < $2
+                        AND user_id NOT IN (SELECT id FROM users WHERE keep_history)
+        `, ReservationStatusActive, cutoff)
+	if err != nil {
+		return anonymized, err
+	}
+
+	return anonymized, nil
+}
+
+// EraseUser removes a patron's account and personal data, keeping anonymized borrow rows
+func EraseUser(userID int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Outstanding loans, requests and claimed returns under investigation must be settled first
+	var count int
+	err = tx.QueryRow(`
+                SELECT COUNT(*) FROM borrows
+                WHERE user_id = $1 AND status IN ($2, $3, $4)
+        `, userID, BorrowStatusPending, BorrowStatusApproved, BorrowStatusClaimedReturned).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("user has active, pending or claimed-returned borrows")
+	}
+
+	// Erasing the account must not silently drop what the patron owes
+	err = tx.QueryRow(`
+                SELECT COUNT(*) FROM charges
+                WHERE user_id = $1 AND status = $2
+        `, userID, ChargeStatusOutstanding).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("user has outstanding charges")
+	}
+
+	// Remove the notices sent to the patron, which hold their address and loans
+	_, err = tx.Exec("DELETE FROM overdue_notices WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	// Detach borrow history from the patron
+	_, err = tx.Exec(`
+                UPDATE borrows
+                SET user_id = NULL, anonymized_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE user_id = $1
+        `, userID)
+	if err != nil {
+		return err
+	}
+
+	// Remove staff references so librarian accounts can be erased too
+	_, err = tx.Exec("UPDATE borrows SET approved_by = NULL WHERE approved_by = $1", userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE books SET added_by = NULL WHERE added_by = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	// Remove reservations
+	_, err = tx.Exec("DELETE FROM reservations WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	// Remove the account itself
+	_, err = tx.Exec("DELETE FROM users WHERE id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
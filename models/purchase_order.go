@@ -0,0 +1,681 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Purchase order statuses
+const (
+	OrderStatusDraft     = "draft"     // Being prepared; lines can be changed
+	OrderStatusOrdered   = "ordered"   // Sent to the vendor; encumbers the fund
+	OrderStatusPartial   = "partial"   // Some copies received
+	OrderStatusReceived  = "received"  // Every copy received
+	OrderStatusClosed    = "closed"    // Final invoice recorded; no longer encumbers
+	OrderStatusCancelled = "cancelled" // Withdrawn before anything was received
+)
+
+// PurchaseOrder is an order for books from one vendor, charged to one fund
+type PurchaseOrder struct {
+	ID        int
+	VendorID  int
+	FundID    int
+	Status    string
+	OrderedAt NullTime
+	Notes     string
+	CreatedBy sql.NullInt64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// Computed properties
+	Vendor   *Vendor
+	Fund     *Fund
+	Lines    []*OrderLine
+	Invoices []*Invoice
+	Total    float64 // Sum of the line totals
+	Invoiced float64 // Sum of the invoices
+}
+
+// OrderLine is a book on a purchase order
+type OrderLine struct {
+	ID               int
+	OrderID          int
+	BookID           int
+	Quantity         int
+	QuantityReceived int
+	UnitPrice        float64
+	CreatedAt        time.Time
+
+	// Computed properties
+	Book  *Book
+	Order *PurchaseOrder
+}
+
+// Invoice is a vendor's bill for an order
+type Invoice struct {
+	ID            int
+	OrderID       int
+	InvoiceNumber string
+	InvoiceDate   time.Time
+	Amount        float64
+	Final         bool // The last invoice for the order; releases what is left encumbered
+	Notes         string
+	CreatedAt     time.Time
+}
+
+// Number returns the order's reference, as printed on the order sent to the vendor
+func (o *PurchaseOrder) Number() string {
+	return fmt.Sprintf("PO-%05d", o.ID)
+}
+
+// Editable reports whether lines can still be added or removed
+func (o *PurchaseOrder) Editable() bool {
+	return o.Status == OrderStatusDraft
+}
+
+// Receivable reports whether copies can be received against the order
+func (o *PurchaseOrder) Receivable() bool {
+	return o.Status == OrderStatusOrdered || o.Status == OrderStatusPartial
+}
+
+// Invoiceable reports whether invoices can be recorded against the order
+func (o *PurchaseOrder) Invoiceable() bool {
+	return o.Status == OrderStatusOrdered || o.Status == OrderStatusPartial || o.Status == OrderStatusReceived
+}
+
+// Encumbered returns what the order still commits from its fund
+func (o *PurchaseOrder) Encumbered() float64 {
+	if !o.Invoiceable() || o.Invoiced >= o.Total {
+		return 0
+	}
+	return o.Total - o.Invoiced
+}
+
+// LineTotal returns the line's quantity times its unit price
+func (l *OrderLine) LineTotal() float64 {
+	return float64(l.Quantity) * l.UnitPrice
+}
+
+// Outstanding returns the copies still to be received
+func (l *OrderLine) Outstanding() int {
+	return l.Quantity - l.QuantityReceived
+}
+
+// orderColumns lists the columns scanned by scanOrder, with the order's totals
+const orderColumns = `o.id, o.vendor_id, o.fund_id, o.status, o.ordered_at, o.notes, o.created_by, o.created_at, o.updated_at,
+                (SELECT COALESCE(SUM(l.quantity * l.unit_price), 0) FROM purchase_order_lines l WHERE l.order_id = o.id),
+                (SELECT COALESCE(SUM(i.amount), 0) FROM invoices i WHERE i.order_id = o.id)`
+
+// scanOrder reads a row selected with orderColumns
+func scanOrder(row interface{ Scan(...interface{}) error }) (*PurchaseOrder, error) {
+	o := &PurchaseOrder{}
+	err := row.Scan(&o.ID, &o.VendorID, &o.FundID, &o.Status, &o.OrderedAt, &o.Notes, &o.CreatedBy,
+		&o.CreatedAt, &o.UpdatedAt, &o.Total, &o.Invoiced)
+	return o, err
+}
+
+// GetPurchaseOrders retrieves orders, newest first, optionally only those with a status
+func GetPurchaseOrders(status string) ([]*PurchaseOrder, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT `+orderColumns+`
+                FROM purchase_orders o
+                WHERE $1 = '' OR o.status = $1
+                ORDER BY o.created_at DESC, o.id DESC
+        `, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var orders []*PurchaseOrder
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Attach vendors and funds
+	vendors := make(map[int]*Vendor)
+	funds := make(map[int]*Fund)
+	for _, o := range orders {
+		if _, ok := vendors[o.VendorID]; !ok {
+			vendors[o.VendorID], _ = GetVendorByID(o.VendorID)
+		}
+		if _, ok := funds[o.FundID]; !ok {
+			funds[o.FundID], _ = GetFundByID(o.FundID)
+		}
+		o.Vendor, o.Fund = vendors[o.VendorID], funds[o.FundID]
+	}
+
+	return orders, nil
+}
+
+// GetPurchaseOrderByID retrieves an order with its vendor, fund, lines and invoices
+func GetPurchaseOrderByID(id int) (*PurchaseOrder, error) {
+	db := config.GetDB()
+
+	o, err := scanOrder(db.QueryRow("SELECT "+orderColumns+" FROM purchase_orders o WHERE o.id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("purchase order not found")
+		}
+		return nil, err
+	}
+	if o.Vendor, err = GetVendorByID(o.VendorID); err != nil {
+		return nil, err
+	}
+	if o.Fund, err = GetFundByID(o.FundID); err != nil {
+		return nil, err
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id, order_id, book_id, quantity, quantity_received, unit_price, created_at
+                FROM purchase_order_lines
+                WHERE order_id = $1
+                ORDER BY id
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	for rows.Next() {
+		l := &OrderLine{Order: o}
+		err := rows.Scan(&l.ID, &l.OrderID, &l.BookID, &l.Quantity, &l.QuantityReceived, &l.UnitPrice, &l.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		o.Lines = append(o.Lines, l)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, l := range o.Lines {
+		if l.Book, err = GetBookByID(l.BookID); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Invoices, err = getOrderInvoices(id); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// getOrderInvoices retrieves the invoices recorded against an order
+func getOrderInvoices(orderID int) ([]*Invoice, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id, order_id, invoice_number, invoice_date, amount, final, notes, created_at
+                FROM invoices
+                WHERE order_id = $1
+                ORDER BY invoice_date, id
+        `, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var invoices []*Invoice
+	for rows.Next() {
+		inv := &Invoice{}
+		err := rows.Scan(&inv.ID, &inv.OrderID, &inv.InvoiceNumber, &inv.InvoiceDate, &inv.Amount, &inv.Final,
+			&inv.Notes, &inv.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invoices, nil
+}
+
+// GetBookOrderLines retrieves the order lines for a book, newest first, so the
+// catalog can show how its copies were bought
+func GetBookOrderLines(bookID int) ([]*OrderLine, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT l.id, l.order_id, l.book_id, l.quantity, l.quantity_received, l.unit_price, l.created_at,
+                        o.status, v.name
+                FROM purchase_order_lines l
+                JOIN purchase_orders o ON o.id = l.order_id
+                JOIN vendors v ON v.id = o.vendor_id
+                WHERE l.book_id = $1 AND o.status <> $2
+                ORDER BY l.created_at DESC
+        `, bookID, OrderStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var lines []*OrderLine
+	for rows.Next() {
+		l := &OrderLine{Order: &PurchaseOrder{Vendor: &Vendor{}}}
+		err := rows.Scan(&l.ID, &l.OrderID, &l.BookID, &l.Quantity, &l.QuantityReceived, &l.UnitPrice, &l.CreatedAt,
+			&l.Order.Status, &l.Order.Vendor.Name)
+		if err != nil {
+			return nil, err
+		}
+		l.Order.ID = l.OrderID
+		lines = append(lines, l)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// Create saves a new draft order
+func (o *PurchaseOrder) Create() error {
+	if o.VendorID <= 0 {
+		return errors.New("choose a vendor")
+	}
+	if o.FundID <= 0 {
+		return errors.New("choose a fund")
+	}
+
+	db := config.GetDB()
+
+	o.Status = OrderStatusDraft
+	return db.QueryRow(`
+                INSERT INTO purchase_orders (vendor_id, fund_id, status, notes, created_by)
+                VALUES ($1, $2, $3, $4, $5)
+                RETURNING id, created_at, updated_at
+        `, o.VendorID, o.FundID, o.Status, strings.TrimSpace(o.Notes), o.CreatedBy).Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+}
+
+// lockOrder locks an order for the rest of the transaction and returns its status
+func lockOrder(tx *sql.Tx, orderID int) (string, error) {
+	var status string
+	err := tx.QueryRow("SELECT status FROM purchase_orders WHERE id = $1 FOR UPDATE", orderID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", errors.New("purchase order not found")
+	}
+	return status, err
+}
+
+// AddOrderLine adds a book to a draft order. A book already on the order has its
+// quantity and price replaced.
+func AddOrderLine(orderID, bookID, quantity int, unitPrice float64) error {
+	if quantity <= 0 {
+		return errors.New("quantity must be a positive number")
+	}
+	if unitPrice < 0 {
+		return errors.New("the price cannot be negative")
+	}
+
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if status != OrderStatusDraft {
+		return errors.New("lines can only be changed before the order is placed")
+	}
+
+	_, err = tx.Exec(`
+                INSERT INTO purchase_order_lines (order_id, book_id, quantity, unit_price)
+                VALUES ($1, $2, $3, $4)
+                ON CONFLICT (order_id, book_id) DO UPDATE
+                SET quantity = EXCLUDED.quantity, unit_price = EXCLUDED.unit_price
+        `, orderID, bookID, quantity, unitPrice)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// RemoveOrderLine takes a line off a draft order
+func RemoveOrderLine(orderID, lineID int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if status != OrderStatusDraft {
+		return errors.New("lines can only be changed before the order is placed")
+	}
+
+	if _, err := tx.Exec("DELETE FROM purchase_order_lines WHERE id = $1 AND order_id = $2", lineID, orderID); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// PlaceOrder marks a draft order as sent to the vendor, which encumbers its fund
+func PlaceOrder(orderID int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if status != OrderStatusDraft {
+		return errors.New("this order has already been placed")
+	}
+
+	var lines int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM purchase_order_lines WHERE order_id = $1", orderID).Scan(&lines); err != nil {
+		return err
+	}
+	if lines == 0 {
+		return errors.New("add at least one book before placing the order")
+	}
+
+	_, err = tx.Exec(`
+                UPDATE purchase_orders SET status = $1, ordered_at = $2, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $3
+        `, OrderStatusOrdered, time.Now(), orderID)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// CancelOrder withdraws an order on which nothing has been received, releasing its
+// encumbrance
+func CancelOrder(orderID int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if status != OrderStatusDraft && status != OrderStatusOrdered {
+		return errors.New("only orders on which nothing has been received can be cancelled")
+	}
+
+	_, err = tx.Exec(`
+                UPDATE purchase_orders SET status = $1, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2
+        `, OrderStatusCancelled, orderID)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// ReceiveOrder records copies arriving against an order's lines, keyed by line ID.
+// Each received copy is added to its book's quantity and availability, suggestions
+// for the book are marked added, and waiting reservations are offered the new
+// copies. It returns the number of copies received.
+func ReceiveOrder(orderID int, received map[int]int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	status, err := lockOrder(tx, orderID)
+	if err != nil {
+		return 0, err
+	}
+	if status != OrderStatusOrdered && status != OrderStatusPartial {
+		return 0, errors.New("copies can only be received on a placed order")
+	}
+
+	total := 0
+	copiesByBook := make(map[int]int)
+	for lineID, count := range received {
+		if count <= 0 {
+			continue
+		}
+
+		var bookID, outstanding int
+		err := tx.QueryRow(`
+                        SELECT book_id, quantity - quantity_received FROM purchase_order_lines
+                        WHERE id = $1 AND order_id = $2
+                        FOR UPDATE
+                `, lineID, orderID).Scan(&bookID, &outstanding)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return 0, errors.New("order line not found")
+			}
+			return 0, err
+		}
+		if count > outstanding {
+			return 0, fmt.Errorf("only %d copies are still expected on one of the lines", outstanding)
+		}
+
+		if _, err := tx.Exec(`
+                        UPDATE purchase_order_lines SET quantity_received = quantity_received + $1
+                        WHERE id = $2
+                `, count, lineID); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`
+                        UPDATE books SET quantity = quantity + $1, available = available + $1, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $2
+                `, count, bookID); err != nil {
+			return 0, err
+		}
+		if err := markSuggestionsAddedTx(tx, bookID); err != nil {
+			return 0, err
+		}
+		copiesByBook[bookID] += count
+		total += count
+	}
+	if total == 0 {
+		return 0, errors.New("enter the number of copies received")
+	}
+
+	// The order is complete once every line is fully received
+	var remaining int
+	err = tx.QueryRow(`
+                SELECT COALESCE(SUM(quantity - quantity_received), 0) FROM purchase_order_lines
+                WHERE order_id = $1
+        `, orderID).Scan(&remaining)
+	if err != nil {
+		return 0, err
+	}
+	status = OrderStatusPartial
+	if remaining == 0 {
+		status = OrderStatusReceived
+	}
+	_, err = tx.Exec(`
+                UPDATE purchase_orders SET status = $1, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2
+        `, status, orderID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// Offer each new copy to the next reservation in line
+	go func() {
+		for bookID, copies := range copiesByBook {
+			for i := 0; i < copies; i++ {
+				_ = ProcessReservationsForBook(bookID)
+			}
+		}
+	}()
+
+	return total, nil
+}
+
+// AddInvoice records a vendor invoice against an order. The final invoice closes
+// the order, releasing anything still encumbered.
+func AddInvoice(inv *Invoice) error {
+	inv.InvoiceNumber = strings.TrimSpace(inv.InvoiceNumber)
+	if inv.InvoiceNumber == "" {
+		return errors.New("invoice number is required")
+	}
+	if inv.Amount < 0 {
+		return errors.New("the invoice amount cannot be negative")
+	}
+	if inv.InvoiceDate.IsZero() {
+		inv.InvoiceDate = time.Now()
+	}
+
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockOrder(tx, inv.OrderID)
+	if err != nil {
+		return err
+	}
+	if status != OrderStatusOrdered && status != OrderStatusPartial && status != OrderStatusReceived {
+		return errors.New("invoices can only be recorded on a placed, open order")
+	}
+
+	err = tx.QueryRow(`
+                INSERT INTO invoices (order_id, invoice_number, invoice_date, amount, final, notes)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                RETURNING id, created_at
+        `, inv.OrderID, inv.InvoiceNumber, inv.InvoiceDate, inv.Amount, inv.Final, inv.Notes).Scan(&inv.ID, &inv.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if inv.Final {
+		_, err = tx.Exec(`
+                        UPDATE purchase_orders SET status = $1, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $2
+                `, OrderStatusClosed, inv.OrderID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// VendorSpending is one vendor's line in the expenditure report
+type VendorSpending struct {
+	Vendor     *Vendor
+	Orders     int
+	Copies     int // Copies received
+	Encumbered float64
+	Expended   float64
+}
+
+// GetVendorSpending summarizes orders and invoices by vendor for a fiscal year's funds
+func GetVendorSpending(fiscalYear int) ([]*VendorSpending, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT v.id, v.name, COUNT(o.id),
+                        COALESCE(SUM((SELECT SUM(l.quantity_received) FROM purchase_order_lines l WHERE l.order_id = o.id)), 0),
+                        COALESCE(SUM(CASE WHEN o.status IN ($2, $3, $4) THEN GREATEST(
+                                (SELECT COALESCE(SUM(l.quantity * l.unit_price), 0) FROM purchase_order_lines l WHERE l.order_id = o.id)
+                                - (SELECT COALESCE(SUM(i.amount), 0) FROM invoices i WHERE i.order_id = o.id), 0) END), 0),
+                        COALESCE(SUM((SELECT SUM(i.amount) FROM invoices i WHERE i.order_id = o.id)), 0)
+                FROM purchase_orders o
+                JOIN vendors v ON v.id = o.vendor_id
+                JOIN funds f ON f.id = o.fund_id
+                WHERE f.fiscal_year = $1 AND o.status NOT IN ($5, $6)
+                GROUP BY v.id, v.name
+                ORDER BY LOWER(v.name)
+        `, fiscalYear, OrderStatusOrdered, OrderStatusPartial, OrderStatusReceived, OrderStatusDraft, OrderStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var report []*VendorSpending
+	for rows.Next() {
+		s := &VendorSpending{Vendor: &Vendor{}}
+		err := rows.Scan(&s.Vendor.ID, &s.Vendor.Name, &s.Orders, &s.Copies, &s.Encumbered, &s.Expended)
+		if err != nil {
+			return nil, err
+		}
+		report = append(report, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
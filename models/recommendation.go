@@ -0,0 +1,168 @@
+package models
+
+import (
+	"library-management-system/config"
+)
+
+// subjectWeight is how much one shared subject heading counts towards a
+// recommendation, relative to one more patron borrowing both books
+const subjectWeight = 0.5
+
+// RefreshRecommendations rebuilds the book-to-book recommendation table. Two books
+// are related when the same patrons borrowed both, or when they share subject
+// headings. Only the number of distinct patrons behind a pair is kept, and pairs
+// below the configured minimum are dropped so a rarely borrowed pair cannot reveal
+// an individual's reading. Anonymized borrows no longer count, and other editions
+// of the same work are never recommended.
+func RefreshRecommendations() error {
+	db := config.GetDB()
+
+	// A pair borrowed by one patron would only describe that patron
+	minPatrons := config.AppConfig.Recommendations.MinPatrons
+	if minPatrons < 2 {
+		minPatrons = 2
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM book_recommendations"); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+                WITH patron_books AS (
+                        SELECT DISTINCT user_id, book_id
+                        FROM borrows
+                        WHERE user_id IS NOT NULL AND status NOT IN ($1, $2)
+                ),
+                co_borrowed AS (
+                        SELECT a.book_id, b.book_id AS recommended_id, COUNT(*) AS patrons
+                        FROM patron_books a
+                        JOIN patron_books b ON b.user_id = a.user_id AND b.book_id <> a.book_id
+                        GROUP BY a.book_id, b.book_id
+                        HAVING COUNT(*) >= $3
+                ),
+                shared AS (
+                        SELECT a.book_id, b.book_id AS recommended_id, COUNT(*) AS subjects
+                        FROM book_subjects a
+                        JOIN book_subjects b ON b.subject_id = a.subject_id AND b.book_id <> a.book_id
+                        GROUP BY a.book_id, b.book_id
+                ),
+                scored AS (
+                        SELECT COALESCE(c.book_id, s.book_id) AS book_id,
+                                COALESCE(c.recommended_id, s.recommended_id) AS recommended_id,
+                                COALESCE(c.patrons, 0) AS patrons,
+                                COALESCE(s.subjects, 0) AS subjects
+                        FROM co_borrowed c
+                        FULL JOIN shared s ON s.book_id = c.book_id AND s.recommended_id = c.recommended_id
+                ),
+                ranked AS (
+                        SELECT sc.*, sc.patrons + sc.subjects * $5::real AS score,
+                                ROW_NUMBER() OVER (
+                                        PARTITION BY sc.book_id
+                                        ORDER BY sc.patrons + sc.subjects * $5::real DESC, sc.recommended_id
+                                ) AS rank
+                        FROM scored sc
+                        JOIN books x ON x.id = sc.book_id
+                        JOIN books y ON y.id = sc.recommended_id
+                        WHERE COALESCE(x.work_id, -x.id) <> COALESCE(y.work_id, -y.id) AND y.withdrawn_at IS NULL
+                )
+                INSERT INTO book_recommendations (book_id, recommended_id, patrons, shared_subjects, score)
+                SELECT book_id, recommended_id, patrons, subjects, score
+                FROM ranked
+                WHERE rank <= $4
+        `, BorrowStatusPending, BorrowStatusRejected, minPatrons,
+		config.AppConfig.Recommendations.PerBook, subjectWeight)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// getRecommendedBooks runs a query returning book IDs and loads the books in order
+func getRecommendedBooks(query string, args ...interface{}) ([]*Book, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var books []*Book
+	for _, id := range ids {
+		book, err := GetBookByID(id)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return books, nil
+}
+
+// GetAlsoBorrowed retrieves the books most often borrowed by patrons who borrowed
+// this one
+func GetAlsoBorrowed(bookID, limit int) ([]*Book, error) {
+	return getRecommendedBooks(`
+                SELECT recommended_id
+                FROM book_recommendations
+                WHERE book_id = $1 AND patrons > 0
+                ORDER BY patrons DESC, score DESC
+                LIMIT $2
+        `, bookID, limit)
+}
+
+// GetSimilarBooks retrieves books related to this one by subject alone
+func GetSimilarBooks(bookID, limit int) ([]*Book, error) {
+	return getRecommendedBooks(`
+                SELECT recommended_id
+                FROM book_recommendations
+                WHERE book_id = $1 AND patrons = 0
+                ORDER BY score DESC
+                LIMIT $2
+        `, bookID, limit)
+}
+
+// GetRecommendedForUser retrieves books related to what the patron has borrowed,
+// leaving out anything they have borrowed already in any edition
+func GetRecommendedForUser(userID, limit int) ([]*Book, error) {
+	return getRecommendedBooks(`
+                WITH borrowed AS (
+                        SELECT DISTINCT b.book_id, COALESCE(bk.work_id, -bk.id) AS work
+                        FROM borrows b
+                        JOIN books bk ON bk.id = b.book_id
+                        WHERE b.user_id = $1 AND b.status NOT IN ($2, $3)
+                )
+                SELECT r.recommended_id
+                FROM book_recommendations r
+                JOIN books y ON y.id = r.recommended_id
+                WHERE r.book_id IN (SELECT book_id FROM borrowed)
+                        AND COALESCE(y.work_id, -y.id) NOT IN (SELECT work FROM borrowed)
+                GROUP BY r.recommended_id
+                ORDER BY SUM(r.score) DESC, r.recommended_id
+                LIMIT $4
+        `, userID, BorrowStatusPending, BorrowStatusRejected, limit)
+}
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"time"
+
+	"library-management-system/config"
+)
+
+// Report intervals, named after the PostgreSQL date_trunc fields they use
+const (
+	ReportIntervalDay   = "day"
+	ReportIntervalWeek  = "week"
+	ReportIntervalMonth = "month"
+)
+
+// Report comparisons
+const (
+	ReportComparePrevious = "previous" // The period of the same length just before
+	ReportCompareYear     = "year"     // The same dates a year earlier
+)
+
+// ReportFilter selects the loans a circulation report covers
+type ReportFilter struct {
+	From       time.Time // First day of the period
+	To         time.Time // Last day of the period, inclusive
+	Interval   string
+	Category   string // Book category; empty for all
+	PatronType string // User role; empty for all
+}
+
+// Days returns the number of days in the period
+func (f ReportFilter) Days() int {
+	return int(f.To.Sub(f.From).Hours()/24) + 1
+}
+
+// Shift returns the filter moved to the period it is compared with
+func (f ReportFilter) Shift(compare string) ReportFilter {
+	shifted := f
+	switch compare {
+	case ReportComparePrevious:
+		shifted.From = f.From.AddDate(0, 0, -f.Days())
+		shifted.To = f.From.AddDate(0, 0, -1)
+	case ReportCompareYear:
+		shifted.From = f.From.AddDate(-1, 0, 0)
+		shifted.To = f.To.AddDate(-1, 0, 0)
+	}
+	return shifted
+}
+
+// ReportPoint holds the circulation figures for one interval of a report
+type ReportPoint struct {
+	Start           time.Time
+	Loans           int
+	Returns         int
+	Due             int // Loans that fell due in the interval
+	Overdue         int // Of those, returned late or still out past their due date
+	UniqueBorrowers int
+}
+
+// OverdueRate returns the percentage of loans due in the interval that were overdue
+func (p *ReportPoint) OverdueRate() float64 {
+	if p.Due == 0 {
+		return 0
+	}
+	return float64(p.Overdue) / float64(p.Due) * 100
+}
+
+// Label names the interval the point covers
+func (p *ReportPoint) Label(interval string) string {
+	switch interval {
+	case ReportIntervalMonth:
+		return p.Start.Format("Jan 2006")
+	case ReportIntervalWeek:
+		return "w/c " + p.Start.Format("Jan 02")
+	default:
+		return p.Start.Format("Jan 02")
+	}
+}
+
+// ReportBook is a book with the number of times it was lent in a report period
+type ReportBook struct {
+	BookID   int
+	Title    string
+	Author   string
+	Category string
+	Loans    int
+}
+
+// ReportCategory is a book category with the number of loans in a report period
+type ReportCategory struct {
+	Category string
+	Loans    int
+}
+
+// reportBorrows selects the borrows matching a filter's category ($3) and patron
+// type ($4). Anonymized borrows have no patron, so they only count when every
+// patron type is included.
+const reportBorrows = `
+                SELECT br.*, COALESCE(b.category, '') AS category
+                FROM borrows br
+                JOIN books b ON b.id = br.book_id
+                LEFT JOIN users u ON u.id = br.user_id
+                WHERE br.status NOT IN ('` + BorrowStatusPending + `', '` + BorrowStatusRejected + `')
+                        AND ($3 = '' OR COALESCE(b.category, '') = $3)
+                        AND ($4 = '' OR u.role = $4)`
+
+// reportOverdue counts a borrow that was returned late or is still out past its due date
+const reportOverdue = `(return_date > due_date OR (return_date IS NULL AND due_date < CURRENT_TIMESTAMP))`
+
+// GetCirculationSeries returns the report's figures for every interval in its period,
+// including intervals with no activity
+func GetCirculationSeries(f ReportFilter) ([]*ReportPoint, error) {
+	db := config.GetDB()
+
+	// Execute query; $1 and $2 bound the period, $5 is the interval
+	rows, err := db.Query(`
+                WITH filtered AS (`+reportBorrows+`
+                ),
+                buckets AS (
+                        SELECT generate_series(date_trunc($5, $1::timestamp), $2::timestamp, ('1 ' || $5)::interval) AS start
+                ),
+                loans AS (
+                        SELECT date_trunc($5, borrow_date) AS start, COUNT(*) AS loans, COUNT(DISTINCT user_id) AS borrowers
+                        FROM filtered
+                        WHERE borrow_date >= $1 AND borrow_date < $2::date + 1
+                        GROUP BY 1
+                ),
+                returns AS (
+                        SELECT date_trunc($5, return_date) AS start, COUNT(*) AS returns
+                        FROM filtered
+                        WHERE return_date >= $1 AND return_date < $2::date + 1
+                        GROUP BY 1
+                ),
+                due AS (
+                        SELECT date_trunc($5, due_date) AS start, COUNT(*) AS due,
+                                COUNT(*) FILTER (WHERE `+reportOverdue+`) AS overdue
+                        FROM filtered
+                        WHERE due_date >= $1 AND due_date < $2::date + 1
+                        GROUP BY 1
+                )
+                SELECT bk.start, COALESCE(l.loans, 0), COALESCE(r.returns, 0), COALESCE(d.due, 0),
+                        COALESCE(d.overdue, 0), COALESCE(l.borrowers, 0)
+                FROM buckets bk
+                LEFT JOIN loans l ON l.start = bk.start
+                LEFT JOIN returns r ON r.start = bk.start
+                LEFT JOIN due d ON d.start = bk.start
+                ORDER BY bk.start
+        `, f.From, f.To, f.Category, f.PatronType, f.Interval)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var points []*ReportPoint
+	for rows.Next() {
+		p := &ReportPoint{}
+		if err := rows.Scan(&p.Start, &p.Loans, &p.Returns, &p.Due, &p.Overdue, &p.UniqueBorrowers); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// GetCirculationTotals returns the report's figures for its whole period. Unique
+// borrowers are counted across the period, not summed over its intervals.
+func GetCirculationTotals(f ReportFilter) (*ReportPoint, error) {
+	db := config.GetDB()
+
+	totals := &ReportPoint{Start: f.From}
+	err := db.QueryRow(`
+                WITH filtered AS (`+reportBorrows+`
+                )
+                SELECT
+                        COUNT(*) FILTER (WHERE borrow_date >= $1 AND borrow_date < $2::date + 1),
+                        COUNT(*) FILTER (WHERE return_date >= $1 AND return_date < $2::date + 1),
+                        COUNT(*) FILTER (WHERE due_date >= $1 AND due_date < $2::date + 1),
+                        COUNT(*) FILTER (WHERE due_date >= $1 AND due_date < $2::date + 1 AND `+reportOverdue+`),
+                        COUNT(DISTINCT user_id) FILTER (WHERE borrow_date >= $1 AND borrow_date < $2::date + 1)
+                FROM filtered
+        `, f.From, f.To, f.Category, f.PatronType).Scan(&totals.Loans, &totals.Returns, &totals.Due, &totals.Overdue, &totals.UniqueBorrowers)
+	if err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// GetTopBooksInPeriod returns the books lent most often in the report's period
+func GetTopBooksInPeriod(f ReportFilter, limit int) ([]*ReportBook, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                WITH filtered AS (`+reportBorrows+`
+                )
+                SELECT b.id, b.title, b.author, COALESCE(b.category, ''), COUNT(*) AS loans
+                FROM filtered f
+                JOIN books b ON b.id = f.book_id
+                WHERE f.borrow_date >= $1 AND f.borrow_date < $2::date + 1
+                GROUP BY b.id
+                ORDER BY loans DESC, b.title
+                LIMIT $5
+        `, f.From, f.To, f.Category, f.PatronType, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var books []*ReportBook
+	for rows.Next() {
+		b := &ReportBook{}
+		if err := rows.Scan(&b.BookID, &b.Title, &b.Author, &b.Category, &b.Loans); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// GetLoansByCategory returns the number of loans in each book category in the
+// report's period, busiest first
+func GetLoansByCategory(f ReportFilter) ([]*ReportCategory, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                WITH filtered AS (`+reportBorrows+`
+                )
+                SELECT category, COUNT(*) AS loans
+                FROM filtered
+                WHERE borrow_date >= $1 AND borrow_date < $2::date + 1
+                GROUP BY category
+                ORDER BY loans DESC, category
+        `, f.From, f.To, f.Category, f.PatronType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var categories []*ReportCategory
+	for rows.Next() {
+		c := &ReportCategory{}
+		if err := rows.Scan(&c.Category, &c.Loans); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// GetBookCategories returns the categories in use in the catalog
+func GetBookCategories() ([]string, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT DISTINCT category FROM books
+                WHERE category IS NOT NULL AND category <> ''
+                ORDER BY category
+        `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var categories []string
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
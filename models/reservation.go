@@ -61,6 +61,7 @@ type Reservation struct {
 	ReservationDate time.Time `json:"reservation_date"`
 	ExpiryDate      time.Time `json:"expiry_date"`
 	FulfilledDate   time.Time `json:"fulfilled_date,omitempty"`
+	AnyEdition      bool      `json:"any_edition"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 
@@ -72,8 +73,18 @@ type Reservation struct {
 	Book *Book `json:"book,omitempty"`
 }
 
-// ReserveBook creates a new reservation for a book
-func ReserveBook(userID, bookID int) error {
+// holdsForBook returns a condition matching reservations a copy of the book given by
+// the placeholder can fulfil: holds on the book itself, and any-edition holds on
+// other editions of the same work
+func holdsForBook(placeholder string) string {
+	return `(book_id = ` + placeholder + ` OR (any_edition AND book_id IN (
+                SELECT id FROM books WHERE work_id = (SELECT work_id FROM books WHERE id = ` + placeholder + `)
+        )))`
+}
+
+// ReserveBook creates a new reservation for a book. With anyEdition set, the first
+// copy of any edition of the same work to come back fulfils it.
+func ReserveBook(userID, bookID int, anyEdition bool) error {
 	db := config.GetDB()
 
 	// Begin transaction
@@ -83,17 +94,47 @@ func ReserveBook(userID, bookID int) error {
 	}
 	defer tx.Rollback()
 
-	// Check if book exists and is unavailable
+	if err := reserveBookTx(tx, userID, bookID, anyEdition); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// reserveBookTx checks and records a reservation inside the caller's transaction
+func reserveBookTx(tx *sql.Tx, userID, bookID int, anyEdition bool) error {
+	// Check if book exists, is still in the collection and is unavailable
 	var available int
-	err = tx.QueryRow("SELECT available FROM books WHERE id = $1", bookID).Scan(&available)
+	var withdrawn bool
+	err := tx.QueryRow("SELECT available, withdrawn_at IS NOT NULL FROM books WHERE id = $1", bookID).Scan(&available, &withdrawn)
 	if err != nil {
 		return err
 	}
 
+	if withdrawn {
+		return errors.New("this book has been withdrawn from the collection")
+	}
 	if available > 0 {
 		return errors.New("this book is currently available and can be borrowed directly")
 	}
 
+	if anyEdition {
+		var other string
+		err = tx.QueryRow(`
+                        SELECT title || CASE WHEN edition_statement <> '' THEN ' (' || edition_statement || ')' ELSE '' END
+                        FROM books
+                        WHERE work_id = (SELECT work_id FROM books WHERE id = $1) AND available > 0
+                        LIMIT 1
+                `, bookID).Scan(&other)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if other != "" {
+			return errors.New("another edition, " + other + ", is available and can be borrowed directly")
+		}
+	}
+
 	// Check if user already has an active reservation for this book
 	var count int
 	err = tx.QueryRow(`
@@ -124,15 +165,11 @@ func ReserveBook(userID, bookID int) error {
 	// Create reservation with expiry date 14 days from now
 	expiryDate := time.Now().AddDate(0, 0, 14)
 	_, err = tx.Exec(`
-                INSERT INTO reservations (user_id, book_id, status, reservation_date, expiry_date)
-                VALUES ($1, $2, $3, CURRENT_TIMESTAMP, $4)
-        `, userID, bookID, ReservationStatusActive, expiryDate)
-	if err != nil {
-		return err
-	}
+                INSERT INTO reservations (user_id, book_id, status, reservation_date, expiry_date, any_edition)
+                VALUES ($1, $2, $3, CURRENT_TIMESTAMP, $4, $5)
+        `, userID, bookID, ReservationStatusActive, expiryDate, anyEdition)
 
-	// Commit transaction
-	return tx.Commit()
+	return err
 }
 
 // CancelReservation cancels a reservation
@@ -201,11 +238,11 @@ func ProcessReservationsForBook(bookID int) error {
 		return nil
 	}
 
-	// Get the oldest active reservation for this book
+	// Get the oldest active reservation this copy can fulfil
 	var reservationID, userID int
 	err = tx.QueryRow(`
                 SELECT id, user_id FROM reservations
-                WHERE book_id = $1 AND status = $2
+                WHERE `+holdsForBook("$1")+` AND status = $2
                 ORDER BY reservation_date ASC
                 LIMIT 1
         `, bookID, ReservationStatusActive).Scan(&reservationID, &userID)
@@ -227,9 +264,9 @@ func ProcessReservationsForBook(bookID int) error {
 		return err
 	}
 
-	// Create a pending borrow request for the user
+	// Create a pending borrow request for the user, for the edition that came back
 	borrowDate := time.Now()
-	dueDate := borrowDate.AddDate(0, 0, 14) // Due in 14 days
+	dueDate := AdjustDueDate(borrowDate.AddDate(0, 0, 14)) // Due in 14 days, on an open day
 	_, err = tx.Exec(`
                 INSERT INTO borrows (user_id, book_id, status, borrow_date, due_date)
                 VALUES ($1, $2, $3, $4, $5)
@@ -249,7 +286,7 @@ func GetUserReservations(userID int) ([]*Reservation, error) {
 	// Execute query to get reservations
 	rows, err := db.Query(`
                 SELECT r.id, r.user_id, r.book_id, r.status, r.reservation_date, r.expiry_date, 
-                       r.fulfilled_date, r.created_at, r.updated_at
+                       r.fulfilled_date, r.any_edition, r.created_at, r.updated_at
                 FROM reservations r
                 WHERE r.user_id = $1
                 ORDER BY r.reservation_date DESC
@@ -275,6 +312,7 @@ func GetUserReservations(userID int) ([]*Reservation, error) {
 			&reservation.ReservationDate,
 			&reservation.ExpiryDate,
 			&fulfilledDate,
+			&reservation.AnyEdition,
 			&reservation.CreatedAt,
 			&reservation.UpdatedAt,
 		)
@@ -0,0 +1,410 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Review statuses
+const (
+	ReviewStatusPending   = "pending"   // Waiting for a librarian while approval is required
+	ReviewStatusPublished = "published" // Shown on the book's page and counted in its rating
+	ReviewStatusHidden    = "hidden"    // Taken down by a librarian
+)
+
+// Review moderation queues
+const (
+	ReviewQueuePending = "pending"
+	ReviewQueueFlagged = "flagged"
+	ReviewQueueHidden  = "hidden"
+)
+
+// maxReviewLength caps the length of a review's text
+const maxReviewLength = 5000
+
+// Review is a patron's rating of a book they have borrowed, with optional text
+type Review struct {
+	ID          int
+	BookID      int
+	UserID      int
+	Rating      int
+	Body        string
+	Status      string
+	Flagged     bool // Reported by a patron or marked by a librarian for another look
+	ModeratedBy sql.NullInt64
+	ModeratedAt NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	// Computed properties
+	UserName    string
+	BookTitle   string
+	ReportCount int
+	Reports     []*ReviewReport
+}
+
+// ReviewReport is a patron's report that a review is abusive
+type ReviewReport struct {
+	ReviewID  int
+	UserID    int
+	UserName  string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// Published reports whether the review is shown to other patrons
+func (r *Review) Published() bool {
+	return r.Status == ReviewStatusPublished
+}
+
+// reviewColumns lists the columns scanned by scanReview from reviews rv joined to
+// users u and books b
+const reviewColumns = `rv.id, rv.book_id, rv.user_id, rv.rating, rv.body, rv.status, rv.flagged, rv.moderated_by,
+                rv.moderated_at, rv.created_at, rv.updated_at, u.name, b.title,
+                (SELECT COUNT(*) FROM review_reports rr WHERE rr.review_id = rv.id)`
+
+// reviewJoins joins the tables reviewColumns reads from
+const reviewJoins = `FROM reviews rv
+                JOIN users u ON u.id = rv.user_id
+                JOIN books b ON b.id = rv.book_id`
+
+// scanReview reads a row selected with reviewColumns
+func scanReview(row interface{ Scan(...interface{}) error }) (*Review, error) {
+	r := &Review{}
+	err := row.Scan(&r.ID, &r.BookID, &r.UserID, &r.Rating, &r.Body, &r.Status, &r.Flagged, &r.ModeratedBy,
+		&r.ModeratedAt, &r.CreatedAt, &r.UpdatedAt, &r.UserName, &r.BookTitle, &r.ReportCount)
+	return r, err
+}
+
+// queryReviews runs a review query and parses its rows
+func queryReviews(query string, args ...interface{}) ([]*Review, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var reviews []*Review
+	for rows.Next() {
+		r, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// GetBookReviews retrieves a book's published reviews, newest first
+func GetBookReviews(bookID int) ([]*Review, error) {
+	return queryReviews(`
+                SELECT `+reviewColumns+`
+                `+reviewJoins+`
+                WHERE rv.book_id = $1 AND rv.status = $2
+                ORDER BY rv.created_at DESC
+        `, bookID, ReviewStatusPublished)
+}
+
+// GetUserReviews retrieves every review a user has written, newest first
+func GetUserReviews(userID int) ([]*Review, error) {
+	return queryReviews(`
+                SELECT `+reviewColumns+`
+                `+reviewJoins+`
+                WHERE rv.user_id = $1
+                ORDER BY rv.created_at DESC
+        `, userID)
+}
+
+// GetBookRating returns the average of a book's published ratings and how many there are
+func GetBookRating(bookID int) (float64, int, error) {
+	db := config.GetDB()
+
+	var average float64
+	var count int
+	err := db.QueryRow(`
+                SELECT COALESCE(AVG(rating), 0), COUNT(*)
+                FROM reviews
+                WHERE book_id = $1 AND status = $2
+        `, bookID, ReviewStatusPublished).Scan(&average, &count)
+	return average, count, err
+}
+
+// GetUserBookReview retrieves the user's own review of a book, whatever its status,
+// or nil if they have not reviewed it
+func GetUserBookReview(userID, bookID int) (*Review, error) {
+	db := config.GetDB()
+
+	r, err := scanReview(db.QueryRow(`
+                SELECT `+reviewColumns+`
+                `+reviewJoins+`
+                WHERE rv.user_id = $1 AND rv.book_id = $2
+        `, userID, bookID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// GetReviewByID retrieves a review with the reports made against it
+func GetReviewByID(id int) (*Review, error) {
+	db := config.GetDB()
+
+	r, err := scanReview(db.QueryRow("SELECT "+reviewColumns+" "+reviewJoins+" WHERE rv.id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("review not found")
+		}
+		return nil, err
+	}
+
+	if err := r.loadReports(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// loadReports fills in the reports made against the review
+func (r *Review) loadReports() error {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT rr.review_id, rr.user_id, u.name, rr.reason, rr.created_at
+                FROM review_reports rr
+                JOIN users u ON u.id = rr.user_id
+                WHERE rr.review_id = $1
+                ORDER BY rr.created_at
+        `, r.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	r.Reports = nil
+	for rows.Next() {
+		report := &ReviewReport{}
+		if err := rows.Scan(&report.ReviewID, &report.UserID, &report.UserName, &report.Reason, &report.CreatedAt); err != nil {
+			return err
+		}
+		r.Reports = append(r.Reports, report)
+	}
+
+	// Check for errors
+	return rows.Err()
+}
+
+// GetReviewQueue retrieves reviews for moderation: those waiting for approval, those
+// flagged, those hidden, or with an empty queue every review, newest first
+func GetReviewQueue(queue string) ([]*Review, error) {
+	var condition string
+	switch queue {
+	case ReviewQueuePending:
+		condition = "WHERE rv.status = '" + ReviewStatusPending + "'"
+	case ReviewQueueFlagged:
+		condition = "WHERE rv.flagged"
+	case ReviewQueueHidden:
+		condition = "WHERE rv.status = '" + ReviewStatusHidden + "'"
+	}
+
+	reviews, err := queryReviews(`
+                SELECT ` + reviewColumns + `
+                ` + reviewJoins + `
+                ` + condition + `
+                ORDER BY rv.flagged DESC, rv.updated_at DESC
+                LIMIT 200
+        `)
+	if err != nil {
+		return nil, err
+	}
+
+	// Show moderators why flagged reviews were reported
+	for _, r := range reviews {
+		if r.ReportCount > 0 {
+			if err := r.loadReports(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return reviews, nil
+}
+
+// CountReviewsAwaitingModeration counts reviews waiting for approval or flagged
+func CountReviewsAwaitingModeration() (int, error) {
+	db := config.GetDB()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM reviews WHERE status = $1 OR flagged", ReviewStatusPending).Scan(&count)
+	return count, err
+}
+
+// CanReview reports whether the user has returned the book, which is required before
+// they can review it, or has already reviewed it. Returns are only known while the
+// loan is in the patron's history: once the retention job anonymizes it, the patron
+// can still edit an existing review but cannot post a new one.
+func CanReview(userID, bookID int) (bool, error) {
+	db := config.GetDB()
+
+	var returned bool
+	err := db.QueryRow(`
+                SELECT EXISTS (SELECT 1 FROM borrows WHERE user_id = $1 AND book_id = $2 AND status = $3)
+                        OR EXISTS (SELECT 1 FROM reviews WHERE user_id = $1 AND book_id = $2)
+        `, userID, bookID, BorrowStatusReturned).Scan(&returned)
+	return returned, err
+}
+
+// SaveReview creates or updates the user's review of a book. While approval is
+// required the review waits for a librarian after every edit; a hidden review stays
+// hidden until a librarian restores it.
+func SaveReview(userID, bookID, rating int, body string) (*Review, error) {
+	body = strings.TrimSpace(body)
+	if rating < 1 || rating > 5 {
+		return nil, errors.New("rating must be between 1 and 5 stars")
+	}
+	if len(body) > maxReviewLength {
+		return nil, errors.New("review is too long")
+	}
+
+	returned, err := CanReview(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	if !returned {
+		return nil, errors.New("you can review a book once you have borrowed and returned it")
+	}
+
+	status := ReviewStatusPublished
+	if config.AppConfig.Reviews.RequireApproval {
+		status = ReviewStatusPending
+	}
+
+	db := config.GetDB()
+
+	var id int
+	err = db.QueryRow(`
+                INSERT INTO reviews (book_id, user_id, rating, body, status)
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (book_id, user_id) DO UPDATE
+                SET rating = EXCLUDED.rating, body = EXCLUDED.body,
+                        status = CASE WHEN reviews.status = $6 THEN reviews.status ELSE EXCLUDED.status END,
+                        updated_at = CURRENT_TIMESTAMP
+                RETURNING id
+        `, bookID, userID, rating, body, status, ReviewStatusHidden).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetReviewByID(id)
+}
+
+// DeleteReview removes a review
+func DeleteReview(id int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM reviews WHERE id = $1", id)
+	return err
+}
+
+// ReportReview records a patron's report that a review is abusive and flags it for
+// the librarians
+func ReportReview(reviewID, userID int, reason string) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var authorID int
+	err = tx.QueryRow("SELECT user_id FROM reviews WHERE id = $1 FOR UPDATE", reviewID).Scan(&authorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("review not found")
+		}
+		return err
+	}
+	if authorID == userID {
+		return errors.New("you cannot report your own review")
+	}
+
+	result, err := tx.Exec(`
+                INSERT INTO review_reports (review_id, user_id, reason)
+                VALUES ($1, $2, $3)
+                ON CONFLICT (review_id, user_id) DO NOTHING
+        `, reviewID, userID, strings.TrimSpace(reason))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("you have already reported this review")
+	}
+
+	if _, err := tx.Exec("UPDATE reviews SET flagged = TRUE WHERE id = $1", reviewID); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// ModerateReview applies a librarian's decision to a review:
+//
+//	approve  publish it and dismiss any reports
+//	hide     take it down and dismiss any reports
+//	flag     mark it for another look without changing what patrons see
+func ModerateReview(id int, action string, moderatorID int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	switch action {
+	case "approve", "hide":
+		status := ReviewStatusPublished
+		if action == "hide" {
+			status = ReviewStatusHidden
+		}
+		result, err = tx.Exec(`
+                        UPDATE reviews
+                        SET status = $2, flagged = FALSE, moderated_by = $3, moderated_at = CURRENT_TIMESTAMP
+                        WHERE id = $1
+                `, id, status, moderatorID)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM review_reports WHERE review_id = $1", id)
+		}
+	case "flag":
+		result, err = tx.Exec("UPDATE reviews SET flagged = TRUE WHERE id = $1", id)
+	default:
+		return errors.New("unknown moderation action")
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("review not found")
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
@@ -0,0 +1,452 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+	"library-management-system/export"
+)
+
+// Report types that can be saved and scheduled
+const (
+	ReportTypeBorrow  = "borrow"
+	ReportTypeBook    = "book"
+	ReportTypeHistory = "history"
+)
+
+// ReportTypes lists the schedulable report types
+var ReportTypes = []string{ReportTypeBorrow, ReportTypeBook, ReportTypeHistory}
+
+// Schedule frequencies
+const (
+	ScheduleDaily   = "daily"
+	ScheduleWeekly  = "weekly"  // Sent on Mondays
+	ScheduleMonthly = "monthly" // Sent on the first of the month
+)
+
+// ScheduleFrequencies lists the frequencies a report can be sent at
+var ScheduleFrequencies = []string{ScheduleDaily, ScheduleWeekly, ScheduleMonthly}
+
+// Report deliveries
+const (
+	DeliveryAttachment = "attachment" // The report file is attached to the email
+	DeliveryLink       = "link"       // The email links to the report for librarians to download
+)
+
+// maxRecipients caps the recipient list of a scheduled report
+const maxRecipients = 50
+
+// ScheduledReport is a saved report definition, sent by email on a schedule. Params
+// holds the report's filters as a query string; the period is not saved but set to
+// the day, week or month before each run.
+type ScheduledReport struct {
+	ID         int
+	Name       string
+	ReportType string
+	Params     string
+	Format     string
+	Frequency  string
+	Delivery   string
+	Recipients string // Email addresses separated by commas or new lines
+	Active     bool
+	NextRunAt  time.Time
+	CreatedBy  sql.NullInt64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+
+	// Computed properties
+	CreatorName  string
+	CreatorEmail string
+	LastRun      *ReportRun
+}
+
+// ReportRun records one attempt to deliver a scheduled report
+type ReportRun struct {
+	ID                int
+	ScheduledReportID int
+	PeriodFrom        time.Time
+	PeriodTo          time.Time
+	Manual            bool // Run by a librarian rather than the schedule
+	Succeeded         bool
+	Error             string
+	StartedAt         time.Time
+	FinishedAt        time.Time
+}
+
+// Failing reports whether the report's last delivery failed
+func (s *ScheduledReport) Failing() bool {
+	return s.LastRun != nil && !s.LastRun.Succeeded
+}
+
+// RecipientList splits the recipients into addresses
+func (s *ScheduledReport) RecipientList() []string {
+	return strings.FieldsFunc(s.Recipients, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+}
+
+// Period returns the first and last days a run at the given time reports on: the
+// day before for daily reports, the seven days before for weekly reports and the
+// previous calendar month for monthly reports
+func (s *ScheduledReport) Period(at time.Time) (time.Time, time.Time) {
+	today := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	yesterday := today.AddDate(0, 0, -1)
+	switch s.Frequency {
+	case ScheduleWeekly:
+		return today.AddDate(0, 0, -7), yesterday
+	case ScheduleMonthly:
+		firstOfMonth := today.AddDate(0, 0, 1-today.Day())
+		return firstOfMonth.AddDate(0, -1, 0), firstOfMonth.AddDate(0, 0, -1)
+	default:
+		return yesterday, yesterday
+	}
+}
+
+// NextRunAfter returns when the report is next due after the given time, at the
+// configured delivery hour
+func (s *ScheduledReport) NextRunAfter(t time.Time) time.Time {
+	hour := config.AppConfig.Reports.DeliveryHour
+	if hour < 0 || hour > 23 {
+		hour = 6
+	}
+
+	switch s.Frequency {
+	case ScheduleMonthly:
+		next := time.Date(t.Year(), t.Month(), 1, hour, 0, 0, 0, t.Location())
+		if !next.After(t) {
+			next = next.AddDate(0, 1, 0)
+		}
+		return next
+	case ScheduleWeekly:
+		next := time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, t.Location())
+		for next.Weekday() != time.Monday || !next.After(t) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	default:
+		next := time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, t.Location())
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	}
+}
+
+// validate checks the definition, normalizing the recipient list
+func (s *ScheduledReport) validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return errors.New("report name is required")
+	}
+	if !containsString(ReportTypes, s.ReportType) {
+		return errors.New("unknown report type")
+	}
+	if !export.Valid(s.Format) {
+		return errors.New("unknown report format")
+	}
+	if !containsString(ScheduleFrequencies, s.Frequency) {
+		return errors.New("unknown schedule frequency")
+	}
+	if s.Delivery != DeliveryAttachment && s.Delivery != DeliveryLink {
+		return errors.New("unknown delivery method")
+	}
+
+	recipients := s.RecipientList()
+	if len(recipients) == 0 {
+		return errors.New("at least one recipient is required")
+	}
+	if len(recipients) > maxRecipients {
+		return errors.New("too many recipients")
+	}
+	for i, r := range recipients {
+		address, err := mail.ParseAddress(r)
+		if err != nil {
+			return errors.New("invalid email address: " + r)
+		}
+		recipients[i] = address.Address
+	}
+	s.Recipients = strings.Join(recipients, ", ")
+	return nil
+}
+
+// containsString reports whether the list holds the value
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduledReportColumns lists the columns scanned by scanScheduledReport from
+// scheduled_reports s left joined to users u
+const scheduledReportColumns = `s.id, s.name, s.report_type, s.params, s.format, s.frequency, s.delivery, s.recipients,
+                s.active, s.next_run_at, s.created_by, s.created_at, s.updated_at, COALESCE(u.name, ''), COALESCE(u.email, '')`
+
+// scanScheduledReport reads a row selected with scheduledReportColumns
+func scanScheduledReport(row interface{ Scan(...interface{}) error }) (*ScheduledReport, error) {
+	s := &ScheduledReport{}
+	err := row.Scan(&s.ID, &s.Name, &s.ReportType, &s.Params, &s.Format, &s.Frequency, &s.Delivery, &s.Recipients,
+		&s.Active, &s.NextRunAt, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt, &s.CreatorName, &s.CreatorEmail)
+	return s, err
+}
+
+// GetScheduledReports retrieves every scheduled report with its last run, by name
+func GetScheduledReports() ([]*ScheduledReport, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT ` + scheduledReportColumns + `
+                FROM scheduled_reports s
+                LEFT JOIN users u ON u.id = s.created_by
+                ORDER BY s.name
+        `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var reports []*ScheduledReport
+	for rows.Next() {
+		s, err := scanScheduledReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range reports {
+		if err := s.loadLastRun(); err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
+
+// GetScheduledReportByID retrieves a scheduled report with its last run
+func GetScheduledReportByID(id int) (*ScheduledReport, error) {
+	db := config.GetDB()
+
+	s, err := scanScheduledReport(db.QueryRow(`
+                SELECT `+scheduledReportColumns+`
+                FROM scheduled_reports s
+                LEFT JOIN users u ON u.id = s.created_by
+                WHERE s.id = $1
+        `, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("scheduled report not found")
+		}
+		return nil, err
+	}
+
+	if err := s.loadLastRun(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadLastRun fills in the report's most recent run, if it has run
+func (s *ScheduledReport) loadLastRun() error {
+	runs, err := GetReportRuns(s.ID, 1)
+	if err != nil {
+		return err
+	}
+	s.LastRun = nil
+	if len(runs) > 0 {
+		s.LastRun = runs[0]
+	}
+	return nil
+}
+
+// Save creates or updates the scheduled report, scheduling its next run
+func (s *ScheduledReport) Save() error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	s.NextRunAt = s.NextRunAfter(time.Now())
+
+	db := config.GetDB()
+
+	if s.ID == 0 {
+		s.Active = true
+		return db.QueryRow(`
+                        INSERT INTO scheduled_reports (name, report_type, params, format, frequency, delivery, recipients, next_run_at, created_by)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                        RETURNING id
+                `, s.Name, s.ReportType, s.Params, s.Format, s.Frequency, s.Delivery, s.Recipients, s.NextRunAt, s.CreatedBy).Scan(&s.ID)
+	}
+
+	result, err := db.Exec(`
+                UPDATE scheduled_reports
+                SET name = $2, params = $3, format = $4, frequency = $5, delivery = $6, recipients = $7,
+                        next_run_at = $8, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $1
+        `, s.ID, s.Name, s.Params, s.Format, s.Frequency, s.Delivery, s.Recipients, s.NextRunAt)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("scheduled report not found")
+	}
+	return nil
+}
+
+// SetScheduledReportActive pauses or resumes a scheduled report. A resumed report
+// is next sent at its next regular time rather than catching up.
+func SetScheduledReportActive(id int, active bool) error {
+	s, err := GetScheduledReportByID(id)
+	if err != nil {
+		return err
+	}
+
+	db := config.GetDB()
+
+	_, err = db.Exec(`
+                UPDATE scheduled_reports
+                SET active = $2, next_run_at = $3, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $1
+        `, id, active, s.NextRunAfter(time.Now()))
+	return err
+}
+
+// DeleteScheduledReport removes a scheduled report and its run history
+func DeleteScheduledReport(id int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM scheduled_reports WHERE id = $1", id)
+	return err
+}
+
+// ClaimDueScheduledReports retrieves the active reports due by now and moves each to
+// its next run time, so a report is sent once even if several schedulers run
+func ClaimDueScheduledReports(now time.Time) ([]*ScheduledReport, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Execute query
+	rows, err := tx.Query(`
+                SELECT `+scheduledReportColumns+`
+                FROM scheduled_reports s
+                LEFT JOIN users u ON u.id = s.created_by
+                WHERE s.active AND s.next_run_at <= $1
+                ORDER BY s.next_run_at
+                FOR UPDATE OF s SKIP LOCKED
+        `, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse rows
+	var due []*ScheduledReport
+	for rows.Next() {
+		s, err := scanScheduledReport(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range due {
+		s.NextRunAt = s.NextRunAfter(now)
+		if _, err := tx.Exec("UPDATE scheduled_reports SET next_run_at = $2 WHERE id = $1", s.ID, s.NextRunAt); err != nil {
+			return nil, err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// RecordReportRun saves the outcome of a delivery attempt
+func RecordReportRun(run *ReportRun) error {
+	db := config.GetDB()
+
+	return db.QueryRow(`
+                INSERT INTO scheduled_report_runs (scheduled_report_id, period_from, period_to, manual, succeeded, error, started_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
+                RETURNING id, finished_at
+        `, run.ScheduledReportID, run.PeriodFrom, run.PeriodTo, run.Manual, run.Succeeded, run.Error, run.StartedAt).Scan(&run.ID, &run.FinishedAt)
+}
+
+// GetReportRuns retrieves a scheduled report's most recent runs, newest first
+func GetReportRuns(scheduledReportID, limit int) ([]*ReportRun, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id, scheduled_report_id, period_from, period_to, manual, succeeded, error, started_at, finished_at
+                FROM scheduled_report_runs
+                WHERE scheduled_report_id = $1
+                ORDER BY started_at DESC, id DESC
+                LIMIT $2
+        `, scheduledReportID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var runs []*ReportRun
+	for rows.Next() {
+		r := &ReportRun{}
+		if err := rows.Scan(&r.ID, &r.ScheduledReportID, &r.PeriodFrom, &r.PeriodTo, &r.Manual, &r.Succeeded,
+			&r.Error, &r.StartedAt, &r.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// CountFailingScheduledReports counts active scheduled reports whose last run failed
+func CountFailingScheduledReports() (int, error) {
+	db := config.GetDB()
+
+	var count int
+	err := db.QueryRow(`
+                SELECT COUNT(*)
+                FROM scheduled_reports s
+                WHERE s.active AND NOT COALESCE((
+                        SELECT r.succeeded FROM scheduled_report_runs r
+                        WHERE r.scheduled_report_id = s.id
+                        ORDER BY r.started_at DESC, r.id DESC
+                        LIMIT 1
+                ), TRUE)
+        `).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,753 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+	"library-management-system/isbn"
+)
+
+// Serial issue statuses
+const (
+	IssueStatusExpected = "expected" // Predicted and not yet arrived
+	IssueStatusReceived = "received" // Checked in and catalogued for lending
+	IssueStatusClaimed  = "claimed"  // Late and claimed from the publisher
+	IssueStatusMissing  = "missing"  // Given up on; no further claims
+)
+
+// Frequency is a publication pattern: how far apart issues are expected
+type Frequency struct {
+	Code   string
+	Label  string
+	Months int
+	Days   int
+}
+
+// Frequencies lists the supported publication patterns
+var Frequencies = []Frequency{
+	{Code: "weekly", Label: "Weekly", Days: 7},
+	{Code: "fortnightly", Label: "Fortnightly", Days: 14},
+	{Code: "monthly", Label: "Monthly", Months: 1},
+	{Code: "bimonthly", Label: "Every two months", Months: 2},
+	{Code: "quarterly", Label: "Quarterly", Months: 3},
+	{Code: "semiannual", Label: "Twice a year", Months: 6},
+	{Code: "annual", Label: "Annual", Months: 12},
+}
+
+// GetFrequency looks up a publication pattern by its code
+func GetFrequency(code string) (Frequency, bool) {
+	for _, f := range Frequencies {
+		if f.Code == code {
+			return f, true
+		}
+	}
+	return Frequency{}, false
+}
+
+// issueDate returns the date the nth issue after one due on start is expected. It is
+// counted from start rather than from the previous issue so month ends do not drift:
+// monthly issues from Jan 31 fall on Feb 28, Mar 31, Apr 30 and so on.
+func (f Frequency) issueDate(start time.Time, n int) time.Time {
+	date := start
+	if f.Months != 0 {
+		first := time.Date(start.Year(), start.Month()+time.Month(n*f.Months), 1, 0, 0, 0, 0, start.Location())
+		day := start.Day()
+		if lastDay := first.AddDate(0, 1, -1).Day(); day > lastDay {
+			day = lastDay
+		}
+		date = first.AddDate(0, 0, day-1)
+	}
+	return date.AddDate(0, 0, n*f.Days)
+}
+
+// predictionHorizon is how far ahead issues are predicted
+const predictionHorizon = 60 * 24 * time.Hour
+
+// Serial is a journal or magazine the library subscribes to
+type Serial struct {
+	ID              int
+	Title           string
+	ISSN            string // Hyphenated, e.g. "0028-0836"; empty if unknown
+	Publisher       string
+	Frequency       string
+	IssuesPerVolume int
+	StartDate       time.Time // Expected date of the first predicted issue
+	StartVolume     int
+	StartNumber     int
+	CopiesPerIssue  int
+	ClaimAfterDays  int // Grace period after the expected date before an issue is claimed
+	CallNumber      string
+	Active          bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+
+	// Computed properties
+	Issues        []*SerialIssue
+	ReceivedCount int
+	LateCount     int
+}
+
+// SerialIssue is one predicted or received issue of a serial
+type SerialIssue struct {
+	ID           int
+	SerialID     int
+	Volume       int
+	Number       int
+	ExpectedDate time.Time
+	Status       string
+	ReceivedAt   NullTime
+	ClaimedAt    NullTime
+	ClaimCount   int
+	BookID       sql.NullInt64 // Catalog record the issue circulates as, once received
+	CreatedAt    time.Time
+
+	// Computed properties
+	Serial *Serial
+	Book   *Book
+}
+
+// FrequencyLabel returns the readable name of the serial's publication pattern
+func (s *Serial) FrequencyLabel() string {
+	if f, ok := GetFrequency(s.Frequency); ok {
+		return f.Label
+	}
+	return s.Frequency
+}
+
+// Label returns the issue's designation, e.g. "Vol. 12, No. 3 (March 2026)"
+func (i *SerialIssue) Label() string {
+	return fmt.Sprintf("Vol. %d, No. %d (%s)", i.Volume, i.Number, i.ExpectedDate.Format("January 2006"))
+}
+
+// Late reports whether an issue that has not arrived is past its claim date
+func (i *SerialIssue) Late(claimAfterDays int) bool {
+	if i.Status != IssueStatusExpected && i.Status != IssueStatusClaimed {
+		return false
+	}
+	return time.Now().After(i.ExpectedDate.AddDate(0, 0, claimAfterDays))
+}
+
+// IsLate reports whether the issue is past its serial's claim date
+func (i *SerialIssue) IsLate() bool {
+	return i.Serial != nil && i.Late(i.Serial.ClaimAfterDays)
+}
+
+// Barcode returns the identifier a received issue is catalogued and scanned under:
+// the ISSN, or the serial's ID when it has none, followed by the volume and number
+func (i *SerialIssue) Barcode(s *Serial) string {
+	base := "S" + strconv.Itoa(s.ID)
+	if s.ISSN != "" {
+		base = strings.ReplaceAll(s.ISSN, "-", "")
+	}
+	return fmt.Sprintf("%sV%dN%d", base, i.Volume, i.Number)
+}
+
+// validate checks and normalizes a serial before it is saved
+func (s *Serial) validate() error {
+	s.Title = strings.TrimSpace(s.Title)
+	if s.Title == "" {
+		return errors.New("serial title is required")
+	}
+	if strings.TrimSpace(s.ISSN) != "" {
+		issn, err := isbn.NormalizeISSN(s.ISSN)
+		if err != nil {
+			return err
+		}
+		s.ISSN = issn
+	} else {
+		s.ISSN = ""
+	}
+	if _, ok := GetFrequency(s.Frequency); !ok {
+		return errors.New("unknown publication frequency")
+	}
+	if s.StartDate.IsZero() {
+		return errors.New("the date of the first expected issue is required")
+	}
+	if s.IssuesPerVolume < 1 {
+		s.IssuesPerVolume = 1
+	}
+	if s.StartVolume < 1 {
+		s.StartVolume = 1
+	}
+	if s.StartNumber < 1 {
+		s.StartNumber = 1
+	}
+	if s.CopiesPerIssue < 1 {
+		s.CopiesPerIssue = 1
+	}
+	if s.ClaimAfterDays < 0 {
+		s.ClaimAfterDays = 0
+	}
+	return nil
+}
+
+// serialColumns lists the columns scanned by scanSerial
+const serialColumns = `id, title, issn, publisher, frequency, issues_per_volume, start_date, start_volume,
+                start_number, copies_per_issue, claim_after_days, call_number, active, created_at, updated_at`
+
+// scanSerial reads a row selected with serialColumns
+func scanSerial(row interface{ Scan(...interface{}) error }, s *Serial) error {
+	return row.Scan(&s.ID, &s.Title, &s.ISSN, &s.Publisher, &s.Frequency, &s.IssuesPerVolume, &s.StartDate,
+		&s.StartVolume, &s.StartNumber, &s.CopiesPerIssue, &s.ClaimAfterDays, &s.CallNumber, &s.Active,
+		&s.CreatedAt, &s.UpdatedAt)
+}
+
+// GetAllSerials retrieves all serials with their received and late issue counts
+func GetAllSerials(search string) ([]*Serial, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT `+serialColumns+`,
+                        (SELECT COUNT(*) FROM serial_issues i WHERE i.serial_id = serials.id AND i.status = $2),
+                        (SELECT COUNT(*) FROM serial_issues i WHERE i.serial_id = serials.id AND i.status IN ($3, $4)
+                                AND i.expected_date + serials.claim_after_days < CURRENT_DATE)
+                FROM serials
+                WHERE $1 = '' OR title ILIKE '%' || $1 || '%' OR REPLACE(issn, '-', '') = REPLACE(UPPER($1), '-', '')
+                ORDER BY active DESC, LOWER(title)
+        `, search, IssueStatusReceived, IssueStatusExpected, IssueStatusClaimed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var serials []*Serial
+	for rows.Next() {
+		s := &Serial{}
+		err := rows.Scan(&s.ID, &s.Title, &s.ISSN, &s.Publisher, &s.Frequency, &s.IssuesPerVolume, &s.StartDate,
+			&s.StartVolume, &s.StartNumber, &s.CopiesPerIssue, &s.ClaimAfterDays, &s.CallNumber, &s.Active,
+			&s.CreatedAt, &s.UpdatedAt, &s.ReceivedCount, &s.LateCount)
+		if err != nil {
+			return nil, err
+		}
+		serials = append(serials, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return serials, nil
+}
+
+// GetSerialByID retrieves a serial with its issues, newest first
+func GetSerialByID(id int) (*Serial, error) {
+	db := config.GetDB()
+
+	s := &Serial{}
+	err := scanSerial(db.QueryRow("SELECT "+serialColumns+" FROM serials WHERE id = $1", id), s)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("serial not found")
+		}
+		return nil, err
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id, serial_id, volume, number, expected_date, status, received_at, claimed_at,
+                        claim_count, book_id, created_at
+                FROM serial_issues
+                WHERE serial_id = $1
+                ORDER BY expected_date DESC, volume DESC, number DESC
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	for rows.Next() {
+		issue := &SerialIssue{Serial: s}
+		err := rows.Scan(&issue.ID, &issue.SerialID, &issue.Volume, &issue.Number, &issue.ExpectedDate,
+			&issue.Status, &issue.ReceivedAt, &issue.ClaimedAt, &issue.ClaimCount, &issue.BookID, &issue.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		s.Issues = append(s.Issues, issue)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Load the catalog records of received issues for their availability
+	for _, issue := range s.Issues {
+		switch {
+		case issue.Status == IssueStatusReceived:
+			s.ReceivedCount++
+		case issue.IsLate():
+			s.LateCount++
+		}
+		if issue.BookID.Valid {
+			issue.Book, _ = GetBookByID(int(issue.BookID.Int64))
+		}
+	}
+
+	return s, nil
+}
+
+// GetBookSerialIssue retrieves the serial issue a book record was created for, or
+// nil if the book is not a periodical issue
+func GetBookSerialIssue(bookID int) (*SerialIssue, error) {
+	db := config.GetDB()
+
+	issue := &SerialIssue{}
+	err := db.QueryRow(`
+                SELECT id, serial_id, volume, number, expected_date, status, received_at, claimed_at,
+                        claim_count, book_id, created_at
+                FROM serial_issues
+                WHERE book_id = $1
+        `, bookID).Scan(&issue.ID, &issue.SerialID, &issue.Volume, &issue.Number, &issue.ExpectedDate,
+		&issue.Status, &issue.ReceivedAt, &issue.ClaimedAt, &issue.ClaimCount, &issue.BookID, &issue.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	issue.Serial = &Serial{}
+	err = scanSerial(db.QueryRow("SELECT "+serialColumns+" FROM serials WHERE id = $1", issue.SerialID), issue.Serial)
+	if err != nil {
+		return nil, err
+	}
+
+	return issue, nil
+}
+
+// Create saves a new serial and predicts its first issues
+func (s *Serial) Create() error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	db := config.GetDB()
+
+	err := db.QueryRow(`
+                INSERT INTO serials (title, issn, publisher, frequency, issues_per_volume, start_date,
+                        start_volume, start_number, copies_per_issue, claim_after_days, call_number, active)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+                RETURNING id, created_at, updated_at
+        `, s.Title, s.ISSN, s.Publisher, s.Frequency, s.IssuesPerVolume, s.StartDate, s.StartVolume,
+		s.StartNumber, s.CopiesPerIssue, s.ClaimAfterDays, s.CallNumber, s.Active).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = PredictSerialIssues(s.ID)
+	return err
+}
+
+// Update saves changes to a serial. A changed pattern applies to issues predicted
+// from now on; issues already predicted keep their dates.
+func (s *Serial) Update() error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                UPDATE serials
+                SET title = $1, issn = $2, publisher = $3, frequency = $4, issues_per_volume = $5,
+                        start_date = $6, start_volume = $7, start_number = $8, copies_per_issue = $9,
+                        claim_after_days = $10, call_number = $11, active = $12, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $13
+        `, s.Title, s.ISSN, s.Publisher, s.Frequency, s.IssuesPerVolume, s.StartDate, s.StartVolume,
+		s.StartNumber, s.CopiesPerIssue, s.ClaimAfterDays, s.CallNumber, s.Active, s.ID)
+
+	return err
+}
+
+// DeleteSerial removes a serial and its issue records. Issues already received stay
+// in the catalog as ordinary books.
+func DeleteSerial(id int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM serials WHERE id = $1", id)
+
+	return err
+}
+
+// PredictSerialIssues adds the expected issues of a serial up to the prediction
+// horizon, continuing the volume and number sequence from the latest issue
+func PredictSerialIssues(serialID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	s := &Serial{}
+	err = scanSerial(tx.QueryRow("SELECT "+serialColumns+" FROM serials WHERE id = $1 FOR UPDATE", serialID), s)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("serial not found")
+		}
+		return 0, err
+	}
+	if !s.Active {
+		return 0, nil
+	}
+	freq, ok := GetFrequency(s.Frequency)
+	if !ok {
+		return 0, errors.New("unknown publication frequency")
+	}
+
+	// Continue after the latest issue, or start from the subscription's first issue.
+	// Dates are counted in issues from the start date.
+	start, n, volume, number := s.StartDate, 0, s.StartVolume, s.StartNumber
+	var last SerialIssue
+	err = tx.QueryRow(`
+                SELECT volume, number, expected_date FROM serial_issues
+                WHERE serial_id = $1
+                ORDER BY expected_date DESC, volume DESC, number DESC
+                LIMIT 1
+        `, serialID).Scan(&last.Volume, &last.Number, &last.ExpectedDate)
+	switch {
+	case err == nil:
+		n = (last.Volume-s.StartVolume)*s.IssuesPerVolume + last.Number - s.StartNumber + 1
+		if n < 1 {
+			// Issues numbered before the subscription start continue from the latest
+			start, n = last.ExpectedDate, 1
+		}
+		volume, number = last.Volume, last.Number+1
+		if number > s.IssuesPerVolume {
+			volume, number = volume+1, 1
+		}
+	case err != sql.ErrNoRows:
+		return 0, err
+	}
+
+	// Insert the issues due before the horizon
+	horizon := time.Now().Add(predictionHorizon)
+	added := 0
+	for date := freq.issueDate(start, n); !date.After(horizon); date = freq.issueDate(start, n) {
+		result, err := tx.Exec(`
+                        INSERT INTO serial_issues (serial_id, volume, number, expected_date, status)
+                        VALUES ($1, $2, $3, $4, $5)
+                        ON CONFLICT (serial_id, volume, number) DO NOTHING
+                `, serialID, volume, number, date, IssueStatusExpected)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+		}
+
+		n++
+		if number++; number > s.IssuesPerVolume {
+			volume, number = volume+1, 1
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return added, nil
+}
+
+// PredictAllSerialIssues predicts upcoming issues for every active serial
+func PredictAllSerialIssues() error {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query("SELECT id FROM serials WHERE active")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if _, err := PredictSerialIssues(id); err != nil {
+			return fmt.Errorf("serial %d: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// AddSerialIssue records an issue outside the predicted pattern, such as a special
+// or supplementary issue
+func AddSerialIssue(serialID, volume, number int, expected time.Time) error {
+	if volume < 1 || number < 1 {
+		return errors.New("volume and number must be positive")
+	}
+	if expected.IsZero() {
+		return errors.New("the issue date is required")
+	}
+
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                INSERT INTO serial_issues (serial_id, volume, number, expected_date, status)
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (serial_id, volume, number) DO NOTHING
+        `, serialID, volume, number, expected, IssueStatusExpected)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("volume %d, number %d is already recorded", volume, number)
+	}
+
+	return nil
+}
+
+// CheckInIssue records the arrival of an issue and catalogs it as a book with the
+// given number of copies, so it circulates through the normal borrow and
+// reservation workflow. It returns the new book's ID.
+func CheckInIssue(issueID, copies, userID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	issue := &SerialIssue{}
+	err = tx.QueryRow(`
+                SELECT id, serial_id, volume, number, expected_date, status
+                FROM serial_issues WHERE id = $1
+                FOR UPDATE
+        `, issueID).Scan(&issue.ID, &issue.SerialID, &issue.Volume, &issue.Number, &issue.ExpectedDate, &issue.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("issue not found")
+		}
+		return 0, err
+	}
+	if issue.Status == IssueStatusReceived {
+		return 0, errors.New("this issue has already been checked in")
+	}
+
+	s := &Serial{}
+	if err := scanSerial(tx.QueryRow("SELECT "+serialColumns+" FROM serials WHERE id = $1", issue.SerialID), s); err != nil {
+		return 0, err
+	}
+	if copies < 1 {
+		copies = s.CopiesPerIssue
+	}
+
+	// Catalog the issue as a book
+	var bookID int
+	err = tx.QueryRow(`
+                INSERT INTO books (title, author, isbn, publisher, publication_year, category, description,
+                        quantity, available, added_by, call_number, call_number_sort)
+                VALUES ($1, '', $2, $3, $4, 'Periodicals', $5, $6, $6, $7, $8, $9)
+                RETURNING id
+        `,
+		s.Title+", "+issue.Label(),
+		issue.Barcode(s),
+		s.Publisher,
+		issue.ExpectedDate.Year(),
+		"Issue of "+s.Title,
+		copies,
+		sql.NullInt64{Int64: int64(userID), Valid: userID > 0},
+		s.CallNumber,
+		CallNumberSortKey(s.CallNumber),
+	).Scan(&bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+                UPDATE serial_issues SET status = $1, received_at = $2, book_id = $3
+                WHERE id = $4
+        `, IssueStatusReceived, time.Now(), bookID, issueID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return bookID, nil
+}
+
+// MarkIssueMissing stops claiming an issue that will not arrive
+func MarkIssueMissing(issueID int) error {
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                UPDATE serial_issues SET status = $1
+                WHERE id = $2 AND status IN ($3, $4)
+        `, IssueStatusMissing, issueID, IssueStatusExpected, IssueStatusClaimed)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("only issues that have not arrived can be marked missing")
+	}
+
+	return nil
+}
+
+// Claim is a claim letter to a publisher for late issues of one serial
+type Claim struct {
+	Serial *Serial
+	Issues []*SerialIssue
+}
+
+// Letter returns the text of the claim
+func (c *Claim) Letter() string {
+	to := c.Serial.Publisher
+	if to == "" {
+		to = "Subscriptions department"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "To: %s\n", to)
+	fmt.Fprintf(&b, "Re: %s", c.Serial.Title)
+	if c.Serial.ISSN != "" {
+		fmt.Fprintf(&b, " (ISSN %s)", c.Serial.ISSN)
+	}
+	b.WriteString("\n\nThe following issues of our subscription have not been received. Please supply them or let us know when they will be published.\n\n")
+	for _, issue := range c.Issues {
+		fmt.Fprintf(&b, "  - %s, expected %s", issue.Label(), issue.ExpectedDate.Format("2 January 2006"))
+		if issue.ClaimCount > 1 {
+			fmt.Fprintf(&b, " (claim %d)", issue.ClaimCount)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// GetClaimableIssues lists late issues that are due a claim: never claimed, or last
+// claimed longer ago than the serial's claim period. Issues are grouped by serial.
+func GetClaimableIssues() ([]*Claim, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT i.id, i.serial_id, i.volume, i.number, i.expected_date, i.status, i.received_at,
+                        i.claimed_at, i.claim_count, i.book_id, i.created_at
+                FROM serial_issues i
+                JOIN serials s ON s.id = i.serial_id
+                WHERE i.status IN ($1, $2)
+                        AND i.expected_date + s.claim_after_days < CURRENT_DATE
+                        AND (i.claimed_at IS NULL OR i.claimed_at::date + GREATEST(s.claim_after_days, 1) <= CURRENT_DATE)
+                ORDER BY LOWER(s.title), i.expected_date
+        `, IssueStatusExpected, IssueStatusClaimed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var issues []*SerialIssue
+	for rows.Next() {
+		issue := &SerialIssue{}
+		err := rows.Scan(&issue.ID, &issue.SerialID, &issue.Volume, &issue.Number, &issue.ExpectedDate,
+			&issue.Status, &issue.ReceivedAt, &issue.ClaimedAt, &issue.ClaimCount, &issue.BookID, &issue.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groupClaims(issues)
+}
+
+// groupClaims groups issues into one claim per serial, keeping their order
+func groupClaims(issues []*SerialIssue) ([]*Claim, error) {
+	db := config.GetDB()
+
+	var claims []*Claim
+	bySerial := make(map[int]*Claim)
+	for _, issue := range issues {
+		claim, ok := bySerial[issue.SerialID]
+		if !ok {
+			s := &Serial{}
+			if err := scanSerial(db.QueryRow("SELECT "+serialColumns+" FROM serials WHERE id = $1", issue.SerialID), s); err != nil {
+				return nil, err
+			}
+			claim = &Claim{Serial: s}
+			bySerial[issue.SerialID] = claim
+			claims = append(claims, claim)
+		}
+		issue.Serial = claim.Serial
+		claim.Issues = append(claim.Issues, issue)
+	}
+
+	return claims, nil
+}
+
+// ClaimIssues marks the given late issues as claimed and returns the claims to send
+func ClaimIssues(issueIDs []int) ([]*Claim, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var issues []*SerialIssue
+	for _, id := range issueIDs {
+		issue := &SerialIssue{}
+		err := tx.QueryRow(`
+                        UPDATE serial_issues
+                        SET status = $1, claimed_at = $2, claim_count = claim_count + 1
+                        WHERE id = $3 AND status IN ($4, $1)
+                        RETURNING id, serial_id, volume, number, expected_date, status, received_at,
+                                claimed_at, claim_count, book_id, created_at
+                `, IssueStatusClaimed, time.Now(), id, IssueStatusExpected).Scan(&issue.ID, &issue.SerialID,
+			&issue.Volume, &issue.Number, &issue.ExpectedDate, &issue.Status, &issue.ReceivedAt,
+			&issue.ClaimedAt, &issue.ClaimCount, &issue.BookID, &issue.CreatedAt)
+		if err == sql.ErrNoRows {
+			// Arrived or written off since the list was shown
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return groupClaims(issues)
+}
@@ -0,0 +1,534 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Series groups books that are read in order. A multi-volume set is a series whose
+// volumes circulate together and can be borrowed or reserved as one.
+type Series struct {
+	ID          int
+	Name        string
+	Description string
+	IsSet       bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	// Computed properties
+	Volumes     []*SeriesVolume
+	VolumeCount int
+}
+
+// SeriesVolume is a book's place in a series
+type SeriesVolume struct {
+	SeriesID int
+	Position int
+	Volume   string // Printed volume designation, e.g. "III" or "2a"
+	Book     *Book
+}
+
+// SeriesMembership describes a book's place in one series, with its neighbours
+type SeriesMembership struct {
+	Series   *Series
+	Position int
+	Volume   string
+	Previous *Book
+	Next     *Book
+}
+
+// Label returns the printed volume designation, or the position if there is none
+func (v *SeriesVolume) Label() string {
+	if v.Volume != "" {
+		return v.Volume
+	}
+	return strconv.Itoa(v.Position)
+}
+
+// Label returns the printed volume designation, or the position if there is none
+func (m *SeriesMembership) Label() string {
+	if m.Volume != "" {
+		return m.Volume
+	}
+	return strconv.Itoa(m.Position)
+}
+
+// AllVolumesAvailable reports whether every volume has a copy on the shelf
+func (s *Series) AllVolumesAvailable() bool {
+	for _, v := range s.Volumes {
+		if v.Book.Available <= 0 {
+			return false
+		}
+	}
+	return len(s.Volumes) > 0
+}
+
+// GetAllSeries retrieves all series with their volume counts
+func GetAllSeries(search string) ([]*Series, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT s.id, s.name, s.description, s.is_set, s.created_at, s.updated_at, COUNT(sb.book_id)
+                FROM series s
+                LEFT JOIN series_books sb ON sb.series_id = s.id
+                WHERE $1 = '' OR s.name ILIKE '%' || $1 || '%'
+                GROUP BY s.id
+                ORDER BY LOWER(s.name)
+        `, search)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var list []*Series
+	for rows.Next() {
+		s := &Series{}
+		err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.IsSet, &s.CreatedAt, &s.UpdatedAt, &s.VolumeCount)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// GetSeriesByID retrieves a series with its volumes in order
+func GetSeriesByID(id int) (*Series, error) {
+	db := config.GetDB()
+
+	s := &Series{}
+	err := db.QueryRow(`
+                SELECT id, name, description, is_set, created_at, updated_at
+                FROM series WHERE id = $1
+        `, id).Scan(&s.ID, &s.Name, &s.Description, &s.IsSet, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("series not found")
+		}
+		return nil, err
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT book_id, position, volume FROM series_books
+                WHERE series_id = $1
+                ORDER BY position, book_id
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var bookIDs []int
+	for rows.Next() {
+		v := &SeriesVolume{SeriesID: id}
+		var bookID int
+		if err := rows.Scan(&bookID, &v.Position, &v.Volume); err != nil {
+			return nil, err
+		}
+		bookIDs = append(bookIDs, bookID)
+		s.Volumes = append(s.Volumes, v)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, bookID := range bookIDs {
+		book, err := GetBookByID(bookID)
+		if err != nil {
+			return nil, err
+		}
+		s.Volumes[i].Book = book
+	}
+	s.VolumeCount = len(s.Volumes)
+
+	return s, nil
+}
+
+// GetBookSeries retrieves the series a book belongs to, with the previous and next
+// volumes in each
+func GetBookSeries(bookID int) ([]*SeriesMembership, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT s.id, s.name, s.is_set, sb.position, sb.volume,
+                        (SELECT p.book_id FROM series_books p
+                         WHERE p.series_id = s.id AND p.position < sb.position
+                         ORDER BY p.position DESC LIMIT 1),
+                        (SELECT n.book_id FROM series_books n
+                         WHERE n.series_id = s.id AND n.position > sb.position
+                         ORDER BY n.position LIMIT 1)
+                FROM series_books sb
+                JOIN series s ON s.id = sb.series_id
+                WHERE sb.book_id = $1
+                ORDER BY LOWER(s.name)
+        `, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var memberships []*SeriesMembership
+	var neighbours [][2]sql.NullInt64
+	for rows.Next() {
+		m := &SeriesMembership{Series: &Series{}}
+		var prev, next sql.NullInt64
+		err := rows.Scan(&m.Series.ID, &m.Series.Name, &m.Series.IsSet, &m.Position, &m.Volume, &prev, &next)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+		neighbours = append(neighbours, [2]sql.NullInt64{prev, next})
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, m := range memberships {
+		if prev := neighbours[i][0]; prev.Valid {
+			m.Previous, _ = GetBookByID(int(prev.Int64))
+		}
+		if next := neighbours[i][1]; next.Valid {
+			m.Next, _ = GetBookByID(int(next.Int64))
+		}
+	}
+
+	return memberships, nil
+}
+
+// Create saves a new series
+func (s *Series) Create() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return errors.New("series name is required")
+	}
+
+	db := config.GetDB()
+
+	return db.QueryRow(`
+                INSERT INTO series (name, description, is_set)
+                VALUES ($1, $2, $3)
+                RETURNING id, created_at, updated_at
+        `, s.Name, s.Description, s.IsSet).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// Update saves changes to a series
+func (s *Series) Update() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return errors.New("series name is required")
+	}
+
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                UPDATE series
+                SET name = $1, description = $2, is_set = $3, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $4
+        `, s.Name, s.Description, s.IsSet, s.ID)
+
+	return err
+}
+
+// DeleteSeries removes a series; its books stay in the catalog
+func DeleteSeries(id int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM series WHERE id = $1", id)
+
+	return err
+}
+
+// AddSeriesVolume places a book in a series, or moves it if it is already there.
+// A position of zero appends the book after the last volume.
+func AddSeriesVolume(seriesID, bookID, position int, volume string) error {
+	db := config.GetDB()
+
+	if position <= 0 {
+		err := db.QueryRow(`
+                        SELECT COALESCE(MAX(position), 0) + 1 FROM series_books
+                        WHERE series_id = $1 AND book_id <> $2
+                `, seriesID, bookID).Scan(&position)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(`
+                INSERT INTO series_books (series_id, book_id, position, volume)
+                VALUES ($1, $2, $3, $4)
+                ON CONFLICT (series_id, book_id) DO UPDATE
+                SET position = EXCLUDED.position, volume = EXCLUDED.volume
+        `, seriesID, bookID, position, strings.TrimSpace(volume))
+
+	return err
+}
+
+// RemoveSeriesVolume takes a book out of a series
+func RemoveSeriesVolume(seriesID, bookID int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM series_books WHERE series_id = $1 AND book_id = $2", seriesID, bookID)
+
+	return err
+}
+
+// setVolume is a volume of a multi-volume set locked for a set transaction
+type setVolume struct {
+	BookID    int
+	Title     string
+	Available int
+}
+
+// lockSetVolumes checks that a series is a multi-volume set and locks its volumes
+func lockSetVolumes(tx *sql.Tx, seriesID int) ([]setVolume, error) {
+	var isSet bool
+	err := tx.QueryRow("SELECT is_set FROM series WHERE id = $1", seriesID).Scan(&isSet)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("series not found")
+		}
+		return nil, err
+	}
+	if !isSet {
+		return nil, errors.New("only multi-volume sets can be borrowed or reserved as a whole")
+	}
+
+	// Execute query
+	rows, err := tx.Query(`
+                SELECT b.id, b.title, b.available
+                FROM series_books sb
+                JOIN books b ON b.id = sb.book_id
+                WHERE sb.series_id = $1
+                ORDER BY sb.position
+                FOR UPDATE OF b
+        `, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var volumes []setVolume
+	for rows.Next() {
+		var v setVolume
+		if err := rows.Scan(&v.BookID, &v.Title, &v.Available); err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, v)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(volumes) == 0 {
+		return nil, errors.New("this set has no volumes")
+	}
+
+	return volumes, nil
+}
+
+// BorrowSet requests every volume of a multi-volume set in one transaction. Either
+// all volumes are requested or none are. The requests share a set request ID, the
+// ID of the first, so they are approved or rejected together.
+func BorrowSet(userID, seriesID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	volumes, err := lockSetVolumes(tx, seriesID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Every volume must be on the shelf
+	var missing []string
+	for _, v := range volumes {
+		if v.Available <= 0 {
+			missing = append(missing, v.Title)
+		}
+	}
+	if len(missing) > 0 {
+		return 0, errors.New("not every volume is available (" + strings.Join(missing, ", ") + "); reserve the set instead")
+	}
+
+	// The patron must not already have a request or loan for any volume
+	for _, v := range volumes {
+		var count int
+		err := tx.QueryRow(`
+                        SELECT COUNT(*) FROM borrows
+                        WHERE user_id = $1 AND book_id = $2 AND status IN ($3, $4)
+                `, userID, v.BookID, BorrowStatusPending, BorrowStatusApproved).Scan(&count)
+		if err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			return 0, errors.New("you already have a request or loan for " + v.Title)
+		}
+	}
+
+	// The whole set counts towards the loan limit
+	if limit := config.AppConfig.Circulation.MaxActiveLoans; limit > 0 {
+		var active int
+		err := tx.QueryRow(`
+                        SELECT COUNT(*) FROM borrows
+                        WHERE user_id = $1 AND status IN ($2, $3)
+                `, userID, BorrowStatusPending, BorrowStatusApproved).Scan(&active)
+		if err != nil {
+			return 0, err
+		}
+		if active+len(volumes) > limit {
+			return 0, fmt.Errorf("borrowing all %d volumes would exceed your loan limit of %d", len(volumes), limit)
+		}
+	}
+
+	// Create a borrow request for each volume, tied together by the first one's ID
+	var setRequestID sql.NullInt64
+	for _, v := range volumes {
+		var id int
+		err := tx.QueryRow(`
+                        INSERT INTO borrows (user_id, book_id, status, set_request_id)
+                        VALUES ($1, $2, $3, $4)
+                        RETURNING id
+                `, userID, v.BookID, BorrowStatusPending, setRequestID).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		if !setRequestID.Valid {
+			setRequestID = sql.NullInt64{Int64: int64(id), Valid: true}
+		}
+	}
+	_, err = tx.Exec("UPDATE borrows SET set_request_id = id WHERE id = $1", setRequestID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(volumes), nil
+}
+
+// approveSetRequest lends every volume of a set request in the caller's transaction.
+// The volumes are locked first, and if any of them cannot be lent none are, so a
+// patron never leaves with part of a set.
+func approveSetRequest(tx *sql.Tx, setRequestID, approverID int, dueDate time.Time) error {
+	// Execute query
+	rows, err := tx.Query(`
+                SELECT br.id, b.id, b.title, b.available
+                FROM borrows br
+                JOIN books b ON b.id = br.book_id
+                WHERE br.set_request_id = $1 AND br.status = $2
+                ORDER BY br.id
+                FOR UPDATE
+        `, setRequestID, BorrowStatusPending)
+	if err != nil {
+		return err
+	}
+
+	// Parse rows
+	type setRequest struct {
+		borrowID int
+		volume   setVolume
+	}
+	var requests []setRequest
+	for rows.Next() {
+		var req setRequest
+		if err := rows.Scan(&req.borrowID, &req.volume.BookID, &req.volume.Title, &req.volume.Available); err != nil {
+			rows.Close()
+			return err
+		}
+		requests = append(requests, req)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Every volume must be on the shelf
+	var missing []string
+	for _, req := range requests {
+		if req.volume.Available <= 0 {
+			missing = append(missing, req.volume.Title)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New("not every volume of the set can be lent (" + strings.Join(missing, ", ") + "); the set is lent whole or not at all")
+	}
+
+	for _, req := range requests {
+		if err := lendRequest(tx, req.borrowID, req.volume.BookID, approverID, dueDate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReserveSet reserves every volume of a multi-volume set that is currently out, in
+// one transaction. Volumes on the shelf are left to be borrowed directly.
+func ReserveSet(userID, seriesID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	volumes, err := lockSetVolumes(tx, seriesID)
+	if err != nil {
+		return 0, err
+	}
+
+	reserved := 0
+	for _, v := range volumes {
+		if v.Available > 0 {
+			continue
+		}
+		if err := reserveBookTx(tx, userID, v.BookID, false); err != nil {
+			return 0, errors.New(v.Title + ": " + err.Error())
+		}
+		reserved++
+	}
+	if reserved == 0 {
+		return 0, errors.New("every volume is available; borrow the set instead")
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return reserved, nil
+}
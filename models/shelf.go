@@ -0,0 +1,367 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Shelf kinds; every patron has the built-in shelves, and can add lists of their own
+const (
+	ShelfKindWantToRead = "want_to_read"
+	ShelfKindFavourites = "favourites"
+	ShelfKindCustom     = "custom"
+)
+
+// defaultShelves names the built-in shelves created for each patron
+var defaultShelves = []struct{ Kind, Name string }{
+	{ShelfKindWantToRead, "Want to Read"},
+	{ShelfKindFavourites, "Favourites"},
+}
+
+// Shelf is a patron's personal shelf or reading list. Public lists can be viewed
+// and exported by anyone with the link.
+type Shelf struct {
+	ID          int
+	UserID      int
+	Name        string
+	Description string
+	Kind        string
+	Public      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	// Computed properties
+	OwnerName string
+	ItemCount int
+	Items     []*ShelfItem
+}
+
+// ShelfItem is a book on a shelf with the owner's note about it
+type ShelfItem struct {
+	ShelfID int
+	BookID  int
+	Note    string
+	AddedAt time.Time
+
+	// Computed properties
+	Book *Book
+}
+
+// BuiltIn reports whether the shelf is one every patron has; built-in shelves
+// cannot be renamed or deleted
+func (s *Shelf) BuiltIn() bool {
+	return s.Kind != ShelfKindCustom
+}
+
+// shelfColumns lists the columns scanned by scanShelf
+const shelfColumns = `s.id, s.user_id, s.name, s.description, s.kind, s.public, s.created_at, s.updated_at,
+                u.name, (SELECT COUNT(*) FROM shelf_items i WHERE i.shelf_id = s.id)`
+
+// scanShelf reads a row selected with shelfColumns from shelves s joined to users u
+func scanShelf(row interface{ Scan(...interface{}) error }) (*Shelf, error) {
+	s := &Shelf{}
+	err := row.Scan(&s.ID, &s.UserID, &s.Name, &s.Description, &s.Kind, &s.Public, &s.CreatedAt, &s.UpdatedAt,
+		&s.OwnerName, &s.ItemCount)
+	return s, err
+}
+
+// queryShelves runs a shelf query and parses its rows
+func queryShelves(query string, args ...interface{}) ([]*Shelf, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var shelves []*Shelf
+	for rows.Next() {
+		s, err := scanShelf(rows)
+		if err != nil {
+			return nil, err
+		}
+		shelves = append(shelves, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shelves, nil
+}
+
+// GetUserShelves retrieves a patron's shelves, built-in shelves first, creating the
+// built-in ones the first time
+func GetUserShelves(userID int) ([]*Shelf, error) {
+	db := config.GetDB()
+
+	for _, d := range defaultShelves {
+		_, err := db.Exec(`
+                        INSERT INTO shelves (user_id, name, kind)
+                        SELECT $1, $2, $3
+                        WHERE NOT EXISTS (SELECT 1 FROM shelves WHERE user_id = $1 AND kind = $3)
+                        ON CONFLICT (user_id, name) DO NOTHING
+                `, userID, d.Name, d.Kind)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return queryShelves(`
+                SELECT `+shelfColumns+`
+                FROM shelves s
+                JOIN users u ON u.id = s.user_id
+                WHERE s.user_id = $1
+                ORDER BY s.kind = '`+ShelfKindCustom+`', s.kind, s.name
+        `, userID)
+}
+
+// GetPublicShelves retrieves the public reading lists of other patrons that have
+// books on them, optionally matching a search on the list or owner name
+func GetPublicShelves(search string, excludeUserID int) ([]*Shelf, error) {
+	return queryShelves(`
+                SELECT `+shelfColumns+`
+                FROM shelves s
+                JOIN users u ON u.id = s.user_id
+                WHERE s.public AND s.user_id <> $2
+                        AND EXISTS (SELECT 1 FROM shelf_items i WHERE i.shelf_id = s.id)
+                        AND ($1 = '' OR s.name ILIKE '%' || $1 || '%' OR u.name ILIKE '%' || $1 || '%')
+                ORDER BY s.updated_at DESC
+                LIMIT 50
+        `, strings.TrimSpace(search), excludeUserID)
+}
+
+// GetShelvesWithBook retrieves the patron's shelves that hold a book
+func GetShelvesWithBook(userID, bookID int) ([]*Shelf, error) {
+	return queryShelves(`
+                SELECT `+shelfColumns+`
+                FROM shelves s
+                JOIN users u ON u.id = s.user_id
+                JOIN shelf_items i ON i.shelf_id = s.id AND i.book_id = $2
+                WHERE s.user_id = $1
+                ORDER BY s.kind = '`+ShelfKindCustom+`', s.kind, s.name
+        `, userID, bookID)
+}
+
+// GetShelfByID retrieves a shelf with its books in the order they were added
+func GetShelfByID(id int) (*Shelf, error) {
+	db := config.GetDB()
+
+	s, err := scanShelf(db.QueryRow(`
+                SELECT `+shelfColumns+`
+                FROM shelves s
+                JOIN users u ON u.id = s.user_id
+                WHERE s.id = $1
+        `, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("shelf not found")
+		}
+		return nil, err
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT shelf_id, book_id, note, added_at
+                FROM shelf_items
+                WHERE shelf_id = $1
+                ORDER BY added_at, book_id
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	for rows.Next() {
+		item := &ShelfItem{}
+		if err := rows.Scan(&item.ShelfID, &item.BookID, &item.Note, &item.AddedAt); err != nil {
+			return nil, err
+		}
+		s.Items = append(s.Items, item)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, item := range s.Items {
+		if item.Book, err = GetBookByID(item.BookID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Save creates a custom list or updates a shelf's details. Built-in shelves keep
+// their name.
+func (s *Shelf) Save() error {
+	s.Name = strings.TrimSpace(s.Name)
+	s.Description = strings.TrimSpace(s.Description)
+	if s.Name == "" {
+		return errors.New("list name is required")
+	}
+
+	db := config.GetDB()
+
+	var err error
+	if s.ID == 0 {
+		s.Kind = ShelfKindCustom
+		err = db.QueryRow(`
+                        INSERT INTO shelves (user_id, name, description, kind, public)
+                        VALUES ($1, $2, $3, $4, $5)
+                        RETURNING id
+                `, s.UserID, s.Name, s.Description, s.Kind, s.Public).Scan(&s.ID)
+	} else {
+		_, err = db.Exec(`
+                        UPDATE shelves
+                        SET name = CASE WHEN kind = $5 THEN $2 ELSE name END,
+                                description = $3, public = $4, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $1
+                `, s.ID, s.Name, s.Description, s.Public, ShelfKindCustom)
+	}
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return errors.New("you already have a list called " + s.Name)
+	}
+	return err
+}
+
+// DeleteShelf deletes a custom list and its entries
+func DeleteShelf(id int) error {
+	db := config.GetDB()
+
+	result, err := db.Exec("DELETE FROM shelves WHERE id = $1 AND kind = $2", id, ShelfKindCustom)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("built-in shelves cannot be deleted")
+	}
+	return nil
+}
+
+// AddToShelf puts a book on a shelf with an optional note
+func AddToShelf(shelfID, bookID int, note string) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+                INSERT INTO shelf_items (shelf_id, book_id, note)
+                VALUES ($1, $2, $3)
+                ON CONFLICT (shelf_id, book_id) DO NOTHING
+        `, shelfID, bookID, strings.TrimSpace(note))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("this book is already on the list")
+	}
+
+	if _, err := tx.Exec("UPDATE shelves SET updated_at = CURRENT_TIMESTAMP WHERE id = $1", shelfID); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// UpdateShelfItemNote changes the note on a shelf entry
+func UpdateShelfItemNote(shelfID, bookID int, note string) error {
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                UPDATE shelf_items SET note = $3 WHERE shelf_id = $1 AND book_id = $2
+        `, shelfID, bookID, strings.TrimSpace(note))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("book is not on this list")
+	}
+	return nil
+}
+
+// RemoveFromShelf takes a book off a shelf
+func RemoveFromShelf(shelfID, bookID int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec("DELETE FROM shelf_items WHERE shelf_id = $1 AND book_id = $2", shelfID, bookID)
+	return err
+}
+
+// ReserveShelf reserves every book on a list that is out, in one transaction. Books on
+// the shelf can be borrowed directly, and those the patron has already reserved or is
+// borrowing are skipped.
+func ReserveShelf(userID, shelfID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+                SELECT b.id, b.title
+                FROM shelf_items i
+                JOIN books b ON b.id = i.book_id
+                WHERE i.shelf_id = $1 AND b.available = 0
+                        AND NOT EXISTS (SELECT 1 FROM reservations r WHERE r.book_id = b.id AND r.user_id = $2 AND r.status = $3)
+                        AND NOT EXISTS (SELECT 1 FROM borrows br WHERE br.book_id = b.id AND br.user_id = $2 AND br.status = $4)
+                ORDER BY i.added_at
+                FOR UPDATE OF b
+        `, shelfID, userID, ReservationStatusActive, BorrowStatusApproved)
+	if err != nil {
+		return 0, err
+	}
+	type outBook struct {
+		id    int
+		title string
+	}
+	var books []outBook
+	for rows.Next() {
+		var b outBook
+		if err := rows.Scan(&b.id, &b.title); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		books = append(books, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(books) == 0 {
+		return 0, errors.New("nothing on this list needs reserving; the books are available or already yours")
+	}
+
+	for _, b := range books {
+		if err := reserveBookTx(tx, userID, b.id, false); err != nil {
+			return 0, errors.New(b.title + ": " + err.Error())
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(books), nil
+}
@@ -0,0 +1,844 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Stocktake status constants
+const (
+	StocktakeStatusOpen      = "open"
+	StocktakeStatusCompleted = "completed"
+)
+
+// Stocktake resolutions, recorded once per book so a finding is not applied twice
+const (
+	StocktakeActionMissing  = "missing"  // Copies not found were written off
+	StocktakeActionStock    = "stock"    // Copies found beyond those expected were added to stock
+	StocktakeActionReshelve = "reshelve" // Misplaced copies were put back in their section
+	StocktakeActionLocation = "location" // The call number was changed to where the copies were found
+)
+
+// Stocktake is an inventory check of the shelves. Staff scan each section of shelving
+// in turn; when a section is finished, the copies the catalogue expects on it are
+// captured and compared with the copies scanned.
+type Stocktake struct {
+	ID          int
+	Name        string
+	Status      string
+	StartedBy   sql.NullInt64
+	StartedAt   time.Time
+	CompletedAt *time.Time
+
+	// Computed properties
+	StarterName      string
+	SectionCount     int
+	FinishedSections int
+	ScanCount        int
+	Sections         []*StocktakeSection
+}
+
+// StocktakeSection is a run of shelving covering a range of call numbers
+type StocktakeSection struct {
+	ID          int
+	StocktakeID int
+	Name        string
+	CallFrom    string
+	CallTo      string
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+
+	// Computed properties
+	ScanCount int
+	Expected  int // Copies expected on the shelf, captured when the section is finished
+}
+
+// StocktakeScan is one copy scanned on the shelf. Barcodes that match no book are
+// kept, with no book, so they appear in the stocktake's findings.
+type StocktakeScan struct {
+	ID          int
+	StocktakeID int
+	SectionID   int
+	BookID      sql.NullInt64
+	Barcode     string
+	ScannedAt   time.Time
+
+	// Computed properties
+	Title      string
+	CallNumber string
+	Misplaced  bool // The book's call number files outside the section it was found in
+}
+
+// StocktakeItem is a book's count in a stocktake, listed on a section's shelf list or
+// as a finding in the stocktake's report
+type StocktakeItem struct {
+	BookID     int
+	Title      string
+	ISBN       string // The scanned barcode when no book matched it
+	CallNumber string
+	Section    string // Section the book's call number files in
+	FoundIn    string // Section misplaced or unknown copies were scanned in
+	Expected   int
+	Found      int
+	OnLoan     int
+	Copies     int    // Copies missing, unexpected or misplaced
+	Resolution string // Action taken on the finding, if any
+}
+
+// StocktakeReport lists the differences between the shelves and the catalogue
+type StocktakeReport struct {
+	Missing    []*StocktakeItem // Fewer copies found than expected
+	Unexpected []*StocktakeItem // More copies found than expected
+	Misplaced  []*StocktakeItem // Copies found in a section their call number does not file in
+	Unknown    []*StocktakeItem // Barcodes that matched no book
+}
+
+// Empty reports whether the stocktake found no differences
+func (r *StocktakeReport) Empty() bool {
+	return len(r.Missing)+len(r.Unexpected)+len(r.Misplaced)+len(r.Unknown) == 0
+}
+
+// Open reports whether the stocktake can still be scanned
+func (s *Stocktake) Open() bool {
+	return s.Status == StocktakeStatusOpen
+}
+
+// Finished reports whether the section has been scanned and its holdings captured
+func (s *StocktakeSection) Finished() bool {
+	return s.CompletedAt != nil
+}
+
+// stocktakeHolds is the SQL condition that a book's call number files within a
+// section's range. The end of the range includes everything under it, so a section
+// ending at 599 holds 599.9 SMI.
+func stocktakeHolds(book, section string) string {
+	return "(" + book + ".call_number_sort <> '' AND " + book + ".call_number_sort >= " + section + ".from_sort AND " +
+		"LEFT(" + book + ".call_number_sort, LENGTH(" + section + ".to_sort)) <= " + section + ".to_sort)"
+}
+
+// stocktakeColumns lists the columns scanned by scanStocktake from stocktakes t left
+// joined to users u
+const stocktakeColumns = `t.id, t.name, t.status, t.started_by, t.started_at, t.completed_at, COALESCE(u.name, ''),
+                (SELECT COUNT(*) FROM stocktake_sections sec WHERE sec.stocktake_id = t.id),
+                (SELECT COUNT(*) FROM stocktake_sections sec WHERE sec.stocktake_id = t.id AND sec.completed_at IS NOT NULL),
+                (SELECT COUNT(*) FROM stocktake_scans s WHERE s.stocktake_id = t.id)`
+
+// scanStocktake reads a row selected with stocktakeColumns
+func scanStocktake(row interface{ Scan(...interface{}) error }) (*Stocktake, error) {
+	s := &Stocktake{}
+	err := row.Scan(&s.ID, &s.Name, &s.Status, &s.StartedBy, &s.StartedAt, &s.CompletedAt, &s.StarterName,
+		&s.SectionCount, &s.FinishedSections, &s.ScanCount)
+	return s, err
+}
+
+// stocktakeSectionColumns lists the columns scanned by scanStocktakeSection from
+// stocktake_sections sec
+const stocktakeSectionColumns = `sec.id, sec.stocktake_id, sec.name, sec.call_from, sec.call_to, sec.completed_at, sec.created_at,
+                (SELECT COUNT(*) FROM stocktake_scans s WHERE s.section_id = sec.id),
+                (SELECT COALESCE(SUM(h.expected), 0) FROM stocktake_holdings h WHERE h.section_id = sec.id)`
+
+// scanStocktakeSection reads a row selected with stocktakeSectionColumns
+func scanStocktakeSection(row interface{ Scan(...interface{}) error }) (*StocktakeSection, error) {
+	s := &StocktakeSection{}
+	err := row.Scan(&s.ID, &s.StocktakeID, &s.Name, &s.CallFrom, &s.CallTo, &s.CompletedAt, &s.CreatedAt,
+		&s.ScanCount, &s.Expected)
+	return s, err
+}
+
+// Create starts a new stocktake
+func (s *Stocktake) Create() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+
+	db := config.GetDB()
+
+	s.Status = StocktakeStatusOpen
+	return db.QueryRow(`
+                INSERT INTO stocktakes (name, status, started_by)
+                VALUES ($1, $2, $3)
+                RETURNING id, started_at
+        `, s.Name, s.Status, s.StartedBy).Scan(&s.ID, &s.StartedAt)
+}
+
+// GetStocktakes retrieves every stocktake, newest first
+func GetStocktakes() ([]*Stocktake, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT ` + stocktakeColumns + `
+                FROM stocktakes t
+                LEFT JOIN users u ON u.id = t.started_by
+                ORDER BY t.started_at DESC
+        `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var stocktakes []*Stocktake
+	for rows.Next() {
+		s, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stocktakes, nil
+}
+
+// GetStocktakeByID retrieves a stocktake with its sections in shelf order
+func GetStocktakeByID(id int) (*Stocktake, error) {
+	db := config.GetDB()
+
+	s, err := scanStocktake(db.QueryRow(`
+                SELECT `+stocktakeColumns+`
+                FROM stocktakes t
+                LEFT JOIN users u ON u.id = t.started_by
+                WHERE t.id = $1
+        `, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("stocktake not found")
+		}
+		return nil, err
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT `+stocktakeSectionColumns+`
+                FROM stocktake_sections sec
+                WHERE sec.stocktake_id = $1
+                ORDER BY sec.from_sort, sec.name
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	for rows.Next() {
+		section, err := scanStocktakeSection(rows)
+		if err != nil {
+			return nil, err
+		}
+		s.Sections = append(s.Sections, section)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// CompleteStocktake closes a stocktake once every section has been finished. Its
+// findings can still be resolved afterwards.
+func CompleteStocktake(id int) error {
+	db := config.GetDB()
+
+	var unfinished int
+	err := db.QueryRow(`
+                SELECT COUNT(*) FROM stocktake_sections
+                WHERE stocktake_id = $1 AND completed_at IS NULL
+        `, id).Scan(&unfinished)
+	if err != nil {
+		return err
+	}
+	if unfinished > 0 {
+		return errors.New("finish or remove every section first")
+	}
+
+	result, err := db.Exec(`
+                UPDATE stocktakes
+                SET status = $1, completed_at = CURRENT_TIMESTAMP
+                WHERE id = $2 AND status = $3
+        `, StocktakeStatusCompleted, id, StocktakeStatusOpen)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("this stocktake is already complete")
+	}
+
+	return nil
+}
+
+// Create adds a section to an open stocktake
+func (s *StocktakeSection) Create() error {
+	s.CallFrom = strings.TrimSpace(s.CallFrom)
+	s.CallTo = strings.TrimSpace(s.CallTo)
+	s.Name = strings.TrimSpace(s.Name)
+	if s.CallFrom == "" || s.CallTo == "" {
+		return errors.New("the first and last call numbers are required")
+	}
+	fromSort, toSort := CallNumberSortKey(s.CallFrom), CallNumberSortKey(s.CallTo)
+	if toSort < fromSort {
+		return errors.New("the last call number files before the first")
+	}
+	if s.Name == "" {
+		s.Name = s.CallFrom + " to " + s.CallTo
+	}
+
+	db := config.GetDB()
+
+	err := db.QueryRow(`
+                INSERT INTO stocktake_sections (stocktake_id, name, call_from, call_to, from_sort, to_sort)
+                SELECT id, $2, $3, $4, $5, $6 FROM stocktakes
+                WHERE id = $1 AND status = $7
+                RETURNING id, created_at
+        `, s.StocktakeID, s.Name, s.CallFrom, s.CallTo, fromSort, toSort, StocktakeStatusOpen).Scan(&s.ID, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return errors.New("this stocktake is complete")
+	}
+
+	return err
+}
+
+// GetStocktakeSectionByID retrieves a stocktake section
+func GetStocktakeSectionByID(id int) (*StocktakeSection, error) {
+	db := config.GetDB()
+
+	s, err := scanStocktakeSection(db.QueryRow(`
+                SELECT `+stocktakeSectionColumns+`
+                FROM stocktake_sections sec
+                WHERE sec.id = $1
+        `, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("section not found")
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// DeleteStocktakeSection removes a section of an open stocktake with its scans
+func DeleteStocktakeSection(id int) error {
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                DELETE FROM stocktake_sections sec
+                USING stocktakes t
+                WHERE sec.id = $1 AND t.id = sec.stocktake_id AND t.status = $2
+        `, id, StocktakeStatusOpen)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("sections of a completed stocktake cannot be removed")
+	}
+
+	return nil
+}
+
+// FinishStocktakeSection closes a section to scanning and captures the copies the
+// catalogue expects on its shelves: those not on loan. Copies that go out or come
+// back afterwards do not change the comparison.
+func FinishStocktakeSection(id int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+                UPDATE stocktake_sections sec
+                SET completed_at = CURRENT_TIMESTAMP
+                FROM stocktakes t
+                WHERE sec.id = $1 AND sec.completed_at IS NULL AND t.id = sec.stocktake_id AND t.status = $2
+        `, id, StocktakeStatusOpen)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("this section is already finished")
+	}
+
+	// A book files in one section; where ranges overlap, the first finished keeps it
+	_, err = tx.Exec(`
+                INSERT INTO stocktake_holdings (stocktake_id, section_id, book_id, expected, on_loan)
+                SELECT sec.stocktake_id, sec.id, b.id, b.available, b.quantity - b.available
+                FROM stocktake_sections sec
+                JOIN books b ON `+stocktakeHolds("b", "sec")+`
+                WHERE sec.id = $1
+                ON CONFLICT (stocktake_id, book_id) DO NOTHING
+        `, id)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// ReopenStocktakeSection reopens a finished section for scanning. Its holdings are
+// captured again when it is finished.
+func ReopenStocktakeSection(id int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+                UPDATE stocktake_sections sec
+                SET completed_at = NULL
+                FROM stocktakes t
+                WHERE sec.id = $1 AND sec.completed_at IS NOT NULL AND t.id = sec.stocktake_id AND t.status = $2
+        `, id, StocktakeStatusOpen)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("only finished sections of an open stocktake can be reopened")
+	}
+
+	if _, err := tx.Exec("DELETE FROM stocktake_holdings WHERE section_id = $1", id); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// AddStocktakeScan records a copy scanned on a section's shelves. A barcode matching
+// no book is recorded too, to be reported as unexpected.
+func AddStocktakeScan(sectionID int, barcode string, userID int) (*StocktakeScan, error) {
+	barcode = strings.TrimSpace(barcode)
+	if barcode == "" {
+		return nil, errors.New("scan a barcode")
+	}
+	if len(barcode) > 50 {
+		return nil, errors.New("barcode is too long")
+	}
+
+	db := config.GetDB()
+
+	bookID, err := findBookByBarcode(barcode)
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &StocktakeScan{SectionID: sectionID, Barcode: barcode}
+	if bookID > 0 {
+		scan.BookID = sql.NullInt64{Int64: int64(bookID), Valid: true}
+	}
+
+	err = db.QueryRow(`
+                INSERT INTO stocktake_scans (stocktake_id, section_id, book_id, barcode, scanned_by)
+                SELECT sec.stocktake_id, sec.id, $2::int, $3, $4::int
+                FROM stocktake_sections sec
+                JOIN stocktakes t ON t.id = sec.stocktake_id
+                WHERE sec.id = $1 AND sec.completed_at IS NULL AND t.status = $5
+                RETURNING id, stocktake_id, scanned_at
+        `, sectionID, scan.BookID, barcode, userID, StocktakeStatusOpen).Scan(&scan.ID, &scan.StocktakeID, &scan.ScannedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("this section is finished; reopen it to scan more")
+		}
+		return nil, err
+	}
+
+	if bookID > 0 {
+		err = db.QueryRow(`
+                        SELECT b.title, b.call_number, NOT `+stocktakeHolds("b", "sec")+`
+                        FROM books b, stocktake_sections sec
+                        WHERE b.id = $1 AND sec.id = $2
+                `, bookID, sectionID).Scan(&scan.Title, &scan.CallNumber, &scan.Misplaced)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return scan, nil
+}
+
+// GetStocktakeScans retrieves a section's most recent scans, newest first
+func GetStocktakeScans(sectionID, limit int) ([]*StocktakeScan, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT s.id, s.stocktake_id, s.section_id, s.book_id, s.barcode, s.scanned_at,
+                        COALESCE(b.title, ''), COALESCE(b.call_number, ''),
+                        b.id IS NOT NULL AND NOT `+stocktakeHolds("b", "sec")+`
+                FROM stocktake_scans s
+                JOIN stocktake_sections sec ON sec.id = s.section_id
+                LEFT JOIN books b ON b.id = s.book_id
+                WHERE s.section_id = $1
+                ORDER BY s.scanned_at DESC, s.id DESC
+                LIMIT $2
+        `, sectionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var scans []*StocktakeScan
+	for rows.Next() {
+		s := &StocktakeScan{}
+		err := rows.Scan(&s.ID, &s.StocktakeID, &s.SectionID, &s.BookID, &s.Barcode, &s.ScannedAt,
+			&s.Title, &s.CallNumber, &s.Misplaced)
+		if err != nil {
+			return nil, err
+		}
+		scans = append(scans, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return scans, nil
+}
+
+// DeleteStocktakeScan removes a mistaken scan from a section still being scanned
+func DeleteStocktakeScan(id, sectionID int) error {
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                DELETE FROM stocktake_scans s
+                USING stocktake_sections sec
+                WHERE s.id = $1 AND s.section_id = $2 AND sec.id = s.section_id AND sec.completed_at IS NULL
+        `, id, sectionID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("scan not found, or its section is finished")
+	}
+
+	return nil
+}
+
+// scanStocktakeItem reads a row selecting, in order, the book's ID, title, ISBN and
+// call number, the section and found in names, and the expected, found, on loan and
+// copies counts followed by the resolution
+func scanStocktakeItem(row interface{ Scan(...interface{}) error }) (*StocktakeItem, error) {
+	item := &StocktakeItem{}
+	err := row.Scan(&item.BookID, &item.Title, &item.ISBN, &item.CallNumber, &item.Section, &item.FoundIn,
+		&item.Expected, &item.Found, &item.OnLoan, &item.Copies, &item.Resolution)
+	return item, err
+}
+
+// queryStocktakeItems runs a query selecting stocktake items
+func queryStocktakeItems(query string, args ...interface{}) ([]*StocktakeItem, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var items []*StocktakeItem
+	for rows.Next() {
+		item, err := scanStocktakeItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// stocktakeFound counts the copies of book b scanned anywhere in stocktake $1
+const stocktakeFound = "(SELECT COUNT(*) FROM stocktake_scans s WHERE s.stocktake_id = $1 AND s.book_id = b.id)"
+
+// stocktakeResolution is the action taken on book b's finding in stocktake $1, out
+// of the actions given
+func stocktakeResolution(actions ...string) string {
+	return "COALESCE((SELECT r.action FROM stocktake_resolutions r WHERE r.stocktake_id = $1 AND r.book_id = b.id " +
+		"AND r.action IN ('" + strings.Join(actions, "', '") + "') LIMIT 1), '')"
+}
+
+// GetStocktakeShelfList lists the books a section holds with the copies found so far
+// across the stocktake, in shelf order. Until the section is finished, the copies
+// expected are those not on loan now.
+func GetStocktakeShelfList(section *StocktakeSection) ([]*StocktakeItem, error) {
+	return queryStocktakeItems(`
+                SELECT b.id, b.title, b.isbn, b.call_number, sec.name, '',
+                        COALESCE(h.expected, b.available), `+stocktakeFound+`, COALESCE(h.on_loan, b.quantity - b.available), 0, ''
+                FROM stocktake_sections sec
+                JOIN books b ON `+stocktakeHolds("b", "sec")+`
+                LEFT JOIN stocktake_holdings h ON h.stocktake_id = sec.stocktake_id AND h.book_id = b.id
+                WHERE sec.stocktake_id = $1 AND sec.id = $2 AND (b.quantity > 0 OR h.book_id IS NOT NULL)
+                ORDER BY b.call_number_sort, b.title
+        `, section.StocktakeID, section.ID)
+}
+
+// GetStocktakeReport compares the copies scanned with those expected in the
+// stocktake's finished sections
+func GetStocktakeReport(id int) (*StocktakeReport, error) {
+	report := &StocktakeReport{}
+	var err error
+
+	// Books in finished sections with fewer or more copies than expected
+	counted := `
+                SELECT b.id, b.title, b.isbn, b.call_number, sec.name, '',
+                        h.expected, ` + stocktakeFound + `, h.on_loan, %s, %s
+                FROM stocktake_holdings h
+                JOIN books b ON b.id = h.book_id
+                JOIN stocktake_sections sec ON sec.id = h.section_id
+                WHERE h.stocktake_id = $1 AND %s
+                ORDER BY b.call_number_sort, b.title
+        `
+	report.Missing, err = queryStocktakeItems(fmt.Sprintf(counted,
+		"h.expected - "+stocktakeFound, stocktakeResolution(StocktakeActionMissing),
+		"h.expected > "+stocktakeFound), id)
+	if err != nil {
+		return nil, err
+	}
+	report.Unexpected, err = queryStocktakeItems(fmt.Sprintf(counted,
+		stocktakeFound+" - h.expected", stocktakeResolution(StocktakeActionStock),
+		stocktakeFound+" > h.expected"), id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copies scanned in a section their call number does not file in
+	report.Misplaced, err = queryStocktakeItems(`
+                SELECT b.id, b.title, b.isbn, b.call_number,
+                        COALESCE((SELECT home.name FROM stocktake_sections home
+                                WHERE home.stocktake_id = $1 AND `+stocktakeHolds("b", "home")+`
+                                ORDER BY home.from_sort LIMIT 1), ''),
+                        sec.name, 0, COUNT(*), 0, COUNT(*),
+                        `+stocktakeResolution(StocktakeActionReshelve, StocktakeActionLocation)+`
+                FROM stocktake_scans s
+                JOIN books b ON b.id = s.book_id
+                JOIN stocktake_sections sec ON sec.id = s.section_id
+                WHERE s.stocktake_id = $1 AND NOT `+stocktakeHolds("b", "sec")+`
+                GROUP BY b.id, sec.id
+                ORDER BY sec.from_sort, b.call_number_sort, b.title
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Barcodes that matched no book
+	report.Unknown, err = queryStocktakeItems(`
+                SELECT 0, '', s.barcode, '', '', sec.name, 0, COUNT(*), 0, COUNT(*), ''
+                FROM stocktake_scans s
+                JOIN stocktake_sections sec ON sec.id = s.section_id
+                WHERE s.stocktake_id = $1 AND s.book_id IS NULL
+                GROUP BY s.barcode, sec.id
+                ORDER BY sec.from_sort, s.barcode
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// stocktakeCounts returns the copies of a book expected and found in a stocktake
+func stocktakeCounts(tx *sql.Tx, stocktakeID, bookID int) (int, int, error) {
+	var expected, found int
+	err := tx.QueryRow(`
+                SELECT h.expected, `+stocktakeFound+`
+                FROM stocktake_holdings h
+                JOIN books b ON b.id = h.book_id
+                WHERE h.stocktake_id = $1 AND h.book_id = $2
+        `, stocktakeID, bookID).Scan(&expected, &found)
+	if err == sql.ErrNoRows {
+		return 0, 0, errors.New("the section this book files in has not been finished")
+	}
+	return expected, found, err
+}
+
+// recordStocktakeResolution records the action taken on a book's finding, failing if
+// it has already been taken
+func recordStocktakeResolution(tx *sql.Tx, stocktakeID, bookID int, action string, copies, userID int) error {
+	result, err := tx.Exec(`
+                INSERT INTO stocktake_resolutions (stocktake_id, book_id, action, copies, resolved_by)
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT DO NOTHING
+        `, stocktakeID, bookID, action, copies, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("this finding has already been resolved")
+	}
+	return nil
+}
+
+// WriteOffMissingCopies removes the copies of a book the stocktake did not find from
+// stock and returns how many were written off
+func WriteOffMissingCopies(stocktakeID, bookID, userID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	expected, found, err := stocktakeCounts(tx, stocktakeID, bookID)
+	if err != nil {
+		return 0, err
+	}
+	missing := expected - found
+	if missing <= 0 {
+		return 0, errors.New("no copies of this book are missing")
+	}
+
+	if err := recordStocktakeResolution(tx, stocktakeID, bookID, StocktakeActionMissing, missing, userID); err != nil {
+		return 0, err
+	}
+
+	// The copies were on the shelf when the section was finished, so they count as available
+	_, err = tx.Exec(`
+                UPDATE books
+                SET quantity = GREATEST(quantity - $1, 0), available = GREATEST(available - $1, 0),
+                        updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2
+        `, missing, bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return missing, nil
+}
+
+// AddSurplusCopies adds the copies of a book found beyond those expected to stock and
+// returns how many were added. Copies recorded on loan should be checked in instead.
+func AddSurplusCopies(stocktakeID, bookID, userID int) (int, error) {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	expected, found, err := stocktakeCounts(tx, stocktakeID, bookID)
+	if err != nil {
+		return 0, err
+	}
+	surplus := found - expected
+	if surplus <= 0 {
+		return 0, errors.New("no unexpected copies of this book were found")
+	}
+
+	if err := recordStocktakeResolution(tx, stocktakeID, bookID, StocktakeActionStock, surplus, userID); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+                UPDATE books
+                SET quantity = quantity + $1, available = available + $1, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2
+        `, surplus, bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// The new copies can serve waiting reservations
+	go func() {
+		_ = ProcessReservationsForBook(bookID)
+	}()
+
+	return surplus, nil
+}
+
+// ReshelveMisplacedCopies records that a book's misplaced copies were put back in the
+// section their call number files in
+func ReshelveMisplacedCopies(stocktakeID, bookID, userID int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recordStocktakeResolution(tx, stocktakeID, bookID, StocktakeActionReshelve, 0, userID); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// RelocateBook changes a book's call number to file it where the stocktake found it
+func RelocateBook(stocktakeID, bookID int, callNumber string, userID int) error {
+	callNumber = strings.TrimSpace(callNumber)
+	if callNumber == "" {
+		return errors.New("call number is required")
+	}
+
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recordStocktakeResolution(tx, stocktakeID, bookID, StocktakeActionLocation, 0, userID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+                UPDATE books
+                SET call_number = $1, call_number_sort = $2, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $3
+        `, callNumber, CallNumberSortKey(callNumber), bookID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("book not found")
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
@@ -0,0 +1,292 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"library-management-system/config"
+)
+
+// Subject represents a controlled subject heading
+type Subject struct {
+	ID        int
+	Name      string
+	BookCount int
+}
+
+// GetSubjects retrieves all subject headings with the number of books under each
+func GetSubjects(search string) ([]*Subject, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT s.id, s.name, COUNT(bs.book_id)
+                FROM subjects s
+                LEFT JOIN book_subjects bs ON bs.subject_id = s.id
+                WHERE $1 = '' OR s.name ILIKE '%' || $1 || '%'
+                GROUP BY s.id
+                ORDER BY LOWER(s.name)
+        `, search)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var subjects []*Subject
+	for rows.Next() {
+		subject := &Subject{}
+		if err := rows.Scan(&subject.ID, &subject.Name, &subject.BookCount); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subjects, nil
+}
+
+// GetSubjectByID retrieves a subject heading by ID
+func GetSubjectByID(id int) (*Subject, error) {
+	db := config.GetDB()
+
+	subject := &Subject{}
+	err := db.QueryRow(`
+                SELECT s.id, s.name, (SELECT COUNT(*) FROM book_subjects WHERE subject_id = s.id)
+                FROM subjects s
+                WHERE s.id = $1
+        `, id).Scan(&subject.ID, &subject.Name, &subject.BookCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("subject not found")
+		}
+		return nil, err
+	}
+
+	return subject, nil
+}
+
+// GetBookSubjects retrieves the subject headings assigned to a book
+func GetBookSubjects(bookID int) ([]*Subject, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT s.id, s.name
+                FROM subjects s
+                JOIN book_subjects bs ON bs.subject_id = s.id
+                WHERE bs.book_id = $1
+                ORDER BY LOWER(s.name)
+        `, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var subjects []*Subject
+	for rows.Next() {
+		subject := &Subject{}
+		if err := rows.Scan(&subject.ID, &subject.Name); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subjects, nil
+}
+
+// SubjectsText formats a book's headings one per line, the format accepted by the book form
+func (b *Book) SubjectsText() string {
+	var names []string
+	for _, s := range b.Subjects {
+		names = append(names, s.Name)
+	}
+	return strings.Join(names, "\n")
+}
+
+// findOrCreateSubject returns the ID of the heading with the given name, ignoring case
+func findOrCreateSubject(tx *sql.Tx, name string) (int, error) {
+	var id int
+	err := tx.QueryRow("SELECT id FROM subjects WHERE LOWER(name) = LOWER($1)", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow("INSERT INTO subjects (name) VALUES ($1) RETURNING id", name).Scan(&id)
+	}
+	return id, err
+}
+
+// SetBookSubjects replaces a book's subject headings, creating new headings as needed.
+// The first heading also becomes the book's category.
+func SetBookSubjects(bookID int, names []string) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM book_subjects WHERE book_id = $1", bookID); err != nil {
+		return err
+	}
+
+	category := ""
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, err := findOrCreateSubject(tx, name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+                        INSERT INTO book_subjects (book_id, subject_id)
+                        VALUES ($1, $2)
+                        ON CONFLICT DO NOTHING
+                `, bookID, id)
+		if err != nil {
+			return err
+		}
+		if category == "" {
+			category = name
+		}
+	}
+
+	// Keep the legacy category column in step for lists and reports
+	_, err = tx.Exec("UPDATE books SET category = LEFT($1, 50) WHERE id = $2", category, bookID)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// RenameSubject changes a heading on every book at once. Renaming to the name of an
+// existing heading merges the two.
+func RenameSubject(id int, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("subject name is required")
+	}
+
+	db := config.GetDB()
+
+	var existingID int
+	err := db.QueryRow("SELECT id FROM subjects WHERE LOWER(name) = LOWER($1) AND id <> $2", name, id).Scan(&existingID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if existingID > 0 {
+		return MergeSubjects(id, existingID)
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.QueryRow("SELECT name FROM subjects WHERE id = $1 FOR UPDATE", id).Scan(&oldName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("subject not found")
+		}
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE subjects SET name = $1 WHERE id = $2", name, id); err != nil {
+		return err
+	}
+
+	// Books whose category was the old heading take the new one
+	_, err = tx.Exec("UPDATE books SET category = LEFT($1, 50) WHERE LOWER(category) = LOWER(LEFT($2, 50))", name, oldName)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// MergeSubjects moves every book from one heading to another and deletes the first
+func MergeSubjects(sourceID, targetID int) error {
+	if sourceID == targetID {
+		return errors.New("cannot merge a subject into itself")
+	}
+
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var sourceName, targetName string
+	err = tx.QueryRow("SELECT name FROM subjects WHERE id = $1", sourceID).Scan(&sourceName)
+	if err == nil {
+		err = tx.QueryRow("SELECT name FROM subjects WHERE id = $1", targetID).Scan(&targetName)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("subject not found")
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`
+                INSERT INTO book_subjects (book_id, subject_id)
+                SELECT book_id, $2 FROM book_subjects WHERE subject_id = $1
+                ON CONFLICT DO NOTHING
+        `, sourceID, targetID)
+	if err != nil {
+		return err
+	}
+
+	// Books whose category was the old heading take the new one
+	_, err = tx.Exec("UPDATE books SET category = LEFT($1, 50) WHERE LOWER(category) = LOWER(LEFT($2, 50))", targetName, sourceName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM subjects WHERE id = $1", sourceID); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// DeleteSubject removes a heading from every book
+func DeleteSubject(id int) error {
+	db := config.GetDB()
+
+	var name string
+	err := db.QueryRow("DELETE FROM subjects WHERE id = $1 RETURNING name", id).Scan(&name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("subject not found")
+		}
+		return err
+	}
+
+	_, err = db.Exec("UPDATE books SET category = '' WHERE LOWER(category) = LOWER(LEFT($1, 50))", name)
+
+	return err
+}
@@ -0,0 +1,507 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+)
+
+// Purchase suggestion statuses
+const (
+	SuggestionStatusNew      = "new"      // Waiting for a librarian to look at it
+	SuggestionStatusAccepted = "accepted" // The library intends to buy it
+	SuggestionStatusRejected = "rejected" // Not bought; the librarian's note says why
+	SuggestionStatusOrdered  = "ordered"  // On order; linked to a record with no copies yet
+	SuggestionStatusAdded    = "added"    // In the catalog with copies on the shelf
+)
+
+// SuggestionStatuses lists the statuses a librarian can set directly; suggestions
+// become added when the book they ask for is linked to them
+var SuggestionStatuses = []string{
+	SuggestionStatusNew,
+	SuggestionStatusAccepted,
+	SuggestionStatusRejected,
+	SuggestionStatusOrdered,
+}
+
+// ErrSuggestionInCatalog is returned when a patron suggests a book the library has
+var ErrSuggestionInCatalog = errors.New("this book is already in the catalog")
+
+// Suggestion is a patron's request that the library buy a title. Patrons who ask for
+// the same title share one suggestion and each add a vote.
+type Suggestion struct {
+	ID              int
+	Title           string
+	Author          string
+	ISBN            string
+	Publisher       string
+	PublicationYear int
+	Notes           string
+	Status          string
+	LibrarianNote   string
+	BookID          sql.NullInt64
+	CreatedBy       sql.NullInt64
+	ReviewedBy      sql.NullInt64
+	ReviewedAt      NullTime
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+
+	// Computed properties
+	Votes  int
+	Voted  bool // The user the suggestions were fetched for has voted
+	Voters []*SuggestionVote
+}
+
+// SuggestionVote is a patron backing a suggestion
+type SuggestionVote struct {
+	UserID    int
+	UserName  string
+	Reserve   bool // Place a hold for the patron once the book is in the catalog
+	CreatedAt time.Time
+}
+
+// Open reports whether the suggestion is still waiting for the book to arrive
+func (s *Suggestion) Open() bool {
+	return s.Status == SuggestionStatusNew || s.Status == SuggestionStatusAccepted || s.Status == SuggestionStatusOrdered
+}
+
+// suggestionColumns lists the columns scanned by scanSuggestion; $1 is the user
+// whose votes are flagged
+const suggestionColumns = `s.id, s.title, s.author, s.isbn, s.publisher, s.publication_year, s.notes, s.status,
+                s.librarian_note, s.book_id, s.created_by, s.reviewed_by, s.reviewed_at, s.created_at, s.updated_at,
+                (SELECT COUNT(*) FROM suggestion_votes v WHERE v.suggestion_id = s.id) AS votes,
+                EXISTS (SELECT 1 FROM suggestion_votes v WHERE v.suggestion_id = s.id AND v.user_id = $1)`
+
+// scanSuggestion reads a row selected with suggestionColumns
+func scanSuggestion(row interface{ Scan(...interface{}) error }) (*Suggestion, error) {
+	s := &Suggestion{}
+	err := row.Scan(&s.ID, &s.Title, &s.Author, &s.ISBN, &s.Publisher, &s.PublicationYear, &s.Notes, &s.Status,
+		&s.LibrarianNote, &s.BookID, &s.CreatedBy, &s.ReviewedBy, &s.ReviewedAt, &s.CreatedAt, &s.UpdatedAt,
+		&s.Votes, &s.Voted)
+	return s, err
+}
+
+// GetSuggestions retrieves suggestions, most wanted first, optionally only those with
+// a status. With mine set, only suggestions the user voted for are returned.
+func GetSuggestions(status string, userID int, mine bool) ([]*Suggestion, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT `+suggestionColumns+`
+                FROM suggestions s
+                WHERE ($2 = '' OR s.status = $2)
+                        AND (NOT $3 OR s.id IN (SELECT suggestion_id FROM suggestion_votes WHERE user_id = $1))
+                ORDER BY votes DESC, s.created_at
+        `, userID, status, mine)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var suggestions []*Suggestion
+	for rows.Next() {
+		s, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// GetSuggestionByID retrieves a suggestion with the patrons backing it
+func GetSuggestionByID(id, userID int) (*Suggestion, error) {
+	db := config.GetDB()
+
+	s, err := scanSuggestion(db.QueryRow("SELECT "+suggestionColumns+" FROM suggestions s WHERE s.id = $2", userID, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("suggestion not found")
+		}
+		return nil, err
+	}
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT v.user_id, u.name, v.reserve, v.created_at
+                FROM suggestion_votes v
+                JOIN users u ON u.id = v.user_id
+                WHERE v.suggestion_id = $1
+                ORDER BY v.created_at
+        `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	for rows.Next() {
+		v := &SuggestionVote{}
+		if err := rows.Scan(&v.UserID, &v.UserName, &v.Reserve, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.Voters = append(s.Voters, v)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SuggestBook records a patron's suggestion. If the same title is already suggested
+// and still open, the patron's vote is added to it instead and merged is true; s.ID
+// is set to the suggestion voted for either way.
+func SuggestBook(s *Suggestion, userID int, reserve bool) (merged bool, err error) {
+	s.Title = strings.TrimSpace(s.Title)
+	s.Author = strings.TrimSpace(s.Author)
+	s.Notes = strings.TrimSpace(s.Notes)
+	if s.Title == "" {
+		return false, errors.New("a title is required")
+	}
+
+	if s.ISBN != "" {
+		exists, err := IsbnExists(s.ISBN)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return false, ErrSuggestionInCatalog
+		}
+	}
+
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Look for an open suggestion of the same book
+	err = tx.QueryRow(`
+                SELECT id FROM suggestions
+                WHERE status IN ($1, $2, $3)
+                        AND (($4 <> '' AND isbn = $4) OR (LOWER(title) = LOWER($5) AND LOWER(author) = LOWER($6)))
+                ORDER BY id
+                LIMIT 1
+        `, SuggestionStatusNew, SuggestionStatusAccepted, SuggestionStatusOrdered, s.ISBN, s.Title, s.Author).Scan(&s.ID)
+	switch {
+	case err == nil:
+		merged = true
+	case err == sql.ErrNoRows:
+		err = tx.QueryRow(`
+                        INSERT INTO suggestions (title, author, isbn, publisher, publication_year, notes, created_by)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7)
+                        RETURNING id, status, created_at, updated_at
+                `, s.Title, s.Author, s.ISBN, s.Publisher, s.PublicationYear, s.Notes, userID,
+		).Scan(&s.ID, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return false, err
+		}
+	default:
+		return false, err
+	}
+
+	if err := addSuggestionVote(tx, s.ID, userID, reserve); err != nil {
+		return false, err
+	}
+
+	// Commit transaction
+	return merged, tx.Commit()
+}
+
+// addSuggestionVote records a vote; voting again only ever turns the hold request on
+func addSuggestionVote(tx *sql.Tx, id, userID int, reserve bool) error {
+	_, err := tx.Exec(`
+                INSERT INTO suggestion_votes (suggestion_id, user_id, reserve)
+                VALUES ($1, $2, $3)
+                ON CONFLICT (suggestion_id, user_id) DO UPDATE
+                SET reserve = suggestion_votes.reserve OR EXCLUDED.reserve
+        `, id, userID, reserve)
+	return err
+}
+
+// ReviewSuggestion sets a suggestion's status and the note shown to the patrons
+// who made it
+func ReviewSuggestion(id int, status, note string, reviewerID int) error {
+	valid := false
+	for _, s := range SuggestionStatuses {
+		valid = valid || s == status
+	}
+	if !valid {
+		return errors.New("invalid suggestion status")
+	}
+
+	db := config.GetDB()
+
+	result, err := db.Exec(`
+                UPDATE suggestions
+                SET status = $1, librarian_note = $2, reviewed_by = $3, reviewed_at = CURRENT_TIMESTAMP,
+                        updated_at = CURRENT_TIMESTAMP
+                WHERE id = $4 AND status <> $5
+        `, status, strings.TrimSpace(note), reviewerID, id, SuggestionStatusAdded)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("suggestion not found or already added to the catalog")
+	}
+
+	return nil
+}
+
+// MergeSuggestions folds a duplicate suggestion into another: the duplicate's votes
+// move across and the duplicate is deleted
+func MergeSuggestions(duplicateID, intoID int) error {
+	if duplicateID == intoID {
+		return errors.New("a suggestion cannot be merged into itself")
+	}
+
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var notes string
+	err = tx.QueryRow("SELECT notes FROM suggestions WHERE id = $1 FOR UPDATE", duplicateID).Scan(&notes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("suggestion not found")
+		}
+		return err
+	}
+	var status string
+	err = tx.QueryRow("SELECT status FROM suggestions WHERE id = $1 FOR UPDATE", intoID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("the suggestion to merge into was not found")
+		}
+		return err
+	}
+	if status == SuggestionStatusRejected {
+		return errors.New("cannot merge into a rejected suggestion")
+	}
+
+	// Move the votes
+	_, err = tx.Exec(`
+                INSERT INTO suggestion_votes (suggestion_id, user_id, reserve, seen_at, created_at)
+                SELECT $2, user_id, reserve, NULL, created_at FROM suggestion_votes WHERE suggestion_id = $1
+                ON CONFLICT (suggestion_id, user_id) DO UPDATE
+                SET reserve = suggestion_votes.reserve OR EXCLUDED.reserve
+        `, duplicateID, intoID)
+	if err != nil {
+		return err
+	}
+
+	// Keep what the duplicate's patrons wrote
+	if notes != "" {
+		_, err = tx.Exec(`
+                        UPDATE suggestions
+                        SET notes = CASE WHEN notes = '' THEN $1 ELSE notes || E'\n' || $1 END, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = $2
+                `, notes, intoID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM suggestions WHERE id = $1", duplicateID)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// LinkSuggestion records that the book a suggestion asks for is in the catalog. A
+// record with no copies yet means the book is on order. Patrons who asked for a hold
+// get one.
+func LinkSuggestion(id, bookID int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var quantity int
+	err = tx.QueryRow("SELECT quantity FROM books WHERE id = $1", bookID).Scan(&quantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("book not found")
+		}
+		return err
+	}
+	status := SuggestionStatusAdded
+	if quantity == 0 {
+		status = SuggestionStatusOrdered
+	}
+
+	result, err := tx.Exec(`
+                UPDATE suggestions
+                SET book_id = $1, status = $2, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $3 AND status <> $4
+        `, bookID, status, id, SuggestionStatusRejected)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("suggestion not found or rejected")
+	}
+
+	// Collect the patrons who want a hold
+	rows, err := tx.Query("SELECT user_id FROM suggestion_votes WHERE suggestion_id = $1 AND reserve", id)
+	if err != nil {
+		return err
+	}
+	var holds []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return err
+		}
+		holds = append(holds, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Place the holds; a patron who already has the book or a hold on it, or
+	// who can borrow it straight away, simply gets the notification
+	for _, userID := range holds {
+		_ = ReserveBook(userID, bookID, false)
+	}
+
+	return nil
+}
+
+// MatchSuggestions links a newly catalogued book to the open suggestions asking for
+// it, by ISBN or by title and author
+func MatchSuggestions(book *Book) error {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id FROM suggestions
+                WHERE book_id IS NULL AND status IN ($1, $2, $3)
+                        AND ((isbn <> '' AND isbn = $4) OR (LOWER(title) = LOWER($5) AND LOWER(author) = LOWER($6)))
+        `, SuggestionStatusNew, SuggestionStatusAccepted, SuggestionStatusOrdered, book.ISBN, book.Title, book.Author)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := LinkSuggestion(id, book.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// markSuggestionsAddedTx marks suggestions for a book on order as added once its
+// copies arrive
+func markSuggestionsAddedTx(tx *sql.Tx, bookID int) error {
+	_, err := tx.Exec(`
+                UPDATE suggestions
+                SET status = $1, updated_at = CURRENT_TIMESTAMP
+                WHERE book_id = $2 AND status = $3
+        `, SuggestionStatusAdded, bookID, SuggestionStatusOrdered)
+	return err
+}
+
+// GetUnseenAddedSuggestions retrieves the user's suggestions that have reached the
+// shelf since the user last looked at their suggestions
+func GetUnseenAddedSuggestions(userID int) ([]*Suggestion, error) {
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT `+suggestionColumns+`
+                FROM suggestions s
+                JOIN suggestion_votes sv ON sv.suggestion_id = s.id AND sv.user_id = $1
+                WHERE s.status = $2 AND sv.seen_at IS NULL
+                ORDER BY s.updated_at DESC
+        `, userID, SuggestionStatusAdded)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var suggestions []*Suggestion
+	for rows.Next() {
+		s, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// MarkSuggestionsSeen clears the user's notifications about suggestions added to
+// the catalog
+func MarkSuggestionsSeen(userID int) error {
+	db := config.GetDB()
+
+	_, err := db.Exec(`
+                UPDATE suggestion_votes
+                SET seen_at = CURRENT_TIMESTAMP
+                WHERE user_id = $1 AND seen_at IS NULL
+                        AND suggestion_id IN (SELECT id FROM suggestions WHERE status = $2)
+        `, userID, SuggestionStatusAdded)
+	return err
+}
@@ -3,6 +3,7 @@ package models
 import (
         "database/sql"
         "errors"
+        "strings"
         "time"
 
         "golang.org/x/crypto/bcrypt"
@@ -25,6 +26,8 @@ type User struct {
         Role         string
         StudentID    sql.NullString
         Phone        sql.NullString
+        KeepHistory  bool
+        ExpiresAt    NullTime
         CreatedAt    time.Time
         UpdatedAt    time.Time
         
@@ -40,7 +43,7 @@ func GetUserByID(id int) (*User, error) {
         
         // Execute query
         err := db.QueryRow(`
-                SELECT id, name, email, password_hash, role, student_id, phone, created_at, updated_at
+                SELECT id, name, email, password_hash, role, student_id, phone, keep_history, expires_at, created_at, updated_at
                 FROM users
                 WHERE id = $1
         `, id).Scan(
@@ -51,6 +54,8 @@ func GetUserByID(id int) (*User, error) {
                 &user.Role,
                 &user.StudentID,
                 &user.Phone,
+                &user.KeepHistory,
+                &user.ExpiresAt,
                 &user.CreatedAt,
                 &user.UpdatedAt,
         )
@@ -75,7 +80,7 @@ func GetUserByEmail(email string) (*User, error) {
         
         // Execute query
         err := db.QueryRow(`
-                SELECT id, name, email, password_hash, role, student_id, phone, created_at, updated_at
+                SELECT id, name, email, password_hash, role, student_id, phone, keep_history, expires_at, created_at, updated_at
                 FROM users
                 WHERE email = $1
         `, email).Scan(
@@ -86,6 +91,8 @@ func GetUserByEmail(email string) (*User, error) {
                 &user.Role,
                 &user.StudentID,
                 &user.Phone,
+                &user.KeepHistory,
+                &user.ExpiresAt,
                 &user.CreatedAt,
                 &user.UpdatedAt,
         )
@@ -103,6 +110,28 @@ func GetUserByEmail(email string) (*User, error) {
         return user, nil
 }
 
+// GetUserByCardNumber retrieves a patron by library card number (student ID) or email
+func GetUserByCardNumber(card string) (*User, error) {
+        db := config.GetDB()
+        
+        // Execute query
+        var id int
+        err := db.QueryRow(`
+                SELECT id FROM users
+                WHERE student_id = $1 OR LOWER(email) = LOWER($1)
+                ORDER BY id
+                LIMIT 1
+        `, strings.TrimSpace(card)).Scan(&id)
+        if err != nil {
+                if err == sql.ErrNoRows {
+                        return nil, errors.New("no patron found for this card")
+                }
+                return nil, err
+        }
+        
+        return GetUserByID(id)
+}
+
 // Create saves a new user to the database
 func (u *User) Create() error {
         db := config.GetDB()
@@ -125,10 +154,10 @@ func (u *User) Create() error {
         
         // Execute query
         err = db.QueryRow(`
-                INSERT INTO users (name, email, password_hash, role, student_id, phone)
-                VALUES ($1, $2, $3, $4, $5, $6)
+                INSERT INTO users (name, email, password_hash, role, student_id, phone, expires_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
                 RETURNING id, created_at, updated_at
-        `, u.Name, u.Email, string(hashedPassword), u.Role, u.StudentID, u.Phone).Scan(
+        `, u.Name, u.Email, string(hashedPassword), u.Role, u.StudentID, u.Phone, u.ExpiresAt).Scan(
                 &u.ID,
                 &u.CreatedAt,
                 &u.UpdatedAt,
@@ -161,9 +190,10 @@ func (u *User) Update() error {
         // Execute query
         _, err = db.Exec(`
                 UPDATE users
-                SET name = $1, email = $2, role = $3, student_id = $4, phone = $5, updated_at = CURRENT_TIMESTAMP
-                WHERE id = $6
-        `, u.Name, u.Email, u.Role, u.StudentID, u.Phone, u.ID)
+                SET name = $1, email = $2, role = $3, student_id = $4, phone = $5, expires_at = $6,
+                        updated_at = CURRENT_TIMESTAMP
+                WHERE id = $7
+        `, u.Name, u.Email, u.Role, u.StudentID, u.Phone, u.ExpiresAt, u.ID)
         if err != nil {
                 return err
         }
@@ -195,6 +225,30 @@ func (u *User) UpdatePassword(newPassword string) error {
         return err
 }
 
+// SetKeepHistory records whether the user opted in to keeping their borrow history
+func (u *User) SetKeepHistory(keep bool) error {
+        db := config.GetDB()
+        
+        // Execute query
+        _, err := db.Exec(`
+                UPDATE users
+                SET keep_history = $1, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2
+        `, keep, u.ID)
+        if err != nil {
+                return err
+        }
+        
+        u.KeepHistory = keep
+        
+        return nil
+}
+
+// IsExpired reports whether the user's membership has passed its expiry date
+func (u *User) IsExpired() bool {
+        return u.ExpiresAt.Valid && time.Now().After(u.ExpiresAt.Time)
+}
+
 // Delete removes a user from the database
 func (u *User) Delete() error {
         db := config.GetDB()
@@ -236,7 +290,7 @@ func GetAllUsers() ([]*User, error) {
         
         // Execute query
         rows, err := db.Query(`
-                SELECT id, name, email, password_hash, role, student_id, phone, created_at, updated_at
+                SELECT id, name, email, password_hash, role, student_id, phone, keep_history, expires_at, created_at, updated_at
                 FROM users
                 ORDER BY id
         `)
@@ -257,6 +311,8 @@ func GetAllUsers() ([]*User, error) {
                         &user.Role,
                         &user.StudentID,
                         &user.Phone,
+                        &user.KeepHistory,
+                        &user.ExpiresAt,
                         &user.CreatedAt,
                         &user.UpdatedAt,
                 )
@@ -285,7 +341,7 @@ func GetAllStudents() ([]*User, error) {
         
         // Execute query
         rows, err := db.Query(`
-                SELECT id, name, email, password_hash, role, student_id, phone, created_at, updated_at
+                SELECT id, name, email, password_hash, role, student_id, phone, keep_history, expires_at, created_at, updated_at
                 FROM users
                 WHERE role = 'student'
                 ORDER BY id
@@ -307,6 +363,8 @@ func GetAllStudents() ([]*User, error) {
                         &user.Role,
                         &user.StudentID,
                         &user.Phone,
+                        &user.KeepHistory,
+                        &user.ExpiresAt,
                         &user.CreatedAt,
                         &user.UpdatedAt,
                 )
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"library-management-system/config"
+)
+
+// GetWorkEditions retrieves the other editions of a book's work, newest first
+func GetWorkEditions(book *Book) ([]*Book, error) {
+	if !book.WorkID.Valid {
+		return nil, nil
+	}
+
+	db := config.GetDB()
+
+	// Execute query
+	rows, err := db.Query(`
+                SELECT id FROM books
+                WHERE work_id = $1 AND id <> $2
+                ORDER BY publication_year DESC, id
+        `, book.WorkID.Int64, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Parse rows
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	// Check for errors
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var editions []*Book
+	for _, id := range ids {
+		edition, err := GetBookByID(id)
+		if err != nil {
+			return nil, err
+		}
+		editions = append(editions, edition)
+	}
+
+	return editions, nil
+}
+
+// AttachEdition records two books as editions of the same work. If either already
+// belongs to a work the other joins it; if both do, the works are combined.
+func AttachEdition(bookID, otherID int) error {
+	if bookID == otherID {
+		return errors.New("a book cannot be an edition of itself")
+	}
+
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var title string
+	var workID, otherWorkID sql.NullInt64
+	err = tx.QueryRow("SELECT title, work_id FROM books WHERE id = $1 FOR UPDATE", bookID).Scan(&title, &workID)
+	if err == nil {
+		err = tx.QueryRow("SELECT work_id FROM books WHERE id = $1 FOR UPDATE", otherID).Scan(&otherWorkID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("book not found")
+		}
+		return err
+	}
+
+	switch {
+	case workID.Valid && otherWorkID.Valid:
+		if workID.Int64 == otherWorkID.Int64 {
+			return errors.New("these books are already editions of the same work")
+		}
+		if _, err := tx.Exec("UPDATE books SET work_id = $1 WHERE work_id = $2", workID.Int64, otherWorkID.Int64); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM works WHERE id = $1", otherWorkID.Int64); err != nil {
+			return err
+		}
+	case otherWorkID.Valid:
+		workID = otherWorkID
+	case !workID.Valid:
+		if err := tx.QueryRow("INSERT INTO works (title) VALUES ($1) RETURNING id", title).Scan(&workID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+                UPDATE books SET work_id = $1, updated_at = CURRENT_TIMESTAMP
+                WHERE id IN ($2, $3)
+        `, workID.Int64, bookID, otherID)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Any-edition holds on either book may now be fulfilled by the other
+	go ProcessReservationsForBook(bookID)
+	go ProcessReservationsForBook(otherID)
+
+	return nil
+}
+
+// DetachEdition removes a book from its work. A work left with a single edition is
+// dissolved. Any-edition holds on the book then apply to it alone.
+func DetachEdition(bookID int) error {
+	db := config.GetDB()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var workID sql.NullInt64
+	err = tx.QueryRow("SELECT work_id FROM books WHERE id = $1 FOR UPDATE", bookID).Scan(&workID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("book not found")
+		}
+		return err
+	}
+	if !workID.Valid {
+		return errors.New("this book is not grouped with other editions")
+	}
+
+	if _, err := tx.Exec("UPDATE books SET work_id = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1", bookID); err != nil {
+		return err
+	}
+
+	var remaining int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM books WHERE work_id = $1", workID.Int64).Scan(&remaining); err != nil {
+		return err
+	}
+	if remaining <= 1 {
+		if _, err := tx.Exec("UPDATE books SET work_id = NULL WHERE work_id = $1", workID.Int64); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM works WHERE id = $1", workID.Int64); err != nil {
+			return err
+		}
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
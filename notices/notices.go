@@ -0,0 +1,173 @@
+// Package notices sends the escalating notices about loans: courtesy reminders before
+// they are due and overdue notices after, by email or as printed letters. The
+// final stage blocks the patron from borrowing until the loan is returned.
+package notices
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+	"library-management-system/mail"
+	"library-management-system/models"
+)
+
+// SendDue generates the day's notices once the configured hour has passed. It runs
+// on every scheduler tick but generates notices once a day.
+func SendDue() error {
+	now := time.Now()
+	hour := config.AppConfig.Notices.RunHour
+	if hour < 0 || hour > 23 {
+		hour = 7
+	}
+	if now.Hour() < hour {
+		return nil
+	}
+
+	run, err := models.ClaimNoticeRun(now, false)
+	if err != nil || run == nil {
+		return err
+	}
+	return Generate(run, now)
+}
+
+// RunNow generates the notices due now, outside the daily run
+func RunNow() (*models.NoticeRun, error) {
+	now := time.Now()
+	run, err := models.ClaimNoticeRun(now, true)
+	if err != nil {
+		return nil, err
+	}
+	return run, Generate(run, now)
+}
+
+// Generate sends every notice due at the given time and records the run. A notice
+// that cannot be emailed is printed as a letter instead, so every patron is told.
+func Generate(run *models.NoticeRun, now time.Time) error {
+	err := generate(run, now)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if finishErr := models.FinishNoticeRun(run); finishErr != nil {
+		log.Printf("Error recording notice run %d: %v", run.ID, finishErr)
+	}
+	if run.Emailed+run.Letters+run.Blocked > 0 {
+		log.Printf("Overdue notices: %d emailed, %d letters to print, %d patrons blocked", run.Emailed, run.Letters, run.Blocked)
+	}
+	return err
+}
+
+// generate lifts the blocks of returned loans, then sends each loan the stage it is due
+func generate(run *models.NoticeRun, now time.Time) error {
+	lifted, err := models.LiftSettledLoanBlocks()
+	if err != nil {
+		return err
+	}
+	if lifted > 0 {
+		log.Printf("Lifted %d borrowing blocks for overdue loans since returned", lifted)
+	}
+
+	due, err := models.GetDueNotices(now)
+	if err != nil {
+		return err
+	}
+	for _, d := range due {
+		if err := send(run, d, now); err != nil {
+			log.Printf("Error sending %q for loan %d: %v", d.Stage.Name, d.BorrowID, err)
+		}
+	}
+	return nil
+}
+
+// send sends a loan the notice for its stage, blocking the patron at a stage that
+// blocks
+func send(run *models.NoticeRun, d *models.DueNotice, now time.Time) error {
+	borrow, err := models.GetBorrowByID(d.BorrowID)
+	if err != nil {
+		return err
+	}
+	if borrow.User == nil {
+		return fmt.Errorf("patron %d not found", borrow.UserID)
+	}
+
+	subject, body, err := d.Stage.Render(models.NewNoticeData(borrow, now))
+	if err != nil {
+		return err
+	}
+
+	notice := &models.OverdueNotice{
+		BorrowID:  borrow.ID,
+		StageID:   sql.NullInt64{Int64: int64(d.Stage.ID), Valid: true},
+		StageName: d.Stage.Name,
+		StageDays: d.Stage.Days,
+		UserID:    sql.NullInt64{Int64: int64(borrow.UserID), Valid: true},
+		Channel:   models.NoticeChannelLetter,
+		Subject:   subject,
+		Body:      body,
+		Status:    models.NoticeStatusPrint,
+	}
+	if d.Stage.Channel == models.NoticeChannelEmail {
+		notice.Recipient = emailAddress(borrow.User)
+	}
+
+	// Claim the notice first, so it is not sent twice
+	claimed, err := models.ClaimOverdueNotice(notice)
+	if err != nil || !claimed {
+		return err
+	}
+
+	if d.Stage.Channel == models.NoticeChannelEmail {
+		switch {
+		case notice.Recipient == "":
+			notice.Error = "The patron has no email address"
+		case !mail.Configured():
+			notice.Error = "Email is not configured"
+		default:
+			err := mail.Send(mail.Message{To: []string{notice.Recipient}, Subject: subject, Body: body})
+			if err != nil {
+				notice.Error = "Email failed: " + err.Error()
+			} else {
+				notice.Channel, notice.Status = models.NoticeChannelEmail, models.NoticeStatusSent
+			}
+		}
+	}
+	if notice.Status == models.NoticeStatusSent {
+		run.Emailed++
+	} else {
+		run.Letters++
+	}
+
+	if d.Stage.BlockPatron {
+		blocked, err := models.BlockPatronForLoan(borrow.UserID, borrow.ID,
+			fmt.Sprintf("%s: %q is %d days overdue. Lifted when it is returned.", d.Stage.Name, bookTitle(borrow), int(now.Sub(*borrow.DueDate).Hours()/24)))
+		if err != nil {
+			log.Printf("Error blocking patron %d for loan %d: %v", borrow.UserID, borrow.ID, err)
+		} else if blocked {
+			notice.Blocked = true
+			run.Blocked++
+		}
+	}
+
+	return models.UpdateOverdueNotice(notice)
+}
+
+// emailAddress returns the patron's email address, or "" if it cannot be used
+func emailAddress(u *models.User) string {
+	address, err := netmail.ParseAddress(strings.TrimSpace(u.Email))
+	if err != nil {
+		return ""
+	}
+	return address.Address
+}
+
+// bookTitle names the book a loan is for
+func bookTitle(b *models.Borrow) string {
+	if b.Book == nil {
+		return "the book"
+	}
+	return b.Book.Title
+}
@@ -0,0 +1,130 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"library-management-system/config"
+	"library-management-system/export"
+	"library-management-system/mail"
+	"library-management-system/models"
+)
+
+// DeliverDue sends every scheduled report that is due. A report that fails is
+// recorded and alerted rather than returned, so it does not hold up the others.
+func DeliverDue() error {
+	due, err := models.ClaimDueScheduledReports(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, s := range due {
+		Deliver(s, false)
+	}
+	return nil
+}
+
+// Deliver emails a scheduled report covering the period before now and records the
+// run. If it fails, the librarian who created the report is alerted.
+func Deliver(s *models.ScheduledReport, manual bool) error {
+	run := &models.ReportRun{
+		ScheduledReportID: s.ID,
+		Manual:            manual,
+		StartedAt:         time.Now(),
+	}
+	run.PeriodFrom, run.PeriodTo = s.Period(run.StartedAt)
+
+	err := send(s, run)
+	run.Succeeded = err == nil
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if recordErr := models.RecordReportRun(run); recordErr != nil {
+		log.Printf("Error recording run of scheduled report %d: %v", s.ID, recordErr)
+	}
+
+	if err != nil {
+		log.Printf("Scheduled report %q failed: %v", s.Name, err)
+		alertFailure(s, run)
+	}
+	return err
+}
+
+// send builds the report for the run's period and emails it to the recipients,
+// attached or as a link to download it
+func send(s *models.ScheduledReport, run *models.ReportRun) error {
+	query, err := url.ParseQuery(s.Params)
+	if err != nil {
+		return fmt.Errorf("invalid report filters: %v", err)
+	}
+	query.Set("from", run.PeriodFrom.Format(DateFormat))
+	query.Set("to", run.PeriodTo.Format(DateFormat))
+	title := Title(s.ReportType, query)
+
+	link := strings.TrimRight(config.AppConfig.Mail.BaseURL, "/") + Path(s.ReportType) + "?" + query.Encode()
+	query.Set("export", s.Format)
+	download := strings.TrimRight(config.AppConfig.Mail.BaseURL, "/") + Path(s.ReportType) + "?" + query.Encode()
+
+	msg := mail.Message{
+		To:      s.RecipientList(),
+		Subject: s.Name + ": " + title,
+	}
+
+	if s.Delivery == models.DeliveryAttachment {
+		var file bytes.Buffer
+		out, err := export.New(&file, s.Format, title)
+		if err != nil {
+			return err
+		}
+		err = Write(out, s.ReportType, query)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("error generating report: %v", err)
+		}
+
+		name := fmt.Sprintf("%s-%s-to-%s.%s", BaseName(s.ReportType), run.PeriodFrom.Format(DateFormat),
+			run.PeriodTo.Format(DateFormat), s.Format)
+		msg.Attachments = []mail.Attachment{{Name: name, ContentType: export.ContentType(s.Format), Data: file.Bytes()}}
+		msg.Body = fmt.Sprintf("%s is attached.\n\nView it in the library system:\n%s\n", title, link)
+	} else {
+		msg.Body = fmt.Sprintf("%s is ready.\n\nDownload it (%s):\n%s\n\nView it in the library system:\n%s\n\n"+
+			"You need to sign in as a librarian to open these links.\n",
+			title, strings.ToUpper(s.Format), download, link)
+	}
+	msg.Body += fmt.Sprintf("\nThis is the %s report %q, set up by %s.\n", s.Frequency, s.Name, creator(s))
+
+	return mail.Send(msg)
+}
+
+// alertFailure emails the report's creator that a delivery failed. The failure is
+// also shown to librarians on the dashboard, which is the only alert when email
+// itself is failing.
+func alertFailure(s *models.ScheduledReport, run *models.ReportRun) {
+	if s.CreatorEmail == "" || !mail.Configured() {
+		return
+	}
+
+	page := strings.TrimRight(config.AppConfig.Mail.BaseURL, "/") + fmt.Sprintf("/reports/schedules/%d", s.ID)
+	err := mail.Send(mail.Message{
+		To:      []string{s.CreatorEmail},
+		Subject: "Scheduled report failed: " + s.Name,
+		Body: fmt.Sprintf("The scheduled report %q could not be sent to its recipients.\n\nError: %s\n\n"+
+			"Review it and send it again from:\n%s\n", s.Name, run.Error, page),
+	})
+	if err != nil {
+		log.Printf("Error alerting %s that scheduled report %d failed: %v", s.CreatorEmail, s.ID, err)
+	}
+}
+
+// creator names the librarian who set up a scheduled report
+func creator(s *models.ScheduledReport) string {
+	if s.CreatorName == "" {
+		return "a librarian"
+	}
+	return s.CreatorName
+}
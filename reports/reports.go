@@ -0,0 +1,368 @@
+// Package reports builds the circulation reports for download and scheduled
+// delivery. Every report reads its filters from a query string, so the page on
+// screen, its download and a saved report definition always agree.
+package reports
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"library-management-system/export"
+	"library-management-system/models"
+)
+
+// DateFormat is the format of the report period's date fields
+const DateFormat = "2006-01-02"
+
+// maxReportYears bounds the period a report covers, ending at its To date
+const maxReportYears = 25
+
+// maxReportPoints bounds the buckets a report's chart has; a chosen interval that
+// would give more is replaced by a coarser one
+const maxReportPoints = 400
+
+// PatronTypes lists the user roles a report can be limited to
+var PatronTypes = []string{"student", "librarian"}
+
+// Name returns a report type's title
+func Name(reportType string) string {
+	switch reportType {
+	case models.ReportTypeBorrow:
+		return "Borrow Report"
+	case models.ReportTypeBook:
+		return "Book Report"
+	case models.ReportTypeHistory:
+		return "Borrow History"
+	}
+	return "Report"
+}
+
+// Path returns the page showing a report type
+func Path(reportType string) string {
+	switch reportType {
+	case models.ReportTypeBorrow:
+		return "/borrow-report"
+	case models.ReportTypeBook:
+		return "/book-report"
+	case models.ReportTypeHistory:
+		return "/borrow-history"
+	}
+	return "/"
+}
+
+// BaseName returns the file name downloads of a report type start with
+func BaseName(reportType string) string {
+	return strings.ToLower(strings.ReplaceAll(Name(reportType), " ", "-"))
+}
+
+// ParseFilter reads a report's period, interval, category and patron type from the
+// query string, along with the period to compare it with. The period defaults to the
+// last twelve months and is at most 25 years long, and the interval defaults to one
+// that gives a readable number of points for its length.
+func ParseFilter(query url.Values) (models.ReportFilter, string) {
+	today := time.Now().Truncate(24 * time.Hour)
+
+	f := models.ReportFilter{
+		From:       today.AddDate(-1, 0, 1),
+		To:         today,
+		Interval:   query.Get("interval"),
+		Category:   query.Get("category"),
+		PatronType: query.Get("patron_type"),
+	}
+	if from, err := time.Parse(DateFormat, query.Get("from")); err == nil {
+		f.From = from
+	}
+	if to, err := time.Parse(DateFormat, query.Get("to")); err == nil {
+		f.To = to
+	}
+	if f.To.Before(f.From) {
+		f.From, f.To = f.To, f.From
+	}
+	if earliest := f.To.AddDate(-maxReportYears, 0, 1); f.From.Before(earliest) {
+		f.From = earliest
+	}
+
+	switch days := f.Days(); f.Interval {
+	case models.ReportIntervalDay, models.ReportIntervalWeek, models.ReportIntervalMonth:
+		if f.Interval == models.ReportIntervalDay && days > maxReportPoints {
+			f.Interval = models.ReportIntervalWeek
+		}
+		if f.Interval == models.ReportIntervalWeek && days/7 > maxReportPoints {
+			f.Interval = models.ReportIntervalMonth
+		}
+	default:
+		switch {
+		case days <= 31:
+			f.Interval = models.ReportIntervalDay
+		case days <= 183:
+			f.Interval = models.ReportIntervalWeek
+		default:
+			f.Interval = models.ReportIntervalMonth
+		}
+	}
+
+	valid := false
+	for _, t := range PatronTypes {
+		valid = valid || f.PatronType == t
+	}
+	if !valid {
+		f.PatronType = ""
+	}
+
+	compare := query.Get("compare")
+	if compare != models.ReportComparePrevious && compare != models.ReportCompareYear {
+		compare = ""
+	}
+	return f, compare
+}
+
+// ParseHistoryFilter reads the borrow history's filters from the query string
+func ParseHistoryFilter(query url.Values) models.BorrowHistoryFilter {
+	f := models.BorrowHistoryFilter{
+		Search: query.Get("search"),
+		Status: query.Get("status"),
+	}
+	if from, err := time.Parse(DateFormat, query.Get("from")); err == nil {
+		f.From = &from
+	}
+	if to, err := time.Parse(DateFormat, query.Get("to")); err == nil {
+		f.To = &to
+	}
+	return f
+}
+
+// CompareName describes the period a report is compared with
+func CompareName(compare string) string {
+	if compare == models.ReportCompareYear {
+		return "A year earlier"
+	}
+	return "Previous period"
+}
+
+// Title describes a report and its filters, for export headings and email subjects
+func Title(reportType string, query url.Values) string {
+	if reportType == models.ReportTypeHistory {
+		title := Name(reportType)
+		f := ParseHistoryFilter(query)
+		if f.From != nil && f.To != nil {
+			title += ", " + f.From.Format(DateFormat) + " to " + f.To.Format(DateFormat)
+		}
+		if f.Status != "" {
+			title += ", " + strings.ReplaceAll(f.Status, "_", " ")
+		}
+		if f.Search != "" {
+			title += `, matching "` + f.Search + `"`
+		}
+		return title
+	}
+
+	f, _ := ParseFilter(query)
+	title := Name(reportType) + ", " + f.From.Format(DateFormat) + " to " + f.To.Format(DateFormat)
+	if f.Category != "" {
+		title += ", " + f.Category
+	}
+	if f.PatronType != "" {
+		title += ", " + f.PatronType + "s"
+	}
+	return title
+}
+
+// Write adds a report's tables to an export. The caller closes the writer.
+func Write(out export.Writer, reportType string, query url.Values) error {
+	switch reportType {
+	case models.ReportTypeBorrow:
+		return writeBorrowReport(out, query)
+	case models.ReportTypeBook:
+		return writeBookReport(out, query)
+	case models.ReportTypeHistory:
+		return writeBorrowHistory(out, query)
+	}
+	return errors.New("unknown report type")
+}
+
+// borrowColumns heads tables of borrow records
+var borrowColumns = []string{"Book", "Patron", "Student ID", "Status", "Requested", "Borrowed", "Due", "Returned", "Approved By"}
+
+// writeBorrow adds a borrow record to an export
+func writeBorrow(out export.Writer, b *models.Borrow) error {
+	var book, patron, studentID, approver string
+	if b.Book != nil {
+		book = b.Book.Title
+	}
+	if b.User != nil {
+		patron, studentID = b.User.Name, b.User.StudentID.String
+	}
+	if b.Approver != nil {
+		approver = b.Approver.Name
+	}
+	status := b.Status
+	if b.IsOverdue {
+		status = models.BorrowStatusOverdue
+	}
+	return out.Row(book, patron, studentID, strings.ReplaceAll(status, "_", " "), b.CreatedAt, b.BorrowDate, b.DueDate, b.ReturnDate, approver)
+}
+
+// writeCirculation adds a table of circulation figures over a period and its total
+func writeCirculation(out export.Writer, title string, f models.ReportFilter) error {
+	series, err := models.GetCirculationSeries(f)
+	if err != nil {
+		return err
+	}
+	totals, err := models.GetCirculationTotals(f)
+	if err != nil {
+		return err
+	}
+
+	if err := out.Table(title, []string{"Period", "Loans", "Returns", "Due", "Overdue", "Overdue Rate %", "Unique Borrowers"}); err != nil {
+		return err
+	}
+	for _, p := range append(series, totals) {
+		label := p.Label(f.Interval)
+		if p == totals {
+			label = "Total"
+		}
+		if err := out.Row(label, p.Loans, p.Returns, p.Due, p.Overdue, roundRate(p.OverdueRate()), p.UniqueBorrowers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteBorrows adds a table of borrow records to an export
+func WriteBorrows(out export.Writer, title string, borrows []*models.Borrow) error {
+	if err := out.Table(title, borrowColumns); err != nil {
+		return err
+	}
+	for _, b := range borrows {
+		if err := writeBorrow(out, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBorrowReport writes the borrow report's period figures, those of the period
+// it is compared with, and the overdue list
+func writeBorrowReport(out export.Writer, query url.Values) error {
+	f, compare := ParseFilter(query)
+	if err := writeCirculation(out, "Circulation", f); err != nil {
+		return err
+	}
+	if compare != "" {
+		previous := f.Shift(compare)
+		title := CompareName(compare) + ", " + previous.From.Format(DateFormat) + " to " + previous.To.Format(DateFormat)
+		if err := writeCirculation(out, title, previous); err != nil {
+			return err
+		}
+	}
+
+	overdue, err := models.GetOverdueBooks()
+	if err != nil {
+		return err
+	}
+
+	if err := out.Table("Overdue Books", borrowColumns); err != nil {
+		return err
+	}
+	for _, b := range overdue {
+		b.IsOverdue = true
+		if err := writeBorrow(out, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBookReport writes the book report's most borrowed books and loans by category
+func writeBookReport(out export.Writer, query url.Values) error {
+	f, _ := ParseFilter(query)
+	books, err := models.GetTopBooksInPeriod(f, 10)
+	if err != nil {
+		return err
+	}
+	categories, err := models.GetLoansByCategory(f)
+	if err != nil {
+		return err
+	}
+
+	if err := out.Table("Most Borrowed", []string{"Title", "Author", "Category", "Loans"}); err != nil {
+		return err
+	}
+	for _, b := range books {
+		if err := out.Row(b.Title, b.Author, b.Category, b.Loans); err != nil {
+			return err
+		}
+	}
+
+	if err := out.Table("Loans by Category", []string{"Category", "Loans"}); err != nil {
+		return err
+	}
+	for _, c := range categories {
+		category := c.Category
+		if category == "" {
+			category = "Uncategorized"
+		}
+		if err := out.Row(category, c.Loans); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBorrowHistory streams every borrow record matching the filters, not just the
+// page on screen
+func writeBorrowHistory(out export.Writer, query url.Values) error {
+	if err := out.Table("Borrow History", borrowColumns); err != nil {
+		return err
+	}
+	return models.EachBorrowHistory(ParseHistoryFilter(query), func(b *models.Borrow) error {
+		return writeBorrow(out, b)
+	})
+}
+
+// roundRate rounds a percentage to one decimal place
+func roundRate(rate float64) float64 {
+	return float64(int(rate*10+0.5)) / 10
+}
+
+// DescribeFilters lists a saved report's filters other than its period, for people
+// to read
+func DescribeFilters(reportType, params string) string {
+	query, _ := url.ParseQuery(params)
+
+	var filters []string
+	if reportType == models.ReportTypeHistory {
+		if status := query.Get("status"); status != "" {
+			filters = append(filters, "status "+strings.ReplaceAll(status, "_", " "))
+		}
+		if search := query.Get("search"); search != "" {
+			filters = append(filters, `matching "`+search+`"`)
+		}
+	} else {
+		f, compare := ParseFilter(query)
+		if f.Category != "" {
+			filters = append(filters, "category "+f.Category)
+		}
+		if f.PatronType != "" {
+			filters = append(filters, f.PatronType+"s only")
+		}
+		if reportType == models.ReportTypeBorrow {
+			if interval := query.Get("interval"); interval != "" {
+				filters = append(filters, "by "+interval)
+			}
+			switch compare {
+			case models.ReportComparePrevious:
+				filters = append(filters, "compared with the previous period")
+			case models.ReportCompareYear:
+				filters = append(filters, "compared with a year earlier")
+			}
+		}
+	}
+
+	if len(filters) == 0 {
+		return "No filters"
+	}
+	return strings.Join(filters, ", ")
+}
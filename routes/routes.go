@@ -2,6 +2,7 @@ package routes
 
 import (
         "net/http"
+        "strings"
 
         "library-management-system/controllers"
         "library-management-system/middleware"
@@ -64,6 +65,27 @@ func SetupRoutes() {
         http.Handle("/profile/", middleware.RequireAuth(profileHandler()))
         http.Handle("/profile/edit", middleware.RequireAuth(http.HandlerFunc(controllers.EditProfile)))
         http.Handle("/profile/password", middleware.RequireAuth(http.HandlerFunc(controllers.ChangePassword)))
+        http.Handle("/profile/export", middleware.RequireAuth(http.HandlerFunc(controllers.ExportMyData)))
+        http.Handle("/profile/privacy", middleware.RequireAuth(http.HandlerFunc(controllers.UpdatePrivacySettings)))
+        
+        // Author routes
+        http.Handle("/authors", middleware.LoadAuth(http.HandlerFunc(controllers.AuthorList)))
+        http.Handle("/authors/", authorHandler())
+        
+        // Series routes
+        http.Handle("/series", middleware.LoadAuth(http.HandlerFunc(controllers.SeriesList)))
+        http.Handle("/series/", seriesHandler())
+        
+        // Serial routes
+        http.Handle("/serials", middleware.LoadAuth(http.HandlerFunc(controllers.SerialList)))
+        http.Handle("/serials/claims", middleware.RequireLibrarian(http.HandlerFunc(controllers.SerialClaims)))
+        http.Handle("/serials/", serialHandler())
+        
+        // Subject and classification routes
+        http.Handle("/subjects", middleware.LoadAuth(http.HandlerFunc(controllers.SubjectList)))
+        http.Handle("/subjects/", middleware.RequireLibrarian(http.HandlerFunc(controllers.SubjectAction)))
+        http.Handle("/classification", middleware.LoadAuth(http.HandlerFunc(controllers.ClassificationBrowse)))
+        http.Handle("/classification/", middleware.RequireLibrarian(http.HandlerFunc(controllers.ClassAction)))
         
         // Book borrow/return routes
         http.Handle("/books/", bookHandler())
@@ -76,14 +98,73 @@ func SetupRoutes() {
         http.Handle("/users/add", middleware.RequireLibrarian(http.HandlerFunc(controllers.AddUser)))
         http.Handle("/users/edit/", middleware.RequireLibrarian(userEditHandler()))
         http.Handle("/users/delete/", middleware.RequireLibrarian(userDeleteHandler()))
+        http.Handle("/users/export/", middleware.RequireLibrarian(http.HandlerFunc(controllers.ExportUserData)))
+        http.Handle("/users/erase/", middleware.RequireLibrarian(http.HandlerFunc(controllers.EraseUser)))
+        http.Handle("/users/block/", middleware.RequireLibrarian(http.HandlerFunc(controllers.BlockUser)))
+        http.Handle("/users/unblock/", middleware.RequireLibrarian(http.HandlerFunc(controllers.UnblockUser)))
+        http.Handle("/charges/", middleware.RequireLibrarian(http.HandlerFunc(controllers.ResolveCharge)))
         
         // Borrow routes for librarians
         http.Handle("/borrows", middleware.RequireLibrarian(http.HandlerFunc(controllers.BorrowList)))
+        http.Handle("/borrows/claims", middleware.RequireLibrarian(http.HandlerFunc(controllers.ClaimsList)))
+        
+        // Circulation desk routes
+        http.Handle("/desk/checkout", middleware.RequireLibrarian(http.HandlerFunc(controllers.DeskCheckout)))
+        http.Handle("/desk/checkin", middleware.RequireLibrarian(http.HandlerFunc(controllers.DeskCheckin)))
+        
+        // Library calendar routes
+        http.Handle("/calendar", middleware.RequireLibrarian(http.HandlerFunc(controllers.LibraryCalendar)))
+        http.Handle("/calendar/exceptions", middleware.RequireLibrarian(http.HandlerFunc(controllers.CalendarException)))
+        http.Handle("/calendar/exceptions/", middleware.RequireLibrarian(http.HandlerFunc(controllers.CalendarException)))
+        
+        // Acquisitions routes
+        http.Handle("/acquisitions", middleware.RequireLibrarian(http.HandlerFunc(controllers.PurchaseOrderList)))
+        http.Handle("/acquisitions/orders/", orderHandler())
+        http.Handle("/acquisitions/vendors", middleware.RequireLibrarian(http.HandlerFunc(controllers.VendorList)))
+        http.Handle("/acquisitions/vendors/", middleware.RequireLibrarian(http.HandlerFunc(controllers.EditVendor)))
+        http.Handle("/acquisitions/funds", middleware.RequireLibrarian(http.HandlerFunc(controllers.FundList)))
+        http.Handle("/acquisitions/funds/", middleware.RequireLibrarian(http.HandlerFunc(controllers.EditFund)))
+        http.Handle("/acquisitions/report", middleware.RequireLibrarian(http.HandlerFunc(controllers.AcquisitionsReport)))
+        
+        // Purchase suggestion routes
+        http.Handle("/suggestions", middleware.RequireAuth(http.HandlerFunc(controllers.SuggestionList)))
+        http.Handle("/suggestions/", suggestionHandler())
+        
+        // Course reserve routes
+        http.Handle("/courses", middleware.RequireAuth(http.HandlerFunc(controllers.CourseList)))
+        http.Handle("/courses/terms", middleware.RequireLibrarian(http.HandlerFunc(controllers.TermList)))
+        http.Handle("/courses/", courseHandler())
+        
+        // Shelf and reading list routes
+        http.Handle("/shelves", middleware.RequireAuth(http.HandlerFunc(controllers.ShelfList)))
+        http.Handle("/shelves/", shelfHandler())
+        
+        // Review moderation and reporting routes
+        http.Handle("/reviews", middleware.RequireLibrarian(http.HandlerFunc(controllers.ReviewList)))
+        http.Handle("/reviews/", middleware.RequireAuth(http.HandlerFunc(controllers.ReviewAction)))
         
         // Report routes
         http.Handle("/borrow-report", middleware.RequireLibrarian(http.HandlerFunc(controllers.BorrowReport)))
         http.Handle("/book-report", middleware.RequireLibrarian(http.HandlerFunc(controllers.BookReport)))
         http.Handle("/borrow-history", middleware.RequireLibrarian(http.HandlerFunc(controllers.BorrowHistory)))
+        http.Handle("/reports/schedules", middleware.RequireLibrarian(http.HandlerFunc(controllers.ScheduleList)))
+        http.Handle("/reports/schedules/", scheduleHandler())
+        
+        // Stocktake routes
+        http.Handle("/stocktakes", middleware.RequireLibrarian(http.HandlerFunc(controllers.StocktakeList)))
+        http.Handle("/stocktakes/", stocktakeHandler())
+        
+        // Collection analysis and weeding routes
+        http.Handle("/collection", middleware.RequireLibrarian(http.HandlerFunc(controllers.CollectionReport)))
+        http.Handle("/collection/weeding", middleware.RequireLibrarian(http.HandlerFunc(controllers.WeedingList)))
+        http.Handle("/collection/weeding/", middleware.RequireLibrarian(http.HandlerFunc(controllers.WeedingAction)))
+        
+        // Overdue notice routes
+        http.Handle("/notices", middleware.RequireLibrarian(http.HandlerFunc(controllers.NoticeList)))
+        http.Handle("/notices/letters", middleware.RequireLibrarian(http.HandlerFunc(controllers.NoticeLetters)))
+        http.Handle("/notices/run", middleware.RequireLibrarian(http.HandlerFunc(controllers.RunNotices)))
+        http.Handle("/notices/stages", middleware.RequireLibrarian(http.HandlerFunc(controllers.NoticeStages)))
+        http.Handle("/notices/stages/", noticeStageHandler())
 }
 
 // Helper handler for book routes
@@ -91,6 +172,28 @@ func bookHandler() http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
                 path := r.URL.Path
                 
+                // Check if it's a book management request
+                if path == "/books/enrich" {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.EnrichBook)).ServeHTTP(w, r)
+                        return
+                }
+                if path == "/books/add" {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.AddBook)).ServeHTTP(w, r)
+                        return
+                }
+                if len(path) > 5 && path[len(path)-5:] == "/edit" {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.EditBook)).ServeHTTP(w, r)
+                        return
+                }
+                if len(path) > 7 && path[len(path)-7:] == "/delete" {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.DeleteBook)).ServeHTTP(w, r)
+                        return
+                }
+                if strings.HasSuffix(path, "/editions") || strings.HasSuffix(path, "/editions/unlink") {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.BookEditions)).ServeHTTP(w, r)
+                        return
+                }
+                
                 // Check if it's a borrow request
                 if len(path) > 7 && path[len(path)-7:] == "/borrow" {
                         middleware.RequireAuth(http.HandlerFunc(controllers.BorrowBook)).ServeHTTP(w, r)
@@ -103,6 +206,12 @@ func bookHandler() http.Handler {
                         return
                 }
                 
+                // Check if it's a review
+                if strings.HasSuffix(path, "/review") {
+                        middleware.RequireAuth(http.HandlerFunc(controllers.ReviewBook)).ServeHTTP(w, r)
+                        return
+                }
+                
                 // Regular book detail with auth context loaded
                 middleware.LoadAuth(http.HandlerFunc(controllers.BookDetail)).ServeHTTP(w, r)
         })
@@ -125,11 +234,97 @@ func borrowHandler() http.Handler {
                         return
                 }
                 
+                // Check if it's a lost/damaged/claimed/found request
+                if len(path) > 8 && path[len(path)-8:] == "/resolve" {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.ResolveLoan)).ServeHTTP(w, r)
+                        return
+                }
+                
                 // Fallback to 404
                 http.NotFound(w, r)
         })
 }
 
+// Helper handler for author routes
+func authorHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/authors/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's an edit request
+                if len(parts) == 2 && parts[1] == "edit" {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.EditAuthor)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Check if it's a variant, see-also or merge request
+                if len(parts) > 1 {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.AuthorAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Regular author page with auth context loaded
+                middleware.LoadAuth(http.HandlerFunc(controllers.AuthorDetail)).ServeHTTP(w, r)
+        })
+}
+
+// Helper handler for series routes
+func seriesHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/series/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's a request to borrow or reserve a whole set
+                if len(parts) == 2 && (parts[1] == "borrow" || parts[1] == "reserve") {
+                        middleware.RequireAuth(http.HandlerFunc(controllers.SeriesCirculation)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Check if it's an edit or volume request
+                if len(parts) > 1 {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.SeriesAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Regular series page with auth context loaded
+                middleware.LoadAuth(http.HandlerFunc(controllers.SeriesDetail)).ServeHTTP(w, r)
+        })
+}
+
+// Helper handler for serial routes
+func serialHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/serials/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's an edit or issue check-in request
+                if len(parts) > 1 {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.SerialAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Regular serial page with auth context loaded
+                middleware.LoadAuth(http.HandlerFunc(controllers.SerialDetail)).ServeHTTP(w, r)
+        })
+}
+
+// Helper handler for purchase order routes
+func orderHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/acquisitions/orders/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's a line, receiving or invoice request
+                if len(parts) > 1 {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.PurchaseOrderAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Regular order page
+                middleware.RequireLibrarian(http.HandlerFunc(controllers.PurchaseOrderDetail)).ServeHTTP(w, r)
+        })
+}
+
 // Helper handler for user edit routes
 func userEditHandler() http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -166,4 +361,118 @@ func reservationHandler() http.Handler {
                 // Fallback to 404
                 http.NotFound(w, r)
         })
-}
\ No newline at end of file
+}
+
+// Helper handler for purchase suggestion routes
+func suggestionHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/suggestions/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's a triage request
+                if len(parts) > 1 {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.SuggestionAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Suggestion page
+                middleware.RequireLibrarian(http.HandlerFunc(controllers.SuggestionDetail)).ServeHTTP(w, r)
+        })
+}
+
+// Helper handler for course reserve routes
+func courseHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/courses/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's a management request; instructors are checked in the controller
+                if len(parts) > 1 {
+                        middleware.RequireAuth(http.HandlerFunc(controllers.CourseAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Course page
+                middleware.RequireAuth(http.HandlerFunc(controllers.CourseDetail)).ServeHTTP(w, r)
+        })
+}
+
+// Helper handler for scheduled report routes
+func scheduleHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/reports/schedules/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's a management request
+                if len(parts) > 1 {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.ScheduleAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Scheduled report page
+                middleware.RequireLibrarian(http.HandlerFunc(controllers.ScheduleDetail)).ServeHTTP(w, r)
+        })
+}
+
+// Helper handler for shelf routes; public lists can be viewed and exported without logging in
+func shelfHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/shelves/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's an export request
+                if len(parts) == 2 && parts[1] == "export" {
+                        middleware.LoadAuth(http.HandlerFunc(controllers.ExportShelf)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Check if it's a shelf management request
+                if len(parts) > 1 {
+                        middleware.RequireAuth(http.HandlerFunc(controllers.ShelfAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Shelf page
+                middleware.LoadAuth(http.HandlerFunc(controllers.ShelfDetail)).ServeHTTP(w, r)
+        })
+}
+
+// Helper handler for notice stage routes
+func noticeStageHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/notices/stages/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's an edit or status change request
+                if len(parts) > 1 {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.NoticeStageAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Notice stage page
+                middleware.RequireLibrarian(http.HandlerFunc(controllers.NoticeStageDetail)).ServeHTTP(w, r)
+        })
+}
+
+// Helper handler for stocktake routes
+func stocktakeHandler() http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/stocktakes/"), "/")
+                parts := strings.Split(path, "/")
+                
+                // Check if it's a section's scanning screen
+                if len(parts) == 3 && parts[1] == "sections" && r.Method == http.MethodGet {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.StocktakeSection)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Check if it's a scanning or resolution request
+                if len(parts) > 1 {
+                        middleware.RequireLibrarian(http.HandlerFunc(controllers.StocktakeAction)).ServeHTTP(w, r)
+                        return
+                }
+                
+                // Stocktake page
+                middleware.RequireLibrarian(http.HandlerFunc(controllers.StocktakeDetail)).ServeHTTP(w, r)
+        })
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local keeps files in a directory and serves them under a URL prefix
+type Local struct {
+	Dir       string
+	URLPrefix string // e.g. /media/
+}
+
+// Put writes the file, replacing any existing one atomically
+func (l *Local) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
+
+// Delete removes the file
+func (l *Local) Delete(ctx context.Context, key string) error {
+	target, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// URL returns the file's address under the URL prefix
+func (l *Local) URL(key string) string {
+	return strings.TrimRight(l.URLPrefix, "/") + "/" + key
+}
+
+// Handler serves the stored files; mount it at URLPrefix. Directories are not listed,
+// so stored keys cannot be enumerated.
+func (l *Local) Handler() http.Handler {
+	files := http.FileServer(http.Dir(l.Dir))
+	return http.StripPrefix(strings.TrimRight(l.URLPrefix, "/")+"/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// path maps a key to a file inside the directory, refusing keys that escape it
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.New("invalid storage key")
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,70 @@
+// Package storage keeps uploaded files such as cover images. Files are written to
+// the local filesystem by default, or to an S3-compatible object store (AWS S3,
+// MinIO) when configured.
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"library-management-system/config"
+)
+
+// Store saves files under slash-separated keys and says where they can be read from
+type Store interface {
+	// Put saves data under the key, replacing any existing file
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes the file; deleting a missing file is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns the address browsers load the file from
+	URL(key string) string
+}
+
+var (
+	mu    sync.RWMutex
+	store Store
+)
+
+// SetDefault replaces the configured store, e.g. with a temporary directory in tests
+func SetDefault(s Store) {
+	mu.Lock()
+	defer mu.Unlock()
+	store = s
+}
+
+// Default returns the store named in the configuration, building it on first use
+func Default() Store {
+	mu.RLock()
+	s := store
+	mu.RUnlock()
+	if s != nil {
+		return s
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if store == nil {
+		store = fromConfig()
+	}
+	return store
+}
+
+// fromConfig builds the configured store, falling back to the local filesystem
+func fromConfig() Store {
+	cfg := config.AppConfig.Storage
+	if cfg.Backend == "s3" {
+		if cfg.S3Endpoint != "" && cfg.S3Bucket != "" {
+			return &S3{
+				Endpoint:  cfg.S3Endpoint,
+				Region:    cfg.S3Region,
+				Bucket:    cfg.S3Bucket,
+				AccessKey: cfg.S3AccessKey,
+				SecretKey: cfg.S3SecretKey,
+				PublicURL: cfg.S3PublicURL,
+			}
+		}
+		log.Println("STORAGE_BACKEND is s3 but S3_ENDPOINT or S3_BUCKET is not set; using local storage")
+	}
+	return &Local{Dir: cfg.LocalDir, URLPrefix: cfg.URLPrefix}
+}